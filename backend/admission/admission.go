@@ -0,0 +1,149 @@
+// Package admission limits how much work runs at once with a weighted
+// semaphore: each unit of work holds a number of slots for as long as it
+// runs, and work that does not fit waits in a bounded first-in, first-out
+// queue.
+package admission
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrQueueFull is returned by Acquire when the wait queue is full.
+var ErrQueueFull = errors.New("admission queue is full")
+
+// Stats is a snapshot of a controller's state.
+type Stats struct {
+	Capacity int64 // Slots in total
+	InUse    int64 // Slots held by running work
+	Queued   int   // Callers waiting for slots
+}
+
+// Controller is safe for concurrent use.
+type Controller struct {
+	mu       sync.Mutex
+	capacity int64
+	inUse    int64
+	maxQueue int
+	waiters  list.List // of *waiter, in arrival order
+
+	observe func(Stats)
+}
+
+type waiter struct {
+	weight int64
+	ready  chan struct{} // Closed once the slots are granted
+}
+
+// New returns a controller with capacity slots and room for maxQueue waiting
+// callers. observe, if not nil, is called with the new state on every change.
+func New(capacity int64, maxQueue int, observe func(Stats)) *Controller {
+	c := &Controller{capacity: max(capacity, 1), maxQueue: maxQueue, observe: observe}
+	c.notify()
+	return c
+}
+
+// Acquire takes weight slots, waiting for them if needed until ctx is done.
+// Weights above the capacity take the whole capacity. It returns ErrQueueFull
+// without waiting when the queue is full, and ctx.Err() if ctx is done first.
+// On success, the caller must call Release with the same weight.
+func (c *Controller) Acquire(ctx context.Context, weight int64) error {
+	weight = c.clamp(weight)
+
+	c.mu.Lock()
+	if c.tryAcquire(weight) {
+		c.mu.Unlock()
+		return nil
+	}
+	if c.waiters.Len() >= c.maxQueue {
+		c.mu.Unlock()
+		return ErrQueueFull
+	}
+	w := &waiter{weight: weight, ready: make(chan struct{})}
+	el := c.waiters.PushBack(w)
+	c.notify()
+	c.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		select {
+		case <-w.ready:
+			// Granted in the meantime; hand the slots on
+			c.inUse -= weight
+		default:
+			c.waiters.Remove(el)
+		}
+		c.grant()
+		c.notify()
+		c.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// TryAcquire takes weight slots if they are free without waiting, and
+// reports whether it did. On success, the caller must call Release with the
+// same weight.
+func (c *Controller) TryAcquire(weight int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tryAcquire(c.clamp(weight))
+}
+
+func (c *Controller) tryAcquire(weight int64) bool {
+	// Work only starts ahead of the queue when nothing is waiting, so heavy
+	// work is not starved by a stream of light work
+	if c.waiters.Len() > 0 || c.inUse+weight > c.capacity {
+		return false
+	}
+	c.inUse += weight
+	c.notify()
+	return true
+}
+
+// Release returns weight slots taken by Acquire or TryAcquire.
+func (c *Controller) Release(weight int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inUse -= c.clamp(weight)
+	c.grant()
+	c.notify()
+}
+
+// Stats returns the current state.
+func (c *Controller) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats()
+}
+
+func (c *Controller) clamp(weight int64) int64 {
+	return min(max(weight, 1), c.capacity)
+}
+
+// grant hands slots to waiters in order for as long as the first one fits.
+func (c *Controller) grant() {
+	for el := c.waiters.Front(); el != nil; el = c.waiters.Front() {
+		w := el.Value.(*waiter)
+		if c.inUse+w.weight > c.capacity {
+			return
+		}
+		c.inUse += w.weight
+		c.waiters.Remove(el)
+		close(w.ready)
+	}
+}
+
+func (c *Controller) stats() Stats {
+	return Stats{Capacity: c.capacity, InUse: c.inUse, Queued: c.waiters.Len()}
+}
+
+func (c *Controller) notify() {
+	if c.observe != nil {
+		c.observe(c.stats())
+	}
+}
@@ -0,0 +1,147 @@
+package admission
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitFor polls until cond holds, failing the test after a second.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+// queue starts a caller acquiring weight slots and waits until it is queued.
+// The returned channel receives the result of Acquire.
+func queue(t *testing.T, c *Controller, ctx context.Context, weight int64) <-chan error {
+	t.Helper()
+	queued := c.Stats().Queued
+	done := make(chan error, 1)
+	go func() { done <- c.Acquire(ctx, weight) }()
+	waitFor(t, "the caller to queue", func() bool { return c.Stats().Queued == queued+1 })
+	return done
+}
+
+func TestFIFO(t *testing.T) {
+	c := New(1, 10, nil)
+	if !c.TryAcquire(1) {
+		t.Fatal("TryAcquire on an idle controller failed")
+	}
+
+	order := make(chan int, 5)
+	for i := range 5 {
+		done := queue(t, c, context.Background(), 1)
+		go func() {
+			if err := <-done; err != nil {
+				t.Errorf("Acquire: %v", err)
+				return
+			}
+			order <- i
+			c.Release(1)
+		}()
+	}
+
+	c.Release(1)
+	for want := range 5 {
+		if got := <-order; got != want {
+			t.Fatalf("caller %d admitted in position %d", got, want)
+		}
+	}
+	waitFor(t, "the slots to be released", func() bool { return c.Stats() == Stats{Capacity: 1} })
+}
+
+func TestHeavyWorkNotStarved(t *testing.T) {
+	c := New(2, 10, nil)
+	c.TryAcquire(1)
+	heavy := queue(t, c, context.Background(), 2)
+
+	// A slot is free, but light work does not jump the queue
+	if c.TryAcquire(1) {
+		t.Fatal("light work started ahead of queued heavy work")
+	}
+	light := queue(t, c, context.Background(), 1)
+
+	c.Release(1)
+	if err := <-heavy; err != nil {
+		t.Fatalf("heavy Acquire: %v", err)
+	}
+	select {
+	case <-light:
+		t.Fatal("light work admitted while heavy work holds the capacity")
+	case <-time.After(10 * time.Millisecond):
+	}
+	c.Release(2)
+	if err := <-light; err != nil {
+		t.Fatalf("light Acquire: %v", err)
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	c := New(1, 2, nil)
+	c.TryAcquire(1)
+	queue(t, c, context.Background(), 1)
+	queue(t, c, context.Background(), 1)
+
+	start := time.Now()
+	if err := c.Acquire(context.Background(), 1); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Acquire with a full queue = %v, want ErrQueueFull", err)
+	}
+	if waited := time.Since(start); waited > 100*time.Millisecond {
+		t.Errorf("Acquire waited %v before reporting a full queue", waited)
+	}
+	if s := c.Stats(); s.Queued != 2 || s.InUse != 1 {
+		t.Errorf("stats %+v after the rejection", s)
+	}
+}
+
+func TestCancelReleasesQueue(t *testing.T) {
+	var observed []Stats
+	c := New(2, 10, func(s Stats) { observed = append(observed, s) })
+	c.TryAcquire(1)
+
+	// Heavy work waits for both slots, and light work behind it for its turn
+	ctx, cancel := context.WithCancel(context.Background())
+	heavy := queue(t, c, ctx, 2)
+	light := queue(t, c, context.Background(), 1)
+
+	cancel()
+	if err := <-heavy; !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled Acquire = %v, want context.Canceled", err)
+	}
+	// With the heavy work gone, the light work fits in the free slot
+	if err := <-light; err != nil {
+		t.Fatalf("light Acquire: %v", err)
+	}
+	if s := c.Stats(); s.InUse != 2 || s.Queued != 0 {
+		t.Errorf("stats %+v, want both slots in use and nothing queued", s)
+	}
+
+	c.Release(1)
+	c.Release(1)
+	if s := c.Stats(); s.InUse != 0 {
+		t.Errorf("%d slots still in use after every release", s.InUse)
+	}
+	if last := observed[len(observed)-1]; last != c.Stats() {
+		t.Errorf("last observed %+v, want %+v", last, c.Stats())
+	}
+}
+
+func TestWeightClamped(t *testing.T) {
+	c := New(4, 0, nil)
+	if !c.TryAcquire(100) {
+		t.Fatal("work heavier than the capacity was not admitted on an idle controller")
+	}
+	if s := c.Stats(); s.InUse != 4 {
+		t.Errorf("%d slots in use, want the whole capacity", s.InUse)
+	}
+	c.Release(100)
+	if s := c.Stats(); s.InUse != 0 {
+		t.Errorf("%d slots in use after the release", s.InUse)
+	}
+}
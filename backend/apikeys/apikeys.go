@@ -0,0 +1,83 @@
+// Package apikeys issues and checks the API keys that identify clients. Only
+// a hash of each key is stored; the key itself is shown once, when issued.
+// Each key also comes with the secret that webhook notifications to its
+// client are signed with, which is stored as is since signing needs it.
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned for key IDs that are not stored.
+var ErrNotFound = errors.New("apikeys: key not found")
+
+// Prefixes start every key and webhook secret, so that they are
+// recognizable, e.g., by secret scanners.
+const (
+	prefix        = "ik_"
+	webhookPrefix = "whsec_"
+)
+
+// Key is an issued API key. Rate, Burst and DailyQuota override the limits
+// of the default key policy when set.
+type Key struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Hash       string     `json:"hash"` // SHA-256 of the key, hex-encoded
+	Created    time.Time  `json:"created"`
+	Revoked    *time.Time `json:"revoked,omitempty"`
+	Rate       float64    `json:"rate,omitempty"`        // Cost units refilled per second
+	Burst      int64      `json:"burst,omitempty"`       // Cost units that may be spent at once
+	DailyQuota int64      `json:"daily_quota,omitempty"` // Cost units per UTC day
+
+	WebhookSecret string `json:"webhook_secret,omitempty"` // Key for signing webhook notifications; empty for keys issued before webhooks
+}
+
+// Active reports whether the key has not been revoked.
+func (k *Key) Active() bool {
+	return k.Revoked == nil
+}
+
+// Store keeps API keys. Implementations are safe for concurrent use.
+type Store interface {
+	// Lookup returns the key whose hash is hash, whether revoked or not.
+	Lookup(hash string) (*Key, bool)
+	// Add stores a newly issued key.
+	Add(key Key) error
+	// Revoke marks the key with the given ID as revoked.
+	Revoke(id string, at time.Time) error
+	// List returns every stored key.
+	List() []Key
+}
+
+// New issues a key named name. It returns the key record to store and the
+// key itself, which is not kept anywhere.
+func New(name string) (Key, string) {
+	id := make([]byte, 6)
+	rand.Read(id)
+	secret := make([]byte, 24)
+	rand.Read(secret)
+	webhookSecret := make([]byte, 24)
+	rand.Read(webhookSecret)
+
+	key := prefix + hex.EncodeToString(secret)
+	return Key{
+		ID:      hex.EncodeToString(id),
+		Name:    name,
+		Hash:    Hash(key),
+		Created: time.Now().UTC(),
+
+		WebhookSecret: webhookPrefix + hex.EncodeToString(webhookSecret),
+	}, key
+}
+
+// Hash returns the hash under which key is stored.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(key)))
+	return hex.EncodeToString(sum[:])
+}
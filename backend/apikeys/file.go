@@ -0,0 +1,108 @@
+package apikeys
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"image-editor-app/backend/utils"
+)
+
+// FileStore keeps keys in a JSON file, which is rewritten on every change.
+type FileStore struct {
+	path string
+
+	mu     sync.RWMutex
+	keys   []Key
+	byHash map[string]int // Index into keys
+}
+
+// OpenFile returns a store backed by the file at path, which is created on
+// the first change if it does not exist.
+func OpenFile(path string) (*FileStore, error) {
+	s := &FileStore{path: path, byHash: map[string]int{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.keys); err != nil {
+		return nil, fmt.Errorf("apikeys: reading %s: %w", path, err)
+	}
+	for i, key := range s.keys {
+		s.byHash[key.Hash] = i
+	}
+	return s, nil
+}
+
+func (s *FileStore) Lookup(hash string) (*Key, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i, ok := s.byHash[hash]
+	if !ok {
+		return nil, false
+	}
+	key := s.keys[i]
+	return &key, true
+}
+
+func (s *FileStore) Add(key Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := append(slices.Clip(s.keys), key)
+	if err := s.save(keys); err != nil {
+		return err
+	}
+	s.keys = keys
+	s.byHash[key.Hash] = len(keys) - 1
+	return nil
+}
+
+func (s *FileStore) Revoke(id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.keys, func(k Key) bool { return k.ID == id })
+	if i < 0 {
+		return ErrNotFound
+	}
+	if !s.keys[i].Active() {
+		return nil
+	}
+
+	keys := slices.Clone(s.keys)
+	at = at.UTC()
+	keys[i].Revoked = &at
+	if err := s.save(keys); err != nil {
+		return err
+	}
+	s.keys = keys
+	return nil
+}
+
+func (s *FileStore) List() []Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.keys)
+}
+
+// save writes keys to a temporary file that replaces the store's file, so
+// that a crash never leaves it half written.
+func (s *FileStore) save(keys []Key) error {
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(s.path, append(data, '\n'), 0o600)
+}
@@ -0,0 +1,265 @@
+// Package cache stores encoded images by content-addressed key, in a
+// size-bounded in-memory LRU backed by an optional on-disk tier.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"image-editor-app/backend/utils"
+)
+
+// Cache is safe for concurrent use.
+type Cache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	order    *list.List // Front is the most recently used
+	items    map[string]*list.Element
+	inflight map[string]*call
+
+	disk *diskTier // nil without an on-disk tier
+}
+
+type entry struct {
+	key  string
+	data []byte
+}
+
+// call is a computation in progress that concurrent requests for the same key wait on.
+type call struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// New returns a cache holding up to memBytes in memory. With dir set, entries
+// are also written to dir, which is kept under diskBytes.
+func New(memBytes int64, dir string, diskBytes int64) (*Cache, error) {
+	c := &Cache{
+		maxBytes: memBytes,
+		order:    list.New(),
+		items:    map[string]*list.Element{},
+		inflight: map[string]*call{},
+	}
+	if dir != "" {
+		disk, err := openDiskTier(dir, diskBytes)
+		if err != nil {
+			return nil, err
+		}
+		c.disk = disk
+	}
+	return c, nil
+}
+
+// Get returns the data stored under key, looking in memory, then on disk.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	if el, ok := c.items[key]; ok {
+		c.order.MoveToFront(el)
+		c.mu.Unlock()
+		return el.Value.(*entry).data, true
+	}
+	c.mu.Unlock()
+
+	if c.disk == nil {
+		return nil, false
+	}
+	data, ok := c.disk.get(key)
+	if ok {
+		c.addMemory(key, data)
+	}
+	return data, ok
+}
+
+// Add stores data under key in both tiers.
+func (c *Cache) Add(key string, data []byte) {
+	c.addMemory(key, data)
+	if c.disk != nil {
+		c.disk.add(key, data)
+	}
+}
+
+// Do returns the data stored under key, or computes and stores it with fn.
+// Concurrent calls for the same key share a single call of fn; a caller
+// waiting on another's call gives up with ctx.Err() once ctx is done. It
+// reports whether the data came from the cache.
+func (c *Cache) Do(ctx context.Context, key string, fn func() ([]byte, error)) ([]byte, bool, error) {
+	if data, ok := c.Get(key); ok {
+		return data, true, nil
+	}
+
+	c.mu.Lock()
+	if cl, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-cl.done:
+			return cl.data, cl.err == nil, cl.err
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+	cl := &call{done: make(chan struct{})}
+	c.inflight[key] = cl
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.inflight, key)
+		c.mu.Unlock()
+		close(cl.done)
+	}()
+
+	cl.data, cl.err = fn()
+	if cl.err == nil {
+		c.Add(key, cl.data)
+	}
+	return cl.data, false, cl.err
+}
+
+func (c *Cache) addMemory(key string, data []byte) {
+	size := int64(len(data))
+	if size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&entry{key: key, data: data})
+	c.size += size
+
+	for c.size > c.maxBytes {
+		oldest := c.order.Back()
+		e := oldest.Value.(*entry)
+		c.order.Remove(oldest)
+		delete(c.items, e.key)
+		c.size -= int64(len(e.data))
+	}
+}
+
+// diskTier keeps one file per entry under dir, sharded by the first two
+// characters of the key. Least recently used files are removed once the
+// directory grows beyond maxBytes; reads refresh a file's modification time.
+type diskTier struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64
+	size     int64
+}
+
+func openDiskTier(dir string, maxBytes int64) (*diskTier, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	d := &diskTier{dir: dir, maxBytes: maxBytes}
+	files, err := d.files()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		d.size += f.size
+	}
+	return d, nil
+}
+
+func (d *diskTier) path(key string) string {
+	if len(key) < 3 {
+		return filepath.Join(d.dir, key)
+	}
+	return filepath.Join(d.dir, key[:2], key)
+}
+
+func (d *diskTier) get(key string) ([]byte, bool) {
+	path := d.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("reading cache entry", "key", key, "error", err)
+		}
+		return nil, false
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return data, true
+}
+
+func (d *diskTier) add(key string, data []byte) {
+	path := d.path(key)
+	if _, err := os.Stat(path); err == nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		slog.Warn("writing cache entry", "key", key, "error", err)
+		return
+	}
+	if err := utils.WriteFileAtomic(path, data, 0o600); err != nil {
+		slog.Warn("writing cache entry", "key", key, "error", err)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.size += int64(len(data))
+	if d.size > d.maxBytes {
+		d.evict()
+	}
+}
+
+type diskFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func (d *diskTier) files() ([]diskFile, error) {
+	var files []diskFile
+	err := filepath.WalkDir(d.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil // Removed concurrently
+		}
+		files = append(files, diskFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	return files, err
+}
+
+// evict removes the least recently used files until the tier is at 90% of
+// its budget, so that eviction does not run on every write. d.mu is held.
+func (d *diskTier) evict() {
+	files, err := d.files()
+	if err != nil {
+		slog.Warn("scanning cache directory", "error", err)
+		return
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	d.size = 0
+	for _, f := range files {
+		d.size += f.size
+	}
+	target := d.maxBytes / 10 * 9
+	for _, f := range files {
+		if d.size <= target {
+			break
+		}
+		if err := os.Remove(f.path); err == nil || errors.Is(err, fs.ErrNotExist) {
+			d.size -= f.size
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryEviction(t *testing.T) {
+	c, err := New(10, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add("a", []byte("aaaa"))
+	c.Add("b", []byte("bbbb"))
+	// Reading a makes b the least recently used
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a missing before the cache is full")
+	}
+	c.Add("c", []byte("cccc"))
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("b kept beyond the byte budget")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("%s evicted", key)
+		}
+	}
+	if c.size != 8 {
+		t.Errorf("size %d, want 8", c.size)
+	}
+
+	// An entry larger than the whole budget is not cached and evicts nothing
+	c.Add("big", make([]byte, 11))
+	if _, ok := c.Get("big"); ok {
+		t.Error("entry larger than the budget cached")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("a evicted by an entry that was not cached")
+	}
+}
+
+func TestDiskTier(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(100, dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.Repeat([]byte("x"), 40)
+	c.Add("aa01", data)
+	if _, err := os.Stat(filepath.Join(dir, "aa", "aa01")); err != nil {
+		t.Fatalf("entry not written to disk: %v", err)
+	}
+
+	// A new cache on the same directory reloads the entry and its size
+	c, err = New(100, dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.disk.size != 40 {
+		t.Errorf("reloaded disk size %d, want 40", c.disk.size)
+	}
+	got, ok := c.Get("aa01")
+	if !ok || !bytes.Equal(got, data) {
+		t.Fatal("entry not read back from disk")
+	}
+	if _, ok := c.items["aa01"]; !ok {
+		t.Error("entry read from disk not kept in memory")
+	}
+
+	// Going over the budget removes the least recently used files
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(dir, "aa", "aa01"), old, old)
+	c.Add("bb02", data)
+	c.Add("cc03", data)
+	if _, err := os.Stat(filepath.Join(dir, "aa", "aa01")); !os.IsNotExist(err) {
+		t.Errorf("oldest file kept over the budget: %v", err)
+	}
+	for _, key := range []string{"bb02", "cc03"} {
+		if _, ok := c.disk.get(key); !ok {
+			t.Errorf("%s evicted from disk", key)
+		}
+	}
+	if c.disk.size != 80 {
+		t.Errorf("disk size %d after eviction, want 80", c.disk.size)
+	}
+}
+
+func TestDo(t *testing.T) {
+	c, err := New(100, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Concurrent calls share the leader's call
+	var calls atomic.Int32
+	started, finish := make(chan struct{}), make(chan struct{})
+	fn := func() ([]byte, error) {
+		calls.Add(1)
+		close(started)
+		<-finish
+		return []byte("result"), nil
+	}
+	var wg sync.WaitGroup
+	results := make([][]byte, 3)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _, _ = c.Do(context.Background(), "key", fn)
+		}()
+		if i == 0 {
+			<-started
+		}
+	}
+	// Wait for the followers to join the call in progress
+	time.Sleep(10 * time.Millisecond)
+	close(finish)
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Errorf("fn called %d times, want 1", n)
+	}
+	for i, r := range results {
+		if string(r) != "result" {
+			t.Errorf("caller %d got %q", i, r)
+		}
+	}
+
+	// Later calls are served from the cache
+	data, hit, err := c.Do(context.Background(), "key", func() ([]byte, error) {
+		t.Error("fn called for a cached key")
+		return nil, nil
+	})
+	if err != nil || !hit || string(data) != "result" {
+		t.Errorf("Do = %q, %v, %v; want a cached result", data, hit, err)
+	}
+
+	// Failures are not cached
+	failure := errors.New("failed")
+	if _, _, err := c.Do(context.Background(), "fails", func() ([]byte, error) { return nil, failure }); err != failure {
+		t.Errorf("Do = %v, want the error of fn", err)
+	}
+	if _, ok := c.Get("fails"); ok {
+		t.Error("failed call cached")
+	}
+}
+
+func TestDoWaiterCanceled(t *testing.T) {
+	c, err := New(100, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	started, finish := make(chan struct{}), make(chan struct{})
+	go c.Do(context.Background(), "key", func() ([]byte, error) {
+		close(started)
+		<-finish
+		return []byte("result"), nil
+	})
+	<-started
+	defer close(finish)
+
+	// A caller waiting on another's call gives up with its own context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, _, err := c.Do(ctx, "key", func() ([]byte, error) {
+		t.Error("fn called while another call is in progress")
+		return nil, nil
+	}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Do = %v, want context.DeadlineExceeded", err)
+	}
+}
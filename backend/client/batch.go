@@ -0,0 +1,147 @@
+package client
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	"image-editor-app/backend/models"
+)
+
+// BatchFile is an input of a batch: an image, or a ZIP archive of images,
+// under the name its result is reported with.
+type BatchFile struct {
+	Name  string
+	Image Image
+}
+
+// BatchManifest describes the outcome for every input of a batch.
+type BatchManifest = models.BatchManifest
+
+// BatchResult is the archive of the results of a batch.
+type BatchResult struct {
+	Archive   []byte // ZIP archive of the results, with manifest.json
+	Manifest  BatchManifest
+	RequestID string
+}
+
+// File returns the content of a result in the archive, by the Output name
+// the manifest gives it.
+func (r *BatchResult) File(name string) ([]byte, error) {
+	archive, err := zip.NewReader(bytes.NewReader(r.Archive), int64(len(r.Archive)))
+	if err != nil {
+		return nil, err
+	}
+	f, err := archive.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// Batch applies one operation to many images. options holds the operation's
+// parameters and the output settings, e.g., ResizeOptions for "resize".
+// Inputs that fail are reported in the manifest without failing the batch;
+// every image is charged to the rate limit.
+func (c *Client) Batch(ctx context.Context, operation string, options any, files ...BatchFile) (*BatchResult, error) {
+	var optionsJSON []byte
+	if options != nil {
+		var err error
+		if optionsJSON, err = json.Marshal(options); err != nil {
+			return nil, err
+		}
+	}
+	replay := true
+	for _, f := range files {
+		replay = replay && f.Image.replayable
+	}
+
+	var result *BatchResult
+	err := c.retry(ctx, replay, func(requestID string) (time.Duration, error) {
+		body, contentType, err := newBatchBody(operation, optionsJSON, files)
+		if err != nil {
+			return 0, err
+		}
+		return c.send(ctx, "/batch", requestID, contentType, body, func(resp *http.Response) (err error) {
+			result, err = decodeBatchResult(resp)
+			return err
+		})
+	})
+	return result, err
+}
+
+// newBatchBody returns the multipart form of a batch, which streams the
+// files as it is read.
+func newBatchBody(operation string, options []byte, files []BatchFile) (io.ReadCloser, string, error) {
+	sources := make([]io.ReadCloser, 0, len(files))
+	closeAll := func() {
+		for _, src := range sources {
+			src.Close()
+		}
+	}
+	for _, f := range files {
+		if f.Image.open == nil {
+			closeAll()
+			return nil, "", fmt.Errorf("%s: no image given", f.Name)
+		}
+		src, err := f.Image.open()
+		if err != nil {
+			closeAll()
+			return nil, "", fmt.Errorf("%s: %w", f.Name, err)
+		}
+		sources = append(sources, src)
+	}
+
+	pr, pw := io.Pipe()
+	form := multipart.NewWriter(pw)
+	go func() {
+		defer closeAll()
+		pw.CloseWithError(writeBatchBody(form, operation, options, files, sources))
+	}()
+	return pr, form.FormDataContentType(), nil
+}
+
+func writeBatchBody(form *multipart.Writer, operation string, options []byte, files []BatchFile, sources []io.ReadCloser) error {
+	if err := form.WriteField("operation", operation); err != nil {
+		return err
+	}
+	if options != nil {
+		if err := form.WriteField("options", string(options)); err != nil {
+			return err
+		}
+	}
+	for i, f := range files {
+		part, err := form.CreateFormFile("files", f.Name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(part, sources[i]); err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+	}
+	return form.Close()
+}
+
+// decodeBatchResult reads the result archive of a batch and its manifest.
+func decodeBatchResult(resp *http.Response) (*BatchResult, error) {
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &networkError{err: err}
+	}
+	result := &BatchResult{Archive: data, RequestID: resp.Header.Get("X-Request-ID")}
+	manifest, err := result.File("manifest.json")
+	if err != nil {
+		return nil, fmt.Errorf("reading batch manifest: %w", err)
+	}
+	if err := json.Unmarshal(manifest, &result.Manifest); err != nil {
+		return nil, fmt.Errorf("decoding batch manifest: %w", err)
+	}
+	return result, nil
+}
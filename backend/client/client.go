@@ -0,0 +1,295 @@
+// Package client is a Go client for the image editor API.
+//
+//	c := client.New("https://api.imagenerd.in")
+//	res, err := c.Resize(ctx, client.ImageFromFile("photo.jpg"), client.ResizeOptions{Width: 800})
+//	if err != nil {
+//		return err
+//	}
+//	return res.WriteFile("photo-small.jpg")
+//
+// Requests go to the /v1 endpoints. Image inputs are base64-encoded while the
+// request body is streamed, so large files are never held in memory twice.
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	mathrand "math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"image-editor-app/backend/models"
+)
+
+// Client calls the image editor API. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	userAgent  string
+	apiKey     string
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests. Deadlines are best
+// set through the context passed to each call.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithMaxRetries sets how many times a request is retried after a 429, a 5xx
+// response or a network error. Zero disables retries.
+func WithMaxRetries(n int) Option {
+	return func(c *Client) {
+		c.maxRetries = n
+	}
+}
+
+// WithBackoff sets the delay before the first retry and the cap on the delay,
+// which doubles with every attempt.
+func WithBackoff(min, max time.Duration) Option {
+	return func(c *Client) {
+		c.minBackoff = min
+		c.maxBackoff = max
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(ua string) Option {
+	return func(c *Client) {
+		c.userAgent = ua
+	}
+}
+
+// WithAPIKey authenticates every request with an API key, which gets the
+// rate limits and quota of the key instead of those of anonymous clients.
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// New returns a client for the API at baseURL, e.g., "http://localhost:8080".
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		userAgent:  "image-editor-go-client",
+		maxRetries: 3,
+		minBackoff: 500 * time.Millisecond,
+		maxBackoff: 10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Result is a processed image returned by the API. Results requested with
+// Delivery "url" have no Data but a URL to download it from until ExpiresAt.
+// Requests with a CallbackURL run in the background: their result only
+// holds the pending Job, whose result is posted to the callback URL.
+type Result struct {
+	Data         []byte
+	URL          string
+	ExpiresAt    time.Time
+	MIMEType     string
+	Format       string // e.g., "jpeg", "png"
+	Width        int
+	Height       int
+	ProcessingMS int64  // Time the server spent processing the request
+	RequestID    string // ID to quote when reporting a problem
+	Job          *Job   // Background job, for requests with a CallbackURL
+}
+
+// Job is a request running in the background.
+type Job = models.Job
+
+// WriteFile writes the image to the named file.
+func (r *Result) WriteFile(name string) error {
+	return os.WriteFile(name, r.Data, 0o644)
+}
+
+// post sends a JSON body built from images and params to a /v1 endpoint,
+// retrying failed attempts, and decodes the response envelope.
+func (c *Client) post(ctx context.Context, path string, images []imageField, params any) (*Result, error) {
+	var result *Result
+	err := c.retry(ctx, replayable(images), func(requestID string) (time.Duration, error) {
+		body, err := newRequestBody(images, params)
+		if err != nil {
+			return 0, err
+		}
+		return c.send(ctx, path, requestID, "application/json", body, func(resp *http.Response) (err error) {
+			result, err = decodeResult(resp)
+			return err
+		})
+	})
+	return result, err
+}
+
+// retry makes attempts at a request until one succeeds, fails for good or
+// the retries are spent. Inputs that cannot be replayed are not retried
+// once sent.
+func (c *Client) retry(ctx context.Context, replayable bool, attempt func(requestID string) (time.Duration, error)) error {
+	// One request ID for all attempts ties the retries together in server logs
+	requestID := newRequestID()
+
+	for n := 0; ; n++ {
+		retryAfter, err := attempt(requestID)
+		if err == nil || !retryable(err) || n >= c.maxRetries {
+			return err
+		}
+		if !replayable {
+			// A plain io.Reader input was consumed by the failed attempt
+			return err
+		}
+
+		delay := c.backoff(n, retryAfter)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// send performs a single attempt and hands a successful response, 200 or
+// 202, to decode. It reports the server's Retry-After delay, if any.
+func (c *Client) send(ctx context.Context, path, requestID, contentType string, body io.ReadCloser, decode func(*http.Response) error) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1"+path, body)
+	if err != nil {
+		body.Close()
+		return 0, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if contentType == "application/json" {
+		req.Header.Set("Accept", "application/json")
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	req.Header.Set("X-Request-ID", requestID)
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		return 0, &networkError{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		return retryAfter(resp.Header), newResponseError(resp)
+	}
+	return 0, decode(resp)
+}
+
+// decodeResult reads the image envelope of a response, or the job of a
+// request accepted to run in the background.
+func decodeResult(resp *http.Response) (*Result, error) {
+	if resp.StatusCode == http.StatusAccepted {
+		var job Job
+		if err := json.NewDecoder(resp.Body).Decode(&job); err != nil {
+			return nil, fmt.Errorf("decoding job: %w", err)
+		}
+		return &Result{Job: &job, RequestID: resp.Header.Get("X-Request-ID")}, nil
+	}
+
+	var envelope models.ImageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(envelope.ImageBase64)
+	if err != nil {
+		return nil, fmt.Errorf("decoding response image: %w", err)
+	}
+
+	result := &Result{
+		Data:         data,
+		URL:          envelope.URL,
+		MIMEType:     envelope.MIMEType,
+		Format:       envelope.Format,
+		Width:        envelope.Width,
+		Height:       envelope.Height,
+		ProcessingMS: envelope.ProcessingMS,
+		RequestID:    resp.Header.Get("X-Request-ID"),
+	}
+	if envelope.ExpiresAt != nil {
+		result.ExpiresAt = *envelope.ExpiresAt
+	}
+	return result, nil
+}
+
+// backoff returns the delay before the next attempt: exponential with full
+// jitter, but never shorter than the server's Retry-After.
+func (c *Client) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	ceiling := float64(c.minBackoff) * math.Pow(2, float64(attempt))
+	if ceiling > float64(c.maxBackoff) {
+		ceiling = float64(c.maxBackoff)
+	}
+	delay := time.Duration(mathrand.Float64() * ceiling)
+	if delay < c.minBackoff {
+		delay = c.minBackoff
+	}
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+	return delay
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func retryAfter(h http.Header) time.Duration {
+	value := h.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
+
+// retryable reports whether a failed attempt may succeed when repeated.
+func retryable(err error) bool {
+	var netErr *networkError
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		// A spent daily quota is not refilled before the next day
+		if apiErr.Code == CodeQuotaExceeded {
+			return false
+		}
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	return false
+}
+
+// newRequestID returns a random ID in the format accepted by the server.
+func newRequestID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,209 @@
+package client
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"image-editor-app/backend/models"
+)
+
+// newTestClient returns a client of a test server serving handler, with
+// retries that do not wait.
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return New(srv.URL, WithBackoff(time.Millisecond, time.Millisecond))
+}
+
+func TestResize(t *testing.T) {
+	image := []byte("not really a JPEG")
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/resize" {
+			t.Errorf("request %s %s, want POST /v1/resize", r.Method, r.URL.Path)
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("decoding request: %v", err)
+		}
+		if body["image_base64"] != base64.StdEncoding.EncodeToString(image) || body["width"] != 800.0 || body["metadata"] != "keep" {
+			t.Errorf("request body %v", body)
+		}
+
+		w.Header().Set("X-Request-ID", r.Header.Get("X-Request-ID"))
+		json.NewEncoder(w).Encode(models.ImageResponse{
+			ImageBase64: base64.StdEncoding.EncodeToString([]byte("resized")),
+			MIMEType:    "image/jpeg",
+			Format:      "jpeg",
+			Width:       800,
+			Height:      600,
+		})
+	})
+
+	res, err := c.Resize(context.Background(), ImageFromBytes(image), ResizeOptions{
+		Width:         800,
+		OutputOptions: OutputOptions{Metadata: "keep"},
+	})
+	if err != nil {
+		t.Fatalf("Resize: %v", err)
+	}
+	if string(res.Data) != "resized" || res.Format != "jpeg" || res.Width != 800 || res.Height != 600 || res.RequestID == "" {
+		t.Errorf("result %+v", res)
+	}
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	attempts := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Error:     "invalid request: width failed gte=0 validation",
+			Code:      "invalid_input",
+			RequestID: "abc",
+			Details:   map[string]any{"fields": []any{}},
+		})
+	})
+
+	_, err := c.Resize(context.Background(), ImageFromBytes([]byte("x")), ResizeOptions{Width: -1})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got %v, want an *Error", err)
+	}
+	if apiErr.StatusCode != http.StatusBadRequest || apiErr.Code != CodeInvalidInput || apiErr.RequestID != "abc" || apiErr.Details == nil {
+		t.Errorf("error %+v", apiErr)
+	}
+	if !IsCode(err, CodeInvalidInput) {
+		t.Error("IsCode(err, CodeInvalidInput) = false")
+	}
+	if attempts != 1 {
+		t.Errorf("%d attempts, want 1: client errors are not retried", attempts)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var requestIDs []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requestIDs = append(requestIDs, r.Header.Get("X-Request-ID"))
+		if len(requestIDs) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(models.ErrorResponse{Error: "busy", Code: "overloaded"})
+			return
+		}
+		json.NewEncoder(w).Encode(models.ImageResponse{Format: "png"})
+	})
+
+	if _, err := c.Crop(context.Background(), ImageFromBytes([]byte("x")), CropOptions{Width: 1, Height: 1}); err != nil {
+		t.Fatalf("Crop: %v", err)
+	}
+	if len(requestIDs) != 2 || requestIDs[0] != requestIDs[1] {
+		t.Errorf("request IDs %v, want two attempts with the same ID", requestIDs)
+	}
+}
+
+func TestAcceptedJob(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(models.Job{ID: "job1", Operation: "upscale", Status: "pending", CallbackURL: "https://example.com/hook"})
+	})
+
+	res, err := c.Upscale(context.Background(), ImageFromBytes([]byte("x")), UpscaleOptions{
+		ScaleFactor:   2,
+		OutputOptions: OutputOptions{CallbackURL: "https://example.com/hook"},
+	})
+	if err != nil {
+		t.Fatalf("Upscale: %v", err)
+	}
+	if res.Job == nil || res.Job.ID != "job1" || res.Job.Status != "pending" || res.Data != nil {
+		t.Errorf("result %+v, want the pending job", res)
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/pipeline" {
+			t.Errorf("path %s, want /v1/pipeline", r.URL.Path)
+		}
+		var body struct {
+			Steps    []models.PipelineStep `json:"steps"`
+			Metadata string                `json:"metadata"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("decoding request: %v", err)
+		}
+		if len(body.Steps) != 3 || body.Steps[0].Operation != "resize" || body.Steps[0].Options["width"] != 100.0 ||
+			body.Steps[1].Operation != "remove-background" || body.Steps[1].Options != nil ||
+			body.Steps[2].Operation != "compress" || body.Steps[2].Options["quality"] != 70.0 || body.Metadata != "strip" {
+			t.Errorf("request body %+v", body)
+		}
+		json.NewEncoder(w).Encode(models.ImageResponse{Format: "png"})
+	})
+
+	quality := 70
+	_, err := c.Pipeline(context.Background(), ImageFromBytes([]byte("x")), PipelineOptions{
+		Steps: []Step{
+			ResizeStep(ResizeParams{Width: 100}),
+			RemoveBackgroundStep(),
+			CompressStep(CompressParams{Quality: &quality}),
+		},
+		OutputOptions: OutputOptions{Metadata: "strip"},
+	})
+	if err != nil {
+		t.Fatalf("Pipeline: %v", err)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatalf("parsing form: %v", err)
+		}
+		if r.FormValue("operation") != "convert" || !strings.Contains(r.FormValue("options"), `"format":"png"`) {
+			t.Errorf("form %v", r.MultipartForm.Value)
+		}
+		files := r.MultipartForm.File["files"]
+		if len(files) != 2 || files[0].Filename != "a.jpg" || files[1].Filename != "b.jpg" {
+			t.Fatalf("files %v", files)
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		zw := zip.NewWriter(w)
+		out, _ := zw.Create("a.png")
+		io.WriteString(out, "converted")
+		manifest, _ := zw.Create("manifest.json")
+		json.NewEncoder(manifest).Encode(models.BatchManifest{
+			Operation: "convert",
+			Total:     2,
+			Succeeded: 1,
+			Failed:    1,
+			Files: []models.BatchManifestFile{
+				{Name: "a.jpg", Status: "ok", Output: "a.png"},
+				{Name: "b.jpg", Status: "failed", Code: "unsupported_format"},
+			},
+		})
+		zw.Close()
+	})
+
+	res, err := c.Batch(context.Background(), "convert", ConvertOptions{Format: "png"},
+		BatchFile{Name: "a.jpg", Image: ImageFromBytes([]byte("a"))},
+		BatchFile{Name: "b.jpg", Image: ImageFromReader(strings.NewReader("b"))})
+	if err != nil {
+		t.Fatalf("Batch: %v", err)
+	}
+	if res.Manifest.Succeeded != 1 || res.Manifest.Failed != 1 || res.Manifest.Files[1].Code != "unsupported_format" {
+		t.Errorf("manifest %+v", res.Manifest)
+	}
+	data, err := res.File(res.Manifest.Files[0].Output)
+	if err != nil || string(data) != "converted" {
+		t.Errorf("File = %q, %v", data, err)
+	}
+}
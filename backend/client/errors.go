@@ -0,0 +1,125 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrorCode is the stable error category reported by the server.
+type ErrorCode string
+
+// These mirror the codes returned by the server.
+const (
+	CodeInvalidInput       ErrorCode = "invalid_input"
+	CodeUnsupportedFormat  ErrorCode = "unsupported_format"
+	CodeTooLarge           ErrorCode = "too_large"
+	CodeLimitExceeded      ErrorCode = "limit_exceeded"
+	CodeUnauthorized       ErrorCode = "unauthorized"
+	CodeNotFound           ErrorCode = "not_found"
+	CodeForbidden          ErrorCode = "forbidden"
+	CodeExpired            ErrorCode = "expired"
+	CodeRateLimited        ErrorCode = "rate_limited"
+	CodeQuotaExceeded      ErrorCode = "quota_exceeded"
+	CodeBackendUnavailable ErrorCode = "backend_unavailable"
+	CodeOverloaded         ErrorCode = "overloaded"
+	CodeTimeout            ErrorCode = "timeout"
+	CodeCanceled           ErrorCode = "canceled"
+	CodeInternal           ErrorCode = "internal"
+)
+
+// Error is an error response from the API.
+type Error struct {
+	StatusCode int
+	Code       ErrorCode
+	Message    string
+	RequestID  string
+	Details    map[string]any
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("image API: %s (%s, status %d)", e.Message, e.Code, e.StatusCode)
+	if e.RequestID != "" {
+		msg += " request_id=" + e.RequestID
+	}
+	return msg
+}
+
+// IsCode reports whether err is an API error with the given code.
+func IsCode(err error, code ErrorCode) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+// newResponseError reads an error envelope. Responses that are not an
+// envelope, such as those from a proxy, get a code derived from the status.
+func newResponseError(resp *http.Response) *Error {
+	apiErr := &Error{StatusCode: resp.StatusCode, RequestID: resp.Header.Get("X-Request-ID")}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	var envelope struct {
+		Error     string         `json:"error"`
+		Code      string         `json:"code"`
+		RequestID string         `json:"request_id"`
+		Details   map[string]any `json:"details"`
+	}
+	if json.Unmarshal(body, &envelope) == nil && envelope.Code != "" {
+		apiErr.Code = ErrorCode(envelope.Code)
+		apiErr.Message = envelope.Error
+		apiErr.Details = envelope.Details
+		if envelope.RequestID != "" {
+			apiErr.RequestID = envelope.RequestID
+		}
+		return apiErr
+	}
+
+	apiErr.Code = codeForStatus(resp.StatusCode)
+	apiErr.Message = http.StatusText(resp.StatusCode)
+	return apiErr
+}
+
+func codeForStatus(status int) ErrorCode {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeInvalidInput
+	case http.StatusUnsupportedMediaType:
+		return CodeUnsupportedFormat
+	case http.StatusRequestEntityTooLarge:
+		return CodeTooLarge
+	case http.StatusUnprocessableEntity:
+		return CodeLimitExceeded
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusGone:
+		return CodeExpired
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return CodeBackendUnavailable
+	case http.StatusGatewayTimeout:
+		return CodeTimeout
+	case 499:
+		return CodeCanceled
+	default:
+		return CodeInternal
+	}
+}
+
+// networkError is a transport failure before a response was received.
+type networkError struct {
+	err error
+}
+
+func (e *networkError) Error() string {
+	return "image API: " + e.err.Error()
+}
+
+func (e *networkError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,139 @@
+package client
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Image is an image input. Create one with ImageFromFile, ImageFromBytes or
+// ImageFromReader.
+type Image struct {
+	open       func() (io.ReadCloser, error)
+	replayable bool // Whether open may be called again to retry a request
+}
+
+// ImageFromFile reads the image from the named file. The file is reopened
+// for every attempt, so requests with file inputs can be retried.
+func ImageFromFile(name string) Image {
+	return Image{
+		open:       func() (io.ReadCloser, error) { return os.Open(name) },
+		replayable: true,
+	}
+}
+
+// ImageFromBytes uses encoded image data held in memory.
+func ImageFromBytes(data []byte) Image {
+	return Image{
+		open:       func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil },
+		replayable: true,
+	}
+}
+
+// ImageFromReader streams the image from r. The reader can only be consumed
+// once, so a request with a reader input is not retried after it was sent.
+func ImageFromReader(r io.Reader) Image {
+	var once sync.Once
+	return Image{
+		open: func() (rc io.ReadCloser, err error) {
+			err = errors.New("image reader already consumed")
+			once.Do(func() {
+				rc, err = io.NopCloser(r), nil
+			})
+			return rc, err
+		},
+	}
+}
+
+// imageField is an image sent under a JSON key such as "image_base64".
+type imageField struct {
+	key   string
+	image Image
+}
+
+func replayable(images []imageField) bool {
+	for _, f := range images {
+		if !f.image.replayable {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestBody returns a JSON object holding the base64-encoded images
+// followed by the fields of params. The images are encoded while the body is
+// read rather than up front.
+func newRequestBody(images []imageField, params any) (io.ReadCloser, error) {
+	rest, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) < 2 || rest[0] != '{' {
+		return nil, fmt.Errorf("request parameters must encode to a JSON object, got %s", rest)
+	}
+
+	sources := make([]io.ReadCloser, 0, len(images))
+	closeAll := func() {
+		for _, src := range sources {
+			src.Close()
+		}
+	}
+	for _, f := range images {
+		if f.image.open == nil {
+			closeAll()
+			return nil, fmt.Errorf("%s: no image given", f.key)
+		}
+		src, err := f.image.open()
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("%s: %w", f.key, err)
+		}
+		sources = append(sources, src)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer closeAll()
+		pw.CloseWithError(writeRequestBody(pw, images, sources, rest))
+	}()
+	return pr, nil
+}
+
+func writeRequestBody(w io.Writer, images []imageField, sources []io.ReadCloser, rest []byte) error {
+	if _, err := io.WriteString(w, "{"); err != nil {
+		return err
+	}
+	for i, f := range images {
+		key, _ := json.Marshal(f.key)
+		if i > 0 {
+			key = append([]byte(","), key...)
+		}
+		if _, err := fmt.Fprintf(w, `%s:"`, key); err != nil {
+			return err
+		}
+		enc := base64.NewEncoder(base64.StdEncoding, w)
+		if _, err := io.Copy(enc, sources[i]); err != nil {
+			return fmt.Errorf("%s: %w", f.key, err)
+		}
+		if err := enc.Close(); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, `"`); err != nil {
+			return err
+		}
+	}
+
+	// rest is a JSON object; splice in its fields after the images
+	if len(images) > 0 && len(bytes.TrimSpace(rest[1:len(rest)-1])) > 0 {
+		if _, err := io.WriteString(w, ","); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(rest[1:])
+	return err
+}
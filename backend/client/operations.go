@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+
+	"image-editor-app/backend/models"
+)
+
+// OutputOptions sets the metadata policy and color profile of the output image.
+type OutputOptions = models.OutputOptions
+
+// Point is a coordinate on a blur path.
+type Point = models.Point
+
+// ResizeOptions sets the target size, either as dimensions or as a preset.
+type ResizeOptions struct {
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	Preset string `json:"preset,omitempty"` // e.g., "youtube_thumbnail", "instagram_story"
+	OutputOptions
+}
+
+// CropOptions sets the rectangle to keep.
+type CropOptions struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	OutputOptions
+}
+
+// UpscaleOptions sets the upscale factor.
+type UpscaleOptions struct {
+	ScaleFactor float64 `json:"scale_factor"` // e.g., 2.0 for 2x upscale
+	OutputOptions
+}
+
+// ConvertOptions sets the target format.
+type ConvertOptions struct {
+	Format string `json:"format"` // e.g., "jpeg", "png", "webp"
+	OutputOptions
+}
+
+// BlurOptions sets the path to blur along.
+type BlurOptions struct {
+	Path   []Point `json:"path"`
+	Radius float64 `json:"radius"`
+	OutputOptions
+}
+
+// RemoveBackgroundOptions sets the output of a background removal.
+type RemoveBackgroundOptions struct {
+	OutputOptions
+}
+
+// ChangeBackgroundOptions sets the new background: an image, a solid color or
+// transparency.
+type ChangeBackgroundOptions struct {
+	Background  Image  `json:"-"`                     // Optional: new background image
+	SolidColor  string `json:"solid_color,omitempty"` // Optional: "R,G,B", e.g., "255,0,0"
+	Transparent bool   `json:"transparent,omitempty"`
+	OutputOptions
+}
+
+// CompressOptions sets the quality, format and maximum size of the output.
+type CompressOptions struct {
+	Quality   int    `json:"quality,omitempty"` // 1-100; zero uses the format's default
+	Format    string `json:"format,omitempty"`
+	MaxWidth  int    `json:"max_width,omitempty"`
+	MaxHeight int    `json:"max_height,omitempty"`
+	OutputOptions
+}
+
+// Resize resizes an image to explicit dimensions or a social media preset.
+func (c *Client) Resize(ctx context.Context, img Image, opts ResizeOptions) (*Result, error) {
+	return c.post(ctx, "/resize", source(img), opts)
+}
+
+// Crop crops an image to a rectangle.
+func (c *Client) Crop(ctx context.Context, img Image, opts CropOptions) (*Result, error) {
+	return c.post(ctx, "/crop", source(img), opts)
+}
+
+// Upscale upscales an image by a factor.
+func (c *Client) Upscale(ctx context.Context, img Image, opts UpscaleOptions) (*Result, error) {
+	return c.post(ctx, "/upscale", source(img), opts)
+}
+
+// Convert converts an image to another format.
+func (c *Client) Convert(ctx context.Context, img Image, opts ConvertOptions) (*Result, error) {
+	return c.post(ctx, "/convert", source(img), opts)
+}
+
+// Blur blurs the regions of an image along a path.
+func (c *Client) Blur(ctx context.Context, img Image, opts BlurOptions) (*Result, error) {
+	return c.post(ctx, "/blur", source(img), opts)
+}
+
+// RemoveBackground removes the background of an image.
+func (c *Client) RemoveBackground(ctx context.Context, img Image, opts RemoveBackgroundOptions) (*Result, error) {
+	return c.post(ctx, "/remove-background", source(img), opts)
+}
+
+// ChangeBackground replaces the background of an image.
+func (c *Client) ChangeBackground(ctx context.Context, img Image, opts ChangeBackgroundOptions) (*Result, error) {
+	images := source(img)
+	if opts.Background.open != nil {
+		images = append(images, imageField{"new_background_image_base64", opts.Background})
+	}
+	return c.post(ctx, "/change-background", images, opts)
+}
+
+// Compress compresses an image, optionally capping its dimensions.
+func (c *Client) Compress(ctx context.Context, img Image, opts CompressOptions) (*Result, error) {
+	return c.post(ctx, "/compress", source(img), opts)
+}
+
+// Pipeline applies several operations to an image one after another. The
+// image is decoded once and encoded once, and the request is charged for
+// every step.
+//
+//	res, err := c.Pipeline(ctx, img, client.PipelineOptions{Steps: []client.Step{
+//		client.ResizeStep(client.ResizeParams{Width: 1200}),
+//		client.CompressStep(client.CompressParams{Quality: &quality}),
+//	}})
+func (c *Client) Pipeline(ctx context.Context, img Image, opts PipelineOptions) (*Result, error) {
+	return c.post(ctx, "/pipeline", source(img), opts)
+}
+
+// PipelineOptions sets the steps of a pipeline and the output settings of
+// its result.
+type PipelineOptions struct {
+	Steps []Step `json:"steps"`
+	OutputOptions
+}
+
+// Step is one operation of a pipeline. Create steps with ResizeStep,
+// CropStep and the like.
+type Step struct {
+	Operation string `json:"operation"`
+	Options   any    `json:"options,omitempty"`
+}
+
+// The parameters of the operations in pipeline steps. They are those of the
+// operation methods, without the output settings, which are set once for
+// the whole pipeline; images in them are given base64-encoded.
+type (
+	ResizeParams           = models.ResizeParams
+	CropParams             = models.CropParams
+	UpscaleParams          = models.UpscaleParams
+	ConvertParams          = models.ConvertParams
+	BlurParams             = models.BlurParams
+	ChangeBackgroundParams = models.ChangeBackgroundParams
+	CompressParams         = models.CompressParams
+	WatermarkParams        = models.WatermarkParams
+)
+
+// ResizeStep resizes the image in a pipeline.
+func ResizeStep(p ResizeParams) Step { return Step{"resize", p} }
+
+// CropStep crops the image in a pipeline.
+func CropStep(p CropParams) Step { return Step{"crop", p} }
+
+// UpscaleStep upscales the image in a pipeline.
+func UpscaleStep(p UpscaleParams) Step { return Step{"upscale", p} }
+
+// ConvertStep sets the format the result of a pipeline is encoded in.
+func ConvertStep(p ConvertParams) Step { return Step{"convert", p} }
+
+// BlurStep blurs the image along a path in a pipeline.
+func BlurStep(p BlurParams) Step { return Step{"blur", p} }
+
+// RemoveBackgroundStep removes the background of the image in a pipeline.
+func RemoveBackgroundStep() Step { return Step{Operation: "remove-background"} }
+
+// ChangeBackgroundStep replaces the background of the image in a pipeline.
+func ChangeBackgroundStep(p ChangeBackgroundParams) Step { return Step{"change-background", p} }
+
+// CompressStep sets the quality and format of the result of a pipeline,
+// optionally capping its dimensions.
+func CompressStep(p CompressParams) Step { return Step{"compress", p} }
+
+// WatermarkStep adds a text or logo watermark to the image in a pipeline.
+func WatermarkStep(p WatermarkParams) Step { return Step{"watermark", p} }
+
+func source(img Image) []imageField {
+	return []imageField{{"image_base64", img}}
+}
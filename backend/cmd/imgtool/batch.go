@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"image-editor-app/backend/utils"
+)
+
+// batchOptions controls how a batch is run and where its outputs go.
+type batchOptions struct {
+	op         string
+	template   string
+	recursive  bool
+	parallel   int
+	dryRun     bool
+	overwrite  bool
+	reportPath string
+}
+
+// Statuses of a file in the report.
+const (
+	statusOK      = "ok"
+	statusSkipped = "skipped"
+	statusFailed  = "failed"
+	statusPlanned = "planned" // Dry run
+)
+
+// fileResult is the outcome for one input.
+type fileResult struct {
+	Input       string `json:"input"`
+	Output      string `json:"output,omitempty"`
+	Status      string `json:"status"`
+	InputBytes  int64  `json:"input_bytes"`
+	OutputBytes int64  `json:"output_bytes,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// report summarizes a batch.
+type report struct {
+	Operation   string       `json:"operation"`
+	DryRun      bool         `json:"dry_run"`
+	Total       int          `json:"total"`
+	Processed   int          `json:"processed"`
+	Skipped     int          `json:"skipped"`
+	Failed      int          `json:"failed"`
+	InputBytes  int64        `json:"input_bytes"`  // Of the processed files
+	OutputBytes int64        `json:"output_bytes"` // Of the processed files
+	ElapsedMS   int64        `json:"elapsed_ms"`
+	Files       []fileResult `json:"files"`
+}
+
+// runBatch processes the inputs with opts.parallel workers. Results are
+// reported in input order.
+func runBatch(inputs []inputFile, op operation, opts batchOptions) *report {
+	start := time.Now()
+	results := make([]fileResult, len(inputs))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < opts.parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = processFile(inputs[i], op, opts)
+			}
+		}()
+	}
+	for i := range inputs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	r := &report{Operation: opts.op, DryRun: opts.dryRun, Total: len(inputs), Files: results}
+	for _, res := range results {
+		switch res.Status {
+		case statusOK:
+			r.Processed++
+			r.InputBytes += res.InputBytes
+			r.OutputBytes += res.OutputBytes
+		case statusSkipped:
+			r.Skipped++
+		case statusFailed:
+			r.Failed++
+		}
+	}
+	r.ElapsedMS = time.Since(start).Milliseconds()
+	return r
+}
+
+func processFile(in inputFile, op operation, opts batchOptions) fileResult {
+	res := fileResult{Input: in.path, Output: outputPath(opts.template, opts.op, in, op.format)}
+	fail := func(err error) fileResult {
+		res.Status = statusFailed
+		res.Error = err.Error()
+		return res
+	}
+
+	info, err := os.Stat(in.path)
+	if err != nil {
+		return fail(err)
+	}
+	res.InputBytes = info.Size()
+
+	if opts.dryRun {
+		res.Status = statusPlanned
+		return res
+	}
+	if !opts.overwrite && exists(res.Output) {
+		res.Status = statusSkipped
+		res.Error = "output exists"
+		return res
+	}
+
+	data, err := os.ReadFile(in.path)
+	if err != nil {
+		return fail(err)
+	}
+	result, err := op.run(data)
+	if err != nil {
+		return fail(err)
+	}
+
+	// Name the output after the format actually written
+	res.Output = outputPath(opts.template, opts.op, in, result.Format)
+	if err := os.MkdirAll(filepath.Dir(res.Output), 0o755); err != nil {
+		return fail(err)
+	}
+	// Written next to its final path and renamed, so an interrupted run
+	// never leaves a truncated image behind
+	if err := utils.WriteFileAtomic(res.Output, result.Data, 0o644); err != nil {
+		return fail(err)
+	}
+	res.Status = statusOK
+	res.OutputBytes = int64(len(result.Data))
+	return res
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// print writes a human-readable summary followed by the failures.
+func (r *report) print(w io.Writer) {
+	if r.DryRun {
+		var total int64
+		for _, f := range r.Files {
+			fmt.Fprintf(w, "%s -> %s\n", f.Input, f.Output)
+			total += f.InputBytes
+		}
+		fmt.Fprintf(w, "\nDry run: %d files (%s) would be processed by %s\n", r.Total, formatBytes(total), r.Operation)
+		return
+	}
+
+	fmt.Fprintf(w, "%s: %d files in %s\n", r.Operation, r.Total, time.Duration(r.ElapsedMS)*time.Millisecond)
+	fmt.Fprintf(w, "  processed: %d\n  skipped:   %d\n  failed:    %d\n", r.Processed, r.Skipped, r.Failed)
+	if r.Processed > 0 {
+		saved := r.InputBytes - r.OutputBytes
+		fmt.Fprintf(w, "  input:     %s\n  output:    %s\n", formatBytes(r.InputBytes), formatBytes(r.OutputBytes))
+		if saved >= 0 {
+			fmt.Fprintf(w, "  saved:     %s (%.1f%%)\n", formatBytes(saved), 100*float64(saved)/float64(r.InputBytes))
+		} else {
+			fmt.Fprintf(w, "  grew:      %s (%.1f%%)\n", formatBytes(-saved), 100*float64(-saved)/float64(r.InputBytes))
+		}
+	}
+
+	if r.Failed > 0 {
+		fmt.Fprintln(w, "\nFailures:")
+		for _, f := range r.Files {
+			if f.Status == statusFailed {
+				fmt.Fprintf(w, "  %s: %s\n", f.Input, f.Error)
+			}
+		}
+	}
+}
+
+// writeJSON writes the full report, including every file, to path.
+func (r *report) writeJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// formatBytes renders a size with a binary unit, e.g., "1.5 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// imageExtensions are the file extensions picked up when walking directories.
+var imageExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true,
+	".bmp": true, ".tif": true, ".tiff": true, ".webp": true,
+}
+
+// inputFile is an image to process.
+type inputFile struct {
+	path string
+	rel  string // Directory relative to the directory argument it was found in
+}
+
+// expandInputs resolves files, glob patterns and directories into a sorted,
+// de-duplicated list of images. Directories are only descended into with
+// recursive set; otherwise just their top-level images are taken.
+func expandInputs(args []string, recursive bool) ([]inputFile, error) {
+	seen := map[string]bool{}
+	var inputs []inputFile
+	add := func(path, rel string) {
+		if !seen[path] {
+			seen[path] = true
+			inputs = append(inputs, inputFile{path: path, rel: rel})
+		}
+	}
+
+	for _, arg := range args {
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", arg, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("%s: no such file or directory", arg)
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				add(filepath.Clean(match), "")
+				continue
+			}
+
+			root := match
+			err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.IsDir() {
+					if path != root && !recursive {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if !imageExtensions[strings.ToLower(filepath.Ext(path))] {
+					return nil
+				}
+				rel, _ := filepath.Rel(root, filepath.Dir(path))
+				add(path, rel)
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sort.Slice(inputs, func(i, j int) bool { return inputs[i].path < inputs[j].path })
+	return inputs, nil
+}
+
+// outputPath expands the output template for an input. ext is the output
+// format; the input's extension is kept when it already names that format.
+func outputPath(template, op string, in inputFile, format string) string {
+	base := filepath.Base(in.path)
+	inputExt := filepath.Ext(base)
+	name := strings.TrimSuffix(base, inputExt)
+
+	ext := strings.TrimPrefix(strings.ToLower(inputExt), ".")
+	if format != "" && formatOfExtension(ext) != format {
+		ext = format
+		if format == "jpeg" {
+			ext = "jpg"
+		}
+	} else if ext != "" {
+		ext = strings.TrimPrefix(inputExt, ".")
+	}
+
+	rel := in.rel
+	if rel == "." {
+		rel = ""
+	}
+	path := strings.NewReplacer(
+		"{dir}", filepath.Dir(in.path),
+		"{rel}", rel,
+		"{name}", name,
+		"{ext}", ext,
+		"{op}", op,
+	).Replace(template)
+	return filepath.Clean(path)
+}
+
+// formatOfExtension maps a lowercase file extension to the format name
+// reported by the image decoders.
+func formatOfExtension(ext string) string {
+	switch ext {
+	case "jpg", "jpeg":
+		return "jpeg"
+	case "tif", "tiff":
+		return "tiff"
+	default:
+		return ext
+	}
+}
@@ -0,0 +1,247 @@
+// Command imgtool applies the server's image operations to local files, without
+// going through HTTP.
+//
+//	imgtool resize -width 800 -r -o 'out/{rel}/{name}.{ext}' photos/
+//	imgtool compress -quality 75 -j 4 -dry-run 'uploads/*.jpg'
+//
+// Every subcommand accepts files, glob patterns and, with -r, directories.
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"image-editor-app/backend/models"
+	"image-editor-app/backend/services"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// aliases maps the short names of subcommands to their operations.
+var aliases = map[string]string{"remove-bg": "remove-background"}
+
+// defaults holds flag defaults that differ from the zero value of their
+// parameter, by subcommand.
+var defaults = map[string]map[string]string{
+	"blur": {"radius": "5"},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: imgtool <command> [flags] <file|glob|dir>...")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, op := range services.Operations() {
+		fmt.Fprintf(os.Stderr, "  %-18s %s\n", op.Name(), op.Summary())
+	}
+	for alias, name := range aliases {
+		fmt.Fprintf(os.Stderr, "  %-18s Alias of %s\n", alias, name)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun 'imgtool <command> -h' for the flags of a command.")
+}
+
+func main() {
+	// Only problems are worth reporting next to the progress output
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := os.Args[1]
+	if alias, ok := aliases[name]; ok {
+		name = alias
+	}
+	op, ok := services.LookupOperation(name)
+	if !ok {
+		if os.Args[1] != "-h" && os.Args[1] != "help" {
+			fmt.Fprintf(os.Stderr, "imgtool: unknown command %q\n\n", os.Args[1])
+		}
+		usage()
+		os.Exit(2)
+	}
+
+	cmdName := os.Args[1]
+	fs := flag.NewFlagSet("imgtool "+cmdName, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: imgtool %s [flags] <file|glob|dir>...\n\n%s.\n\nFlags:\n", cmdName, op.Summary())
+		fs.PrintDefaults()
+	}
+
+	var out models.OutputOptions
+	fs.StringVar(&out.Metadata, "metadata", "", "metadata policy: keep, strip (default), strip_gps, keep_copyright_only")
+	fs.StringVar(&out.OutputProfile, "output-profile", "", "output color profile: srgb (default), display_p3, adobe_rgb")
+	fs.BoolVar(&out.EmbedProfile, "embed-profile", false, "embed the output ICC profile")
+
+	var batch batchOptions
+	fs.StringVar(&batch.template, "o", "{dir}/{name}_{op}.{ext}", "output path template; placeholders: {dir}, {rel}, {name}, {ext}, {op}")
+	fs.BoolVar(&batch.recursive, "r", false, "process directories recursively")
+	fs.IntVar(&batch.parallel, "j", runtime.NumCPU(), "number of images processed in parallel")
+	fs.BoolVar(&batch.dryRun, "dry-run", false, "list the planned outputs without processing anything")
+	fs.BoolVar(&batch.overwrite, "overwrite", false, "replace existing output files instead of skipping them")
+	fs.StringVar(&batch.reportPath, "report", "", "also write the report as JSON to this file")
+
+	params := op.Params()
+	paramFlags(fs, params)
+	for name, value := range defaults[op.Name()] {
+		f := fs.Lookup(name)
+		f.Value.Set(value)
+		f.DefValue = value
+	}
+	fs.Parse(os.Args[2:])
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	err := validate(params)
+	if err == nil {
+		err = validate(out)
+	}
+	if err == nil {
+		err = op.Validate(params)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "imgtool %s: %v\n", cmdName, err)
+		os.Exit(2)
+	}
+	steps := services.Steps{{Operation: op, Params: params}}
+	run := operation{
+		run: func(data []byte) (*services.Result, error) {
+			return steps.Apply(context.Background(), data, out)
+		},
+		format: steps.Format(),
+	}
+	batch.op = cmdName
+	if batch.parallel < 1 {
+		batch.parallel = 1
+	}
+
+	inputs, err := expandInputs(fs.Args(), batch.recursive)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "imgtool %s: %v\n", cmdName, err)
+		os.Exit(2)
+	}
+
+	report := runBatch(inputs, run, batch)
+	report.print(os.Stdout)
+	if batch.reportPath != "" {
+		if err := report.writeJSON(batch.reportPath); err != nil {
+			fmt.Fprintf(os.Stderr, "imgtool: writing report: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// operation processes one encoded image. format is the output format when it
+// is known before processing, used to name outputs in dry runs.
+type operation struct {
+	run    func(data []byte) (*services.Result, error)
+	format string
+}
+
+// paramFlags registers a flag for every field of the operation parameters
+// params points to, named after its JSON name and described by its doc tag.
+// Optional parameters are only set when their flag is given, and images
+// (fields ending in _base64) are read from the file their flag names.
+func paramFlags(fs *flag.FlagSet, params any) {
+	v := reflect.ValueOf(params).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if jsonName == "" || jsonName == "-" {
+			continue
+		}
+		name := flagName(jsonName)
+		usage := field.Tag.Get("doc")
+
+		switch p := v.Field(i).Addr().Interface().(type) {
+		case flag.Value:
+			fs.Var(p, name, usage)
+		case *int:
+			fs.IntVar(p, name, *p, usage)
+		case *float64:
+			fs.Float64Var(p, name, *p, usage)
+		case *bool:
+			fs.BoolVar(p, name, *p, usage)
+		case *string:
+			if !strings.HasSuffix(jsonName, "_base64") {
+				fs.StringVar(p, name, *p, usage)
+				continue
+			}
+			fs.Func(name, usage+" (path of the file)", func(path string) error {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				*p = base64.StdEncoding.EncodeToString(data)
+				return nil
+			})
+		case **int:
+			fs.Func(name, usage, func(s string) error {
+				n, err := strconv.Atoi(s)
+				if err != nil {
+					return err
+				}
+				*p = &n
+				return nil
+			})
+		case **float64:
+			fs.Func(name, usage, func(s string) error {
+				f, err := strconv.ParseFloat(s, 64)
+				if err != nil {
+					return err
+				}
+				*p = &f
+				return nil
+			})
+		case **string:
+			fs.Func(name, usage, func(s string) error {
+				*p = &s
+				return nil
+			})
+		default:
+			panic(fmt.Sprintf("imgtool: no flag for %s.%s of type %s", v.Type().Name(), field.Name, field.Type))
+		}
+	}
+}
+
+// flagName returns the name of the flag of a parameter: its JSON name with
+// dashes, without the _base64 suffix of images.
+func flagName(jsonName string) string {
+	return strings.ReplaceAll(strings.TrimSuffix(jsonName, "_base64"), "_", "-")
+}
+
+// validate checks operation parameters or output settings against the
+// binding rules the server applies.
+func validate(req any) error {
+	v := validator.New()
+	v.SetTagName("binding")
+	if err := models.RegisterValidations(v); err != nil {
+		return err
+	}
+
+	err := v.Struct(req)
+	if validationErrs, ok := err.(validator.ValidationErrors); ok {
+		messages := make([]string, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			rule := fe.Tag()
+			if fe.Param() != "" {
+				rule += "=" + fe.Param()
+			}
+			messages = append(messages, fmt.Sprintf("-%s fails %s", flagName(fe.Field()), rule))
+		}
+		return fmt.Errorf("invalid flags: %s", strings.Join(messages, "; "))
+	}
+	return err
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"image-editor-app/backend/apikeys"
+	"image-editor-app/backend/models"
+	"image-editor-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListAPIKeys handles GET /admin/keys: every issued key, without the keys themselves.
+func ListAPIKeys(store apikeys.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !keysEnabled(c, store) {
+			return
+		}
+		keys := store.List()
+		resp := models.APIKeyList{Keys: make([]models.APIKey, 0, len(keys))}
+		for _, key := range keys {
+			resp.Keys = append(resp.Keys, apiKeyResponse(key))
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// CreateAPIKey handles POST /admin/keys. The response is the only place the
+// new key is ever shown.
+func CreateAPIKey(store apikeys.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !keysEnabled(c, store) {
+			return
+		}
+		var req models.CreateAPIKeyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			bindError(c, err)
+			return
+		}
+
+		key, secret := apikeys.New(req.Name)
+		key.Rate, key.Burst, key.DailyQuota = req.Rate, req.Burst, req.DailyQuota
+		if err := store.Add(key); err != nil {
+			serviceError(c, err)
+			return
+		}
+		slog.InfoContext(c.Request.Context(), "issued API key", "key_id", key.ID, "name", key.Name)
+
+		resp := apiKeyResponse(key)
+		resp.Key, resp.WebhookSecret = secret, key.WebhookSecret
+		c.JSON(http.StatusCreated, resp)
+	}
+}
+
+// RevokeAPIKey handles DELETE /admin/keys/:id. Requests with a revoked key
+// are rejected from then on.
+func RevokeAPIKey(store apikeys.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !keysEnabled(c, store) {
+			return
+		}
+		err := store.Revoke(c.Param("id"), time.Now())
+		if errors.Is(err, apikeys.ErrNotFound) {
+			RespondError(c, &services.Error{Code: services.CodeNotFound, Message: "API key not found"})
+			return
+		}
+		if err != nil {
+			serviceError(c, err)
+			return
+		}
+		slog.InfoContext(c.Request.Context(), "revoked API key", "key_id", c.Param("id"))
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// keysEnabled responds with an error when API keys are not configured.
+func keysEnabled(c *gin.Context, store apikeys.Store) bool {
+	if store == nil {
+		RespondError(c, &services.Error{Code: services.CodeNotFound, Message: "API keys are not enabled"})
+		return false
+	}
+	return true
+}
+
+func apiKeyResponse(key apikeys.Key) models.APIKey {
+	return models.APIKey{
+		ID:         key.ID,
+		Name:       key.Name,
+		Created:    key.Created,
+		Revoked:    key.Revoked,
+		Rate:       key.Rate,
+		Burst:      key.Burst,
+		DailyQuota: key.DailyQuota,
+	}
+}
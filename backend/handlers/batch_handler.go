@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"image-editor-app/backend/models"
+	"image-editor-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// batchSteps decodes the options of a batch request into the parameters of
+// its operation and the output settings, and validates them like the body
+// of a single-image request.
+func batchSteps(op services.Operation, options string) (services.Steps, models.OutputOptions, error) {
+	params := op.Params()
+	var out models.OutputOptions
+	for _, v := range []any{params, &out} {
+		if strings.TrimSpace(options) != "" {
+			if err := json.Unmarshal([]byte(options), v); err != nil {
+				return nil, out, RequestError(fmt.Errorf("options: %w", err))
+			}
+		}
+		if err := binding.Validator.ValidateStruct(v); err != nil {
+			return nil, out, RequestError(err)
+		}
+	}
+	if err := op.Validate(params); err != nil {
+		return nil, out, err
+	}
+	return services.Steps{{Operation: op, Params: params}}, out, nil
+}
+
+// unknownOperation is the error for a request naming an operation that is
+// not registered.
+func unknownOperation(field, name string) *services.Error {
+	names := make([]string, 0, len(services.Operations()))
+	for _, op := range services.Operations() {
+		names = append(names, op.Name())
+	}
+	return &services.Error{
+		Code:    services.CodeInvalidInput,
+		Message: fmt.Sprintf("%s: unknown operation %q", field, name),
+		Details: map[string]any{"field": field, "operations": names},
+	}
+}
+
+// Batch handles /v1/batch requests: one operation applied to every uploaded
+// image, with the results and a manifest returned as a ZIP archive. Files that
+// fail are reported in the manifest without failing the batch.
+func Batch(c *gin.Context) {
+	trackProgress(c)
+
+	var req models.BatchRequest
+	if err := c.ShouldBind(&req); err != nil {
+		bindError(c, err)
+		return
+	}
+	services.ReportProgress(c.Request.Context(), services.StageReceived, -1)
+
+	op, ok := services.LookupOperation(req.Operation)
+	if !ok {
+		RespondError(c, unknownOperation("operation", req.Operation))
+		return
+	}
+	steps, out, err := batchSteps(op, req.Options)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+
+	files, err := readBatchFiles(req.Files)
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	// The request was charged for one image when it came in
+	if !chargeClient(c, steps.Cost()*int64(len(files))-1) {
+		return
+	}
+
+	results := services.ProcessBatch(c.Request.Context(), req.Operation, files, func(ctx context.Context, data []byte) (*services.Result, error) {
+		return steps.Apply(ctx, data, out)
+	})
+	writeBatchArchive(c, req.Operation, results)
+	services.FinishProgress(c.Request.Context(), nil)
+}
+
+// readBatchFiles reads the uploaded files, unpacking ZIP archives.
+func readBatchFiles(headers []*multipart.FileHeader) ([]services.BatchFile, error) {
+	var files []services.BatchFile
+	var total int64
+	add := func(name string, data []byte) error {
+		files = append(files, services.BatchFile{Name: name, Data: data})
+		total += int64(len(data))
+		return services.CheckBatchSize(len(files), total)
+	}
+
+	for _, fh := range headers {
+		data, err := readUpload(fh)
+		if err != nil {
+			return nil, err
+		}
+
+		if !isZip(data) {
+			if err := add(cleanEntryName(fh.Filename), data); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, &services.Error{Code: services.CodeInvalidInput, Message: fmt.Sprintf("%s is not a valid ZIP archive", fh.Filename), Err: err}
+		}
+		for _, entry := range archive.File {
+			if skipZipEntry(entry) {
+				continue
+			}
+			data, err := readZipEntry(entry, services.GetLimits().MaxBatchBytes-total)
+			if err != nil {
+				return nil, err
+			}
+			if err := add(cleanEntryName(entry.Name), data); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, &services.Error{Code: services.CodeInvalidInput, Message: "batch contains no images"}
+	}
+	return files, nil
+}
+
+func readUpload(fh *multipart.FileHeader) ([]byte, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, &services.Error{Code: services.CodeInternal, Message: "internal error", Err: err}
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, &services.Error{Code: services.CodeInternal, Message: "internal error", Err: err}
+	}
+	return data, nil
+}
+
+// readZipEntry decompresses an entry, failing once more than remaining bytes
+// come out, whatever size the archive declares.
+func readZipEntry(entry *zip.File, remaining int64) ([]byte, error) {
+	tooLarge := &services.Error{
+		Code:    services.CodeTooLarge,
+		Message: "batch exceeds the maximum allowed size",
+		Details: map[string]any{"max_bytes": services.GetLimits().MaxBatchBytes},
+	}
+	if int64(entry.UncompressedSize64) > remaining {
+		return nil, tooLarge
+	}
+
+	rc, err := entry.Open()
+	if err != nil {
+		return nil, &services.Error{Code: services.CodeInvalidInput, Message: fmt.Sprintf("%s could not be read from the archive", entry.Name), Err: err}
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, remaining+1))
+	if err != nil {
+		return nil, &services.Error{Code: services.CodeInvalidInput, Message: fmt.Sprintf("%s could not be read from the archive", entry.Name), Err: err}
+	}
+	if int64(len(data)) > remaining {
+		return nil, tooLarge
+	}
+	return data, nil
+}
+
+func isZip(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("PK\x03\x04"))
+}
+
+// skipZipEntry reports whether an archive entry is a directory or metadata
+// added by the archiver, such as macOS resource forks.
+func skipZipEntry(entry *zip.File) bool {
+	name := entry.Name
+	if entry.FileInfo().IsDir() || strings.HasPrefix(name, "__MACOSX/") {
+		return true
+	}
+	return strings.HasPrefix(path.Base(name), ".")
+}
+
+// cleanEntryName turns an upload or archive entry name into a safe relative
+// path for the result archive.
+func cleanEntryName(name string) string {
+	name = path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))
+	name = strings.TrimPrefix(name, "/")
+	if name == "" {
+		return "image"
+	}
+	return name
+}
+
+// batchOutputName names a result after its input, with the extension of the
+// format it was written in. Names already taken get a numeric suffix.
+func batchOutputName(name, format string, taken map[string]bool) string {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	outExt := ext
+	if extensionFormat(ext) != format {
+		outExt = "." + format
+		if format == "jpeg" {
+			outExt = ".jpg"
+		}
+	}
+
+	out := base + outExt
+	for i := 2; taken[out]; i++ {
+		out = base + "-" + strconv.Itoa(i) + outExt
+	}
+	taken[out] = true
+	return out
+}
+
+// extensionFormat maps a file extension to the format name reported by the
+// image decoders.
+func extensionFormat(ext string) string {
+	switch ext = strings.ToLower(strings.TrimPrefix(ext, ".")); ext {
+	case "jpg":
+		return "jpeg"
+	case "tif":
+		return "tiff"
+	default:
+		return ext
+	}
+}
+
+// writeBatchArchive streams the results as a ZIP archive with manifest.json
+// as its first entry.
+func writeBatchArchive(c *gin.Context, operation string, results []services.BatchResult) {
+	manifest := models.BatchManifest{
+		Operation: operation,
+		RequestID: RequestID(c),
+		Total:     len(results),
+		Files:     make([]models.BatchManifestFile, len(results)),
+	}
+	outputs := make([]string, len(results))
+	taken := map[string]bool{"manifest.json": true}
+	for i, res := range results {
+		entry := models.BatchManifestFile{Name: res.Name}
+		if res.Err != nil {
+			entry.Status = "failed"
+			entry.Error = res.Err.Message
+			entry.Code = string(res.Err.Code)
+			entry.Details = res.Err.Details
+			manifest.Failed++
+			if res.Err.HTTPStatus() >= http.StatusInternalServerError {
+				slog.ErrorContext(c.Request.Context(), "batch file failed", "file", res.Name, "code", res.Err.Code, "error", res.Err)
+			}
+		} else {
+			outputs[i] = batchOutputName(res.Name, res.Result.Format, taken)
+			entry.Status = "ok"
+			entry.Output = outputs[i]
+			entry.Format = res.Result.Format
+			entry.Width = res.Result.Width
+			entry.Height = res.Result.Height
+			entry.Bytes = len(res.Result.Data)
+			manifest.Succeeded++
+		}
+		manifest.Files[i] = entry
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="batch-%s.zip"`, RequestID(c)))
+	c.Header("X-Batch-Succeeded", strconv.Itoa(manifest.Succeeded))
+	c.Header("X-Batch-Failed", strconv.Itoa(manifest.Failed))
+	c.Status(http.StatusOK)
+
+	now := time.Now()
+	zw := zip.NewWriter(c.Writer)
+	err := func() error {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: "manifest.json", Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(manifest); err != nil {
+			return err
+		}
+
+		for i, res := range results {
+			if res.Result == nil {
+				continue
+			}
+			// Images are already compressed; storing them saves CPU for nothing lost
+			w, err := zw.CreateHeader(&zip.FileHeader{Name: outputs[i], Method: zip.Store, Modified: now})
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(res.Result.Data); err != nil {
+				return err
+			}
+		}
+		return zw.Close()
+	}()
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "writing batch archive", "error", err)
+	}
+}
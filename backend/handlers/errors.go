@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"image-editor-app/backend/models"
+	"image-editor-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// RequestIDKey is the gin context key holding the request ID.
+const RequestIDKey = "request_id"
+
+// ErrorCodeKey is the gin context key holding the error code of a failed request.
+const ErrorCodeKey = "error_code"
+
+// ClientKey is the gin context key holding the ID of the API key a request
+// was made with, if any.
+const ClientKey = "client"
+
+// WebhookSecretKey is the gin context key holding the secret that webhook
+// notifications to the client are signed with, for requests made with an
+// API key that has one.
+const WebhookSecretKey = "webhook_secret"
+
+// ChargeKey is the gin context key holding a func(units int64) *services.Error
+// that charges further cost units to the client, for requests whose cost is
+// only known once their body is read.
+const ChargeKey = "charge"
+
+// RequestID returns the ID assigned to the request by the request ID middleware.
+func RequestID(c *gin.Context) string {
+	return c.GetString(RequestIDKey)
+}
+
+// RespondError writes the JSON error envelope shared by all endpoints and
+// middleware: a human-readable message, a stable code, the request ID and
+// optional details. The progress of the request, if tracked, ends as failed.
+func RespondError(c *gin.Context, svcErr *services.Error) {
+	services.FinishProgress(c.Request.Context(), svcErr)
+	c.Set(ErrorCodeKey, string(svcErr.Code))
+	if svcErr.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(svcErr.RetryAfter.Seconds()))))
+	}
+	c.AbortWithStatusJSON(svcErr.HTTPStatus(), models.ErrorResponse{
+		Error:     svcErr.Message,
+		Code:      string(svcErr.Code),
+		RequestID: RequestID(c),
+		Details:   svcErr.Details,
+	})
+}
+
+// bindError responds to a request body that could not be bound or failed validation.
+func bindError(c *gin.Context, err error) {
+	RespondError(c, RequestError(err))
+}
+
+// RequestError converts an error binding or validating a request to a
+// service error, with the fields that failed validation in its details.
+func RequestError(err error) *services.Error {
+	return requestErrorAt("", err)
+}
+
+// requestErrorAt converts an error binding or validating the part of a
+// request at path, such as "steps[0].options", as RequestError does.
+func requestErrorAt(path string, err error) *services.Error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return &services.Error{
+			Code:    services.CodeTooLarge,
+			Message: "request body exceeds the maximum allowed size",
+			Details: map[string]any{"max_bytes": maxBytesErr.Limit},
+		}
+	}
+
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]gin.H, 0, len(validationErrs))
+		messages := make([]string, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			field := jsonFieldPath(fe.Namespace())
+			if path != "" {
+				field = path + "." + field
+			}
+			fields = append(fields, gin.H{"field": field, "rule": fe.Tag(), "param": fe.Param()})
+			messages = append(messages, fmt.Sprintf("%s failed %s validation", field, validationRule(fe)))
+		}
+		return &services.Error{
+			Code:    services.CodeInvalidInput,
+			Message: "invalid request: " + strings.Join(messages, "; "),
+			Details: map[string]any{"fields": fields},
+		}
+	}
+
+	message := err.Error()
+	if path != "" {
+		message = path + ": " + message
+	}
+	return &services.Error{Code: services.CodeInvalidInput, Message: "invalid request body: " + message}
+}
+
+// jsonFieldPath turns a validator namespace such as
+// "ImageInput.OutputOptions.metadata" into the JSON path "metadata".
+// Segments named after Go types (the request and embedded structs) are dropped.
+func jsonFieldPath(namespace string) string {
+	var parts []string
+	for _, part := range strings.Split(namespace, ".") {
+		if part != "" && !unicode.IsUpper(rune(part[0])) {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// validationRule formats a failed validation tag with its parameter, e.g. "max=100".
+func validationRule(fe validator.FieldError) string {
+	if fe.Param() == "" {
+		return fe.Tag()
+	}
+	return fe.Tag() + "=" + fe.Param()
+}
+
+// serviceError responds to an error returned by the services package.
+// The underlying cause of server-side failures is logged, not returned.
+func serviceError(c *gin.Context, err error) {
+	svcErr := services.AsError(err)
+	if svcErr.HTTPStatus() >= http.StatusInternalServerError {
+		slog.ErrorContext(c.Request.Context(), "request failed", "route", c.FullPath(), "code", svcErr.Code, "error", err)
+	}
+	RespondError(c, svcErr)
+}
+
+// chargeClient charges units to the client's rate limit and quota. It writes
+// the response and returns false when the client is over its limits.
+func chargeClient(c *gin.Context, units int64) bool {
+	charge, ok := c.Value(ChargeKey).(func(int64) *services.Error)
+	if !ok || units <= 0 {
+		return true
+	}
+	if svcErr := charge(units); svcErr != nil {
+		RespondError(c, svcErr)
+		return false
+	}
+	return true
+}
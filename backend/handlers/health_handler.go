@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"image-editor-app/backend/models"
+	"image-editor-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// shuttingDown is set once the server stops accepting new work, and
+// shutdown closed at the same time.
+var (
+	shuttingDown atomic.Bool
+	shutdown     = make(chan struct{})
+	shutdownOnce sync.Once
+)
+
+// BeginShutdown makes the readiness probe fail, so that load balancers stop
+// sending requests while those in flight drain, and ends progress streams,
+// which would otherwise hold up the drain.
+func BeginShutdown() {
+	shutdownOnce.Do(func() {
+		shuttingDown.Store(true)
+		close(shutdown)
+	})
+}
+
+// Livez handles the liveness probe: the process is up and serving requests.
+func Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, models.HealthResponse{Status: "ok"})
+}
+
+// Readyz handles the readiness probe: it fails while the server shuts down
+// or when a dependency of the operations is missing or unreachable.
+func Readyz(c *gin.Context) {
+	if shuttingDown.Load() {
+		c.JSON(http.StatusServiceUnavailable, models.HealthResponse{Status: "shutting_down"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+	defer cancel()
+	resp := models.HealthResponse{Status: "ready", Checks: map[string]string{}}
+	status := http.StatusOK
+	for _, check := range services.CheckReadiness(ctx) {
+		if check.Err == nil {
+			resp.Checks[check.Name] = "ok"
+			continue
+		}
+		// Only the safe message is returned; the cause, with paths and hosts, is logged
+		resp.Checks[check.Name] = services.AsError(check.Err).Message
+		resp.Status = "not_ready"
+		status = http.StatusServiceUnavailable
+		slog.WarnContext(c.Request.Context(), "readiness check failed", "check", check.Name, "error", check.Err)
+	}
+	c.JSON(status, resp)
+}
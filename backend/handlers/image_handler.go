@@ -1,161 +1,210 @@
 package handlers
 
 import (
+	"context"
+	"encoding/base64"
+	"log/slog"
 	"net/http"
+	"strings"
+	"time"
 
 	"image-editor-app/backend/models"
 	"image-editor-app/backend/services"
+	"image-editor-app/backend/tracing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-// Helper function for min (Go 1.21+ has built-in, but for broader compatibility)
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
+// operationRequest is a bound request: the image with its output settings,
+// the steps to apply to it and the cache key of the result.
+type operationRequest struct {
+	input models.ImageInput
+	steps services.Steps
+	key   string
 }
 
-// ResizeImage handles image resizing requests.
-func ResizeImage(c *gin.Context) {
-	var req models.ResizeRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	resizedImageBase64, err := services.ResizeImage(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"resized_image_base64": resizedImageBase64})
+// LegacyResponse is how a legacy route returns its result: the image in
+// base64 under an endpoint-specific key, and with the background
+// operations its format.
+type LegacyResponse struct {
+	Key        string
+	WithFormat bool
 }
 
-// CropImage handles image cropping requests.
-func CropImage(c *gin.Context) {
-	var req models.CropRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	croppedImageBase64, err := services.CropImage(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+// Operation returns the handler of the /v1 route of an operation.
+func Operation(op services.Operation) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		req, ok := bindOperation(c, op)
+		if !ok {
+			return
+		}
+		respondV1(c, req, start)
 	}
-
-	c.JSON(http.StatusOK, gin.H{"cropped_image_base64": croppedImageBase64})
 }
 
-// UpscaleImage handles image upscaling requests.
-func UpscaleImage(c *gin.Context) {
-	var req models.UpscaleRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+// LegacyOperation returns the handler of the unversioned route of an
+// operation, which writes the response shape of the legacy endpoints.
+func LegacyOperation(op services.Operation, legacy LegacyResponse) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req, ok := bindOperation(c, op)
+		if !ok {
+			return
+		}
+		result, ok := runBound(c, req)
+		if !ok {
+			return
+		}
+
+		body := gin.H{legacy.Key: base64.StdEncoding.EncodeToString(result.Data)}
+		if legacy.WithFormat {
+			body["format"] = result.Format
+		}
+		c.JSON(http.StatusOK, body)
+		services.FinishProgress(c.Request.Context(), nil)
 	}
+}
 
-	upscaledImageBase64, err := services.UpscaleImage(req)
+// bindOperation binds the JSON request body of an operation, the image and
+// output settings on one side and the operation's parameters on the other,
+// and returns it with the cache key of its result. It writes the response
+// itself and returns false on failure.
+func bindOperation(c *gin.Context, op services.Operation) (*operationRequest, bool) {
+	trackProgress(c)
+
+	req := &operationRequest{}
+	params := op.Params()
+	_, span := tracing.Start(c.Request.Context(), "bind")
+	err := c.ShouldBindBodyWith(&req.input, binding.JSON)
+	if err == nil {
+		err = c.ShouldBindBodyWith(params, binding.JSON)
+	}
+	tracing.End(span, err)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		bindError(c, err)
+		return nil, false
 	}
-
-	c.JSON(http.StatusOK, gin.H{"upscaled_image_base64": upscaledImageBase64})
+	if err := op.Validate(params); err != nil {
+		serviceError(c, err)
+		return nil, false
+	}
+	req.steps = services.Steps{{Operation: op, Params: params}}
+	return req, finishBinding(c, req, req.input, params)
 }
 
-// ConvertImage handles image conversion requests.
-func ConvertImage(c *gin.Context) {
-	var req models.ConvertRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+// finishBinding derives the cache key of a bound request from its parts.
+// It writes the response itself and returns false on failure.
+func finishBinding(c *gin.Context, req *operationRequest, parts ...any) bool {
+	services.ReportProgress(c.Request.Context(), services.StageReceived, -1)
 
-	convertedImageBase64, err := services.ConvertImage(req)
+	// Legacy and /v1 routes are cached apart since their bodies differ
+	key, err := services.CacheKey(c.FullPath(), parts...)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		serviceError(c, err)
+		return false
 	}
-
-	c.JSON(http.StatusOK, gin.H{"converted_image_base64": convertedImageBase64})
+	req.key = key
+	return true
 }
 
-// BlurImage handles image blurring requests for specific regions.
-func BlurImage(c *gin.Context) {
-	var req models.BlurRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	blurredImageBase64, err := services.BlurImage(req)
+// runBound runs a bound request through the result cache. It writes the
+// response itself and returns false on failure, or when the client already
+// holds the result.
+func runBound(c *gin.Context, req *operationRequest) (*services.Result, bool) {
+	// A download link is new on every response, so only inline results are
+	// cacheable by the client
+	etag := ""
+	if !deliversURL(req.input.OutputOptions) {
+		etag = `W/"` + req.key + `"`
+		if etagMatches(c.GetHeader("If-None-Match"), etag) {
+			setCacheHeaders(c, etag)
+			c.AbortWithStatus(http.StatusNotModified)
+			services.FinishProgress(c.Request.Context(), nil)
+			return nil, false
+		}
+	}
+
+	// The operation stops when the client goes away or its time is up
+	result, hit, err := execute(c.Request.Context(), c.FullPath(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		serviceError(c, err)
+		return nil, false
 	}
 
-	c.JSON(http.StatusOK, gin.H{"blurred_image_base64": blurredImageBase64})
-}
-
-// RemoveBackground handles image background removal requests.
-func RemoveBackground(c *gin.Context) {
-	var req models.RemoveBackgroundRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	if etag != "" {
+		setCacheHeaders(c, etag)
 	}
-
-	removedBgImageBase64, format, err := services.RemoveBackground(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	if hit {
+		c.Header("X-Cache", "HIT")
+	} else {
+		c.Header("X-Cache", "MISS")
 	}
+	return result, true
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"image_without_background_base64": removedBgImageBase64,
-		"format":                          format,
+// execute applies the steps of a request on route through the result
+// cache, within their time limit, and reports whether the result was
+// cached.
+func execute(ctx context.Context, route string, req *operationRequest) (*services.Result, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, req.steps.Timeout())
+	defer cancel()
+	ctx, span := tracing.Start(ctx, "operation", attribute.String("image.operation", route))
+	start := time.Now()
+	result, hit, err := services.Cached(ctx, req.key, func() (*services.Result, error) {
+		// Only the work actually done waits for capacity, not cache hits
+		release, err := req.steps.Admit(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		imgBytes, err := services.ReadImage(ctx, req.input.ImageBase64)
+		if err != nil {
+			return nil, err
+		}
+		return req.steps.Apply(ctx, imgBytes, req.input.OutputOptions)
 	})
+	span.SetAttributes(attribute.Bool("image.cache_hit", hit))
+	tracing.End(span, err)
+	if err != nil {
+		return nil, false, err
+	}
+	slog.InfoContext(ctx, "operation completed",
+		"operation", route,
+		"format", result.Format,
+		"width", result.Width,
+		"height", result.Height,
+		"bytes", len(result.Data),
+		"cache_hit", hit,
+		"duration_ms", time.Since(start).Milliseconds())
+	return result, hit, nil
 }
 
-// ChangeBackground handles image background change requests.
-func ChangeBackground(c *gin.Context) {
-	var req models.ChangeBackgroundRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	changedBgImageBase64, format, err := services.ChangeBackground(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
+// deliversURL reports whether a request asks for its result as a download
+// link, which results posted to a callback URL always are.
+func deliversURL(out models.OutputOptions) bool {
+	return out.Delivery == "url" || out.CallbackURL != ""
+}
 
-	// Return JSON with format information
-	c.JSON(http.StatusOK, gin.H{
-		"image_base64": changedBgImageBase64,
-		"format":       format,
-	})
+// setCacheHeaders marks a response as cacheable by the client. Results are
+// content-addressed, so the same request always yields the same image.
+func setCacheHeaders(c *gin.Context, etag string) {
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, max-age=86400")
 }
 
-// CompressImage handles image compression requests.
-func CompressImage(c *gin.Context) {
-	var req models.CompressRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+// etagMatches reports whether an If-None-Match header matches etag, using
+// the weak comparison that RFC 9110 prescribes for If-None-Match.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
 	}
-
-	compressedImageBase64, err := services.CompressImage(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
 	}
-
-	c.JSON(http.StatusOK, gin.H{"compressed_image_base64": compressedImageBase64})
+	return false
 }
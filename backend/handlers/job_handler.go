@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"net/http"
+
+	"image-editor-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJob handles GET /v1/jobs/:id: the status of a job started for a
+// request with a callback_url, with the log of its webhook deliveries. Jobs
+// are only visible to the API key that started them.
+func GetJob(c *gin.Context) {
+	job, err := services.GetJob(c.Param("id"), c.GetString(ClientKey))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"image-editor-app/backend/models"
+	"image-editor-app/backend/services"
+	"image-editor-app/backend/tracing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Pipeline handles /v1/pipeline requests: registered operations applied
+// one after another to an image, which is decoded once and encoded once.
+// The request is charged for the cost of every step.
+func Pipeline(c *gin.Context) {
+	start := time.Now()
+	trackProgress(c)
+
+	var body models.PipelineRequest
+	_, span := tracing.Start(c.Request.Context(), "bind")
+	err := c.ShouldBindJSON(&body)
+	tracing.End(span, err)
+	if err != nil {
+		bindError(c, err)
+		return
+	}
+
+	steps, svcErr := pipelineSteps(body.Steps)
+	if svcErr != nil {
+		RespondError(c, svcErr)
+		return
+	}
+	// The request was charged for one unit when it came in
+	if !chargeClient(c, steps.Cost()-1) {
+		return
+	}
+
+	req := &operationRequest{input: body.ImageInput, steps: steps}
+	if !finishBinding(c, req, body) {
+		return
+	}
+	respondV1(c, req, start)
+}
+
+// pipelineSteps looks up the operation of every step of a pipeline and
+// decodes and validates its options as the operation's parameters.
+func pipelineSteps(specs []models.PipelineStep) (services.Steps, *services.Error) {
+	steps := make(services.Steps, 0, len(specs))
+	for i, spec := range specs {
+		path := fmt.Sprintf("steps[%d]", i)
+		op, ok := services.LookupOperation(spec.Operation)
+		if !ok {
+			return nil, unknownOperation(path+".operation", spec.Operation)
+		}
+
+		params := op.Params()
+		if len(spec.Options) > 0 {
+			raw, err := json.Marshal(spec.Options)
+			if err == nil {
+				err = json.Unmarshal(raw, params)
+			}
+			if err != nil {
+				return nil, requestErrorAt(path+".options", err)
+			}
+		}
+		if err := binding.Validator.ValidateStruct(params); err != nil {
+			return nil, requestErrorAt(path+".options", err)
+		}
+		if err := op.Validate(params); err != nil {
+			svcErr := *services.AsError(err)
+			svcErr.Message = path + ": " + svcErr.Message
+			return nil, &svcErr
+		}
+		steps = append(steps, services.Step{Operation: op, Params: params})
+	}
+	return steps, nil
+}
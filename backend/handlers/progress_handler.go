@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"image-editor-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// progressStreamTimeout bounds how long a progress stream stays open.
+	progressStreamTimeout = 10 * time.Minute
+
+	// progressKeepAlive is how often an idle progress stream sends a
+	// comment, so that proxies do not close it.
+	progressKeepAlive = 15 * time.Second
+
+	// maxProgressID is the length of the longest request ID the server accepts.
+	maxProgressID = 64
+)
+
+// trackProgress makes the operations of the request report their progress
+// under its request ID.
+func trackProgress(c *gin.Context) {
+	c.Request = c.Request.WithContext(services.TrackProgress(c.Request.Context(), RequestID(c)))
+}
+
+// Progress handles GET /v1/progress/:id: a Server-Sent Events stream of the
+// progress of the request with that request ID. Clients choose the ID, send
+// it in X-Request-ID and may open the stream before sending the request.
+// Each event is a ProgressEvent as JSON, the latest whenever it changes; the
+// stream ends after the "done" or "failed" event.
+func Progress(c *gin.Context) {
+	id := c.Param("id")
+	if len(id) > maxProgressID {
+		RespondError(c, &services.Error{Code: services.CodeInvalidInput, Message: "invalid request ID"})
+		return
+	}
+
+	watcher := services.WatchProgress(id)
+	defer watcher.Close()
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), progressStreamTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-shutdown:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	h := c.Writer.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no") // Stop nginx from buffering the stream
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	for {
+		waitCtx, cancelWait := context.WithTimeout(ctx, progressKeepAlive)
+		event, final, err := watcher.Next(waitCtx)
+		cancelWait()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			fmt.Fprint(c.Writer, ": keep-alive\n\n")
+			c.Writer.Flush()
+			continue
+		}
+
+		data, err := json.Marshal(event)
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(c.Writer, "data: %s\n\n", data)
+		c.Writer.Flush()
+		if final {
+			return
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"image-editor-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetResult serves a stored result through the signed link returned for
+// requests with delivery "url". Browsers display it inline unless the link
+// has download=1.
+func GetResult(c *gin.Context) {
+	expires := c.Query("expires")
+	obj, body, err := services.OpenResult(c.Request.Context(), c.Param("id"), expires, c.Query("signature"))
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	defer body.Close()
+
+	disposition := "inline"
+	if c.Query("download") == "1" {
+		disposition = "attachment"
+	}
+	// Links are only valid until they expire, so caches must not keep them longer
+	maxAge := int64(0)
+	if expiresUnix, err := strconv.ParseInt(expires, 10, 64); err == nil {
+		maxAge = max(expiresUnix-time.Now().Unix(), 0)
+	}
+
+	c.DataFromReader(http.StatusOK, obj.Size, obj.ContentType, body, map[string]string{
+		"Content-Disposition": mime.FormatMediaType(disposition, map[string]string{"filename": obj.Filename}),
+		"Cache-Control":       "private, max-age=" + strconv.FormatInt(maxAge, 10),
+	})
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"image-editor-app/backend/models"
+	"image-editor-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondV1 runs a bound request and writes the uniform /v1 response
+// envelope. Requests with a callback_url are answered at once with the
+// pending job.
+func respondV1(c *gin.Context, req *operationRequest, start time.Time) {
+	if req.input.CallbackURL != "" {
+		startJob(c, req)
+		return
+	}
+	result, ok := runBound(c, req)
+	if !ok {
+		return
+	}
+
+	resp := newImageResponse(result, time.Since(start))
+	if deliversURL(req.input.OutputOptions) {
+		if err := storeForDownload(c.Request.Context(), &resp, result, c.FullPath(), publicBaseURL(c)); err != nil {
+			serviceError(c, err)
+			return
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+	services.FinishProgress(c.Request.Context(), nil)
+}
+
+// startJob runs a bound request in the background, to post its result to
+// the request's callback URL, and responds with the pending job.
+func startJob(c *gin.Context, req *operationRequest) {
+	route, base := c.FullPath(), publicBaseURL(c)
+	job, err := services.StartJob(c.Request.Context(), services.JobRequest{
+		Operation:   strings.TrimPrefix(route, "/v1/"),
+		RequestID:   RequestID(c),
+		Client:      c.GetString(ClientKey),
+		CallbackURL: req.input.CallbackURL,
+		Secret:      c.GetString(WebhookSecretKey),
+	}, func(ctx context.Context) (*models.ImageResponse, error) {
+		start := time.Now()
+		result, _, err := execute(ctx, route, req)
+		if err != nil {
+			return nil, err
+		}
+		resp := newImageResponse(result, time.Since(start))
+		if err := storeForDownload(ctx, &resp, result, route, base); err != nil {
+			return nil, err
+		}
+		return &resp, nil
+	})
+	if err != nil {
+		serviceError(c, err)
+		return
+	}
+	c.Header("Location", "/v1/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// storeForDownload stores a result and replaces the image in its response
+// with a download link under base.
+func storeForDownload(ctx context.Context, resp *models.ImageResponse, result *services.Result, route, base string) error {
+	stored, err := services.StoreResult(ctx, result, strings.TrimPrefix(route, "/v1/"))
+	if err != nil {
+		return err
+	}
+	resp.ImageBase64 = ""
+	resp.URL = base + stored.Path
+	resp.ExpiresAt = &stored.ExpiresAt
+	return nil
+}
+
+// publicBaseURL returns the URL the API is reached at: the configured one,
+// or else the one the client used.
+func publicBaseURL(c *gin.Context) string {
+	if base := services.ResultBaseURL(); base != "" {
+		return strings.TrimRight(base, "/")
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// newImageResponse builds the /v1 response envelope for a result.
+func newImageResponse(result *services.Result, elapsed time.Duration) models.ImageResponse {
+	return models.ImageResponse{
+		ImageBase64:  base64.StdEncoding.EncodeToString(result.Data),
+		MIMEType:     result.MIMEType(),
+		Format:       result.Format,
+		Width:        result.Width,
+		Height:       result.Height,
+		Bytes:        len(result.Data),
+		ProcessingMS: elapsed.Milliseconds(),
+	}
+}
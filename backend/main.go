@@ -1,16 +1,147 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"image-editor-app/backend/handlers"
 	"image-editor-app/backend/server"
+	"image-editor-app/backend/services"
+	"image-editor-app/backend/tracing"
+
+	"google.golang.org/grpc"
 )
 
 func main() {
+	// SIGTERM is what deploys and systemd send; the first one starts a
+	// graceful shutdown and a second one kills the process
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx)
+	if err != nil {
+		slog.Error("tracing is disabled", "error", err)
+		shutdownTracing = func(context.Context) error { return nil }
+	}
+
 	router := server.SetupRouter()
+
+	// Delete stored results once their links have expired
+	go services.SweepResults(ctx)
+
+	// Requests run under requestCtx, so that those still running when the
+	// drain timeout is up can be canceled, stopping their Python subprocesses
+	requestCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
 	port := ":8080"
-	log.Printf("Server starting on port %s", port)
-	if err := router.Run(port); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+	srv := &http.Server{
+		Addr:              port,
+		Handler:           router,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return requestCtx },
+	}
+
+	serveErr := make(chan error, 2)
+	go func() {
+		slog.Info("server starting", "addr", port)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	// The gRPC API, alongside the REST API when GRPC_ADDR is set
+	var grpcServer *grpc.Server
+	if addr := grpcAddr(); addr != "" {
+		lis, err := net.Listen("tcp", addr)
+		if err != nil {
+			slog.Error("gRPC server failed to start", "error", err)
+			os.Exit(1)
+		}
+		grpcServer = server.SetupGRPC()
+		go func() {
+			slog.Info("gRPC server starting", "addr", addr)
+			serveErr <- grpcServer.Serve(lis)
+		}()
+	}
+
+	select {
+	case err := <-serveErr:
+		slog.Error("server failed to start", "error", err)
+		os.Exit(1)
+	case <-ctx.Done():
+		stop()
+	}
+
+	drainTimeout := shutdownTimeout()
+	slog.Info("shutting down, draining requests", "timeout", drainTimeout.String())
+	handlers.BeginShutdown()
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	// gRPC calls drain at the same time as HTTP requests
+	grpcStopped := make(chan struct{})
+	go func() {
+		if grpcServer != nil {
+			grpcServer.GracefulStop()
+		}
+		close(grpcStopped)
+	}()
+	if err := srv.Shutdown(drainCtx); errors.Is(err, context.DeadlineExceeded) {
+		slog.Warn("canceling requests still running after the drain timeout")
+		cancelRequests()
+
+		// Canceled requests end as soon as their subprocesses have exited
+		finalCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(finalCtx); err != nil {
+			srv.Close()
+		}
+	}
+
+	select {
+	case <-grpcStopped:
+	case <-drainCtx.Done():
+		if grpcServer != nil {
+			slog.Warn("canceling gRPC calls still running after the drain timeout")
+			grpcServer.Stop()
+		}
+		<-grpcStopped
+	}
+
+	// Background jobs share what is left of the drain timeout
+	services.StopJobs(drainCtx)
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Warn("flushing traces", "error", err)
+	}
+	slog.Info("server stopped")
+}
+
+// grpcAddr returns the address the gRPC server listens on, GRPC_ADDR, e.g.,
+// ":9090". The gRPC server is opt-in: without GRPC_ADDR, only the HTTP
+// server runs.
+func grpcAddr() string {
+	return os.Getenv("GRPC_ADDR")
+}
+
+// shutdownTimeout returns how long in-flight requests may take to finish on
+// shutdown: SHUTDOWN_TIMEOUT as a Go duration, or 30 seconds.
+func shutdownTimeout() time.Duration {
+	if raw := os.Getenv("SHUTDOWN_TIMEOUT"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err == nil && d > 0 {
+			return d
+		}
+		slog.Warn("ignoring invalid environment variable", "name", "SHUTDOWN_TIMEOUT", "value", raw)
 	}
-}
\ No newline at end of file
+	return 30 * time.Second
+}
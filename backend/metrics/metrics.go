@@ -0,0 +1,191 @@
+// Package metrics defines the Prometheus metrics of the image API. Metrics
+// are registered on Registry, which the server exposes at /metrics.
+package metrics
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Registry holds every metric of the API, plus the Go runtime and process
+// metrics.
+var Registry = prometheus.NewRegistry()
+
+// Byte size buckets from 1KB to 64MB.
+var sizeBuckets = prometheus.ExponentialBuckets(1024, 4, 9)
+
+var (
+	// Requests counts requests by route, method and status code.
+	Requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "image_api_requests_total",
+		Help: "Requests handled, by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	// Errors counts error responses by route and error code.
+	Errors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "image_api_errors_total",
+		Help: "Error responses, by route and error code.",
+	}, []string{"route", "code"})
+
+	// RequestDuration observes request latency by route.
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "image_api_request_duration_seconds",
+		Help:    "Time to handle a request, by route.",
+		Buckets: []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"route"})
+
+	// RequestBytes observes request body sizes by route.
+	RequestBytes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "image_api_request_bytes",
+		Help:    "Size of request bodies, by route.",
+		Buckets: sizeBuckets,
+	}, []string{"route"})
+
+	// ResponseBytes observes response body sizes by route.
+	ResponseBytes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "image_api_response_bytes",
+		Help:    "Size of response bodies, by route.",
+		Buckets: sizeBuckets,
+	}, []string{"route"})
+
+	// DecodedMegapixels observes the size of decoded input images.
+	DecodedMegapixels = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "image_decoded_megapixels",
+		Help:    "Size of decoded input images in megapixels.",
+		Buckets: []float64{0.1, 0.5, 1, 2, 4, 8, 12, 16, 24, 32, 50},
+	})
+
+	// PythonDuration observes the run time of Python subprocesses by operation.
+	PythonDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "image_python_duration_seconds",
+		Help:    "Run time of Python subprocesses, by operation.",
+		Buckets: []float64{0.25, 0.5, 1, 2, 4, 8, 15, 30, 60, 120},
+	}, []string{"operation"})
+
+	// PythonFailures counts failed Python subprocesses by operation.
+	PythonFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "image_python_failures_total",
+		Help: "Python subprocesses that failed, by operation.",
+	}, []string{"operation"})
+
+	// BatchQueueDepth is the number of batch files waiting for a worker.
+	BatchQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "image_batch_queue_depth",
+		Help: "Batch files waiting for a worker.",
+	})
+
+	// BatchWorkersBusy is the number of batch workers processing a file.
+	BatchWorkersBusy = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "image_batch_workers_busy",
+		Help: "Batch workers processing a file.",
+	})
+
+	// AdmissionCapacity is the number of cost units that may be processed at once.
+	AdmissionCapacity = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "image_admission_capacity",
+		Help: "Cost units of image processing that may run at once.",
+	})
+
+	// AdmissionInUse is the number of cost units held by running operations.
+	AdmissionInUse = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "image_admission_in_use",
+		Help: "Cost units held by running image operations.",
+	})
+
+	// AdmissionQueued is the number of operations waiting to be admitted.
+	AdmissionQueued = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "image_admission_queued",
+		Help: "Image operations waiting to be admitted.",
+	})
+
+	// AdmissionWait observes how long operations waited to be admitted.
+	AdmissionWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "image_admission_wait_seconds",
+		Help:    "Time image operations waited to be admitted, by operation.",
+		Buckets: []float64{0.001, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"operation"})
+
+	// AdmissionRejected counts operations turned away because the server was
+	// saturated, by operation.
+	AdmissionRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "image_admission_rejected_total",
+		Help: "Image operations rejected because the server was saturated, by operation.",
+	}, []string{"operation"})
+
+	// CacheLookups counts result cache lookups by result, "hit" or "miss".
+	// Record lookups with CacheLookup to keep image_cache_hit_ratio current.
+	CacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "image_cache_lookups_total",
+		Help: "Result cache lookups, by result (hit or miss).",
+	}, []string{"result"})
+
+	// GRPCRequests counts gRPC calls by method and status code, e.g.,
+	// "InvalidArgument".
+	GRPCRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "image_grpc_requests_total",
+		Help: "gRPC calls handled, by method and status code.",
+	}, []string{"method", "code"})
+
+	// GRPCDuration observes the duration of gRPC calls by method.
+	GRPCDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "image_grpc_duration_seconds",
+		Help:    "Time to handle a gRPC call, by method.",
+		Buckets: []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300},
+	}, []string{"method"})
+
+	// JobsRunning is the number of background jobs, run for requests with a
+	// callback URL, whose operation has not finished.
+	JobsRunning = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "image_jobs_running",
+		Help: "Background jobs whose operation has not finished.",
+	})
+
+	// WebhookAttempts counts attempts at delivering webhook notifications by
+	// outcome: "delivered", "retry" or "failed".
+	WebhookAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "image_webhook_attempts_total",
+		Help: "Attempts at delivering webhook notifications, by outcome (delivered, retry or failed).",
+	}, []string{"outcome"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		Requests, Errors, RequestDuration, RequestBytes, ResponseBytes,
+		DecodedMegapixels, PythonDuration, PythonFailures,
+		BatchQueueDepth, BatchWorkersBusy, CacheLookups,
+		AdmissionCapacity, AdmissionInUse, AdmissionQueued, AdmissionWait, AdmissionRejected,
+		GRPCRequests, GRPCDuration, JobsRunning, WebhookAttempts,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "image_cache_hit_ratio",
+			Help: "Share of result cache lookups that were hits since the process started.",
+		}, cacheHitRatio),
+	)
+}
+
+// cacheHits and cacheLookups back image_cache_hit_ratio, since counters
+// cannot be read back cheaply.
+var cacheHits, cacheLookups atomic.Uint64
+
+// CacheLookup records a result cache lookup.
+func CacheLookup(hit bool) {
+	cacheLookups.Add(1)
+	if hit {
+		cacheHits.Add(1)
+		CacheLookups.WithLabelValues("hit").Inc()
+	} else {
+		CacheLookups.WithLabelValues("miss").Inc()
+	}
+}
+
+// cacheHitRatio returns the share of lookups that were hits, 0 before any lookup.
+func cacheHitRatio() float64 {
+	lookups := cacheLookups.Load()
+	if lookups == 0 {
+		return 0
+	}
+	return float64(cacheHits.Load()) / float64(lookups)
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// CreateAPIKeyRequest defines the request body for issuing an API key. Limits
+// left out follow the default key policy.
+type CreateAPIKeyRequest struct {
+	Name       string  `json:"name" binding:"required,max=100"`      // Who or what the key is for
+	Rate       float64 `json:"rate" binding:"omitempty,gt=0"`        // Optional: cost units refilled per second
+	Burst      int64   `json:"burst" binding:"omitempty,gt=0"`       // Optional: cost units that may be spent at once
+	DailyQuota int64   `json:"daily_quota" binding:"omitempty,gt=0"` // Optional: cost units per UTC day
+}
+
+// APIKey describes an issued API key. The key itself and its webhook secret
+// are only returned when it is issued.
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Key        string     `json:"key,omitempty"` // Secret to send as a Bearer token or in X-API-Key
+	Created    time.Time  `json:"created"`
+	Revoked    *time.Time `json:"revoked,omitempty"`
+	Rate       float64    `json:"rate,omitempty"`
+	Burst      int64      `json:"burst,omitempty"`
+	DailyQuota int64      `json:"daily_quota,omitempty"`
+
+	WebhookSecret string `json:"webhook_secret,omitempty"` // Key that webhook notifications to callback_url are signed with
+}
+
+// APIKeyList defines the response body listing the issued API keys.
+type APIKeyList struct {
+	Keys []APIKey `json:"keys"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "mime/multipart"
+
+// BatchRequest defines the multipart form of a batch request.
+type BatchRequest struct {
+	Operation string                  `json:"operation" form:"operation" binding:"required"`
+	Options   string                  `json:"options" form:"options"`                // Optional: JSON object with the operation's parameters and output settings
+	Files     []*multipart.FileHeader `json:"files" form:"files" binding:"required"` // Images, or ZIP archives of images
+}
+
+// BatchManifest describes the contents of a batch result archive. It is
+// stored in the archive as manifest.json.
+type BatchManifest struct {
+	Operation string              `json:"operation"`
+	RequestID string              `json:"request_id"`
+	Total     int                 `json:"total"`
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+	Files     []BatchManifestFile `json:"files"`
+}
+
+// BatchManifestFile is the status of one input file of a batch.
+type BatchManifestFile struct {
+	Name    string         `json:"name"`             // Name of the input file
+	Status  string         `json:"status"`           // "ok" or "failed"
+	Output  string         `json:"output,omitempty"` // Name of the result in the archive
+	Format  string         `json:"format,omitempty"`
+	Width   int            `json:"width,omitempty"`
+	Height  int            `json:"height,omitempty"`
+	Bytes   int            `json:"bytes,omitempty"`
+	Error   string         `json:"error,omitempty"`   // Human-readable message of a failure
+	Code    string         `json:"code,omitempty"`    // Stable error code of a failure
+	Details map[string]any `json:"details,omitempty"` // Optional: structured information about a failure
+}
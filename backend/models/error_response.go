@@ -0,0 +1,9 @@
+package models
+
+// ErrorResponse defines the response body of every failed request.
+type ErrorResponse struct {
+	Error     string         `json:"error"`             // Human-readable message
+	Code      string         `json:"code"`              // Stable error code, e.g., "invalid_input"
+	RequestID string         `json:"request_id"`        // ID from the X-Request-ID response header
+	Details   map[string]any `json:"details,omitempty"` // Optional: structured information about the error
+}
@@ -0,0 +1,7 @@
+package models
+
+// HealthResponse defines the response body of the liveness and readiness probes.
+type HealthResponse struct {
+	Status string            `json:"status"`           // "ok", "ready", "not_ready" or "shutting_down"
+	Checks map[string]string `json:"checks,omitempty"` // Outcome of each readiness check: "ok" or what is wrong
+}
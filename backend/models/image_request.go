@@ -1,65 +1,155 @@
 package models
 
-// ResizeRequest defines the structure for an image resize request.
-type ResizeRequest struct {
-	ImageBase64 string `json:"image_base64" binding:"required"`
-	Width       int    `json:"width"`
-	Height      int    `json:"height"`
-	Preset      string `json:"preset"` // e.g., "youtube_thumbnail", "instagram_story"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// OutputOptions defines the output settings shared by all image requests.
+type OutputOptions struct {
+	Metadata      string `json:"metadata" binding:"omitempty,oneof=keep strip strip_gps keep_copyright_only"` // Optional: keep, strip (default), strip_gps, keep_copyright_only
+	OutputProfile string `json:"output_profile" binding:"omitempty,oneof=srgb display_p3 adobe_rgb"`          // Optional: srgb (default), display_p3, adobe_rgb
+	EmbedProfile  bool   `json:"embed_profile"`                                                               // Optional: embed the output ICC profile (always done for non-sRGB output)
+	Delivery      string `json:"delivery" binding:"omitempty,oneof=inline url"`                               // Optional: inline (default) returns the image in the response, url a signed download link (/v1 only)
+	CallbackURL   string `json:"callback_url" binding:"omitempty,url,max=2048"`                               // Optional: run in the background and post the result, as a download link, to this URL (/v1 only)
 }
 
-// CropRequest defines the structure for an image crop request.
-type CropRequest struct {
+// ImageInput is what every operation request carries besides the
+// operation's parameters: the image and the output settings. The request
+// body of an operation is a single JSON object with the fields of both.
+type ImageInput struct {
 	ImageBase64 string `json:"image_base64" binding:"required"`
-	X           int    `json:"x"`
-	Y           int    `json:"y"`
-	Width       int    `json:"width"`
-	Height      int    `json:"height"`
+	OutputOptions
 }
 
-// UpscaleRequest defines the structure for an image upscale request.
-type UpscaleRequest struct {
-	ImageBase64 string  `json:"image_base64" binding:"required"`
-	ScaleFactor float64 `json:"scale_factor" binding:"required"` // e.g., 2.0 for 2x upscale
+// The parameters of the image operations. Their doc tags describe the
+// fields in the OpenAPI document and in imgtool's flags.
+
+// ResizeParams defines the parameters of a resize.
+type ResizeParams struct {
+	Width  int    `json:"width" binding:"gte=0" doc:"target width; 0 keeps the aspect ratio"`
+	Height int    `json:"height" binding:"gte=0" doc:"target height; 0 keeps the aspect ratio"`
+	Preset string `json:"preset" binding:"omitempty,oneof=youtube_thumbnail instagram_story instagram_post_cover twitter_post facebook_story facebook_post" doc:"social media preset, e.g., youtube_thumbnail"`
 }
 
-// ConvertRequest defines the structure for an image conversion request.
-type ConvertRequest struct {
-	ImageBase64 string `json:"image_base64" binding:"required"`
-	Format      string `json:"format" binding:"required"` // e.g., "jpeg", "png", "gif"
+// CropParams defines the parameters of a crop.
+type CropParams struct {
+	X      int `json:"x" binding:"gte=0" doc:"left edge of the crop rectangle"`
+	Y      int `json:"y" binding:"gte=0" doc:"top edge of the crop rectangle"`
+	Width  int `json:"width" binding:"gt=0" doc:"width of the crop rectangle"`
+	Height int `json:"height" binding:"gt=0" doc:"height of the crop rectangle"`
+}
+
+// UpscaleParams defines the parameters of an upscale.
+type UpscaleParams struct {
+	ScaleFactor float64 `json:"scale_factor" binding:"required,gt=0" doc:"upscale factor, e.g., 2 for 2x"`
+}
+
+// ConvertParams defines the parameters of a conversion.
+type ConvertParams struct {
+	Format string `json:"format" binding:"required,oneof=jpeg jpg png gif bmp tiff webp" doc:"output format: jpeg, png, gif, bmp, tiff or webp"`
 }
 
 // Point defines a coordinate for drawing paths.
 type Point struct {
-	X int `json:"x"`
-	Y int `json:"y"`
+	X int `json:"x" binding:"gte=0"`
+	Y int `json:"y" binding:"gte=0"`
 }
 
-// BlurRequest defines the structure for an image blur request with a path.
-type BlurRequest struct {
-	ImageBase64 string  `json:"image_base64" binding:"required"`
-	Path        []Point `json:"path" binding:"required"` // List of points defining the blur path
-	Radius      float64 `json:"radius" binding:"required"`  // Blur radius
+// Path is a list of points. It implements flag.Value, reading points
+// written as "x,y;x,y".
+type Path []Point
+
+// String formats the path as "x,y;x,y".
+func (p *Path) String() string {
+	if p == nil {
+		return ""
+	}
+	pairs := make([]string, len(*p))
+	for i, point := range *p {
+		pairs[i] = fmt.Sprintf("%d,%d", point.X, point.Y)
+	}
+	return strings.Join(pairs, ";")
 }
 
-// RemoveBackgroundRequest defines the structure for a background removal request.
-type RemoveBackgroundRequest struct {
-	ImageBase64 string `json:"image_base64" binding:"required"`
+// Set parses points written as "x,y;x,y".
+func (p *Path) Set(s string) error {
+	var points Path
+	for _, pair := range strings.Split(s, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		xs, ys, ok := strings.Cut(pair, ",")
+		x, errX := strconv.Atoi(strings.TrimSpace(xs))
+		y, errY := strconv.Atoi(strings.TrimSpace(ys))
+		if !ok || errX != nil || errY != nil {
+			return fmt.Errorf("invalid point %q, want x,y", pair)
+		}
+		points = append(points, Point{X: x, Y: y})
+	}
+	*p = points
+	return nil
 }
 
-// ChangeBackgroundRequest defines the structure for a background change request.
-type ChangeBackgroundRequest struct {
-	ImageBase64        string `json:"image_base64" binding:"required"`
-	NewBackgroundImage string `json:"new_background_image_base64"` // Optional: base64 encoded image
-	SolidColor         string `json:"solid_color"`                 // Optional: Solid color in "R,G,B" format (e.g., "255,0,0" for red)
-    Transparent        bool   `json:"transparent"`                 // Optional: If true, return transparent PNG (no background)
+// BlurParams defines the parameters of a blur along a path.
+type BlurParams struct {
+	Path   Path    `json:"path" binding:"required,min=1,dive" doc:"points to blur around, as \"x,y;x,y;...\" on the command line"`
+	Radius float64 `json:"radius" binding:"required,gt=0,lte=100" doc:"blur radius (up to 100)"`
 }
 
-// CompressRequest defines the structure for an image compression request.
-type CompressRequest struct {
-	ImageBase64 string  `json:"image_base64" binding:"required"`
-	Quality     *int    `json:"quality"`     // Optional: JPEG/WebP quality (1-100), default varies by format
-	Format      *string `json:"format"`      // Optional: Force output format (jpeg, png, webp, etc.)
-	MaxWidth    *int    `json:"max_width"`   // Optional: Resize if width exceeds this
-	MaxHeight   *int    `json:"max_height"`  // Optional: Resize if height exceeds this
-}
\ No newline at end of file
+// RemoveBackgroundParams defines the parameters of a background removal,
+// which has none.
+type RemoveBackgroundParams struct{}
+
+// ChangeBackgroundParams defines the parameters of a background change.
+type ChangeBackgroundParams struct {
+	NewBackgroundImage string `json:"new_background_image_base64" doc:"new background image"`
+	SolidColor         string `json:"solid_color" binding:"omitempty,rgb_triplet" doc:"solid background color as \"R,G,B\", e.g., \"255,0,0\" for red"`
+	Transparent        bool   `json:"transparent" doc:"return a transparent PNG without background"`
+}
+
+// CompressParams defines the parameters of a compression.
+type CompressParams struct {
+	Quality   *int    `json:"quality" binding:"omitempty,min=1,max=100" doc:"JPEG quality (1-100), 85 by default; WebP is always lossless"`
+	Format    *string `json:"format" binding:"omitempty,oneof=jpeg jpg png gif bmp tiff webp" doc:"output format; defaults to the input format"`
+	MaxWidth  *int    `json:"max_width" binding:"omitempty,gt=0" doc:"downscale images wider than this"`
+	MaxHeight *int    `json:"max_height" binding:"omitempty,gt=0" doc:"downscale images taller than this"`
+}
+
+// WatermarkParams defines the parameters of a watermark: a text or a logo
+// placed at an anchor, or repeated diagonally across the image when tiled.
+type WatermarkParams struct {
+	Text          string   `json:"text" binding:"max=500" doc:"text to write; lines are separated by \"\\n\""`
+	Font          string   `json:"font" binding:"omitempty,oneof=regular bold italic bold-italic mono mono-bold" doc:"bundled font: regular (default), bold, italic, bold-italic, mono or mono-bold"`
+	FontFile      string   `json:"font_file_base64" doc:"TrueType or OpenType font to write the text in, instead of a bundled one"`
+	FontSize      float64  `json:"font_size" binding:"gte=0,lte=1000" doc:"font size in pixels; defaults to 5% of the image width"`
+	Color         string   `json:"color" binding:"omitempty,rgb_triplet" doc:"text color as \"R,G,B\"; defaults to white"`
+	StrokeWidth   float64  `json:"stroke_width" binding:"gte=0,lte=50" doc:"width of the outline around the text in pixels"`
+	StrokeColor   string   `json:"stroke_color" binding:"omitempty,rgb_triplet" doc:"outline color as \"R,G,B\"; defaults to black"`
+	ShadowOffsetX int      `json:"shadow_offset_x" binding:"gte=-100,lte=100" doc:"horizontal offset of the text shadow in pixels; no shadow if both offsets are 0"`
+	ShadowOffsetY int      `json:"shadow_offset_y" binding:"gte=-100,lte=100" doc:"vertical offset of the text shadow in pixels"`
+	ShadowBlur    float64  `json:"shadow_blur" binding:"gte=0,lte=50" doc:"blur of the text shadow"`
+	ShadowColor   string   `json:"shadow_color" binding:"omitempty,rgb_triplet" doc:"shadow color as \"R,G,B\"; defaults to black"`
+	Logo          string   `json:"logo_base64" doc:"logo image to place instead of a text"`
+	LogoScale     float64  `json:"logo_scale" binding:"gte=0,lte=1" doc:"logo width relative to the image width; defaults to 0.2"`
+	Opacity       *float64 `json:"opacity" binding:"omitempty,gte=0,lte=1" doc:"opacity of the watermark from 0 to 1; defaults to 0.5"`
+	Rotation      *float64 `json:"rotation" binding:"omitempty,gte=-360,lte=360" doc:"counterclockwise rotation in degrees; defaults to 0, or 30 when tiled"`
+	Anchor        string   `json:"anchor" binding:"omitempty,oneof=top-left top top-right left center right bottom-left bottom bottom-right" doc:"position of the watermark: top-left, top, top-right, left, center, right, bottom-left, bottom or bottom-right (default)"`
+	Margin        *int     `json:"margin" binding:"omitempty,gte=0" doc:"distance from the edges in pixels; defaults to 2% of the image width"`
+	Tiled         bool     `json:"tiled" doc:"repeat the watermark diagonally across the whole image instead of placing it once"`
+	TileSpacing   *int     `json:"tile_spacing" binding:"omitempty,gte=0" doc:"space between tiled watermarks in pixels; defaults to half their height"`
+}
+
+// PipelineStep is one operation of a pipeline.
+type PipelineStep struct {
+	Operation string         `json:"operation" binding:"required" doc:"name of the operation, as in its route, e.g., resize"`
+	Options   map[string]any `json:"options" doc:"parameters of the operation, as in the body of its route but without the image and output settings"`
+}
+
+// PipelineRequest defines the structure for a pipeline request: operations
+// applied one after another, with the image encoded once at the end.
+type PipelineRequest struct {
+	ImageInput
+	Steps []PipelineStep `json:"steps" binding:"required,min=1,max=10,dive"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// ImageResponse defines the response body shared by all /v1 image operations.
+// Results requested with delivery "url" carry a download link instead of the image.
+type ImageResponse struct {
+	ImageBase64  string     `json:"image_base64,omitempty"`
+	URL          string     `json:"url,omitempty"`        // Signed download link of the image
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"` // When the download link expires
+	MIMEType     string     `json:"mime_type"`
+	Format       string     `json:"format"` // e.g., "jpeg", "png"
+	Width        int        `json:"width"`
+	Height       int        `json:"height"`
+	Bytes        int        `json:"bytes"`         // Size of the encoded image
+	ProcessingMS int64      `json:"processing_ms"` // Time spent processing the request
+}
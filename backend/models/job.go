@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Job describes an operation run in the background for a request with a
+// callback_url. When it completes, the job, without its delivery log, is
+// posted to the callback URL as a signed webhook notification.
+type Job struct {
+	ID          string         `json:"id"`
+	Operation   string         `json:"operation"`  // e.g., "remove-background"
+	Status      string         `json:"status"`     // "pending", "succeeded" or "failed"
+	RequestID   string         `json:"request_id"` // ID of the request, under which /v1/progress/{id} follows the job
+	CreatedAt   time.Time      `json:"created_at"`
+	CompletedAt *time.Time     `json:"completed_at,omitempty"`
+	Result      *ImageResponse `json:"result,omitempty"` // Download link and description of the image, when succeeded
+	Error       *ErrorResponse `json:"error,omitempty"`  // Why the job failed
+	CallbackURL string         `json:"callback_url"`
+
+	DeliveryStatus string            `json:"delivery_status,omitempty"` // "pending", "delivered" or "failed"
+	Deliveries     []WebhookDelivery `json:"deliveries,omitempty"`      // Attempts at delivering the notification, oldest first
+}
+
+// WebhookDelivery is one attempt at delivering the notification of a job.
+type WebhookDelivery struct {
+	Attempt       int        `json:"attempt"` // From 1
+	AttemptedAt   time.Time  `json:"attempted_at"`
+	DurationMS    int64      `json:"duration_ms"`
+	StatusCode    int        `json:"status_code,omitempty"`     // Status the receiver responded with, if any
+	Error         string     `json:"error,omitempty"`           // Why the attempt failed
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"` // When the attempt is retried
+}
@@ -0,0 +1,12 @@
+package models
+
+// ProgressEvent defines an event of the progress stream of a request.
+type ProgressEvent struct {
+	// "received", "queued", "decoding", the operation's own stages such as
+	// "resizing" or "removing_background", "encoding", and finally "done"
+	// or "failed"
+	Stage   string `json:"stage"`
+	Percent *int   `json:"percent,omitempty"` // Progress of the stage, where known
+	Code    string `json:"code,omitempty"`    // Error code, when failed
+	Message string `json:"message,omitempty"` // Error message, when failed
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// rgbTripletPattern matches colors in "R,G,B" format.
+var rgbTripletPattern = regexp.MustCompile(`^\d{1,3},\d{1,3},\d{1,3}$`)
+
+// RegisterValidations adds the custom validation tags used by the request
+// models and makes validation errors report JSON field names.
+func RegisterValidations(v *validator.Validate) error {
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		return name
+	})
+	return v.RegisterValidation("rgb_triplet", validateRGBTriplet)
+}
+
+// validateRGBTriplet checks for an "R,G,B" color with components in 0-255.
+func validateRGBTriplet(fl validator.FieldLevel) bool {
+	value := fl.Field().String()
+	if !rgbTripletPattern.MatchString(value) {
+		return false
+	}
+	for _, part := range strings.Split(value, ",") {
+		if n, _ := strconv.Atoi(part); n > 255 {
+			return false
+		}
+	}
+	return true
+}
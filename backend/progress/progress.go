@@ -0,0 +1,146 @@
+// Package progress relays the progress of long-running work to watchers.
+// Work publishes events under an ID, and watchers of the ID see the latest
+// event whenever it changes; a watcher that falls behind skips the events it
+// missed rather than holding up the work.
+package progress
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Hub is safe for concurrent use.
+type Hub[E any] struct {
+	mu        sync.Mutex
+	topics    map[string]*topic[E]
+	retention time.Duration
+	lastPrune time.Time
+
+	now func() time.Time
+}
+
+// topic holds the events of one ID.
+type topic[E any] struct {
+	seq      uint64 // Number of events published; 0 before the first
+	latest   E
+	final    bool
+	watchers int
+	touched  time.Time     // Last publish or watcher leaving
+	changed  chan struct{} // Closed, and replaced, on every publish
+}
+
+// NewHub returns a hub that forgets the events of an ID once nothing has
+// been published under it or watched it for retention.
+func NewHub[E any](retention time.Duration) *Hub[E] {
+	return &Hub[E]{topics: map[string]*topic[E]{}, retention: retention, now: time.Now}
+}
+
+// Begin starts the events of a new run of the work under id. If the events
+// of id had ended, as when an ID is reused after its work finished, they
+// start over; watchers of the ended events still see their final event.
+func (h *Hub[E]) Begin(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if t := h.topic(id); t.final {
+		h.topics[id] = &topic[E]{touched: h.now(), changed: make(chan struct{})}
+	}
+}
+
+// Publish makes e the latest event of id. A final event ends the events of
+// id: watchers stop after it, and later events are dropped until Begin
+// starts them over.
+func (h *Hub[E]) Publish(id string, e E, final bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t := h.topic(id)
+	if t.final {
+		return
+	}
+	t.seq++
+	t.latest, t.final = e, final
+	t.touched = h.now()
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// topic returns the topic of id, creating it if needed. h.mu must be held.
+func (h *Hub[E]) topic(id string) *topic[E] {
+	now := h.now()
+	if now.Sub(h.lastPrune) > h.retention/2 {
+		h.prune(now)
+	}
+	t, ok := h.topics[id]
+	if !ok {
+		t = &topic[E]{touched: now, changed: make(chan struct{})}
+		h.topics[id] = t
+	}
+	return t
+}
+
+// prune forgets the topics that nobody watches and nothing was published to
+// for the retention time. Work still running publishes again later, under a
+// new topic.
+func (h *Hub[E]) prune(now time.Time) {
+	for id, t := range h.topics {
+		if t.watchers == 0 && now.Sub(t.touched) > h.retention {
+			delete(h.topics, id)
+		}
+	}
+	h.lastPrune = now
+}
+
+// Watcher follows the events of one ID. It is not safe for concurrent use.
+type Watcher[E any] struct {
+	hub   *Hub[E]
+	topic *topic[E] // Kept when Begin starts the events of the ID over
+	seen  uint64
+	done  bool
+}
+
+// Watch returns a watcher of the events of id, which may be watched before
+// anything is published under it. The watcher must be closed.
+func (h *Hub[E]) Watch(id string) *Watcher[E] {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t := h.topic(id)
+	t.watchers++
+	return &Watcher[E]{hub: h, topic: t}
+}
+
+// Next waits until there is an event newer than the last one returned and
+// returns it, with whether it is the final one. It returns ctx.Err() if ctx
+// is done first.
+func (w *Watcher[E]) Next(ctx context.Context) (e E, final bool, err error) {
+	for {
+		w.hub.mu.Lock()
+		t := w.topic
+		if t.seq > w.seen {
+			w.seen = t.seq
+			e, final = t.latest, t.final
+			w.hub.mu.Unlock()
+			return e, final, nil
+		}
+		changed := t.changed
+		w.hub.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return e, false, ctx.Err()
+		}
+	}
+}
+
+// Close stops watching.
+func (w *Watcher[E]) Close() {
+	if w.done {
+		return
+	}
+	w.done = true
+	w.hub.mu.Lock()
+	defer w.hub.mu.Unlock()
+	t := w.topic
+	t.watchers--
+	t.touched = w.hub.now()
+}
@@ -0,0 +1,98 @@
+package progress
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWatchSeesLatestEvent(t *testing.T) {
+	h := NewHub[string](time.Minute)
+	w := h.Watch("a")
+	defer w.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := w.Next(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Next before any event: %v, want the deadline to pass", err)
+	}
+
+	// Events the watcher was too slow to see are skipped
+	h.Publish("a", "decoding", false)
+	h.Publish("a", "encoding", false)
+	h.Publish("b", "other", false)
+	if e, final, err := w.Next(context.Background()); err != nil || e != "encoding" || final {
+		t.Fatalf("Next = %q, %v, %v; want encoding", e, final, err)
+	}
+
+	next := make(chan string)
+	go func() {
+		e, _, _ := w.Next(context.Background())
+		next <- e
+	}()
+	time.Sleep(10 * time.Millisecond)
+	h.Publish("a", "done", true)
+	if e := <-next; e != "done" {
+		t.Errorf("Next while waiting = %q, want done", e)
+	}
+}
+
+func TestFinalEventEndsTopic(t *testing.T) {
+	h := NewHub[string](time.Minute)
+	h.Publish("a", "done", true)
+	h.Publish("a", "late", false)
+
+	// A watcher arriving after the end still sees it
+	w := h.Watch("a")
+	defer w.Close()
+	if e, final, err := w.Next(context.Background()); err != nil || e != "done" || !final {
+		t.Errorf("Next = %q, %v, %v; want the final event", e, final, err)
+	}
+}
+
+func TestPruneForgetsIdleTopics(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	h := NewHub[string](time.Minute)
+	h.now = func() time.Time { return now }
+
+	h.Publish("idle", "done", true)
+	w := h.Watch("watched")
+	defer w.Close()
+
+	now = now.Add(2 * time.Minute)
+	h.Publish("new", "received", false)
+	if _, ok := h.topics["idle"]; ok {
+		t.Error("idle topic was kept")
+	}
+	if _, ok := h.topics["watched"]; !ok {
+		t.Error("watched topic was forgotten")
+	}
+}
+
+func TestBeginStartsOver(t *testing.T) {
+	h := NewHub[string](time.Minute)
+	h.Publish("a", "done", true)
+	old := h.Watch("a")
+	defer old.Close()
+
+	// A new request under the same ID publishes again
+	h.Begin("a")
+	w := h.Watch("a")
+	defer w.Close()
+	h.Publish("a", "decoding", false)
+	if e, final, err := w.Next(context.Background()); err != nil || e != "decoding" || final {
+		t.Errorf("Next = %q, %v, %v; want the event of the new request", e, final, err)
+	}
+	// Watchers of the ended events still see how they ended
+	if e, final, err := old.Next(context.Background()); err != nil || e != "done" || !final {
+		t.Errorf("Next of the earlier watcher = %q, %v, %v; want its final event", e, final, err)
+	}
+
+	// Events still running are not started over, so early watchers keep following them
+	h.Begin("a")
+	h.Publish("a", "encoding", false)
+	if e, _, err := w.Next(context.Background()); err != nil || e != "encoding" {
+		t.Errorf("Next after Begin on running events = %q, %v; want encoding", e, err)
+	}
+}
@@ -4,13 +4,45 @@ On-demand background remover - runs once and exits.
 No Flask, no continuous server. Called directly by Go backend.
 """
 
+import os
 import sys
 import json
+import time
 import base64
 from io import BytesIO
 from PIL import Image
 from rembg import remove
 
+# Request ID from the Go backend, added to every log line
+request_id = None
+
+# Trace ID of the Go backend's span, from the W3C trace context it passes in
+# TRACEPARENT ("00-<trace id>-<span id>-<flags>")
+trace_id = None
+_traceparent = os.environ.get("TRACEPARENT", "").split("-")
+if len(_traceparent) == 4:
+    trace_id = _traceparent[1]
+
+
+def log(level: str, msg: str, **fields):
+    """Write a JSON log line to stderr, which the Go backend forwards to its log."""
+    record = {"level": level, "msg": msg}
+    if request_id:
+        record["request_id"] = request_id
+    if trace_id:
+        record["trace_id"] = trace_id
+    record.update(fields)
+    print(json.dumps(record), file=sys.stderr, flush=True)
+
+
+def progress(stage: str):
+    """Report the stage the operation reached on stderr; the Go backend relays it to the client."""
+    print(json.dumps({"progress": stage}), file=sys.stderr, flush=True)
+
+
+def elapsed_ms(start: float) -> int:
+    return int((time.monotonic() - start) * 1000)
+
 
 def get_image_object(image_base64: str):
     """Decode base64 image string and return PIL Image object."""
@@ -35,13 +67,23 @@ def get_image_object(image_base64: str):
 
 def remove_background(image_base64: str):
     """Remove background from image."""
+    progress("decoding")
+    start = time.monotonic()
     img_input = get_image_object(image_base64)
     img_input = img_input.convert('RGBA')
+    log("debug", "decoded image", width=img_input.width, height=img_input.height, duration_ms=elapsed_ms(start))
+
+    progress("removing_background")
+    start = time.monotonic()
     bg_removed_img = remove(img_input)
-    
+    log("info", "removed background", width=bg_removed_img.width, height=bg_removed_img.height, duration_ms=elapsed_ms(start))
+
+    progress("encoding")
+    start = time.monotonic()
     buffered = BytesIO()
     bg_removed_img.save(buffered, format="PNG", optimize=True, compress_level=6)
     output_base64 = base64.b64encode(buffered.getvalue()).decode('utf-8')
+    log("debug", "encoded image", format="png", bytes=buffered.tell(), duration_ms=elapsed_ms(start))
     
     return {
         "success": True,
@@ -52,11 +94,20 @@ def remove_background(image_base64: str):
 
 def change_background(image_base64: str, new_bg_base64=None, solid_color=None):
     """Remove background and replace it with new background."""
+    progress("decoding")
+    start = time.monotonic()
     img_input = get_image_object(image_base64)
     file_format = img_input.format
     img_input = img_input.convert('RGBA')
+    log("debug", "decoded image", format=file_format, width=img_input.width, height=img_input.height,
+        duration_ms=elapsed_ms(start))
+
+    progress("removing_background")
+    start = time.monotonic()
     bg_removed_img = remove(img_input)
+    log("info", "removed background", width=bg_removed_img.width, height=bg_removed_img.height, duration_ms=elapsed_ms(start))
     
+    progress("replacing_background")
     if new_bg_base64:
         input_bg_img = get_image_object(new_bg_base64)
         input_bg_img = input_bg_img.convert('RGBA')
@@ -70,13 +121,15 @@ def change_background(image_base64: str, new_bg_base64=None, solid_color=None):
     
     combined = Image.alpha_composite(input_bg_img, bg_removed_img).convert("RGB")
     
+    progress("encoding")
     buffered = BytesIO()
     output_format = "JPEG"
     if file_format and file_format.upper() in ['JPEG', 'JPG']:
         output_format = "JPEG"
     
     combined.save(buffered, format=output_format, quality=85, optimize=True)
-    
+    log("debug", "encoded image", format=output_format.lower(), bytes=buffered.tell())
+
     output_base64 = base64.b64encode(buffered.getvalue()).decode('utf-8')
     return {
         "success": True,
@@ -89,7 +142,8 @@ if __name__ == "__main__":
     try:
         # Read JSON input from stdin
         input_data = json.loads(sys.stdin.read())
-        
+
+        request_id = input_data.get("request_id")
         operation = input_data.get("operation")
         
         if operation == "remove_background":
@@ -108,6 +162,7 @@ if __name__ == "__main__":
         sys.exit(0)
         
     except Exception as e:
+        log("error", "operation failed", error=str(e))
         error_result = {"success": False, "error": str(e)}
         print(json.dumps(error_result))
         sys.exit(1)
@@ -5,8 +5,11 @@ Handles background removal and background replacement requests.
 """
 
 import sys
+import json
+import time
+import logging
 import base64
-from flask import Flask, request, jsonify
+from flask import Flask, g, has_request_context, request, jsonify
 from flask_cors import CORS
 from io import BytesIO
 from PIL import Image, ImageFile
@@ -21,6 +24,60 @@ CORS(app)  # Enable CORS for all routes
 app.config['MAX_CONTENT_LENGTH'] = 100 * 1024 * 1024
 
 
+class JSONFormatter(logging.Formatter):
+    """Formats log records as JSON lines tagged with the request ID."""
+
+    def format(self, record):
+        entry = {
+            "time": self.formatTime(record),
+            "level": record.levelname.lower(),
+            "msg": record.getMessage(),
+        }
+        if has_request_context() and g.get("request_id"):
+            entry["request_id"] = g.request_id
+        if has_request_context() and g.get("trace_id"):
+            entry["trace_id"] = g.trace_id
+        entry.update(getattr(record, "fields", {}))
+        if record.exc_info:
+            entry["exception"] = self.formatException(record.exc_info)
+        return json.dumps(entry)
+
+
+handler = logging.StreamHandler(sys.stderr)
+handler.setFormatter(JSONFormatter())
+log = logging.getLogger("image_server")
+log.addHandler(handler)
+log.setLevel(logging.INFO)
+
+
+@app.before_request
+def start_request():
+    """Take the request ID from the Go backend, from the header or the payload,
+    and the trace ID from its W3C traceparent header."""
+    g.start = time.monotonic()
+    traceparent = request.headers.get("traceparent", "").split("-")
+    if len(traceparent) == 4:
+        g.trace_id = traceparent[1]
+    g.request_id = request.headers.get("X-Request-ID")
+    if not g.request_id and request.is_json:
+        g.request_id = (request.get_json(silent=True) or {}).get("request_id")
+
+
+@app.after_request
+def log_request(response):
+    """Log every request with its outcome and timing."""
+    if g.get("request_id"):
+        response.headers["X-Request-ID"] = g.request_id
+    log.info("request", extra={"fields": {
+        "method": request.method,
+        "path": request.path,
+        "status": response.status_code,
+        "duration_ms": int((time.monotonic() - g.start) * 1000),
+        **g.get("log_fields", {}),
+    }})
+    return response
+
+
 def get_image_object(image_base64: str):
     """Decode base64 image string and return PIL Image object."""
     try:
@@ -56,6 +113,7 @@ def remove_background():
         
         buffered = BytesIO()
         bg_removed_img.save(buffered, format="PNG")
+        g.log_fields = {"operation": "remove_background", "width": bg_removed_img.width, "height": bg_removed_img.height, "format": "png"}
         output_base64 = base64.b64encode(buffered.getvalue()).decode('utf-8')
         
         return jsonify({
@@ -64,6 +122,7 @@ def remove_background():
             "format": "png"
         }), 200
     except Exception as e:
+        log.exception("operation failed", extra={"fields": {"path": request.path}})
         return jsonify({"success": False, "error": str(e)}), 500
 
 
@@ -107,6 +166,7 @@ def change_background():
             output_format = "JPEG"
         
         combined.save(buffered, format=output_format, quality=95)
+        g.log_fields = {"operation": "change_background", "width": combined.width, "height": combined.height, "format": output_format.lower()}
         
         output_base64 = base64.b64encode(buffered.getvalue()).decode('utf-8')
         return jsonify({
@@ -115,6 +175,7 @@ def change_background():
             "format": output_format.lower()
         }), 200
     except Exception as e:
+        log.exception("operation failed", extra={"fields": {"path": request.path}})
         return jsonify({"success": False, "error": str(e)}), 500
 
 
@@ -122,6 +183,6 @@ if __name__ == "__main__":
     # Default port 5001 to avoid conflicts with Go server
     port = int(sys.argv[1]) if len(sys.argv) > 1 else 5001
     
-    print(f"Starting image processing server on port {port}", file=sys.stderr)
+    log.info("starting image processing server", extra={"fields": {"port": port}})
     app.run(host='localhost', port=port, debug=False)
 
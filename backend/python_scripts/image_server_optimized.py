@@ -5,9 +5,12 @@ Uses lazy loading and process priority adjustments for Oracle free tier.
 """
 
 import sys
+import json
+import time
+import logging
 import os
 import base64
-from flask import Flask, request, jsonify
+from flask import Flask, g, has_request_context, request, jsonify
 from flask_cors import CORS
 from io import BytesIO
 from PIL import Image, ImageFile
@@ -26,6 +29,60 @@ CORS(app)
 # Max content length (50MB - reduced for free tier)
 app.config['MAX_CONTENT_LENGTH'] = 50 * 1024 * 1024
 
+
+class JSONFormatter(logging.Formatter):
+    """Formats log records as JSON lines tagged with the request ID."""
+
+    def format(self, record):
+        entry = {
+            "time": self.formatTime(record),
+            "level": record.levelname.lower(),
+            "msg": record.getMessage(),
+        }
+        if has_request_context() and g.get("request_id"):
+            entry["request_id"] = g.request_id
+        if has_request_context() and g.get("trace_id"):
+            entry["trace_id"] = g.trace_id
+        entry.update(getattr(record, "fields", {}))
+        if record.exc_info:
+            entry["exception"] = self.formatException(record.exc_info)
+        return json.dumps(entry)
+
+
+handler = logging.StreamHandler(sys.stderr)
+handler.setFormatter(JSONFormatter())
+log = logging.getLogger("image_server")
+log.addHandler(handler)
+log.setLevel(logging.INFO)
+
+
+@app.before_request
+def start_request():
+    """Take the request ID from the Go backend, from the header or the payload,
+    and the trace ID from its W3C traceparent header."""
+    g.start = time.monotonic()
+    traceparent = request.headers.get("traceparent", "").split("-")
+    if len(traceparent) == 4:
+        g.trace_id = traceparent[1]
+    g.request_id = request.headers.get("X-Request-ID")
+    if not g.request_id and request.is_json:
+        g.request_id = (request.get_json(silent=True) or {}).get("request_id")
+
+
+@app.after_request
+def log_request(response):
+    """Log every request with its outcome and timing."""
+    if g.get("request_id"):
+        response.headers["X-Request-ID"] = g.request_id
+    log.info("request", extra={"fields": {
+        "method": request.method,
+        "path": request.path,
+        "status": response.status_code,
+        "duration_ms": int((time.monotonic() - g.start) * 1000),
+        **g.get("log_fields", {}),
+    }})
+    return response
+
 # Lazy load rembg only when needed (saves memory and startup time)
 _rembg_loaded = False
 _remove_func = None
@@ -34,7 +91,7 @@ def load_rembg():
     """Lazy load rembg library only when actually needed."""
     global _rembg_loaded, _remove_func
     if not _rembg_loaded:
-        print("Loading rembg library (first use)...", file=sys.stderr)
+        log.info("loading rembg library (first use)")
         from rembg import remove
         _remove_func = remove
         _rembg_loaded = True
@@ -87,6 +144,7 @@ def remove_background():
         buffered = BytesIO()
         # Optimize PNG compression for smaller output
         bg_removed_img.save(buffered, format="PNG", optimize=True, compress_level=6)
+        g.log_fields = {"operation": "remove_background", "width": bg_removed_img.width, "height": bg_removed_img.height, "format": "png"}
         output_base64 = base64.b64encode(buffered.getvalue()).decode('utf-8')
         
         return jsonify({
@@ -95,6 +153,7 @@ def remove_background():
             "format": "png"
         }), 200
     except Exception as e:
+        log.exception("operation failed", extra={"fields": {"path": request.path}})
         return jsonify({"success": False, "error": str(e)}), 500
 
 
@@ -139,6 +198,7 @@ def change_background():
         
         # Use lower quality for smaller files and faster processing
         combined.save(buffered, format=output_format, quality=85, optimize=True)
+        g.log_fields = {"operation": "change_background", "width": combined.width, "height": combined.height, "format": output_format.lower()}
         
         output_base64 = base64.b64encode(buffered.getvalue()).decode('utf-8')
         return jsonify({
@@ -147,14 +207,14 @@ def change_background():
             "format": output_format.lower()
         }), 200
     except Exception as e:
+        log.exception("operation failed", extra={"fields": {"path": request.path}})
         return jsonify({"success": False, "error": str(e)}), 500
 
 
 if __name__ == "__main__":
     port = int(sys.argv[1]) if len(sys.argv) > 1 else 5001
     
-    print(f"Starting optimized image server on port {port} (low CPU mode)", file=sys.stderr)
-    print(f"Process priority adjusted for free tier usage", file=sys.stderr)
+    log.info("starting optimized image server (low CPU mode)", extra={"fields": {"port": port}})
     
     # Use threading mode with single thread for low resource usage
     app.run(
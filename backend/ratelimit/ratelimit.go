@@ -0,0 +1,155 @@
+// Package ratelimit meters the cost units that clients spend, with a token
+// bucket that bounds their rate and a quota that bounds what they spend per
+// UTC day.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Policy bounds the cost units a client may spend.
+type Policy struct {
+	Rate       float64 // Units refilled per second
+	Burst      int64   // Units that may be spent at once
+	DailyQuota int64   // Units per UTC day; 0 for no quota
+}
+
+// Decision is the outcome of a request to spend units, with the state of the
+// client's budget afterwards.
+type Decision struct {
+	Allowed       bool
+	QuotaExceeded bool // Whether the daily quota, rather than the rate, was exceeded
+	Policy        Policy
+
+	// The budget closest to running out, the bucket or the daily quota:
+	// its size, the units left in it and the time until it is full again
+	Limit     int64
+	Remaining int64
+	Reset     time.Duration
+
+	RetryAfter time.Duration // When rejected, the time until the units are available
+}
+
+// Limiter is safe for concurrent use.
+type Limiter struct {
+	mu        sync.Mutex
+	clients   map[string]*budget
+	lastPrune time.Time
+
+	now func() time.Time
+}
+
+// budget is the state of one client.
+type budget struct {
+	tokens  float64
+	updated time.Time
+	day     time.Time // Start of the UTC day that used counts for
+	used    int64
+}
+
+// New returns a limiter with no history.
+func New() *Limiter {
+	return &Limiter{clients: map[string]*budget{}, now: time.Now}
+}
+
+// pruneInterval is how often budgets that went back to full are forgotten.
+const pruneInterval = 10 * time.Minute
+
+// Take spends cost units of client's budget under policy, if they are
+// available. Costs above the burst size take the whole bucket, but are
+// charged in full against the daily quota.
+func (l *Limiter) Take(client string, policy Policy, cost int64) Decision {
+	now := l.now()
+	day := now.UTC().Truncate(24 * time.Hour)
+	burst := max(policy.Burst, 1)
+	cost = max(cost, 1)
+	draw := min(cost, burst)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastPrune) > pruneInterval {
+		l.prune(now, day)
+	}
+
+	b, ok := l.clients[client]
+	if !ok {
+		b = &budget{tokens: float64(burst), updated: now, day: day}
+		l.clients[client] = b
+	}
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.updated).Seconds()*policy.Rate)
+	b.updated = now
+	if !b.day.Equal(day) {
+		b.day, b.used = day, 0
+	}
+
+	d := Decision{Policy: policy}
+	switch {
+	case policy.DailyQuota > 0 && b.used+cost > policy.DailyQuota:
+		d.QuotaExceeded = true
+		d.RetryAfter = day.Add(24 * time.Hour).Sub(now)
+	case b.tokens < float64(draw):
+		d.RetryAfter = refillTime(float64(draw)-b.tokens, policy.Rate)
+	default:
+		d.Allowed = true
+		b.tokens -= float64(draw)
+		b.used += cost
+	}
+
+	d.Limit, d.Remaining = burst, int64(b.tokens)
+	d.Reset = refillTime(float64(burst)-b.tokens, policy.Rate)
+	if left := policy.DailyQuota - b.used; policy.DailyQuota > 0 && left < d.Remaining {
+		d.Limit, d.Remaining = policy.DailyQuota, left
+		d.Reset = day.Add(24 * time.Hour).Sub(now)
+	}
+	return d
+}
+
+// prune forgets the clients whose bucket is full again and whose quota was
+// last used on an earlier day, since they are in the state of a new client.
+// It is called with l.mu held.
+func (l *Limiter) prune(now, day time.Time) {
+	l.lastPrune = now
+	for client, b := range l.clients {
+		if b.day.Before(day) && now.Sub(b.updated) > pruneInterval {
+			delete(l.clients, client)
+		}
+	}
+}
+
+// refillTime returns the time to refill units at rate units per second.
+func refillTime(units, rate float64) time.Duration {
+	if units <= 0 {
+		return 0
+	}
+	if rate <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(units / rate * float64(time.Second))
+}
+
+// SetHeaders writes the RateLimit-Policy, RateLimit-Limit, RateLimit-Remaining
+// and RateLimit-Reset fields of the IETF RateLimit header draft to h.
+func (d Decision) SetHeaders(h http.Header) {
+	window := int64(86400)
+	if d.Policy.Rate > 0 {
+		window = seconds(refillTime(float64(d.Policy.Burst), d.Policy.Rate))
+	}
+	policy := fmt.Sprintf("%d;w=%d", d.Policy.Burst, window)
+	if d.Policy.DailyQuota > 0 {
+		policy += fmt.Sprintf(", %d;w=86400", d.Policy.DailyQuota)
+	}
+	h.Set("RateLimit-Policy", policy)
+	h.Set("RateLimit-Limit", strconv.FormatInt(d.Limit, 10))
+	h.Set("RateLimit-Remaining", strconv.FormatInt(max(d.Remaining, 0), 10))
+	h.Set("RateLimit-Reset", strconv.FormatInt(seconds(d.Reset), 10))
+}
+
+// seconds rounds d up to whole seconds.
+func seconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTakeRefillsAtRate(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	l := New()
+	l.now = func() time.Time { return now }
+	policy := Policy{Rate: 1, Burst: 4}
+
+	if d := l.Take("a", policy, 3); !d.Allowed || d.Remaining != 1 {
+		t.Fatalf("first take: %+v, want allowed with 1 remaining", d)
+	}
+	d := l.Take("a", policy, 2)
+	if d.Allowed || d.RetryAfter != time.Second {
+		t.Fatalf("second take: %+v, want rejected until 1s later", d)
+	}
+	if d := l.Take("b", policy, 2); !d.Allowed {
+		t.Errorf("another client was limited: %+v", d)
+	}
+
+	now = now.Add(time.Second)
+	if d := l.Take("a", policy, 2); !d.Allowed || d.Remaining != 0 {
+		t.Errorf("take after refill: %+v, want allowed with 0 remaining", d)
+	}
+	if d := l.Take("a", policy, 100); d.Allowed || d.RetryAfter != 4*time.Second {
+		t.Errorf("take above burst: %+v, want rejected until the bucket is full", d)
+	}
+}
+
+func TestTakeEnforcesDailyQuota(t *testing.T) {
+	now := time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC)
+	l := New()
+	l.now = func() time.Time { return now }
+	policy := Policy{Rate: 100, Burst: 100, DailyQuota: 5}
+
+	if d := l.Take("a", policy, 4); !d.Allowed || d.Limit != 5 || d.Remaining != 1 || d.Reset != time.Hour {
+		t.Fatalf("first take: %+v, want the quota reported with 1 remaining until midnight", d)
+	}
+	d := l.Take("a", policy, 2)
+	if d.Allowed || !d.QuotaExceeded || d.RetryAfter != time.Hour {
+		t.Fatalf("second take: %+v, want quota exceeded until midnight", d)
+	}
+
+	h := http.Header{}
+	d.SetHeaders(h)
+	if got := h.Get("RateLimit-Policy"); got != "100;w=1, 5;w=86400" {
+		t.Errorf("RateLimit-Policy = %q", got)
+	}
+	if got := h.Get("RateLimit-Remaining"); got != "1" {
+		t.Errorf("RateLimit-Remaining = %q, want 1", got)
+	}
+
+	now = now.Add(time.Hour)
+	if d := l.Take("a", policy, 2); !d.Allowed {
+		t.Errorf("take on the next day: %+v, want allowed", d)
+	}
+}
+
+func TestTakeChargesFullCostAgainstQuota(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	l := New()
+	l.now = func() time.Time { return now }
+	policy := Policy{Rate: 1, Burst: 20, DailyQuota: 500}
+
+	// The bucket gives out at most its burst, but the quota is charged 400
+	if d := l.Take("a", policy, 400); !d.Allowed || d.Limit != 20 || d.Remaining != 0 {
+		t.Fatalf("first take: %+v, want allowed with the bucket empty", d)
+	}
+	now = now.Add(20 * time.Second)
+	if d := l.Take("a", policy, 150); d.Allowed || !d.QuotaExceeded {
+		t.Fatalf("second take: %+v, want quota exceeded after 400 of 500 units", d)
+	}
+	if d := l.Take("a", policy, 100); !d.Allowed || d.Remaining != 0 {
+		t.Errorf("third take: %+v, want allowed with nothing left", d)
+	}
+	now = now.Add(20 * time.Second)
+	if d := l.Take("a", policy, 1); d.Allowed || !d.QuotaExceeded {
+		t.Errorf("take with a full bucket: %+v, want the spent quota to reject it", d)
+	}
+}
@@ -0,0 +1,1777 @@
+// The gRPC API of the image editor. It mirrors the /v1 REST API, whose
+// request models are in models/image_request.go, with images sent and
+// returned as raw bytes in chunks rather than as base64.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        v5.29.3
+// source: imageeditor/v1/image_editor.proto
+
+package imagepb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Operation is an operation with its parameters.
+type Operation struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Operation:
+	//
+	//	*Operation_Resize
+	//	*Operation_Crop
+	//	*Operation_Upscale
+	//	*Operation_Convert
+	//	*Operation_Blur
+	//	*Operation_RemoveBackground
+	//	*Operation_ChangeBackground
+	//	*Operation_Compress
+	//	*Operation_Watermark
+	Operation     isOperation_Operation `protobuf_oneof:"operation"`
+	Output        *OutputOptions        `protobuf:"bytes,15,opt,name=output,proto3" json:"output,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Operation) Reset() {
+	*x = Operation{}
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Operation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Operation) ProtoMessage() {}
+
+func (x *Operation) ProtoReflect() protoreflect.Message {
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Operation.ProtoReflect.Descriptor instead.
+func (*Operation) Descriptor() ([]byte, []int) {
+	return file_imageeditor_v1_image_editor_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Operation) GetOperation() isOperation_Operation {
+	if x != nil {
+		return x.Operation
+	}
+	return nil
+}
+
+func (x *Operation) GetResize() *ResizeOptions {
+	if x != nil {
+		if x, ok := x.Operation.(*Operation_Resize); ok {
+			return x.Resize
+		}
+	}
+	return nil
+}
+
+func (x *Operation) GetCrop() *CropOptions {
+	if x != nil {
+		if x, ok := x.Operation.(*Operation_Crop); ok {
+			return x.Crop
+		}
+	}
+	return nil
+}
+
+func (x *Operation) GetUpscale() *UpscaleOptions {
+	if x != nil {
+		if x, ok := x.Operation.(*Operation_Upscale); ok {
+			return x.Upscale
+		}
+	}
+	return nil
+}
+
+func (x *Operation) GetConvert() *ConvertOptions {
+	if x != nil {
+		if x, ok := x.Operation.(*Operation_Convert); ok {
+			return x.Convert
+		}
+	}
+	return nil
+}
+
+func (x *Operation) GetBlur() *BlurOptions {
+	if x != nil {
+		if x, ok := x.Operation.(*Operation_Blur); ok {
+			return x.Blur
+		}
+	}
+	return nil
+}
+
+func (x *Operation) GetRemoveBackground() *RemoveBackgroundOptions {
+	if x != nil {
+		if x, ok := x.Operation.(*Operation_RemoveBackground); ok {
+			return x.RemoveBackground
+		}
+	}
+	return nil
+}
+
+func (x *Operation) GetChangeBackground() *ChangeBackgroundOptions {
+	if x != nil {
+		if x, ok := x.Operation.(*Operation_ChangeBackground); ok {
+			return x.ChangeBackground
+		}
+	}
+	return nil
+}
+
+func (x *Operation) GetCompress() *CompressOptions {
+	if x != nil {
+		if x, ok := x.Operation.(*Operation_Compress); ok {
+			return x.Compress
+		}
+	}
+	return nil
+}
+
+func (x *Operation) GetWatermark() *WatermarkOptions {
+	if x != nil {
+		if x, ok := x.Operation.(*Operation_Watermark); ok {
+			return x.Watermark
+		}
+	}
+	return nil
+}
+
+func (x *Operation) GetOutput() *OutputOptions {
+	if x != nil {
+		return x.Output
+	}
+	return nil
+}
+
+type isOperation_Operation interface {
+	isOperation_Operation()
+}
+
+type Operation_Resize struct {
+	Resize *ResizeOptions `protobuf:"bytes,1,opt,name=resize,proto3,oneof"`
+}
+
+type Operation_Crop struct {
+	Crop *CropOptions `protobuf:"bytes,2,opt,name=crop,proto3,oneof"`
+}
+
+type Operation_Upscale struct {
+	Upscale *UpscaleOptions `protobuf:"bytes,3,opt,name=upscale,proto3,oneof"`
+}
+
+type Operation_Convert struct {
+	Convert *ConvertOptions `protobuf:"bytes,4,opt,name=convert,proto3,oneof"`
+}
+
+type Operation_Blur struct {
+	Blur *BlurOptions `protobuf:"bytes,5,opt,name=blur,proto3,oneof"`
+}
+
+type Operation_RemoveBackground struct {
+	RemoveBackground *RemoveBackgroundOptions `protobuf:"bytes,6,opt,name=remove_background,json=removeBackground,proto3,oneof"`
+}
+
+type Operation_ChangeBackground struct {
+	ChangeBackground *ChangeBackgroundOptions `protobuf:"bytes,7,opt,name=change_background,json=changeBackground,proto3,oneof"`
+}
+
+type Operation_Compress struct {
+	Compress *CompressOptions `protobuf:"bytes,8,opt,name=compress,proto3,oneof"`
+}
+
+type Operation_Watermark struct {
+	Watermark *WatermarkOptions `protobuf:"bytes,9,opt,name=watermark,proto3,oneof"`
+}
+
+func (*Operation_Resize) isOperation_Operation() {}
+
+func (*Operation_Crop) isOperation_Operation() {}
+
+func (*Operation_Upscale) isOperation_Operation() {}
+
+func (*Operation_Convert) isOperation_Operation() {}
+
+func (*Operation_Blur) isOperation_Operation() {}
+
+func (*Operation_RemoveBackground) isOperation_Operation() {}
+
+func (*Operation_ChangeBackground) isOperation_Operation() {}
+
+func (*Operation_Compress) isOperation_Operation() {}
+
+func (*Operation_Watermark) isOperation_Operation() {}
+
+// OutputOptions are the output settings shared by all operations.
+type OutputOptions struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Metadata      string                 `protobuf:"bytes,1,opt,name=metadata,proto3" json:"metadata,omitempty"`                                // keep, strip (default), strip_gps, keep_copyright_only
+	OutputProfile string                 `protobuf:"bytes,2,opt,name=output_profile,json=outputProfile,proto3" json:"output_profile,omitempty"` // srgb (default), display_p3, adobe_rgb
+	EmbedProfile  bool                   `protobuf:"varint,3,opt,name=embed_profile,json=embedProfile,proto3" json:"embed_profile,omitempty"`   // Embed the output ICC profile (always done for non-sRGB output)
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OutputOptions) Reset() {
+	*x = OutputOptions{}
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OutputOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OutputOptions) ProtoMessage() {}
+
+func (x *OutputOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OutputOptions.ProtoReflect.Descriptor instead.
+func (*OutputOptions) Descriptor() ([]byte, []int) {
+	return file_imageeditor_v1_image_editor_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *OutputOptions) GetMetadata() string {
+	if x != nil {
+		return x.Metadata
+	}
+	return ""
+}
+
+func (x *OutputOptions) GetOutputProfile() string {
+	if x != nil {
+		return x.OutputProfile
+	}
+	return ""
+}
+
+func (x *OutputOptions) GetEmbedProfile() bool {
+	if x != nil {
+		return x.EmbedProfile
+	}
+	return false
+}
+
+type ResizeOptions struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Width         int32                  `protobuf:"varint,1,opt,name=width,proto3" json:"width,omitempty"`
+	Height        int32                  `protobuf:"varint,2,opt,name=height,proto3" json:"height,omitempty"`
+	Preset        string                 `protobuf:"bytes,3,opt,name=preset,proto3" json:"preset,omitempty"` // e.g., "youtube_thumbnail", "instagram_story"
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResizeOptions) Reset() {
+	*x = ResizeOptions{}
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResizeOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResizeOptions) ProtoMessage() {}
+
+func (x *ResizeOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResizeOptions.ProtoReflect.Descriptor instead.
+func (*ResizeOptions) Descriptor() ([]byte, []int) {
+	return file_imageeditor_v1_image_editor_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ResizeOptions) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *ResizeOptions) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *ResizeOptions) GetPreset() string {
+	if x != nil {
+		return x.Preset
+	}
+	return ""
+}
+
+type CropOptions struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	X             int32                  `protobuf:"varint,1,opt,name=x,proto3" json:"x,omitempty"`
+	Y             int32                  `protobuf:"varint,2,opt,name=y,proto3" json:"y,omitempty"`
+	Width         int32                  `protobuf:"varint,3,opt,name=width,proto3" json:"width,omitempty"`
+	Height        int32                  `protobuf:"varint,4,opt,name=height,proto3" json:"height,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CropOptions) Reset() {
+	*x = CropOptions{}
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CropOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CropOptions) ProtoMessage() {}
+
+func (x *CropOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CropOptions.ProtoReflect.Descriptor instead.
+func (*CropOptions) Descriptor() ([]byte, []int) {
+	return file_imageeditor_v1_image_editor_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CropOptions) GetX() int32 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *CropOptions) GetY() int32 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+func (x *CropOptions) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *CropOptions) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+type UpscaleOptions struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ScaleFactor   float64                `protobuf:"fixed64,1,opt,name=scale_factor,json=scaleFactor,proto3" json:"scale_factor,omitempty"` // e.g., 2.0 for 2x upscale
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpscaleOptions) Reset() {
+	*x = UpscaleOptions{}
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpscaleOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpscaleOptions) ProtoMessage() {}
+
+func (x *UpscaleOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpscaleOptions.ProtoReflect.Descriptor instead.
+func (*UpscaleOptions) Descriptor() ([]byte, []int) {
+	return file_imageeditor_v1_image_editor_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *UpscaleOptions) GetScaleFactor() float64 {
+	if x != nil {
+		return x.ScaleFactor
+	}
+	return 0
+}
+
+type ConvertOptions struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Format        string                 `protobuf:"bytes,1,opt,name=format,proto3" json:"format,omitempty"` // jpeg, jpg, png, gif, bmp, tiff or webp
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConvertOptions) Reset() {
+	*x = ConvertOptions{}
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConvertOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConvertOptions) ProtoMessage() {}
+
+func (x *ConvertOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConvertOptions.ProtoReflect.Descriptor instead.
+func (*ConvertOptions) Descriptor() ([]byte, []int) {
+	return file_imageeditor_v1_image_editor_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ConvertOptions) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+type Point struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	X             int32                  `protobuf:"varint,1,opt,name=x,proto3" json:"x,omitempty"`
+	Y             int32                  `protobuf:"varint,2,opt,name=y,proto3" json:"y,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Point) Reset() {
+	*x = Point{}
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Point) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Point) ProtoMessage() {}
+
+func (x *Point) ProtoReflect() protoreflect.Message {
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Point.ProtoReflect.Descriptor instead.
+func (*Point) Descriptor() ([]byte, []int) {
+	return file_imageeditor_v1_image_editor_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Point) GetX() int32 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *Point) GetY() int32 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+type BlurOptions struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Path          []*Point               `protobuf:"bytes,1,rep,name=path,proto3" json:"path,omitempty"` // Points defining the blur path
+	Radius        float64                `protobuf:"fixed64,2,opt,name=radius,proto3" json:"radius,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BlurOptions) Reset() {
+	*x = BlurOptions{}
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BlurOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlurOptions) ProtoMessage() {}
+
+func (x *BlurOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlurOptions.ProtoReflect.Descriptor instead.
+func (*BlurOptions) Descriptor() ([]byte, []int) {
+	return file_imageeditor_v1_image_editor_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *BlurOptions) GetPath() []*Point {
+	if x != nil {
+		return x.Path
+	}
+	return nil
+}
+
+func (x *BlurOptions) GetRadius() float64 {
+	if x != nil {
+		return x.Radius
+	}
+	return 0
+}
+
+type RemoveBackgroundOptions struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RemoveBackgroundOptions) Reset() {
+	*x = RemoveBackgroundOptions{}
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RemoveBackgroundOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveBackgroundOptions) ProtoMessage() {}
+
+func (x *RemoveBackgroundOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveBackgroundOptions.ProtoReflect.Descriptor instead.
+func (*RemoveBackgroundOptions) Descriptor() ([]byte, []int) {
+	return file_imageeditor_v1_image_editor_proto_rawDescGZIP(), []int{8}
+}
+
+type ChangeBackgroundOptions struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	NewBackgroundImage []byte                 `protobuf:"bytes,1,opt,name=new_background_image,json=newBackgroundImage,proto3" json:"new_background_image,omitempty"` // Encoded image
+	SolidColor         string                 `protobuf:"bytes,2,opt,name=solid_color,json=solidColor,proto3" json:"solid_color,omitempty"`                           // "R,G,B", e.g., "255,0,0" for red
+	Transparent        bool                   `protobuf:"varint,3,opt,name=transparent,proto3" json:"transparent,omitempty"`                                          // Return a transparent PNG
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *ChangeBackgroundOptions) Reset() {
+	*x = ChangeBackgroundOptions{}
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChangeBackgroundOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChangeBackgroundOptions) ProtoMessage() {}
+
+func (x *ChangeBackgroundOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChangeBackgroundOptions.ProtoReflect.Descriptor instead.
+func (*ChangeBackgroundOptions) Descriptor() ([]byte, []int) {
+	return file_imageeditor_v1_image_editor_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ChangeBackgroundOptions) GetNewBackgroundImage() []byte {
+	if x != nil {
+		return x.NewBackgroundImage
+	}
+	return nil
+}
+
+func (x *ChangeBackgroundOptions) GetSolidColor() string {
+	if x != nil {
+		return x.SolidColor
+	}
+	return ""
+}
+
+func (x *ChangeBackgroundOptions) GetTransparent() bool {
+	if x != nil {
+		return x.Transparent
+	}
+	return false
+}
+
+type CompressOptions struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Quality       *int32                 `protobuf:"varint,1,opt,name=quality,proto3,oneof" json:"quality,omitempty"`                      // JPEG/WebP quality (1-100), default varies by format
+	Format        *string                `protobuf:"bytes,2,opt,name=format,proto3,oneof" json:"format,omitempty"`                         // Force output format (jpeg, png, webp, etc.)
+	MaxWidth      *int32                 `protobuf:"varint,3,opt,name=max_width,json=maxWidth,proto3,oneof" json:"max_width,omitempty"`    // Resize if width exceeds this
+	MaxHeight     *int32                 `protobuf:"varint,4,opt,name=max_height,json=maxHeight,proto3,oneof" json:"max_height,omitempty"` // Resize if height exceeds this
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CompressOptions) Reset() {
+	*x = CompressOptions{}
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CompressOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CompressOptions) ProtoMessage() {}
+
+func (x *CompressOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CompressOptions.ProtoReflect.Descriptor instead.
+func (*CompressOptions) Descriptor() ([]byte, []int) {
+	return file_imageeditor_v1_image_editor_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *CompressOptions) GetQuality() int32 {
+	if x != nil && x.Quality != nil {
+		return *x.Quality
+	}
+	return 0
+}
+
+func (x *CompressOptions) GetFormat() string {
+	if x != nil && x.Format != nil {
+		return *x.Format
+	}
+	return ""
+}
+
+func (x *CompressOptions) GetMaxWidth() int32 {
+	if x != nil && x.MaxWidth != nil {
+		return *x.MaxWidth
+	}
+	return 0
+}
+
+func (x *CompressOptions) GetMaxHeight() int32 {
+	if x != nil && x.MaxHeight != nil {
+		return *x.MaxHeight
+	}
+	return 0
+}
+
+// WatermarkOptions place either a text or a logo.
+type WatermarkOptions struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Text          string                 `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`                                           // Lines are separated by "\n"
+	Font          string                 `protobuf:"bytes,2,opt,name=font,proto3" json:"font,omitempty"`                                           // regular (default), bold, italic, bold-italic, mono or mono-bold
+	FontFile      []byte                 `protobuf:"bytes,3,opt,name=font_file,json=fontFile,proto3" json:"font_file,omitempty"`                   // TrueType or OpenType font, instead of a bundled one
+	FontSize      float64                `protobuf:"fixed64,4,opt,name=font_size,json=fontSize,proto3" json:"font_size,omitempty"`                 // In pixels; defaults to 5% of the image width
+	Color         string                 `protobuf:"bytes,5,opt,name=color,proto3" json:"color,omitempty"`                                         // "R,G,B"; defaults to white
+	StrokeWidth   float64                `protobuf:"fixed64,6,opt,name=stroke_width,json=strokeWidth,proto3" json:"stroke_width,omitempty"`        // Width of the outline around the text in pixels
+	StrokeColor   string                 `protobuf:"bytes,7,opt,name=stroke_color,json=strokeColor,proto3" json:"stroke_color,omitempty"`          // "R,G,B"; defaults to black
+	ShadowOffsetX int32                  `protobuf:"varint,8,opt,name=shadow_offset_x,json=shadowOffsetX,proto3" json:"shadow_offset_x,omitempty"` // No shadow if both offsets are 0
+	ShadowOffsetY int32                  `protobuf:"varint,9,opt,name=shadow_offset_y,json=shadowOffsetY,proto3" json:"shadow_offset_y,omitempty"`
+	ShadowBlur    float64                `protobuf:"fixed64,10,opt,name=shadow_blur,json=shadowBlur,proto3" json:"shadow_blur,omitempty"`
+	ShadowColor   string                 `protobuf:"bytes,11,opt,name=shadow_color,json=shadowColor,proto3" json:"shadow_color,omitempty"`        // "R,G,B"; defaults to black
+	Logo          []byte                 `protobuf:"bytes,12,opt,name=logo,proto3" json:"logo,omitempty"`                                         // Encoded image, instead of a text
+	LogoScale     float64                `protobuf:"fixed64,13,opt,name=logo_scale,json=logoScale,proto3" json:"logo_scale,omitempty"`            // Logo width relative to the image width; defaults to 0.2
+	Opacity       *float64               `protobuf:"fixed64,14,opt,name=opacity,proto3,oneof" json:"opacity,omitempty"`                           // From 0 to 1; defaults to 0.5
+	Rotation      *float64               `protobuf:"fixed64,15,opt,name=rotation,proto3,oneof" json:"rotation,omitempty"`                         // Counterclockwise, in degrees; defaults to 0, or 30 when tiled
+	Anchor        string                 `protobuf:"bytes,16,opt,name=anchor,proto3" json:"anchor,omitempty"`                                     // e.g., "top-left", "center"; defaults to "bottom-right"
+	Margin        *int32                 `protobuf:"varint,17,opt,name=margin,proto3,oneof" json:"margin,omitempty"`                              // Distance from the edges in pixels; defaults to 2% of the image width
+	Tiled         bool                   `protobuf:"varint,18,opt,name=tiled,proto3" json:"tiled,omitempty"`                                      // Repeat the watermark diagonally across the image
+	TileSpacing   *int32                 `protobuf:"varint,19,opt,name=tile_spacing,json=tileSpacing,proto3,oneof" json:"tile_spacing,omitempty"` // Space between tiled watermarks in pixels
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatermarkOptions) Reset() {
+	*x = WatermarkOptions{}
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatermarkOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatermarkOptions) ProtoMessage() {}
+
+func (x *WatermarkOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatermarkOptions.ProtoReflect.Descriptor instead.
+func (*WatermarkOptions) Descriptor() ([]byte, []int) {
+	return file_imageeditor_v1_image_editor_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *WatermarkOptions) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *WatermarkOptions) GetFont() string {
+	if x != nil {
+		return x.Font
+	}
+	return ""
+}
+
+func (x *WatermarkOptions) GetFontFile() []byte {
+	if x != nil {
+		return x.FontFile
+	}
+	return nil
+}
+
+func (x *WatermarkOptions) GetFontSize() float64 {
+	if x != nil {
+		return x.FontSize
+	}
+	return 0
+}
+
+func (x *WatermarkOptions) GetColor() string {
+	if x != nil {
+		return x.Color
+	}
+	return ""
+}
+
+func (x *WatermarkOptions) GetStrokeWidth() float64 {
+	if x != nil {
+		return x.StrokeWidth
+	}
+	return 0
+}
+
+func (x *WatermarkOptions) GetStrokeColor() string {
+	if x != nil {
+		return x.StrokeColor
+	}
+	return ""
+}
+
+func (x *WatermarkOptions) GetShadowOffsetX() int32 {
+	if x != nil {
+		return x.ShadowOffsetX
+	}
+	return 0
+}
+
+func (x *WatermarkOptions) GetShadowOffsetY() int32 {
+	if x != nil {
+		return x.ShadowOffsetY
+	}
+	return 0
+}
+
+func (x *WatermarkOptions) GetShadowBlur() float64 {
+	if x != nil {
+		return x.ShadowBlur
+	}
+	return 0
+}
+
+func (x *WatermarkOptions) GetShadowColor() string {
+	if x != nil {
+		return x.ShadowColor
+	}
+	return ""
+}
+
+func (x *WatermarkOptions) GetLogo() []byte {
+	if x != nil {
+		return x.Logo
+	}
+	return nil
+}
+
+func (x *WatermarkOptions) GetLogoScale() float64 {
+	if x != nil {
+		return x.LogoScale
+	}
+	return 0
+}
+
+func (x *WatermarkOptions) GetOpacity() float64 {
+	if x != nil && x.Opacity != nil {
+		return *x.Opacity
+	}
+	return 0
+}
+
+func (x *WatermarkOptions) GetRotation() float64 {
+	if x != nil && x.Rotation != nil {
+		return *x.Rotation
+	}
+	return 0
+}
+
+func (x *WatermarkOptions) GetAnchor() string {
+	if x != nil {
+		return x.Anchor
+	}
+	return ""
+}
+
+func (x *WatermarkOptions) GetMargin() int32 {
+	if x != nil && x.Margin != nil {
+		return *x.Margin
+	}
+	return 0
+}
+
+func (x *WatermarkOptions) GetTiled() bool {
+	if x != nil {
+		return x.Tiled
+	}
+	return false
+}
+
+func (x *WatermarkOptions) GetTileSpacing() int32 {
+	if x != nil && x.TileSpacing != nil {
+		return *x.TileSpacing
+	}
+	return 0
+}
+
+type ProcessRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Part:
+	//
+	//	*ProcessRequest_Operation
+	//	*ProcessRequest_Chunk
+	Part          isProcessRequest_Part `protobuf_oneof:"part"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProcessRequest) Reset() {
+	*x = ProcessRequest{}
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProcessRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProcessRequest) ProtoMessage() {}
+
+func (x *ProcessRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProcessRequest.ProtoReflect.Descriptor instead.
+func (*ProcessRequest) Descriptor() ([]byte, []int) {
+	return file_imageeditor_v1_image_editor_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ProcessRequest) GetPart() isProcessRequest_Part {
+	if x != nil {
+		return x.Part
+	}
+	return nil
+}
+
+func (x *ProcessRequest) GetOperation() *Operation {
+	if x != nil {
+		if x, ok := x.Part.(*ProcessRequest_Operation); ok {
+			return x.Operation
+		}
+	}
+	return nil
+}
+
+func (x *ProcessRequest) GetChunk() []byte {
+	if x != nil {
+		if x, ok := x.Part.(*ProcessRequest_Chunk); ok {
+			return x.Chunk
+		}
+	}
+	return nil
+}
+
+type isProcessRequest_Part interface {
+	isProcessRequest_Part()
+}
+
+type ProcessRequest_Operation struct {
+	Operation *Operation `protobuf:"bytes,1,opt,name=operation,proto3,oneof"` // First message
+}
+
+type ProcessRequest_Chunk struct {
+	Chunk []byte `protobuf:"bytes,2,opt,name=chunk,proto3,oneof"` // Following messages: the encoded image
+}
+
+func (*ProcessRequest_Operation) isProcessRequest_Part() {}
+
+func (*ProcessRequest_Chunk) isProcessRequest_Part() {}
+
+type ProcessResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Part:
+	//
+	//	*ProcessResponse_Info
+	//	*ProcessResponse_Chunk
+	Part          isProcessResponse_Part `protobuf_oneof:"part"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProcessResponse) Reset() {
+	*x = ProcessResponse{}
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProcessResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProcessResponse) ProtoMessage() {}
+
+func (x *ProcessResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProcessResponse.ProtoReflect.Descriptor instead.
+func (*ProcessResponse) Descriptor() ([]byte, []int) {
+	return file_imageeditor_v1_image_editor_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ProcessResponse) GetPart() isProcessResponse_Part {
+	if x != nil {
+		return x.Part
+	}
+	return nil
+}
+
+func (x *ProcessResponse) GetInfo() *ImageInfo {
+	if x != nil {
+		if x, ok := x.Part.(*ProcessResponse_Info); ok {
+			return x.Info
+		}
+	}
+	return nil
+}
+
+func (x *ProcessResponse) GetChunk() []byte {
+	if x != nil {
+		if x, ok := x.Part.(*ProcessResponse_Chunk); ok {
+			return x.Chunk
+		}
+	}
+	return nil
+}
+
+type isProcessResponse_Part interface {
+	isProcessResponse_Part()
+}
+
+type ProcessResponse_Info struct {
+	Info *ImageInfo `protobuf:"bytes,1,opt,name=info,proto3,oneof"` // First message
+}
+
+type ProcessResponse_Chunk struct {
+	Chunk []byte `protobuf:"bytes,2,opt,name=chunk,proto3,oneof"` // Following messages: the encoded result
+}
+
+func (*ProcessResponse_Info) isProcessResponse_Part() {}
+
+func (*ProcessResponse_Chunk) isProcessResponse_Part() {}
+
+// ImageInfo describes a result.
+type ImageInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MimeType      string                 `protobuf:"bytes,1,opt,name=mime_type,json=mimeType,proto3" json:"mime_type,omitempty"`
+	Format        string                 `protobuf:"bytes,2,opt,name=format,proto3" json:"format,omitempty"` // e.g., "jpeg", "png"
+	Width         int32                  `protobuf:"varint,3,opt,name=width,proto3" json:"width,omitempty"`
+	Height        int32                  `protobuf:"varint,4,opt,name=height,proto3" json:"height,omitempty"`
+	Bytes         int64                  `protobuf:"varint,5,opt,name=bytes,proto3" json:"bytes,omitempty"`                                   // Size of the encoded image
+	ProcessingMs  int64                  `protobuf:"varint,6,opt,name=processing_ms,json=processingMs,proto3" json:"processing_ms,omitempty"` // Time spent processing the image
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImageInfo) Reset() {
+	*x = ImageInfo{}
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImageInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImageInfo) ProtoMessage() {}
+
+func (x *ImageInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImageInfo.ProtoReflect.Descriptor instead.
+func (*ImageInfo) Descriptor() ([]byte, []int) {
+	return file_imageeditor_v1_image_editor_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *ImageInfo) GetMimeType() string {
+	if x != nil {
+		return x.MimeType
+	}
+	return ""
+}
+
+func (x *ImageInfo) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *ImageInfo) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *ImageInfo) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *ImageInfo) GetBytes() int64 {
+	if x != nil {
+		return x.Bytes
+	}
+	return 0
+}
+
+func (x *ImageInfo) GetProcessingMs() int64 {
+	if x != nil {
+		return x.ProcessingMs
+	}
+	return 0
+}
+
+type BatchRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Part:
+	//
+	//	*BatchRequest_Operation
+	//	*BatchRequest_Image
+	Part          isBatchRequest_Part `protobuf_oneof:"part"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchRequest) Reset() {
+	*x = BatchRequest{}
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchRequest) ProtoMessage() {}
+
+func (x *BatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchRequest.ProtoReflect.Descriptor instead.
+func (*BatchRequest) Descriptor() ([]byte, []int) {
+	return file_imageeditor_v1_image_editor_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *BatchRequest) GetPart() isBatchRequest_Part {
+	if x != nil {
+		return x.Part
+	}
+	return nil
+}
+
+func (x *BatchRequest) GetOperation() *Operation {
+	if x != nil {
+		if x, ok := x.Part.(*BatchRequest_Operation); ok {
+			return x.Operation
+		}
+	}
+	return nil
+}
+
+func (x *BatchRequest) GetImage() *ImageChunk {
+	if x != nil {
+		if x, ok := x.Part.(*BatchRequest_Image); ok {
+			return x.Image
+		}
+	}
+	return nil
+}
+
+type isBatchRequest_Part interface {
+	isBatchRequest_Part()
+}
+
+type BatchRequest_Operation struct {
+	Operation *Operation `protobuf:"bytes,1,opt,name=operation,proto3,oneof"` // First message
+}
+
+type BatchRequest_Image struct {
+	Image *ImageChunk `protobuf:"bytes,2,opt,name=image,proto3,oneof"` // Following messages
+}
+
+func (*BatchRequest_Operation) isBatchRequest_Part() {}
+
+func (*BatchRequest_Image) isBatchRequest_Part() {}
+
+// ImageChunk is a part of one image of a batch.
+type ImageChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"` // Client's ID of the image, unique within the batch
+	Data          []byte                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	Last          bool                   `protobuf:"varint,3,opt,name=last,proto3" json:"last,omitempty"` // Whether the image is complete
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImageChunk) Reset() {
+	*x = ImageChunk{}
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImageChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImageChunk) ProtoMessage() {}
+
+func (x *ImageChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImageChunk.ProtoReflect.Descriptor instead.
+func (*ImageChunk) Descriptor() ([]byte, []int) {
+	return file_imageeditor_v1_image_editor_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ImageChunk) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ImageChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *ImageChunk) GetLast() bool {
+	if x != nil {
+		return x.Last
+	}
+	return false
+}
+
+type BatchResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"` // ID of the image the message is about
+	// Types that are valid to be assigned to Part:
+	//
+	//	*BatchResponse_Info
+	//	*BatchResponse_Chunk
+	//	*BatchResponse_Error
+	Part          isBatchResponse_Part `protobuf_oneof:"part"`
+	Last          bool                 `protobuf:"varint,5,opt,name=last,proto3" json:"last,omitempty"` // Whether this is the last message about the image
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchResponse) Reset() {
+	*x = BatchResponse{}
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchResponse) ProtoMessage() {}
+
+func (x *BatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchResponse.ProtoReflect.Descriptor instead.
+func (*BatchResponse) Descriptor() ([]byte, []int) {
+	return file_imageeditor_v1_image_editor_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *BatchResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *BatchResponse) GetPart() isBatchResponse_Part {
+	if x != nil {
+		return x.Part
+	}
+	return nil
+}
+
+func (x *BatchResponse) GetInfo() *ImageInfo {
+	if x != nil {
+		if x, ok := x.Part.(*BatchResponse_Info); ok {
+			return x.Info
+		}
+	}
+	return nil
+}
+
+func (x *BatchResponse) GetChunk() []byte {
+	if x != nil {
+		if x, ok := x.Part.(*BatchResponse_Chunk); ok {
+			return x.Chunk
+		}
+	}
+	return nil
+}
+
+func (x *BatchResponse) GetError() *Error {
+	if x != nil {
+		if x, ok := x.Part.(*BatchResponse_Error); ok {
+			return x.Error
+		}
+	}
+	return nil
+}
+
+func (x *BatchResponse) GetLast() bool {
+	if x != nil {
+		return x.Last
+	}
+	return false
+}
+
+type isBatchResponse_Part interface {
+	isBatchResponse_Part()
+}
+
+type BatchResponse_Info struct {
+	Info *ImageInfo `protobuf:"bytes,2,opt,name=info,proto3,oneof"` // First message of a result
+}
+
+type BatchResponse_Chunk struct {
+	Chunk []byte `protobuf:"bytes,3,opt,name=chunk,proto3,oneof"` // Following messages: the encoded result
+}
+
+type BatchResponse_Error struct {
+	Error *Error `protobuf:"bytes,4,opt,name=error,proto3,oneof"` // Why the image failed, instead of a result
+}
+
+func (*BatchResponse_Info) isBatchResponse_Part() {}
+
+func (*BatchResponse_Chunk) isBatchResponse_Part() {}
+
+func (*BatchResponse_Error) isBatchResponse_Part() {}
+
+// Error is the failure of one image of a batch.
+type Error struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`                                                                                 // Stable error code, e.g., "invalid_input"
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`                                                                           // Human-readable message
+	Details       map[string]string      `protobuf:"bytes,3,rep,name=details,proto3" json:"details,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // Structured information about the error, as JSON values
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Error) Reset() {
+	*x = Error{}
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Error) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Error) ProtoMessage() {}
+
+func (x *Error) ProtoReflect() protoreflect.Message {
+	mi := &file_imageeditor_v1_image_editor_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Error.ProtoReflect.Descriptor instead.
+func (*Error) Descriptor() ([]byte, []int) {
+	return file_imageeditor_v1_image_editor_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *Error) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Error) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Error) GetDetails() map[string]string {
+	if x != nil {
+		return x.Details
+	}
+	return nil
+}
+
+var File_imageeditor_v1_image_editor_proto protoreflect.FileDescriptor
+
+const file_imageeditor_v1_image_editor_proto_rawDesc = "" +
+	"\n" +
+	"!imageeditor/v1/image_editor.proto\x12\x0eimageeditor.v1\"\x97\x05\n" +
+	"\tOperation\x127\n" +
+	"\x06resize\x18\x01 \x01(\v2\x1d.imageeditor.v1.ResizeOptionsH\x00R\x06resize\x121\n" +
+	"\x04crop\x18\x02 \x01(\v2\x1b.imageeditor.v1.CropOptionsH\x00R\x04crop\x12:\n" +
+	"\aupscale\x18\x03 \x01(\v2\x1e.imageeditor.v1.UpscaleOptionsH\x00R\aupscale\x12:\n" +
+	"\aconvert\x18\x04 \x01(\v2\x1e.imageeditor.v1.ConvertOptionsH\x00R\aconvert\x121\n" +
+	"\x04blur\x18\x05 \x01(\v2\x1b.imageeditor.v1.BlurOptionsH\x00R\x04blur\x12V\n" +
+	"\x11remove_background\x18\x06 \x01(\v2'.imageeditor.v1.RemoveBackgroundOptionsH\x00R\x10removeBackground\x12V\n" +
+	"\x11change_background\x18\a \x01(\v2'.imageeditor.v1.ChangeBackgroundOptionsH\x00R\x10changeBackground\x12=\n" +
+	"\bcompress\x18\b \x01(\v2\x1f.imageeditor.v1.CompressOptionsH\x00R\bcompress\x12@\n" +
+	"\twatermark\x18\t \x01(\v2 .imageeditor.v1.WatermarkOptionsH\x00R\twatermark\x125\n" +
+	"\x06output\x18\x0f \x01(\v2\x1d.imageeditor.v1.OutputOptionsR\x06outputB\v\n" +
+	"\toperation\"w\n" +
+	"\rOutputOptions\x12\x1a\n" +
+	"\bmetadata\x18\x01 \x01(\tR\bmetadata\x12%\n" +
+	"\x0eoutput_profile\x18\x02 \x01(\tR\routputProfile\x12#\n" +
+	"\rembed_profile\x18\x03 \x01(\bR\fembedProfile\"U\n" +
+	"\rResizeOptions\x12\x14\n" +
+	"\x05width\x18\x01 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\x02 \x01(\x05R\x06height\x12\x16\n" +
+	"\x06preset\x18\x03 \x01(\tR\x06preset\"W\n" +
+	"\vCropOptions\x12\f\n" +
+	"\x01x\x18\x01 \x01(\x05R\x01x\x12\f\n" +
+	"\x01y\x18\x02 \x01(\x05R\x01y\x12\x14\n" +
+	"\x05width\x18\x03 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\x04 \x01(\x05R\x06height\"3\n" +
+	"\x0eUpscaleOptions\x12!\n" +
+	"\fscale_factor\x18\x01 \x01(\x01R\vscaleFactor\"(\n" +
+	"\x0eConvertOptions\x12\x16\n" +
+	"\x06format\x18\x01 \x01(\tR\x06format\"#\n" +
+	"\x05Point\x12\f\n" +
+	"\x01x\x18\x01 \x01(\x05R\x01x\x12\f\n" +
+	"\x01y\x18\x02 \x01(\x05R\x01y\"P\n" +
+	"\vBlurOptions\x12)\n" +
+	"\x04path\x18\x01 \x03(\v2\x15.imageeditor.v1.PointR\x04path\x12\x16\n" +
+	"\x06radius\x18\x02 \x01(\x01R\x06radius\"\x19\n" +
+	"\x17RemoveBackgroundOptions\"\x8e\x01\n" +
+	"\x17ChangeBackgroundOptions\x120\n" +
+	"\x14new_background_image\x18\x01 \x01(\fR\x12newBackgroundImage\x12\x1f\n" +
+	"\vsolid_color\x18\x02 \x01(\tR\n" +
+	"solidColor\x12 \n" +
+	"\vtransparent\x18\x03 \x01(\bR\vtransparent\"\xc7\x01\n" +
+	"\x0fCompressOptions\x12\x1d\n" +
+	"\aquality\x18\x01 \x01(\x05H\x00R\aquality\x88\x01\x01\x12\x1b\n" +
+	"\x06format\x18\x02 \x01(\tH\x01R\x06format\x88\x01\x01\x12 \n" +
+	"\tmax_width\x18\x03 \x01(\x05H\x02R\bmaxWidth\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"max_height\x18\x04 \x01(\x05H\x03R\tmaxHeight\x88\x01\x01B\n" +
+	"\n" +
+	"\b_qualityB\t\n" +
+	"\a_formatB\f\n" +
+	"\n" +
+	"_max_widthB\r\n" +
+	"\v_max_height\"\xff\x04\n" +
+	"\x10WatermarkOptions\x12\x12\n" +
+	"\x04text\x18\x01 \x01(\tR\x04text\x12\x12\n" +
+	"\x04font\x18\x02 \x01(\tR\x04font\x12\x1b\n" +
+	"\tfont_file\x18\x03 \x01(\fR\bfontFile\x12\x1b\n" +
+	"\tfont_size\x18\x04 \x01(\x01R\bfontSize\x12\x14\n" +
+	"\x05color\x18\x05 \x01(\tR\x05color\x12!\n" +
+	"\fstroke_width\x18\x06 \x01(\x01R\vstrokeWidth\x12!\n" +
+	"\fstroke_color\x18\a \x01(\tR\vstrokeColor\x12&\n" +
+	"\x0fshadow_offset_x\x18\b \x01(\x05R\rshadowOffsetX\x12&\n" +
+	"\x0fshadow_offset_y\x18\t \x01(\x05R\rshadowOffsetY\x12\x1f\n" +
+	"\vshadow_blur\x18\n" +
+	" \x01(\x01R\n" +
+	"shadowBlur\x12!\n" +
+	"\fshadow_color\x18\v \x01(\tR\vshadowColor\x12\x12\n" +
+	"\x04logo\x18\f \x01(\fR\x04logo\x12\x1d\n" +
+	"\n" +
+	"logo_scale\x18\r \x01(\x01R\tlogoScale\x12\x1d\n" +
+	"\aopacity\x18\x0e \x01(\x01H\x00R\aopacity\x88\x01\x01\x12\x1f\n" +
+	"\brotation\x18\x0f \x01(\x01H\x01R\brotation\x88\x01\x01\x12\x16\n" +
+	"\x06anchor\x18\x10 \x01(\tR\x06anchor\x12\x1b\n" +
+	"\x06margin\x18\x11 \x01(\x05H\x02R\x06margin\x88\x01\x01\x12\x14\n" +
+	"\x05tiled\x18\x12 \x01(\bR\x05tiled\x12&\n" +
+	"\ftile_spacing\x18\x13 \x01(\x05H\x03R\vtileSpacing\x88\x01\x01B\n" +
+	"\n" +
+	"\b_opacityB\v\n" +
+	"\t_rotationB\t\n" +
+	"\a_marginB\x0f\n" +
+	"\r_tile_spacing\"k\n" +
+	"\x0eProcessRequest\x129\n" +
+	"\toperation\x18\x01 \x01(\v2\x19.imageeditor.v1.OperationH\x00R\toperation\x12\x16\n" +
+	"\x05chunk\x18\x02 \x01(\fH\x00R\x05chunkB\x06\n" +
+	"\x04part\"b\n" +
+	"\x0fProcessResponse\x12/\n" +
+	"\x04info\x18\x01 \x01(\v2\x19.imageeditor.v1.ImageInfoH\x00R\x04info\x12\x16\n" +
+	"\x05chunk\x18\x02 \x01(\fH\x00R\x05chunkB\x06\n" +
+	"\x04part\"\xa9\x01\n" +
+	"\tImageInfo\x12\x1b\n" +
+	"\tmime_type\x18\x01 \x01(\tR\bmimeType\x12\x16\n" +
+	"\x06format\x18\x02 \x01(\tR\x06format\x12\x14\n" +
+	"\x05width\x18\x03 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\x04 \x01(\x05R\x06height\x12\x14\n" +
+	"\x05bytes\x18\x05 \x01(\x03R\x05bytes\x12#\n" +
+	"\rprocessing_ms\x18\x06 \x01(\x03R\fprocessingMs\"\x85\x01\n" +
+	"\fBatchRequest\x129\n" +
+	"\toperation\x18\x01 \x01(\v2\x19.imageeditor.v1.OperationH\x00R\toperation\x122\n" +
+	"\x05image\x18\x02 \x01(\v2\x1a.imageeditor.v1.ImageChunkH\x00R\x05imageB\x06\n" +
+	"\x04part\"D\n" +
+	"\n" +
+	"ImageChunk\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\fR\x04data\x12\x12\n" +
+	"\x04last\x18\x03 \x01(\bR\x04last\"\xb3\x01\n" +
+	"\rBatchResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12/\n" +
+	"\x04info\x18\x02 \x01(\v2\x19.imageeditor.v1.ImageInfoH\x00R\x04info\x12\x16\n" +
+	"\x05chunk\x18\x03 \x01(\fH\x00R\x05chunk\x12-\n" +
+	"\x05error\x18\x04 \x01(\v2\x15.imageeditor.v1.ErrorH\x00R\x05error\x12\x12\n" +
+	"\x04last\x18\x05 \x01(\bR\x04lastB\x06\n" +
+	"\x04part\"\xaf\x01\n" +
+	"\x05Error\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12<\n" +
+	"\adetails\x18\x03 \x03(\v2\".imageeditor.v1.Error.DetailsEntryR\adetails\x1a:\n" +
+	"\fDetailsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x012\xa7\x01\n" +
+	"\vImageEditor\x12N\n" +
+	"\aProcess\x12\x1e.imageeditor.v1.ProcessRequest\x1a\x1f.imageeditor.v1.ProcessResponse(\x010\x01\x12H\n" +
+	"\x05Batch\x12\x1c.imageeditor.v1.BatchRequest\x1a\x1d.imageeditor.v1.BatchResponse(\x010\x01B&Z$image-editor-app/backend/rpc/imagepbb\x06proto3"
+
+var (
+	file_imageeditor_v1_image_editor_proto_rawDescOnce sync.Once
+	file_imageeditor_v1_image_editor_proto_rawDescData []byte
+)
+
+func file_imageeditor_v1_image_editor_proto_rawDescGZIP() []byte {
+	file_imageeditor_v1_image_editor_proto_rawDescOnce.Do(func() {
+		file_imageeditor_v1_image_editor_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_imageeditor_v1_image_editor_proto_rawDesc), len(file_imageeditor_v1_image_editor_proto_rawDesc)))
+	})
+	return file_imageeditor_v1_image_editor_proto_rawDescData
+}
+
+var file_imageeditor_v1_image_editor_proto_msgTypes = make([]protoimpl.MessageInfo, 20)
+var file_imageeditor_v1_image_editor_proto_goTypes = []any{
+	(*Operation)(nil),               // 0: imageeditor.v1.Operation
+	(*OutputOptions)(nil),           // 1: imageeditor.v1.OutputOptions
+	(*ResizeOptions)(nil),           // 2: imageeditor.v1.ResizeOptions
+	(*CropOptions)(nil),             // 3: imageeditor.v1.CropOptions
+	(*UpscaleOptions)(nil),          // 4: imageeditor.v1.UpscaleOptions
+	(*ConvertOptions)(nil),          // 5: imageeditor.v1.ConvertOptions
+	(*Point)(nil),                   // 6: imageeditor.v1.Point
+	(*BlurOptions)(nil),             // 7: imageeditor.v1.BlurOptions
+	(*RemoveBackgroundOptions)(nil), // 8: imageeditor.v1.RemoveBackgroundOptions
+	(*ChangeBackgroundOptions)(nil), // 9: imageeditor.v1.ChangeBackgroundOptions
+	(*CompressOptions)(nil),         // 10: imageeditor.v1.CompressOptions
+	(*WatermarkOptions)(nil),        // 11: imageeditor.v1.WatermarkOptions
+	(*ProcessRequest)(nil),          // 12: imageeditor.v1.ProcessRequest
+	(*ProcessResponse)(nil),         // 13: imageeditor.v1.ProcessResponse
+	(*ImageInfo)(nil),               // 14: imageeditor.v1.ImageInfo
+	(*BatchRequest)(nil),            // 15: imageeditor.v1.BatchRequest
+	(*ImageChunk)(nil),              // 16: imageeditor.v1.ImageChunk
+	(*BatchResponse)(nil),           // 17: imageeditor.v1.BatchResponse
+	(*Error)(nil),                   // 18: imageeditor.v1.Error
+	nil,                             // 19: imageeditor.v1.Error.DetailsEntry
+}
+var file_imageeditor_v1_image_editor_proto_depIdxs = []int32{
+	2,  // 0: imageeditor.v1.Operation.resize:type_name -> imageeditor.v1.ResizeOptions
+	3,  // 1: imageeditor.v1.Operation.crop:type_name -> imageeditor.v1.CropOptions
+	4,  // 2: imageeditor.v1.Operation.upscale:type_name -> imageeditor.v1.UpscaleOptions
+	5,  // 3: imageeditor.v1.Operation.convert:type_name -> imageeditor.v1.ConvertOptions
+	7,  // 4: imageeditor.v1.Operation.blur:type_name -> imageeditor.v1.BlurOptions
+	8,  // 5: imageeditor.v1.Operation.remove_background:type_name -> imageeditor.v1.RemoveBackgroundOptions
+	9,  // 6: imageeditor.v1.Operation.change_background:type_name -> imageeditor.v1.ChangeBackgroundOptions
+	10, // 7: imageeditor.v1.Operation.compress:type_name -> imageeditor.v1.CompressOptions
+	11, // 8: imageeditor.v1.Operation.watermark:type_name -> imageeditor.v1.WatermarkOptions
+	1,  // 9: imageeditor.v1.Operation.output:type_name -> imageeditor.v1.OutputOptions
+	6,  // 10: imageeditor.v1.BlurOptions.path:type_name -> imageeditor.v1.Point
+	0,  // 11: imageeditor.v1.ProcessRequest.operation:type_name -> imageeditor.v1.Operation
+	14, // 12: imageeditor.v1.ProcessResponse.info:type_name -> imageeditor.v1.ImageInfo
+	0,  // 13: imageeditor.v1.BatchRequest.operation:type_name -> imageeditor.v1.Operation
+	16, // 14: imageeditor.v1.BatchRequest.image:type_name -> imageeditor.v1.ImageChunk
+	14, // 15: imageeditor.v1.BatchResponse.info:type_name -> imageeditor.v1.ImageInfo
+	18, // 16: imageeditor.v1.BatchResponse.error:type_name -> imageeditor.v1.Error
+	19, // 17: imageeditor.v1.Error.details:type_name -> imageeditor.v1.Error.DetailsEntry
+	12, // 18: imageeditor.v1.ImageEditor.Process:input_type -> imageeditor.v1.ProcessRequest
+	15, // 19: imageeditor.v1.ImageEditor.Batch:input_type -> imageeditor.v1.BatchRequest
+	13, // 20: imageeditor.v1.ImageEditor.Process:output_type -> imageeditor.v1.ProcessResponse
+	17, // 21: imageeditor.v1.ImageEditor.Batch:output_type -> imageeditor.v1.BatchResponse
+	20, // [20:22] is the sub-list for method output_type
+	18, // [18:20] is the sub-list for method input_type
+	18, // [18:18] is the sub-list for extension type_name
+	18, // [18:18] is the sub-list for extension extendee
+	0,  // [0:18] is the sub-list for field type_name
+}
+
+func init() { file_imageeditor_v1_image_editor_proto_init() }
+func file_imageeditor_v1_image_editor_proto_init() {
+	if File_imageeditor_v1_image_editor_proto != nil {
+		return
+	}
+	file_imageeditor_v1_image_editor_proto_msgTypes[0].OneofWrappers = []any{
+		(*Operation_Resize)(nil),
+		(*Operation_Crop)(nil),
+		(*Operation_Upscale)(nil),
+		(*Operation_Convert)(nil),
+		(*Operation_Blur)(nil),
+		(*Operation_RemoveBackground)(nil),
+		(*Operation_ChangeBackground)(nil),
+		(*Operation_Compress)(nil),
+		(*Operation_Watermark)(nil),
+	}
+	file_imageeditor_v1_image_editor_proto_msgTypes[10].OneofWrappers = []any{}
+	file_imageeditor_v1_image_editor_proto_msgTypes[11].OneofWrappers = []any{}
+	file_imageeditor_v1_image_editor_proto_msgTypes[12].OneofWrappers = []any{
+		(*ProcessRequest_Operation)(nil),
+		(*ProcessRequest_Chunk)(nil),
+	}
+	file_imageeditor_v1_image_editor_proto_msgTypes[13].OneofWrappers = []any{
+		(*ProcessResponse_Info)(nil),
+		(*ProcessResponse_Chunk)(nil),
+	}
+	file_imageeditor_v1_image_editor_proto_msgTypes[15].OneofWrappers = []any{
+		(*BatchRequest_Operation)(nil),
+		(*BatchRequest_Image)(nil),
+	}
+	file_imageeditor_v1_image_editor_proto_msgTypes[17].OneofWrappers = []any{
+		(*BatchResponse_Info)(nil),
+		(*BatchResponse_Chunk)(nil),
+		(*BatchResponse_Error)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_imageeditor_v1_image_editor_proto_rawDesc), len(file_imageeditor_v1_image_editor_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   20,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_imageeditor_v1_image_editor_proto_goTypes,
+		DependencyIndexes: file_imageeditor_v1_image_editor_proto_depIdxs,
+		MessageInfos:      file_imageeditor_v1_image_editor_proto_msgTypes,
+	}.Build()
+	File_imageeditor_v1_image_editor_proto = out.File
+	file_imageeditor_v1_image_editor_proto_goTypes = nil
+	file_imageeditor_v1_image_editor_proto_depIdxs = nil
+}
@@ -0,0 +1,177 @@
+// The gRPC API of the image editor. It mirrors the /v1 REST API, whose
+// request models are in models/image_request.go, with images sent and
+// returned as raw bytes in chunks rather than as base64.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: imageeditor/v1/image_editor.proto
+
+package imagepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ImageEditor_Process_FullMethodName = "/imageeditor.v1.ImageEditor/Process"
+	ImageEditor_Batch_FullMethodName   = "/imageeditor.v1.ImageEditor/Batch"
+)
+
+// ImageEditorClient is the client API for ImageEditor service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ImageEditor processes images. Calls are authenticated with an API key in
+// the "authorization" ("Bearer <key>") or "x-api-key" metadata and metered
+// like REST requests. Failures carry a google.rpc.ErrorInfo detail whose
+// reason is the REST error code, e.g., "invalid_input".
+type ImageEditorClient interface {
+	// Process applies one operation to one image. The client sends the
+	// operation, then the image in chunks, and closes its side; the server
+	// responds with a description of the result, then the result in chunks.
+	Process(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ProcessRequest, ProcessResponse], error)
+	// Batch applies one operation to many images. The client sends the
+	// operation, then the images in chunks, each image under an ID of its
+	// own. The server responds for each image as soon as it is processed, in
+	// any order, so that images may be sent for as long as the stream is
+	// open. An image that fails is reported without failing the batch.
+	Batch(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[BatchRequest, BatchResponse], error)
+}
+
+type imageEditorClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewImageEditorClient(cc grpc.ClientConnInterface) ImageEditorClient {
+	return &imageEditorClient{cc}
+}
+
+func (c *imageEditorClient) Process(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ProcessRequest, ProcessResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ImageEditor_ServiceDesc.Streams[0], ImageEditor_Process_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ProcessRequest, ProcessResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ImageEditor_ProcessClient = grpc.BidiStreamingClient[ProcessRequest, ProcessResponse]
+
+func (c *imageEditorClient) Batch(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[BatchRequest, BatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ImageEditor_ServiceDesc.Streams[1], ImageEditor_Batch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[BatchRequest, BatchResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ImageEditor_BatchClient = grpc.BidiStreamingClient[BatchRequest, BatchResponse]
+
+// ImageEditorServer is the server API for ImageEditor service.
+// All implementations must embed UnimplementedImageEditorServer
+// for forward compatibility.
+//
+// ImageEditor processes images. Calls are authenticated with an API key in
+// the "authorization" ("Bearer <key>") or "x-api-key" metadata and metered
+// like REST requests. Failures carry a google.rpc.ErrorInfo detail whose
+// reason is the REST error code, e.g., "invalid_input".
+type ImageEditorServer interface {
+	// Process applies one operation to one image. The client sends the
+	// operation, then the image in chunks, and closes its side; the server
+	// responds with a description of the result, then the result in chunks.
+	Process(grpc.BidiStreamingServer[ProcessRequest, ProcessResponse]) error
+	// Batch applies one operation to many images. The client sends the
+	// operation, then the images in chunks, each image under an ID of its
+	// own. The server responds for each image as soon as it is processed, in
+	// any order, so that images may be sent for as long as the stream is
+	// open. An image that fails is reported without failing the batch.
+	Batch(grpc.BidiStreamingServer[BatchRequest, BatchResponse]) error
+	mustEmbedUnimplementedImageEditorServer()
+}
+
+// UnimplementedImageEditorServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedImageEditorServer struct{}
+
+func (UnimplementedImageEditorServer) Process(grpc.BidiStreamingServer[ProcessRequest, ProcessResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Process not implemented")
+}
+func (UnimplementedImageEditorServer) Batch(grpc.BidiStreamingServer[BatchRequest, BatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Batch not implemented")
+}
+func (UnimplementedImageEditorServer) mustEmbedUnimplementedImageEditorServer() {}
+func (UnimplementedImageEditorServer) testEmbeddedByValue()                     {}
+
+// UnsafeImageEditorServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ImageEditorServer will
+// result in compilation errors.
+type UnsafeImageEditorServer interface {
+	mustEmbedUnimplementedImageEditorServer()
+}
+
+func RegisterImageEditorServer(s grpc.ServiceRegistrar, srv ImageEditorServer) {
+	// If the following call pancis, it indicates UnimplementedImageEditorServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ImageEditor_ServiceDesc, srv)
+}
+
+func _ImageEditor_Process_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ImageEditorServer).Process(&grpc.GenericServerStream[ProcessRequest, ProcessResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ImageEditor_ProcessServer = grpc.BidiStreamingServer[ProcessRequest, ProcessResponse]
+
+func _ImageEditor_Batch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ImageEditorServer).Batch(&grpc.GenericServerStream[BatchRequest, BatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ImageEditor_BatchServer = grpc.BidiStreamingServer[BatchRequest, BatchResponse]
+
+// ImageEditor_ServiceDesc is the grpc.ServiceDesc for ImageEditor service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ImageEditor_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "imageeditor.v1.ImageEditor",
+	HandlerType: (*ImageEditorServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Process",
+			Handler:       _ImageEditor_Process_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Batch",
+			Handler:       _ImageEditor_Batch_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "imageeditor/v1/image_editor.proto",
+}
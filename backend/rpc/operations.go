@@ -0,0 +1,158 @@
+package rpc
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"image-editor-app/backend/handlers"
+	"image-editor-app/backend/models"
+	"image-editor-app/backend/rpc/imagepb"
+	"image-editor-app/backend/services"
+	"image-editor-app/backend/tracing"
+
+	"github.com/gin-gonic/gin/binding"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// operation is a bound and validated operation, ready to apply to images.
+type operation struct {
+	name   string // As in the REST routes, e.g., "remove-background"
+	steps  services.Steps
+	output models.OutputOptions
+}
+
+// bindOperation converts an operation message to the parameters of the
+// registered operation and validates them like the body of a REST request.
+func bindOperation(op *imagepb.Operation) (operation, error) {
+	if op == nil {
+		return operation{}, &services.Error{Code: services.CodeInvalidInput, Message: "the first message must be the operation"}
+	}
+	output := outputOptions(op.GetOutput())
+	switch o := op.GetOperation().(type) {
+	case *imagepb.Operation_Resize:
+		return bind("resize", &models.ResizeParams{
+			Width:  int(o.Resize.GetWidth()),
+			Height: int(o.Resize.GetHeight()),
+			Preset: o.Resize.GetPreset(),
+		}, output)
+	case *imagepb.Operation_Crop:
+		return bind("crop", &models.CropParams{
+			X:      int(o.Crop.GetX()),
+			Y:      int(o.Crop.GetY()),
+			Width:  int(o.Crop.GetWidth()),
+			Height: int(o.Crop.GetHeight()),
+		}, output)
+	case *imagepb.Operation_Upscale:
+		return bind("upscale", &models.UpscaleParams{ScaleFactor: o.Upscale.GetScaleFactor()}, output)
+	case *imagepb.Operation_Convert:
+		return bind("convert", &models.ConvertParams{Format: o.Convert.GetFormat()}, output)
+	case *imagepb.Operation_Blur:
+		path := make(models.Path, 0, len(o.Blur.GetPath()))
+		for _, p := range o.Blur.GetPath() {
+			path = append(path, models.Point{X: int(p.GetX()), Y: int(p.GetY())})
+		}
+		return bind("blur", &models.BlurParams{Path: path, Radius: o.Blur.GetRadius()}, output)
+	case *imagepb.Operation_RemoveBackground:
+		return bind("remove-background", &models.RemoveBackgroundParams{}, output)
+	case *imagepb.Operation_ChangeBackground:
+		params := &models.ChangeBackgroundParams{
+			SolidColor:  o.ChangeBackground.GetSolidColor(),
+			Transparent: o.ChangeBackground.GetTransparent(),
+		}
+		if background := o.ChangeBackground.GetNewBackgroundImage(); len(background) > 0 {
+			// The Python backend takes images in base64
+			params.NewBackgroundImage = base64.StdEncoding.EncodeToString(background)
+		}
+		return bind("change-background", params, output)
+	case *imagepb.Operation_Compress:
+		c := o.Compress
+		return bind("compress", &models.CompressParams{
+			Format:    c.Format,
+			Quality:   optionalInt(c.Quality),
+			MaxWidth:  optionalInt(c.MaxWidth),
+			MaxHeight: optionalInt(c.MaxHeight),
+		}, output)
+	case *imagepb.Operation_Watermark:
+		w := o.Watermark
+		params := &models.WatermarkParams{
+			Text:          w.GetText(),
+			Font:          w.GetFont(),
+			FontSize:      w.GetFontSize(),
+			Color:         w.GetColor(),
+			StrokeWidth:   w.GetStrokeWidth(),
+			StrokeColor:   w.GetStrokeColor(),
+			ShadowOffsetX: int(w.GetShadowOffsetX()),
+			ShadowOffsetY: int(w.GetShadowOffsetY()),
+			ShadowBlur:    w.GetShadowBlur(),
+			ShadowColor:   w.GetShadowColor(),
+			LogoScale:     w.GetLogoScale(),
+			Opacity:       w.Opacity,
+			Rotation:      w.Rotation,
+			Anchor:        w.GetAnchor(),
+			Margin:        optionalInt(w.Margin),
+			Tiled:         w.GetTiled(),
+			TileSpacing:   optionalInt(w.TileSpacing),
+		}
+		// The operation takes the font and logo in base64, as REST requests do
+		if len(w.GetFontFile()) > 0 {
+			params.FontFile = base64.StdEncoding.EncodeToString(w.GetFontFile())
+		}
+		if len(w.GetLogo()) > 0 {
+			params.Logo = base64.StdEncoding.EncodeToString(w.GetLogo())
+		}
+		return bind("watermark", params, output)
+	default:
+		return operation{}, &services.Error{Code: services.CodeInvalidInput, Message: "no operation was given"}
+	}
+}
+
+// bind validates the parameters of the named operation and the output
+// settings, and returns the operation applying them.
+func bind(name string, params any, output models.OutputOptions) (operation, error) {
+	op, ok := services.LookupOperation(name)
+	if !ok {
+		return operation{}, &services.Error{Code: services.CodeInternal, Message: "internal error", Err: fmt.Errorf("operation %q is not registered", name)}
+	}
+	for _, v := range []any{params, output} {
+		if err := binding.Validator.ValidateStruct(v); err != nil {
+			return operation{}, handlers.RequestError(err)
+		}
+	}
+	if err := op.Validate(params); err != nil {
+		return operation{}, err
+	}
+	return operation{name: name, steps: services.Steps{{Operation: op, Params: params}}, output: output}, nil
+}
+
+func outputOptions(o *imagepb.OutputOptions) models.OutputOptions {
+	return models.OutputOptions{
+		Metadata:      o.GetMetadata(),
+		OutputProfile: o.GetOutputProfile(),
+		EmbedProfile:  o.GetEmbedProfile(),
+	}
+}
+
+func optionalInt(v *int32) *int {
+	if v == nil {
+		return nil
+	}
+	n := int(*v)
+	return &n
+}
+
+// apply applies the operation to an encoded image within the operation's
+// time limit, once there is capacity for it.
+func (op operation) apply(ctx context.Context, data []byte) (result *services.Result, err error) {
+	ctx, cancel := context.WithTimeout(ctx, op.steps.Timeout())
+	defer cancel()
+	ctx, span := tracing.Start(ctx, "operation", attribute.String("image.operation", op.name))
+	defer func() { tracing.End(span, err) }()
+
+	release, err := op.steps.Admit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return op.steps.Apply(ctx, data, op.output)
+}
@@ -0,0 +1,309 @@
+// Package rpc implements the gRPC API defined in proto/imageeditor/v1 on top
+// of the services package, as the REST handlers do for the Gin router.
+// Authentication, limits, logs and metrics are left to the interceptors of
+// the server, which hand the client of each call to the service with
+// WithClient.
+package rpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"image-editor-app/backend/logging"
+	"image-editor-app/backend/rpc/imagepb"
+	"image-editor-app/backend/services"
+)
+
+// chunkSize is the size of the chunks results are sent in, well below the
+// 4 MiB message size limit of gRPC.
+const chunkSize = 64 << 10
+
+// Client is the client a call is made by.
+type Client struct {
+	Charge func(units int64) *services.Error // Charges cost units to the client's limits
+}
+
+type clientKey struct{}
+
+// WithClient returns a context for a call made by client.
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// charge charges units to the client of the call under ctx, if any.
+func charge(ctx context.Context, units int64) error {
+	client, _ := ctx.Value(clientKey{}).(Client)
+	if client.Charge == nil {
+		return nil
+	}
+	if svcErr := client.Charge(units); svcErr != nil {
+		return svcErr
+	}
+	return nil
+}
+
+// Service implements imagepb.ImageEditorServer.
+type Service struct {
+	imagepb.UnimplementedImageEditorServer
+}
+
+// NewService returns the image editor service.
+func NewService() *Service {
+	return &Service{}
+}
+
+// Process implements the Process RPC. The progress of the call can be
+// followed at /v1/progress/{id} under its request ID.
+func (s *Service) Process(stream imagepb.ImageEditor_ProcessServer) (err error) {
+	ctx := services.TrackProgress(stream.Context(), logging.RequestID(stream.Context()))
+	defer func() {
+		if err != nil {
+			services.FinishProgress(ctx, err)
+			err = Status(err)
+		}
+	}()
+
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	op, err := bindOperation(first.GetOperation())
+	if err != nil {
+		return err
+	}
+	if err := charge(ctx, op.steps.Cost()); err != nil {
+		return err
+	}
+
+	var data []byte
+	maxBytes := services.GetLimits().MaxBodyBytes
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		chunk, ok := msg.GetPart().(*imagepb.ProcessRequest_Chunk)
+		if !ok {
+			return &services.Error{Code: services.CodeInvalidInput, Message: "the operation must only be sent once, before the image"}
+		}
+		if int64(len(data)+len(chunk.Chunk)) > maxBytes {
+			return &services.Error{
+				Code:    services.CodeTooLarge,
+				Message: "image exceeds the maximum allowed size",
+				Details: map[string]any{"max_bytes": maxBytes},
+			}
+		}
+		data = append(data, chunk.Chunk...)
+	}
+	if len(data) == 0 {
+		return &services.Error{Code: services.CodeInvalidInput, Message: "no image was sent"}
+	}
+	services.ReportProgress(ctx, services.StageReceived, -1)
+
+	start := time.Now()
+	result, err := op.apply(ctx, data)
+	if err != nil {
+		return err
+	}
+	err = sendResult(result, time.Since(start), func(info *imagepb.ImageInfo, chunk []byte) error {
+		if info != nil {
+			return stream.Send(&imagepb.ProcessResponse{Part: &imagepb.ProcessResponse_Info{Info: info}})
+		}
+		return stream.Send(&imagepb.ProcessResponse{Part: &imagepb.ProcessResponse_Chunk{Chunk: chunk}})
+	})
+	if err != nil {
+		return err
+	}
+	services.FinishProgress(ctx, nil)
+	return nil
+}
+
+// sendResult sends the description of a result, then the result in chunks.
+// send is called with either a description or a chunk.
+func sendResult(result *services.Result, elapsed time.Duration, send func(*imagepb.ImageInfo, []byte) error) error {
+	info := &imagepb.ImageInfo{
+		MimeType:     result.MIMEType(),
+		Format:       result.Format,
+		Width:        int32(result.Width),
+		Height:       int32(result.Height),
+		Bytes:        int64(len(result.Data)),
+		ProcessingMs: elapsed.Milliseconds(),
+	}
+	if err := send(info, nil); err != nil {
+		return err
+	}
+	for data := result.Data; len(data) > 0; {
+		n := min(len(data), chunkSize)
+		if err := send(nil, data[:n]); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// Batch implements the Batch RPC. Images are processed by as many workers
+// as REST batches, and a batch stream is limited to the files and bytes of
+// a REST batch.
+func (s *Service) Batch(stream imagepb.ImageEditor_BatchServer) error {
+	ctx := stream.Context()
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	op, err := bindOperation(first.GetOperation())
+	if err != nil {
+		return Status(err)
+	}
+
+	b := &batch{
+		ctx:     ctx,
+		op:      op,
+		stream:  stream,
+		workers: make(chan struct{}, max(services.GetLimits().BatchWorkers, 1)),
+		pending: map[string][]byte{},
+		seen:    map[string]bool{},
+	}
+	err = b.receive()
+	b.wg.Wait()
+	if err != nil {
+		return Status(err)
+	}
+	return nil
+}
+
+// batch is the state of a Batch call.
+type batch struct {
+	ctx     context.Context
+	op      operation
+	stream  imagepb.ImageEditor_BatchServer
+	workers chan struct{} // Holds a token per image being processed
+
+	pending map[string][]byte // Images being received, by ID
+	seen    map[string]bool   // IDs of the images received
+	files   int
+	bytes   int64
+
+	wg     sync.WaitGroup
+	sendMu sync.Mutex // Sends of one image are not interleaved with others
+}
+
+// receive reads images until the client closes its side of the stream,
+// processing each once complete.
+func (b *batch) receive() error {
+	for {
+		msg, err := b.stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		chunk := msg.GetImage()
+		if chunk == nil {
+			return &services.Error{Code: services.CodeInvalidInput, Message: "the operation must only be sent once, before the images"}
+		}
+
+		id := chunk.GetId()
+		if _, receiving := b.pending[id]; !receiving {
+			if b.seen[id] {
+				b.fail(id, &services.Error{Code: services.CodeInvalidInput, Message: "image ID was already used in the batch"})
+				continue
+			}
+			b.seen[id] = true
+			b.files++
+		}
+		b.bytes += int64(len(chunk.GetData()))
+		if err := services.CheckBatchSize(b.files, b.bytes); err != nil {
+			return err
+		}
+		data := append(b.pending[id], chunk.GetData()...)
+		if !chunk.GetLast() {
+			b.pending[id] = data
+			continue
+		}
+		delete(b.pending, id)
+		b.process(id, data)
+	}
+
+	for id := range b.pending {
+		b.fail(id, &services.Error{Code: services.CodeInvalidInput, Message: "the stream ended before the image was complete"})
+	}
+	return nil
+}
+
+// process charges an image to the client and processes it on a worker,
+// waiting for one to be free.
+func (b *batch) process(id string, data []byte) {
+	if err := charge(b.ctx, b.op.steps.Cost()); err != nil {
+		b.fail(id, err)
+		return
+	}
+	select {
+	case b.workers <- struct{}{}:
+	case <-b.ctx.Done():
+		return
+	}
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		defer func() { <-b.workers }()
+
+		start := time.Now()
+		result, err := b.op.apply(b.ctx, data)
+		if err != nil {
+			b.fail(id, err)
+			return
+		}
+		slog.InfoContext(b.ctx, "batch image completed",
+			"operation", b.op.name,
+			"image_id", id,
+			"bytes", len(result.Data),
+			"duration_ms", time.Since(start).Milliseconds())
+
+		b.sendMu.Lock()
+		defer b.sendMu.Unlock()
+		var sent int
+		err = sendResult(result, time.Since(start), func(info *imagepb.ImageInfo, chunk []byte) error {
+			resp := &imagepb.BatchResponse{Id: id}
+			if info != nil {
+				resp.Part = &imagepb.BatchResponse_Info{Info: info}
+			} else {
+				sent += len(chunk)
+				resp.Part = &imagepb.BatchResponse_Chunk{Chunk: chunk}
+				resp.Last = sent == len(result.Data)
+			}
+			return b.stream.Send(resp)
+		})
+		if err != nil {
+			slog.WarnContext(b.ctx, "sending batch result", "image_id", id, "error", err)
+		}
+	}()
+}
+
+// fail reports the failure of one image.
+func (b *batch) fail(id string, err error) {
+	svcErr := services.AsError(err)
+	if svcErr.HTTPStatus() >= http.StatusInternalServerError {
+		slog.ErrorContext(b.ctx, "batch image failed", "operation", b.op.name, "image_id", id, "error", err)
+	}
+	b.sendMu.Lock()
+	defer b.sendMu.Unlock()
+	b.stream.Send(&imagepb.BatchResponse{
+		Id: id,
+		Part: &imagepb.BatchResponse_Error{Error: &imagepb.Error{
+			Code:    string(svcErr.Code),
+			Message: svcErr.Message,
+			Details: detailStrings(svcErr.Details),
+		}},
+		Last: true,
+	})
+}
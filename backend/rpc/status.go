@@ -0,0 +1,85 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+
+	"image-editor-app/backend/services"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// errorDomain qualifies the reasons of ErrorInfo details.
+const errorDomain = "api.imagenerd.in"
+
+// Status converts an error to a gRPC status error. Errors of the services
+// package keep their code as the reason of an ErrorInfo detail, with their
+// details JSON-encoded in its metadata, and when to retry, if known, in a
+// RetryInfo detail. Errors that already are status errors, such as those of
+// the stream, are returned as they are.
+func Status(err error) error {
+	var svcErr *services.Error
+	if !errors.As(err, &svcErr) {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		svcErr = services.AsError(err)
+	}
+
+	details := []protoadapt.MessageV1{
+		&errdetails.ErrorInfo{Reason: string(svcErr.Code), Domain: errorDomain, Metadata: detailStrings(svcErr.Details)},
+	}
+	if svcErr.RetryAfter > 0 {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(svcErr.RetryAfter)})
+	}
+	st := status.New(grpcCode(svcErr.Code), svcErr.Message)
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// grpcCode returns the gRPC status code closest to a service error code.
+func grpcCode(code services.ErrorCode) codes.Code {
+	switch code {
+	case services.CodeInvalidInput, services.CodeUnsupportedFormat, services.CodeLimitExceeded:
+		return codes.InvalidArgument
+	case services.CodeTooLarge, services.CodeRateLimited, services.CodeQuotaExceeded:
+		return codes.ResourceExhausted
+	case services.CodeUnauthorized:
+		return codes.Unauthenticated
+	case services.CodeForbidden:
+		return codes.PermissionDenied
+	case services.CodeNotFound, services.CodeExpired:
+		return codes.NotFound
+	case services.CodeBackendUnavailable, services.CodeOverloaded:
+		return codes.Unavailable
+	case services.CodeTimeout:
+		return codes.DeadlineExceeded
+	case services.CodeCanceled:
+		return codes.Canceled
+	default:
+		return codes.Internal
+	}
+}
+
+// detailStrings JSON-encodes the values of error details, for metadata
+// maps that only hold strings.
+func detailStrings(details map[string]any) map[string]string {
+	if len(details) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(details))
+	for k, v := range details {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			continue
+		}
+		m[k] = string(raw)
+	}
+	return m
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"image-editor-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOverloaded(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	saved := services.GetLimits()
+	t.Cleanup(func() { services.SetLimits(saved) })
+
+	// Requests are turned away when the queue is full, or after waiting in it
+	for name, queueLength := range map[string]int{"queue full": 0, "queue wait": 1} {
+		limits := saved
+		limits.Capacity = 1
+		limits.QueueLength = queueLength
+		limits.MaxQueueWait = 20 * time.Millisecond
+		services.SetLimits(limits)
+		router := SetupRouter()
+
+		// Work already running takes the whole capacity
+		release, err := services.Admit(context.Background(), "resize")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body := `{"image_base64":"` + base64.StdEncoding.EncodeToString(testPNG(t, 8, 8)) + `","width":4}`
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/resize", strings.NewReader(body)))
+		release()
+
+		var resp struct {
+			Code string `json:"code"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: invalid JSON: %v", name, err)
+		}
+		if w.Code != http.StatusServiceUnavailable || resp.Code != string(services.CodeOverloaded) {
+			t.Errorf("%s: status %d, body %s; want 503 overloaded", name, w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("Retry-After"); got != "5" {
+			t.Errorf("%s: Retry-After %q, want 5", name, got)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"image-editor-app/backend/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNotModified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := SetupRouter()
+	limitAnonymous(t, ratelimit.Policy{Rate: 1, Burst: 10})
+
+	body := `{"image_base64":"` + base64.StdEncoding.EncodeToString(testPNG(t, 8, 8)) + `","width":4}`
+	post := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/resize", strings.NewReader(body))
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := post("")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("status %d with ETag %q, want 200 with an ETag", first.Code, etag)
+	}
+
+	for _, ifNoneMatch := range []string{etag, `"other", ` + strings.TrimPrefix(etag, "W/"), "*"} {
+		w := post(ifNoneMatch)
+		if w.Code != http.StatusNotModified {
+			t.Errorf("If-None-Match %s: status %d, want 304", ifNoneMatch, w.Code)
+			continue
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s: 304 with a body", ifNoneMatch)
+		}
+		if got := w.Header().Get("ETag"); got != etag {
+			t.Errorf("If-None-Match %s: ETag %q, want %q", ifNoneMatch, got, etag)
+		}
+		if got := w.Header().Get("Cache-Control"); got != "private, max-age=86400" {
+			t.Errorf("If-None-Match %s: Cache-Control %q", ifNoneMatch, got)
+		}
+	}
+
+	if w := post(`W/"stale"`); w.Code != http.StatusOK {
+		t.Errorf("stale If-None-Match: status %d, want 200", w.Code)
+	}
+}
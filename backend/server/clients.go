@@ -0,0 +1,234 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"image-editor-app/backend/apikeys"
+	"image-editor-app/backend/handlers"
+	"image-editor-app/backend/ratelimit"
+	"image-editor-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clientLimits identifies clients by API key or, for anonymous clients, by
+// IP address, and meters the cost of their requests.
+type clientLimits struct {
+	keys        apikeys.Store // nil when API keys are not enabled
+	requireKeys bool          // Whether anonymous requests are rejected
+	adminToken  string        // Bearer token of the admin endpoints; empty to disable them
+
+	keyPolicy       ratelimit.Policy // Limits of API keys without limits of their own
+	anonymousPolicy ratelimit.Policy // Limits of each anonymous IP address
+	limiter         *ratelimit.Limiter
+}
+
+// loadClientLimits configures client limits from the environment:
+//
+//   - API_KEYS_FILE: JSON file holding the issued API keys; API keys are disabled if unset
+//   - API_KEYS_REQUIRED: "true" to reject requests without an API key
+//   - ADMIN_TOKEN: Bearer token of the /admin endpoints, which are disabled if unset
+//   - RATE_LIMIT_RATE, RATE_LIMIT_BURST and RATE_LIMIT_DAILY_QUOTA: default
+//     limits of API keys, in cost units per second, units at once and units
+//     per day, where a quota of 0 means none
+//   - RATE_LIMIT_ANONYMOUS_RATE, RATE_LIMIT_ANONYMOUS_BURST and
+//     RATE_LIMIT_ANONYMOUS_DAILY_QUOTA: limits of each anonymous IP address
+//
+// Requests cost the units of their operation, as weighed for admission.
+func loadClientLimits() (*clientLimits, error) {
+	l := &clientLimits{
+		requireKeys:     os.Getenv("API_KEYS_REQUIRED") == "true",
+		adminToken:      os.Getenv("ADMIN_TOKEN"),
+		keyPolicy:       ratelimit.Policy{Rate: 2, Burst: 40, DailyQuota: 20000},
+		anonymousPolicy: ratelimit.Policy{Rate: 0.5, Burst: 20, DailyQuota: 1000},
+		limiter:         ratelimit.New(),
+	}
+	if file := os.Getenv("API_KEYS_FILE"); file != "" {
+		keys, err := apikeys.OpenFile(file)
+		if err != nil {
+			return nil, err
+		}
+		l.keys = keys
+	}
+	if l.requireKeys && l.keys == nil {
+		return nil, fmt.Errorf("API_KEYS_REQUIRED is set without API_KEYS_FILE")
+	}
+	loadPolicy(&l.keyPolicy, "RATE_LIMIT_")
+	loadPolicy(&l.anonymousPolicy, "RATE_LIMIT_ANONYMOUS_")
+	return l, nil
+}
+
+// loadPolicy overrides the limits of p set in environment variables starting with prefix.
+func loadPolicy(p *ratelimit.Policy, prefix string) {
+	if v, ok := envNumber(prefix + "RATE"); ok {
+		p.Rate = v
+	}
+	if v, ok := envNumber(prefix + "BURST"); ok {
+		p.Burst = int64(v)
+	}
+	if v, ok := envNumber(prefix + "DAILY_QUOTA"); ok {
+		p.DailyQuota = int64(v)
+	}
+}
+
+func envNumber(name string) (float64, bool) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil || v < 0 {
+		slog.Warn("ignoring invalid environment variable", "name", name, "value", raw)
+		return 0, false
+	}
+	return v, true
+}
+
+// limit authenticates requests that carry an API key, either as a Bearer
+// token or in X-API-Key, and charges the cost of the request to the key, or
+// to the client's IP address for anonymous requests. The state of the
+// client's limits is returned in RateLimit headers.
+func (l *clientLimits) limit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client, policy, ok := l.authenticateRequest(c)
+		if !ok {
+			return
+		}
+		charge := l.charger(client, policy, c.Writer.Header())
+		if svcErr := charge(services.GetLimits().Cost(path.Base(c.FullPath()))); svcErr != nil {
+			handlers.RespondError(c, svcErr)
+			return
+		}
+		c.Set(handlers.ChargeKey, charge)
+		c.Next()
+	}
+}
+
+// authenticate authenticates requests as limit does without charging them,
+// for reads that clients repeat while they wait, such as polling a job.
+func (l *clientLimits) authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := l.authenticateRequest(c); ok {
+			c.Next()
+		}
+	}
+}
+
+// authenticateRequest identifies the client of a request and records its
+// key in the context. It responds with the error and reports false if the
+// request is not authorized.
+func (l *clientLimits) authenticateRequest(c *gin.Context) (client string, policy ratelimit.Policy, ok bool) {
+	key, client, policy, svcErr := l.identify(requestAPIKey(c), c.ClientIP())
+	if svcErr != nil {
+		handlers.RespondError(c, svcErr)
+		return "", policy, false
+	}
+	if key != nil {
+		c.Set(handlers.ClientKey, key.ID)
+		if key.WebhookSecret != "" {
+			c.Set(handlers.WebhookSecretKey, key.WebhookSecret)
+		}
+	}
+	return client, policy, true
+}
+
+// identify returns the key of a request made with the API key secret, or
+// nil for an anonymous request from ip, with the name of the budget the
+// request is charged to and its limits.
+func (l *clientLimits) identify(secret, ip string) (*apikeys.Key, string, ratelimit.Policy, *services.Error) {
+	if secret == "" {
+		if l.requireKeys {
+			return nil, "", ratelimit.Policy{}, &services.Error{Code: services.CodeUnauthorized, Message: "an API key is required"}
+		}
+		return nil, "ip:" + ip, l.anonymousPolicy, nil
+	}
+	key, ok := l.lookup(secret)
+	if !ok {
+		return nil, "", ratelimit.Policy{}, &services.Error{Code: services.CodeUnauthorized, Message: "invalid or revoked API key"}
+	}
+	return key, "key:" + key.ID, l.policyOf(key), nil
+}
+
+// charger returns the function that charges cost units to client under
+// policy, setting the RateLimit headers in header.
+func (l *clientLimits) charger(client string, policy ratelimit.Policy, header http.Header) func(units int64) *services.Error {
+	return func(units int64) *services.Error {
+		d := l.limiter.Take(client, policy, units)
+		d.SetHeaders(header)
+		if d.Allowed {
+			return nil
+		}
+		if d.QuotaExceeded {
+			return &services.Error{
+				Code:       services.CodeQuotaExceeded,
+				Message:    "daily quota exceeded",
+				Details:    map[string]any{"daily_quota": policy.DailyQuota},
+				RetryAfter: d.RetryAfter,
+			}
+		}
+		return &services.Error{Code: services.CodeRateLimited, Message: "rate limit exceeded, please slow down", RetryAfter: d.RetryAfter}
+	}
+}
+
+// requestAPIKey returns the API key sent with a request, or "".
+func requestAPIKey(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// lookup returns the active key matching secret.
+func (l *clientLimits) lookup(secret string) (*apikeys.Key, bool) {
+	if l.keys == nil {
+		return nil, false
+	}
+	key, ok := l.keys.Lookup(apikeys.Hash(secret))
+	if !ok || !key.Active() {
+		return nil, false
+	}
+	return key, true
+}
+
+// policyOf returns the limits of key: its own where set, the default key
+// policy elsewhere.
+func (l *clientLimits) policyOf(key *apikeys.Key) ratelimit.Policy {
+	p := l.keyPolicy
+	if key.Rate > 0 {
+		p.Rate = key.Rate
+	}
+	if key.Burst > 0 {
+		p.Burst = key.Burst
+	}
+	if key.DailyQuota > 0 {
+		p.DailyQuota = key.DailyQuota
+	}
+	return p
+}
+
+// requireAdmin restricts a route to requests with the admin token.
+func (l *clientLimits) requireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if l.adminToken == "" {
+			handlers.RespondError(c, &services.Error{Code: services.CodeNotFound, Message: "the admin API is not enabled"})
+			return
+		}
+		scheme, token, _ := strings.Cut(c.GetHeader("Authorization"), " ")
+		if !strings.EqualFold(scheme, "Bearer") || subtle.ConstantTimeCompare([]byte(token), []byte(l.adminToken)) != 1 {
+			handlers.RespondError(c, &services.Error{Code: services.CodeUnauthorized, Message: "a valid admin token is required"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"image-editor-app/backend/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// limitAnonymous gives anonymous clients policy, with a limiter of their
+// own, for the rest of the test, so that the test does not spend the budget
+// that the other tests of the package share.
+func limitAnonymous(t *testing.T, policy ratelimit.Policy) {
+	t.Helper()
+	clients, err := sharedClients()
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := *clients
+	clients.anonymousPolicy = policy
+	clients.limiter = ratelimit.New()
+	t.Cleanup(func() { *clients = saved })
+}
+
+func TestPollingIsNotCharged(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := SetupRouter()
+
+	// Anonymous clients get one request
+	limitAnonymous(t, ratelimit.Policy{Rate: 0, Burst: 1})
+
+	for i := 0; i < 5; i++ {
+		for _, path := range []string{"/v1/jobs/unknown", "/v1/progress/unknown"} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			if path == "/v1/progress/unknown" {
+				// The stream stays open until the request ends
+				ctx, cancel := context.WithCancel(req.Context())
+				cancel()
+				req = req.WithContext(ctx)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code == http.StatusTooManyRequests || w.Header().Get("RateLimit-Remaining") != "" {
+				t.Fatalf("GET %s #%d: status %d with headers %v, want it uncharged", path, i+1, w.Code, w.Header())
+			}
+		}
+	}
+
+	// Operations are still charged
+	codes := make([]int, 2)
+	for i := range codes {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/resize", nil))
+		codes[i] = w.Code
+	}
+	if codes[1] != http.StatusTooManyRequests {
+		t.Errorf("operation statuses %v, want the second limited", codes)
+	}
+}
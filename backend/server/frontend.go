@@ -0,0 +1,37 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"image-editor-app/backend/web"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveFrontend serves the frontend on GET requests no route matches, when
+// it is embedded in the binary (see `make build-embed`). FRONTEND_API_BASE
+// sets the URL the frontend sends API requests to, by default the origin
+// serving it.
+func serveFrontend(router *gin.Engine) {
+	files := web.Files()
+	if files == nil {
+		return
+	}
+	site, err := web.New(files, web.Config{APIBase: os.Getenv("FRONTEND_API_BASE")})
+	if err != nil {
+		panic(err)
+	}
+	router.NoRoute(func(c *gin.Context) {
+		// Unknown API paths keep their plain 404
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead ||
+			strings.HasPrefix(c.Request.URL.Path, "/v1/") || strings.HasPrefix(c.Request.URL.Path, "/admin/") {
+			return
+		}
+		site.ServeHTTP(c.Writer, c.Request)
+		// Sends the headers of bodiless responses, which gin would otherwise
+		// replace with its own 404 page
+		c.Writer.WriteHeaderNow()
+	})
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"image-editor-app/backend/logging"
+	"image-editor-app/backend/metrics"
+	"image-editor-app/backend/rpc"
+	"image-editor-app/backend/rpc/imagepb"
+	"image-editor-app/backend/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// SetupGRPC configures and returns the gRPC server. It shares the client
+// limits of the router, so that a client's budget covers both, and logs,
+// traces and counts calls as the router does requests.
+func SetupGRPC() *grpc.Server {
+	registerValidations()
+	clients, err := sharedClients()
+	if err != nil {
+		panic(err)
+	}
+
+	srv := grpc.NewServer(grpc.ChainStreamInterceptor(observeCalls, clients.limitCalls))
+	imagepb.RegisterImageEditorServer(srv, rpc.NewService())
+	return srv
+}
+
+// callStream is a server stream with a context of its own.
+type callStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *callStream) Context() context.Context {
+	return s.ctx
+}
+
+// observeCalls assigns every call a request ID, reusing a well-formed
+// x-request-id from the client's metadata and returning it in the response
+// header, and traces, logs and counts the call. A panic fails the call
+// rather than the process.
+func observeCalls(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	start := time.Now()
+	ctx := ss.Context()
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	id := firstValue(md, "x-request-id")
+	if !validRequestID.MatchString(id) {
+		var b [12]byte
+		rand.Read(b[:])
+		id = hex.EncodeToString(b[:])
+	}
+	ctx = logging.WithRequestID(ctx, id)
+	ss.SetHeader(metadata.Pairs("x-request-id", id))
+
+	ctx = tracing.Extract(ctx, metadataCarrier(md))
+	ctx, span := tracing.Tracer().Start(ctx, info.FullMethod,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "grpc"),
+			attribute.String("rpc.method", info.FullMethod),
+			attribute.String("request.id", id),
+		))
+	defer span.End()
+
+	defer func() {
+		if r := recover(); r != nil {
+			slog.ErrorContext(ctx, "panic in gRPC call", "method", info.FullMethod, "panic", r, "stack", string(debug.Stack()))
+			err = status.Error(codes.Internal, "internal error")
+		}
+
+		st := status.Convert(err)
+		errorCode := ""
+		for _, d := range st.Details() {
+			if detail, ok := d.(*errdetails.ErrorInfo); ok {
+				errorCode = detail.GetReason()
+			}
+		}
+		span.SetAttributes(attribute.String("rpc.grpc.status_code", st.Code().String()))
+		if st.Code() != codes.OK {
+			span.SetStatus(otelcodes.Error, st.Message())
+		}
+
+		metrics.GRPCRequests.WithLabelValues(info.FullMethod, st.Code().String()).Inc()
+		metrics.GRPCDuration.WithLabelValues(info.FullMethod).Observe(time.Since(start).Seconds())
+		if errorCode != "" {
+			metrics.Errors.WithLabelValues(info.FullMethod, errorCode).Inc()
+		}
+
+		level := slog.LevelInfo
+		switch st.Code() {
+		case codes.OK:
+		case codes.Internal, codes.Unknown, codes.Unavailable, codes.DataLoss:
+			level = slog.LevelError
+		default:
+			level = slog.LevelWarn
+		}
+		attrs := []any{
+			"method", info.FullMethod,
+			"status", st.Code().String(),
+			"duration_ms", time.Since(start).Milliseconds(),
+		}
+		if p, ok := peer.FromContext(ctx); ok {
+			attrs = append(attrs, "client_ip", peerIP(p))
+		}
+		if errorCode != "" {
+			attrs = append(attrs, "code", errorCode)
+		}
+		slog.Log(ctx, level, "grpc call", attrs...)
+	}()
+
+	return handler(srv, &callStream{ServerStream: ss, ctx: ctx})
+}
+
+// limitCalls authenticates calls that carry an API key, in the
+// authorization metadata as a Bearer token or in x-api-key, and hands the
+// service the function that charges the client. The state of the client's
+// limits is returned in RateLimit trailers.
+func (l *clientLimits) limitCalls(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx := ss.Context()
+	md, _ := metadata.FromIncomingContext(ctx)
+	secret := firstValue(md, "x-api-key")
+	if scheme, token, ok := strings.Cut(firstValue(md, "authorization"), " "); secret == "" && ok && strings.EqualFold(scheme, "Bearer") {
+		secret = strings.TrimSpace(token)
+	}
+	ip := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		ip = peerIP(p)
+	}
+
+	_, client, policy, svcErr := l.identify(secret, ip)
+	if svcErr != nil {
+		return rpc.Status(svcErr)
+	}
+	header := http.Header{}
+	caller := rpc.Client{Charge: l.charger(client, policy, header)}
+	defer func() {
+		trailer := metadata.MD{}
+		for name, values := range header {
+			trailer.Append(strings.ToLower(name), values...)
+		}
+		ss.SetTrailer(trailer)
+	}()
+	return handler(srv, &callStream{ServerStream: ss, ctx: rpc.WithClient(ctx, caller)})
+}
+
+// metadataCarrier adapts incoming metadata to the propagation.TextMapCarrier
+// interface, for extracting trace context.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	return firstValue(metadata.MD(c), key)
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func peerIP(p *peer.Peer) string {
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
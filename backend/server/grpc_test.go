@@ -0,0 +1,224 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"image-editor-app/backend/rpc/imagepb"
+	"image-editor-app/backend/services"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func newTestClient(t *testing.T) imagepb.ImageEditorClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := SetupGRPC()
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return imagepb.NewImageEditorClient(conn)
+}
+
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func resizeTo(width int32) *imagepb.Operation {
+	return &imagepb.Operation{Operation: &imagepb.Operation_Resize{Resize: &imagepb.ResizeOptions{Width: width}}}
+}
+
+func TestGRPCProcess(t *testing.T) {
+	client := newTestClient(t)
+	img := testPNG(t, 120, 80)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "grpc-process-test")
+	stream, err := client.Process(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Send(&imagepb.ProcessRequest{Part: &imagepb.ProcessRequest_Operation{Operation: resizeTo(30)}})
+	for i := 0; i < len(img); i += 50 {
+		stream.Send(&imagepb.ProcessRequest{Part: &imagepb.ProcessRequest_Chunk{Chunk: img[i:min(i+50, len(img))]}})
+	}
+	stream.CloseSend()
+
+	var info *imagepb.ImageInfo
+	var out []byte
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		if msg.GetInfo() != nil {
+			info = msg.GetInfo()
+		}
+		out = append(out, msg.GetChunk()...)
+	}
+	if info == nil || info.Width != 30 || info.Height != 20 || info.Bytes != int64(len(out)) {
+		t.Fatalf("info %v for %d bytes, want a 30x20 image", info, len(out))
+	}
+	if cfg, err := png.DecodeConfig(bytes.NewReader(out)); err != nil || cfg.Width != 30 {
+		t.Errorf("result decodes to %+v, %v", cfg, err)
+	}
+
+	header, _ := stream.Header()
+	if got := header.Get("x-request-id"); len(got) != 1 || got[0] != "grpc-process-test" {
+		t.Errorf("x-request-id header %v, want the client's", got)
+	}
+	if got := stream.Trailer().Get("ratelimit-remaining"); len(got) != 1 {
+		t.Errorf("ratelimit-remaining trailer %v, want one value", got)
+	}
+}
+
+func TestGRPCWatermark(t *testing.T) {
+	client := newTestClient(t)
+	var logo bytes.Buffer
+	white := image.NewGray(image.Rect(0, 0, 10, 10))
+	for i := range white.Pix {
+		white.Pix[i] = 255
+	}
+	if err := png.Encode(&logo, white); err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := client.Process(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	opacity := 1.0
+	stream.Send(&imagepb.ProcessRequest{Part: &imagepb.ProcessRequest_Operation{Operation: &imagepb.Operation{
+		Operation: &imagepb.Operation_Watermark{Watermark: &imagepb.WatermarkOptions{Logo: logo.Bytes(), Opacity: &opacity, Anchor: "center"}},
+	}}})
+	stream.Send(&imagepb.ProcessRequest{Part: &imagepb.ProcessRequest_Chunk{Chunk: testPNG(t, 100, 60)}})
+	stream.CloseSend()
+
+	var out []byte
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		out = append(out, msg.GetChunk()...)
+	}
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("decoding the result: %v", err)
+	}
+	if r, _, _, _ := img.At(50, 30).RGBA(); r>>8 != 255 {
+		t.Errorf("center of the result has red %d, want the white logo", r>>8)
+	}
+}
+
+// TestGRPCBindsEveryOperation checks that every registered operation has a
+// field of its own in the Operation message.
+func TestGRPCBindsEveryOperation(t *testing.T) {
+	fields := (&imagepb.Operation{}).ProtoReflect().Descriptor().Oneofs().ByName("operation").Fields()
+	for _, op := range services.Operations() {
+		name := protoreflect.Name(strings.ReplaceAll(op.Name(), "-", "_"))
+		if fields.ByName(name) == nil {
+			t.Errorf("operation %q has no field in the Operation message", op.Name())
+		}
+	}
+}
+
+func TestGRPCProcessInvalidOperation(t *testing.T) {
+	client := newTestClient(t)
+	stream, err := client.Process(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Send(&imagepb.ProcessRequest{Part: &imagepb.ProcessRequest_Operation{Operation: &imagepb.Operation{
+		Operation: &imagepb.Operation_Convert{Convert: &imagepb.ConvertOptions{Format: "xyz"}},
+	}}})
+	stream.CloseSend()
+
+	_, err = stream.Recv()
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("status %v, want InvalidArgument", st)
+	}
+	var reason string
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok {
+			reason = info.GetReason()
+		}
+	}
+	if reason != "invalid_input" {
+		t.Errorf("ErrorInfo reason %q, want invalid_input", reason)
+	}
+}
+
+func TestGRPCBatch(t *testing.T) {
+	client := newTestClient(t)
+	img := testPNG(t, 64, 64)
+
+	stream, err := client.Batch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := func(id string, data []byte, last bool) {
+		stream.Send(&imagepb.BatchRequest{Part: &imagepb.BatchRequest_Image{Image: &imagepb.ImageChunk{Id: id, Data: data, Last: last}}})
+	}
+	stream.Send(&imagepb.BatchRequest{Part: &imagepb.BatchRequest_Operation{Operation: resizeTo(16)}})
+	// Chunks of different images may interleave
+	send("a", img[:len(img)/2], false)
+	send("bad", []byte("not an image"), true)
+	send("a", img[len(img)/2:], true)
+	send("cut", img[:10], false)
+	stream.CloseSend()
+
+	results := map[string][]byte{}
+	failures := map[string]string{}
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		if e := msg.GetError(); e != nil {
+			failures[msg.GetId()] = e.GetCode()
+		}
+		results[msg.GetId()] = append(results[msg.GetId()], msg.GetChunk()...)
+	}
+
+	if cfg, err := png.DecodeConfig(bytes.NewReader(results["a"])); err != nil || cfg.Width != 16 {
+		t.Errorf("image a decodes to %+v, %v; want 16 pixels wide", cfg, err)
+	}
+	if failures["bad"] != "unsupported_format" || failures["cut"] != "invalid_input" || len(failures) != 2 {
+		t.Errorf("failures %v, want bad unsupported and cut incomplete", failures)
+	}
+}
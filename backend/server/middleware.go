@@ -0,0 +1,161 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"image-editor-app/backend/handlers"
+	"image-editor-app/backend/logging"
+	"image-editor-app/backend/metrics"
+	"image-editor-app/backend/services"
+	"image-editor-app/backend/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// validRequestID matches client-supplied request IDs that are safe to echo back.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// requestID assigns every request an ID, reusing a well-formed X-Request-ID
+// header from the client, and returns it in the X-Request-ID response header.
+func requestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader("X-Request-ID")
+		if !validRequestID.MatchString(id) {
+			var b [12]byte
+			rand.Read(b[:])
+			id = hex.EncodeToString(b[:])
+		}
+		c.Set(handlers.RequestIDKey, id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Header("X-Request-ID", id)
+		c.Next()
+	}
+}
+
+// traceRequests starts a server span for every request, continuing the trace
+// of the client if the request carries a traceparent header.
+func traceRequests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := tracing.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		ctx, span := tracing.Tracer().Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", c.Request.URL.Path),
+				attribute.String("request.id", handlers.RequestID(c)),
+			))
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		if code := c.GetString(handlers.ErrorCodeKey); code != "" {
+			span.SetAttributes(attribute.String("error.code", code))
+		}
+	}
+}
+
+// logRequests writes one structured log record per request, replacing gin's
+// text access log.
+func logRequests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		level := slog.LevelInfo
+		switch status := c.Writer.Status(); {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		attrs := []any{
+			"method", c.Request.Method,
+			"route", c.FullPath(),
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"bytes_in", c.Request.ContentLength,
+			"bytes_out", max(c.Writer.Size(), 0),
+			"duration_ms", time.Since(start).Milliseconds(),
+			"client_ip", c.ClientIP(),
+		}
+		if client := c.GetString(handlers.ClientKey); client != "" {
+			attrs = append(attrs, "api_key", client)
+		}
+		if code := c.GetString(handlers.ErrorCodeKey); code != "" {
+			attrs = append(attrs, "code", code)
+		}
+		slog.Log(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// recordMetrics records the request count, latency and body sizes of every
+// request, and its error code if it failed.
+func recordMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		// Label by route pattern rather than path to bound cardinality
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.Requests.WithLabelValues(route, c.Request.Method, strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.RequestDuration.WithLabelValues(route).Observe(time.Since(start).Seconds())
+		if c.Request.ContentLength > 0 {
+			metrics.RequestBytes.WithLabelValues(route).Observe(float64(c.Request.ContentLength))
+		}
+		if size := c.Writer.Size(); size > 0 {
+			metrics.ResponseBytes.WithLabelValues(route).Observe(float64(size))
+		}
+		if code := c.GetString(handlers.ErrorCodeKey); code != "" {
+			metrics.Errors.WithLabelValues(route, code).Inc()
+		}
+	}
+}
+
+// limitBodySize caps the size of request bodies so oversized uploads fail
+// while being read instead of being buffered in full.
+func limitBodySize() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		maxBytes := services.GetLimits().MaxBodyBytes
+		if c.Request.ContentLength > maxBytes {
+			// Answered as a body that fails while being read would be
+			handlers.RespondError(c, handlers.RequestError(&http.MaxBytesError{Limit: maxBytes}))
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		c.Next()
+	}
+}
+
+// deprecated marks a legacy route with a Deprecation header and a Link to the
+// route that replaces it.
+func deprecated(successor string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		c.Header("Link", "<"+successor+`>; rel="successor-version"`)
+		c.Next()
+	}
+}
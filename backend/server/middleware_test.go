@@ -0,0 +1,46 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"image-editor-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBodySizeLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	saved := services.GetLimits()
+	limits := saved
+	limits.MaxBodyBytes = 64
+	services.SetLimits(limits)
+	t.Cleanup(func() { services.SetLimits(saved) })
+	router := SetupRouter()
+
+	body := `{"image_base64":"` + strings.Repeat("A", 100) + `","width":10}`
+	// A declared length is rejected before the body is read, a streamed body
+	// while it is read; both get the same envelope
+	for name, contentLength := range map[string]int64{"declared": int64(len(body)), "streamed": -1} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/resize", strings.NewReader(body))
+		req.ContentLength = contentLength
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var resp struct {
+			Code      string         `json:"code"`
+			RequestID string         `json:"request_id"`
+			Details   map[string]any `json:"details"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: invalid JSON: %v", name, err)
+		}
+		if w.Code != http.StatusRequestEntityTooLarge || resp.Code != string(services.CodeTooLarge) ||
+			resp.RequestID == "" || resp.RequestID != w.Header().Get("X-Request-ID") || resp.Details["max_bytes"] != 64.0 {
+			t.Errorf("%s: status %d, body %s", name, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,595 @@
+package server
+
+import (
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"image-editor-app/backend/handlers"
+	"image-editor-app/backend/models"
+	"image-editor-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiOperation documents one route for the OpenAPI specification.
+type apiOperation struct {
+	method     string
+	path       string
+	summary    string
+	tag        string
+	request    any // Request model, nil for routes without a body
+	params     any // Parameters of an image operation, whose request is models.ImageInput with them
+	response   any // Response model; nil with legacy set for legacy routes
+	legacy     *handlers.LegacyResponse
+	deprecated bool
+
+	requestType  string // Media type of the request body; JSON if empty
+	responseType string // Media type of the success response, binary unless response is set; JSON if empty
+	description  string
+	parameters   []apiParameter
+
+	id       string // Operation ID, if the one derived from the path is taken
+	status   int    // Status of a success response, 200 if zero
+	accepted any    // Model of a 202 response, for requests that may run in the background
+	security string // "apiKey" for metered routes, "admin" for admin routes
+}
+
+// apiParameter documents a path or query parameter of a route.
+type apiParameter struct {
+	name        string
+	in          string // "path" or "query"
+	description string
+	required    bool
+}
+
+// jobDescription documents the requests that may run in the background.
+const jobDescription = "Requests with a callback_url are answered with 202 and the pending job, and run in the " +
+	"background. When the job completes, it is posted as JSON to the callback URL, with the result as a " +
+	"download link. The notification is signed in X-Webhook-Signature as \"t=<unix time>,v1=<signature>\", " +
+	"the signature being the hex-encoded HMAC-SHA256 of the time, a period and the body, keyed with the " +
+	"webhook secret of the API key. Failed deliveries are retried with exponential backoff."
+
+// apiOperations returns every documented route.
+func apiOperations() []apiOperation {
+	ops := []apiOperation{
+		{method: http.MethodGet, path: "/livez", summary: "Liveness probe", tag: "system", response: models.HealthResponse{}},
+		{
+			method:   http.MethodGet,
+			path:     "/readyz",
+			summary:  "Readiness probe",
+			tag:      "system",
+			response: models.HealthResponse{},
+			description: "Fails with 503 while the server shuts down, or when the Python backend, its script, " +
+				"the rembg model or the result storage is unavailable. Every check is listed with its outcome.",
+		},
+		{method: http.MethodGet, path: "/health", summary: "Health check (alias of /livez)", tag: "system", response: models.HealthResponse{}},
+		{method: http.MethodGet, path: "/metrics", summary: "Prometheus metrics", tag: "system", responseType: "text/plain"},
+		{method: http.MethodGet, path: "/openapi.json", summary: "This OpenAPI specification", tag: "system"},
+		{method: http.MethodGet, path: "/docs", summary: "Interactive API documentation", tag: "system"},
+	}
+	for _, op := range services.Operations() {
+		ops = append(ops, apiOperation{
+			method:      http.MethodPost,
+			path:        "/v1/" + op.Name(),
+			summary:     op.Summary(),
+			tag:         "v1",
+			params:      op.Params(),
+			response:    models.ImageResponse{},
+			accepted:    models.Job{},
+			description: jobDescription,
+			security:    "apiKey",
+		})
+	}
+	ops = append(ops, apiOperation{
+		method:   http.MethodPost,
+		path:     "/v1/pipeline",
+		summary:  "Apply several operations to an image",
+		tag:      "v1",
+		request:  models.PipelineRequest{},
+		response: models.ImageResponse{},
+		accepted: models.Job{},
+		description: "The steps are applied in order, each taking the options its operation takes in the body of " +
+			"its route, without the image and output settings; the image is decoded once and encoded once, with " +
+			"the format set by the last step that sets one. The request is charged for every step. " + jobDescription,
+		security: "apiKey",
+	})
+	ops = append(ops, apiOperation{
+		method:       http.MethodPost,
+		path:         "/v1/batch",
+		summary:      "Apply one operation to many images",
+		tag:          "v1",
+		request:      models.BatchRequest{},
+		requestType:  "multipart/form-data",
+		responseType: "application/zip",
+		description: "Upload images, or ZIP archives of images, as files. The response is a ZIP archive of the results " +
+			"with a manifest.json describing the outcome for every input. Files that fail are reported in the " +
+			"manifest (see BatchManifest) without failing the batch. Every image is charged to the rate limit.",
+		security: "apiKey",
+	})
+	ops = append(ops, apiOperation{
+		method:       http.MethodGet,
+		path:         "/v1/progress/{id}",
+		summary:      "Follow the progress of a request",
+		tag:          "v1",
+		response:     models.ProgressEvent{},
+		responseType: "text/event-stream",
+		description: "A Server-Sent Events stream of the progress of the request whose X-Request-ID is id. " +
+			"Send the request with an ID of your own; the stream may be opened before the request is sent. " +
+			"Each event carries a ProgressEvent as JSON: the stage reached, with its progress where known. " +
+			"The stream ends after the \"done\" or \"failed\" event. Stages that pass quickly may be skipped.",
+		parameters: []apiParameter{{"id", "path", "Request ID of the request to follow", true}},
+		security:   "apiKey",
+	})
+	ops = append(ops, apiOperation{
+		method:   http.MethodGet,
+		path:     "/v1/jobs/{id}",
+		summary:  "Get a background job",
+		tag:      "v1",
+		response: models.Job{},
+		description: "The status of a job started for a request with a callback_url, with its result or error once " +
+			"completed and the log of its webhook deliveries. Jobs are only visible to the API key that started them.",
+		parameters: []apiParameter{{"id", "path", "Job ID", true}},
+		security:   "apiKey",
+	})
+	result := apiOperation{
+		method:       http.MethodGet,
+		path:         "/v1/results/{id}",
+		summary:      "Download a stored result",
+		tag:          "v1",
+		responseType: "application/octet-stream",
+		description: "Serves a result requested with delivery \"url\". The link returned in the response is " +
+			"signed and expires; links that were altered are rejected with 403 and expired ones with 410.",
+		parameters: []apiParameter{
+			{"id", "path", "Result ID", true},
+			{"expires", "query", "Expiry of the link as a Unix time", true},
+			{"signature", "query", "Signature of the link", true},
+			{"download", "query", "1 to download the result as an attachment instead of displaying it", false},
+		},
+	}
+	resultAlias := result
+	resultAlias.path = "/results/{id}"
+	resultAlias.summary += " (alias of /v1/results/{id})"
+	resultAlias.id = "v1ResultsAlias"
+	ops = append(ops, result, resultAlias)
+	for _, op := range services.Operations() {
+		legacy, ok := legacyResponses[op.Name()]
+		if !ok {
+			continue
+		}
+		ops = append(ops, apiOperation{
+			method:     http.MethodPost,
+			path:       "/" + op.Name(),
+			summary:    op.Summary() + " (deprecated, use /v1/" + op.Name() + ")",
+			tag:        "legacy",
+			params:     op.Params(),
+			legacy:     &legacy,
+			deprecated: true,
+			security:   "apiKey",
+		})
+	}
+	ops = append(ops,
+		apiOperation{
+			method:   http.MethodGet,
+			path:     "/admin/keys",
+			summary:  "List the issued API keys",
+			tag:      "admin",
+			response: models.APIKeyList{},
+			id:       "adminListKeys",
+			security: "admin",
+		},
+		apiOperation{
+			method:      http.MethodPost,
+			path:        "/admin/keys",
+			summary:     "Issue an API key",
+			tag:         "admin",
+			request:     models.CreateAPIKeyRequest{},
+			response:    models.APIKey{},
+			description: "The key is only returned in this response; it cannot be retrieved later.",
+			id:          "adminCreateKey",
+			status:      http.StatusCreated,
+			security:    "admin",
+		},
+		apiOperation{
+			method:     http.MethodDelete,
+			path:       "/admin/keys/{id}",
+			summary:    "Revoke an API key",
+			tag:        "admin",
+			parameters: []apiParameter{{"id", "path", "Key ID", true}},
+			id:         "adminRevokeKey",
+			status:     http.StatusNoContent,
+			security:   "admin",
+		},
+	)
+	return ops
+}
+
+// securitySchemes documents how clients authenticate.
+var securitySchemes = map[string]any{
+	"apiKey": map[string]any{
+		"type":        "http",
+		"scheme":      "bearer",
+		"description": "An API key, which gets its own rate limit and daily quota. Requests without one are limited per IP address.",
+	},
+	"apiKeyHeader": map[string]any{
+		"type": "apiKey",
+		"in":   "header",
+		"name": "X-API-Key",
+	},
+	"adminToken": map[string]any{
+		"type":        "http",
+		"scheme":      "bearer",
+		"description": "The token configured in ADMIN_TOKEN.",
+	},
+}
+
+// operationSecurity returns the security requirements of a route.
+func operationSecurity(security string) []map[string][]string {
+	switch security {
+	case "apiKey":
+		// An empty requirement makes the key optional
+		return []map[string][]string{{}, {"apiKey": {}}, {"apiKeyHeader": {}}}
+	case "admin":
+		return []map[string][]string{{"adminToken": {}}}
+	default:
+		return nil
+	}
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPISpec map[string]any
+)
+
+// OpenAPISpec returns the OpenAPI 3 document for the API. Schemas are derived
+// from the request and response models, including their binding rules.
+func OpenAPISpec() map[string]any {
+	openAPIOnce.Do(func() {
+		openAPISpec = buildOpenAPISpec(apiOperations())
+	})
+	return openAPISpec
+}
+
+func buildOpenAPISpec(ops []apiOperation) map[string]any {
+	schemas := &schemaBuilder{components: map[string]any{}}
+	errorRef := schemas.ref(reflect.TypeOf(models.ErrorResponse{}))
+	schemas.ref(reflect.TypeOf(models.BatchManifest{})) // Documents manifest.json in batch archives
+
+	paths := map[string]any{}
+	for _, op := range ops {
+		operation := map[string]any{
+			"summary":     op.summary,
+			"tags":        []string{op.tag},
+			"operationId": operationID(op),
+		}
+		if op.id != "" {
+			operation["operationId"] = op.id
+		}
+		if op.deprecated {
+			operation["deprecated"] = true
+		}
+		if security := operationSecurity(op.security); security != nil {
+			operation["security"] = security
+		}
+		if op.description != "" {
+			operation["description"] = op.description
+		}
+
+		if len(op.parameters) > 0 {
+			params := make([]map[string]any, 0, len(op.parameters))
+			for _, p := range op.parameters {
+				params = append(params, map[string]any{
+					"name":        p.name,
+					"in":          p.in,
+					"description": p.description,
+					"required":    p.required,
+					"schema":      map[string]any{"type": "string"},
+				})
+			}
+			operation["parameters"] = params
+		}
+
+		var request map[string]any
+		switch {
+		case op.params != nil:
+			request = schemas.operationRequest(strings.TrimPrefix(op.path, "/v1"), reflect.TypeOf(op.params).Elem())
+		case op.request != nil:
+			request = schemas.ref(reflect.TypeOf(op.request))
+		}
+		if request != nil {
+			requestType := op.requestType
+			if requestType == "" {
+				requestType = "application/json"
+			}
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{requestType: map[string]any{"schema": request}},
+			}
+		}
+
+		successType := "application/json"
+		var success map[string]any
+		switch {
+		case op.response != nil:
+			if op.responseType != "" {
+				successType = op.responseType
+			}
+			success = schemas.ref(reflect.TypeOf(op.response))
+		case op.responseType != "":
+			successType = op.responseType
+			success = map[string]any{"type": "string", "format": "binary"}
+		case op.legacy != nil:
+			props := map[string]any{op.legacy.Key: map[string]any{"type": "string", "format": "byte"}}
+			if op.legacy.WithFormat {
+				props["format"] = map[string]any{"type": "string"}
+			}
+			success = map[string]any{"type": "object", "properties": props}
+		default:
+			success = map[string]any{"type": "object"}
+		}
+
+		status := op.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		response := map[string]any{"description": "Success"}
+		if status != http.StatusNoContent {
+			response["content"] = map[string]any{successType: map[string]any{"schema": success}}
+		}
+		responses := map[string]any{strconv.Itoa(status): response}
+		if op.accepted != nil {
+			responses[strconv.Itoa(http.StatusAccepted)] = map[string]any{
+				"description": "Accepted, to run in the background",
+				"content":     map[string]any{"application/json": map[string]any{"schema": schemas.ref(reflect.TypeOf(op.accepted))}},
+			}
+		}
+		if request != nil || len(op.parameters) > 0 || op.security != "" {
+			responses["default"] = map[string]any{
+				"description": "Error",
+				"content":     map[string]any{"application/json": map[string]any{"schema": errorRef}},
+			}
+		}
+		operation["responses"] = responses
+
+		path, _ := paths[op.path].(map[string]any)
+		if path == nil {
+			path = map[string]any{}
+			paths[op.path] = path
+		}
+		path[strings.ToLower(op.method)] = operation
+	}
+
+	schemas.documentOperations()
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Image Editor API",
+			"version":     "1.0.0",
+			"description": "Image resizing, cropping, conversion, compression and background removal.",
+		},
+		"servers":    []map[string]any{{"url": "https://api.imagenerd.in"}},
+		"paths":      paths,
+		"components": map[string]any{"schemas": schemas.components, "securitySchemes": securitySchemes},
+	}
+}
+
+// operationID derives an operation ID such as "v1Resize" or "legacyChangeBackground".
+func operationID(op apiOperation) string {
+	id := op.tag
+	for _, part := range strings.FieldsFunc(strings.TrimPrefix(op.path, "/v1"), func(r rune) bool {
+		return r == '/' || r == '-' || r == '.'
+	}) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+// schemaBuilder converts Go types into OpenAPI schemas, registering structs
+// as named components.
+type schemaBuilder struct {
+	components map[string]any
+}
+
+// operationRequest returns a reference to the schema of the request body of
+// the operation at path: the image input with the operation's parameters.
+// It is named after the operation, e.g., "ChangeBackgroundRequest".
+func (b *schemaBuilder) operationRequest(path string, params reflect.Type) map[string]any {
+	name := operationID(apiOperation{path: path}) + "Request"
+	if _, ok := b.components[name]; !ok {
+		schema := b.structSchema(reflect.TypeOf(models.ImageInput{}))
+		paramsSchema := b.structSchema(params)
+		properties := schema["properties"].(map[string]any)
+		for field, s := range paramsSchema["properties"].(map[string]any) {
+			properties[field] = s
+		}
+		if required, ok := paramsSchema["required"].([]string); ok {
+			schema["required"] = append(schema["required"].([]string), required...)
+		}
+		b.components[name] = schema
+	}
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// documentOperations lists the registered operations in the schemas that
+// name them, those of pipeline steps and batches, and documents the
+// parameters that pipeline steps take.
+func (b *schemaBuilder) documentOperations() {
+	var names []string
+	var params []map[string]any
+	for _, op := range services.Operations() {
+		names = append(names, op.Name())
+		params = append(params, b.ref(reflect.TypeOf(op.Params()).Elem()))
+	}
+	property := func(component, name string) map[string]any {
+		schema, _ := b.components[component].(map[string]any)
+		properties, _ := schema["properties"].(map[string]any)
+		p, _ := properties[name].(map[string]any)
+		return p
+	}
+	if p := property("BatchRequest", "operation"); p != nil {
+		p["enum"] = names
+	}
+	if p := property("PipelineStep", "operation"); p != nil {
+		p["enum"] = names
+	}
+	if p := property("PipelineStep", "options"); p != nil {
+		p["anyOf"] = params
+	}
+}
+
+// ref returns a reference to the component schema of a struct type.
+func (b *schemaBuilder) ref(t reflect.Type) map[string]any {
+	if _, ok := b.components[t.Name()]; !ok {
+		b.components[t.Name()] = nil // Reserve the name for recursive types
+		b.components[t.Name()] = b.structSchema(t)
+	}
+	return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+}
+
+func (b *schemaBuilder) schemaFor(t reflect.Type) map[string]any {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(multipart.FileHeader{}) {
+		return map[string]any{"type": "string", "format": "binary"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": b.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": true}
+	case reflect.Struct:
+		return b.ref(t)
+	}
+	return map[string]any{}
+}
+
+// structSchema describes a struct, flattening embedded structs the way
+// encoding/json does.
+func (b *schemaBuilder) structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+
+	var addFields func(t reflect.Type)
+	addFields = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				addFields(field.Type)
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "" || name == "-" || !field.IsExported() {
+				continue
+			}
+
+			schema := b.schemaFor(field.Type)
+			if applyBindingRules(schema, field.Type, field.Tag.Get("binding")) {
+				required = append(required, name)
+			}
+			if doc := field.Tag.Get("doc"); doc != "" && schema["$ref"] == nil {
+				schema["description"] = doc
+			}
+			properties[name] = schema
+		}
+	}
+	addFields(t)
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// applyBindingRules translates validator tags into schema constraints and
+// reports whether the field is required. Rules after "dive" apply to the
+// elements of a slice and are left to the element's own schema.
+func applyBindingRules(schema map[string]any, t reflect.Type, binding string) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	lengthRule := t.Kind() == reflect.String || t.Kind() == reflect.Slice
+
+	required := false
+	for _, rule := range strings.Split(binding, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		value, _ := strconv.ParseFloat(param, 64)
+
+		switch name {
+		case "dive":
+			return required
+		case "required":
+			required = true
+		case "oneof":
+			schema["enum"] = strings.Fields(param)
+		case "rgb_triplet":
+			schema["pattern"] = `^\d{1,3},\d{1,3},\d{1,3}$`
+		case "min", "gte":
+			switch {
+			case lengthRule && t.Kind() == reflect.String:
+				schema["minLength"] = int(value)
+			case lengthRule:
+				schema["minItems"] = int(value)
+			default:
+				schema["minimum"] = value
+			}
+		case "max", "lte":
+			switch {
+			case lengthRule && t.Kind() == reflect.String:
+				schema["maxLength"] = int(value)
+			case lengthRule:
+				schema["maxItems"] = int(value)
+			default:
+				schema["maximum"] = value
+			}
+		case "gt":
+			schema["minimum"] = value
+			schema["exclusiveMinimum"] = true
+		case "lt":
+			schema["maximum"] = value
+			schema["exclusiveMaximum"] = true
+		}
+	}
+	return required
+}
+
+// serveOpenAPISpec handles GET /openapi.json.
+func serveOpenAPISpec(c *gin.Context) {
+	c.JSON(http.StatusOK, OpenAPISpec())
+}
+
+// swaggerUIPage renders Swagger UI against /openapi.json.
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Image Editor API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: '/openapi.json', dom_id: '#swagger-ui' });
+  </script>
+</body>
+</html>
+`
+
+// serveSwaggerUI handles GET /docs.
+func serveSwaggerUI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+}
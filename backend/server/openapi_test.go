@@ -0,0 +1,147 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pathParam matches gin path parameters such as ":id".
+var pathParam = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+func TestOpenAPISpecMatchesRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := SetupRouter()
+
+	registered := map[string]bool{}
+	for _, route := range router.Routes() {
+		path := pathParam.ReplaceAllString(route.Path, "{$1}")
+		registered[strings.ToLower(route.Method)+" "+path] = true
+	}
+
+	documented := map[string]bool{}
+	paths, _ := OpenAPISpec()["paths"].(map[string]any)
+	for path, item := range paths {
+		for method := range item.(map[string]any) {
+			documented[method+" "+path] = true
+		}
+	}
+
+	var undocumented, unregistered []string
+	for route := range registered {
+		if !documented[route] {
+			undocumented = append(undocumented, route)
+		}
+	}
+	for route := range documented {
+		if !registered[route] {
+			unregistered = append(unregistered, route)
+		}
+	}
+	sort.Strings(undocumented)
+	sort.Strings(unregistered)
+
+	if len(undocumented) > 0 {
+		t.Errorf("routes missing from the OpenAPI spec: %v", undocumented)
+	}
+	if len(unregistered) > 0 {
+		t.Errorf("OpenAPI paths without a registered route: %v", unregistered)
+	}
+}
+
+func TestOpenAPISpecServed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := SetupRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json returned %d", w.Code)
+	}
+
+	var spec struct {
+		OpenAPI    string `json:"openapi"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]any `json:"properties"`
+				Required   []string                  `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Errorf("openapi = %q, want 3.x", spec.OpenAPI)
+	}
+
+	// Spot-check that binding rules made it into the schemas
+	compress := spec.Components.Schemas["CompressRequest"]
+	if q := compress.Properties["quality"]; q["minimum"] != 1.0 || q["maximum"] != 100.0 {
+		t.Errorf("CompressRequest.quality = %v, want minimum 1 and maximum 100", q)
+	}
+	if _, ok := compress.Properties["metadata"]["enum"]; !ok {
+		t.Errorf("CompressRequest.metadata has no enum: %v", compress.Properties["metadata"])
+	}
+	upscale := spec.Components.Schemas["UpscaleRequest"]
+	if s := upscale.Properties["scale_factor"]; s["exclusiveMinimum"] != true {
+		t.Errorf("UpscaleRequest.scale_factor = %v, want an exclusive minimum", s)
+	}
+	if len(upscale.Required) != 2 {
+		t.Errorf("UpscaleRequest required = %v, want image_base64 and scale_factor", upscale.Required)
+	}
+}
+
+func TestRequestValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := SetupRouter()
+
+	cases := []struct {
+		path  string
+		body  string
+		field string
+	}{
+		{"/v1/upscale", `{"image_base64":"x","scale_factor":-1}`, "scale_factor"},
+		{"/v1/crop", `{"image_base64":"x","x":0,"y":0,"width":-5,"height":10}`, "width"},
+		{"/v1/compress", `{"image_base64":"x","quality":150}`, "quality"},
+		{"/v1/resize", `{"image_base64":"x","preset":"myspace_banner"}`, "preset"},
+		{"/v1/convert", `{"image_base64":"x","format":"psd"}`, "format"},
+		{"/v1/resize", `{"image_base64":"x","width":10,"metadata":"everything"}`, "metadata"},
+		{"/v1/change-background", `{"image_base64":"x","solid_color":"300,0,0"}`, "solid_color"},
+		{"/v1/blur", `{"image_base64":"x","radius":5,"path":[{"x":-1,"y":0}]}`, "path[0].x"},
+		{"/v1/watermark", `{"image_base64":"x","text":"x","opacity":2}`, "opacity"},
+		{"/v1/pipeline", `{"image_base64":"x","steps":[]}`, "steps"},
+		{"/v1/pipeline", `{"image_base64":"x","steps":[{"operation":"crop","options":{"width":-5,"height":10}}]}`, "steps[0].options.width"},
+		{"/v1/pipeline", `{"image_base64":"x","steps":[{"operation":"resize","options":{"width":10}},{"operation":"compress","options":{"quality":0}}]}`, "steps[1].options.quality"},
+	}
+
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: status %d, want 400", tc.path, tc.body, w.Code)
+			continue
+		}
+
+		var resp struct {
+			Code    string `json:"code"`
+			Details struct {
+				Fields []struct {
+					Field string `json:"field"`
+				} `json:"fields"`
+			} `json:"details"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: invalid JSON: %v", tc.path, err)
+		}
+		if resp.Code != "invalid_input" || len(resp.Details.Fields) == 0 || resp.Details.Fields[0].Field != tc.field {
+			t.Errorf("%s %s: got %s, want invalid_input for field %s", tc.path, tc.body, w.Body.String(), tc.field)
+		}
+	}
+}
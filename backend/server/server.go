@@ -1,51 +1,169 @@
 package server
 
 import (
+	"os"
+	"strings"
+	"sync"
+
 	"image-editor-app/backend/handlers"
+	"image-editor-app/backend/metrics"
+	"image-editor-app/backend/models"
+	"image-editor-app/backend/services"
 
 	"github.com/gin-contrib/cors" // Import the cors package
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// registerValidations registers the custom validation tags used by the
+// request models.
+var registerValidations = sync.OnceFunc(func() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		if err := models.RegisterValidations(v); err != nil {
+			panic(err)
+		}
+	}
+})
+
+// legacyResponses lists the operations with a legacy route, with the key
+// each returns the image under. Operations added since have none.
+var legacyResponses = map[string]handlers.LegacyResponse{
+	"resize":            {Key: "resized_image_base64"},
+	"crop":              {Key: "cropped_image_base64"},
+	"upscale":           {Key: "upscaled_image_base64"},
+	"convert":           {Key: "converted_image_base64"},
+	"blur":              {Key: "blurred_image_base64"},
+	"remove-background": {Key: "image_without_background_base64", WithFormat: true},
+	"change-background": {Key: "image_base64", WithFormat: true},
+	"compress":          {Key: "compressed_image_base64"},
+}
+
+// sharedClients loads the client limits once for the router and the gRPC
+// server, so that a client has the same budget over both.
+var sharedClients = sync.OnceValues(loadClientLimits)
+
 // SetupRouter configures and returns the Gin router.
 func SetupRouter() *gin.Engine {
-	router := gin.Default()
+	registerValidations()
+	clients, err := sharedClients()
+	if err != nil {
+		panic(err)
+	}
+
+	router := gin.New()
+	router.Use(gin.Recovery())
+
+	// Client IPs, which anonymous rate limits apply to, are only taken from
+	// X-Forwarded-For when set by a trusted reverse proxy
+	if err := router.SetTrustedProxies(trustedProxies()); err != nil {
+		panic(err)
+	}
 
 	// Configure CORS middleware
 	config := cors.DefaultConfig()
 	config.AllowAllOrigins = true // Allow requests from all origins
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-API-Key", "X-Request-ID", "If-None-Match"}
+	config.ExposeHeaders = []string{"X-Request-ID", "Deprecation", "Link", "Content-Disposition", "X-Batch-Succeeded", "X-Batch-Failed", "ETag", "X-Cache",
+		"RateLimit-Policy", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"}
 	router.Use(cors.New(config))
 
-	// Health check endpoint
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
-	})
+	// Tag every request with an ID for error responses and logs
+	router.Use(requestID())
+	router.Use(traceRequests())
+	router.Use(logRequests())
+
+	// Count and time every request, including those rejected below
+	router.Use(recordMetrics())
 
-	// Image resizing endpoint
-	router.POST("/resize", handlers.ResizeImage)
+	// Reject oversized request bodies before they are read
+	router.Use(limitBodySize())
 
-	// Image cropping endpoint
-	router.POST("/crop", handlers.CropImage)
+	// Liveness and readiness probes; /health predates them and is kept for
+	// existing monitors as an alias of /livez
+	router.GET("/livez", handlers.Livez)
+	router.GET("/readyz", handlers.Readyz)
+	router.GET("/health", handlers.Livez)
 
-	// Image upscaling endpoint
-	router.POST("/upscale", handlers.UpscaleImage)
+	// Prometheus metrics
+	router.GET("/metrics", gin.WrapH(promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{})))
 
-	// Image conversion endpoint
-	router.POST("/convert", handlers.ConvertImage)
+	// API documentation
+	router.GET("/openapi.json", serveOpenAPISpec)
+	router.GET("/docs", serveSwaggerUI)
 
-	// Image blur endpoint
-	router.POST("/blur", handlers.BlurImage)
+	// Issuing and revoking API keys
+	admin := router.Group("/admin", clients.requireAdmin())
+	{
+		admin.GET("/keys", handlers.ListAPIKeys(clients.keys))
+		admin.POST("/keys", handlers.CreateAPIKey(clients.keys))
+		admin.DELETE("/keys/:id", handlers.RevokeAPIKey(clients.keys))
+	}
 
-	// Image background removal endpoint
-	router.POST("/remove-background", handlers.RemoveBackground)
+	// The API proper is metered per API key or, without one, per IP address
+	api := router.Group("/", clients.limit())
 
-	// Image change background endpoint
-	router.POST("/change-background", handlers.ChangeBackground)
+	// Versioned API: every operation returns the same response envelope
+	v1 := api.Group("/v1")
+	{
+		// One route per registered operation
+		for _, op := range services.Operations() {
+			v1.POST("/"+op.Name(), handlers.Operation(op))
+		}
 
-	// Image compression endpoint
-	router.POST("/compress", handlers.CompressImage)
+		// Operations applied one after another to one image
+		v1.POST("/pipeline", handlers.Pipeline)
+
+		// Many images and one operation in, a ZIP of results out
+		v1.POST("/batch", handlers.Batch)
+	}
+
+	// Reads that clients poll while they wait are authenticated, but not
+	// charged, so following a long request does not spend its budget
+	status := router.Group("/v1", clients.authenticate())
+	{
+		// Progress of a request by its request ID, as Server-Sent Events
+		status.GET("/progress/:id", handlers.Progress)
+
+		// Jobs of requests with a callback_url, with their webhook delivery logs
+		status.GET("/jobs/:id", handlers.GetJob)
+	}
+
+	// Results requested with delivery "url", behind signed expiring links.
+	// The link is the credential, so that it opens in a browser without an API key.
+	// They are also served at /results/:id
+	router.GET("/v1/results/:id", handlers.GetResult)
+	router.GET("/results/:id", handlers.GetResult)
+
+	// Legacy endpoints, kept as deprecated aliases of the /v1 routes of the
+	// operations that predate them
+	for _, op := range services.Operations() {
+		if legacy, ok := legacyResponses[op.Name()]; ok {
+			api.POST("/"+op.Name(), deprecated("/v1/"+op.Name()), handlers.LegacyOperation(op, legacy))
+		}
+	}
+
+	// The frontend, on every other GET path, in binaries that embed it
+	serveFrontend(router)
 
 	return router
 }
+
+// trustedProxies returns the reverse proxies allowed to set the client IP:
+// those listed in TRUSTED_PROXIES, separated by commas, or else the loopback
+// addresses of a proxy on the same host.
+func trustedProxies() []string {
+	raw := os.Getenv("TRUSTED_PROXIES")
+	if raw == "" {
+		return []string{"127.0.0.1", "::1"}
+	}
+	var proxies []string
+	for _, proxy := range strings.Split(raw, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
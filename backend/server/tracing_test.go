@@ -0,0 +1,72 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"image-editor-app/backend/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracingSpans(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	exporter := tracetest.NewInMemoryExporter()
+	tracing.UseExporter(exporter)
+	router := SetupRouter()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 37, 23))); err != nil {
+		t.Fatal(err)
+	}
+	body := `{"image_base64":"` + base64.StdEncoding.EncodeToString(buf.Bytes()) + `","width":10}`
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodPost, "/v1/resize", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /v1/resize returned %d: %s", w.Code, w.Body)
+	}
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+		if got := span.SpanContext.TraceID().String(); got != traceID {
+			t.Errorf("span %s has trace ID %s, want the client's %s", span.Name, got, traceID)
+		}
+	}
+
+	server, ok := spans["POST /v1/resize"]
+	if !ok {
+		t.Fatalf("no server span among %v", spanNames(spans))
+	}
+	operation := spans["operation"]
+	for _, name := range []string{"bind", "operation"} {
+		if span, ok := spans[name]; !ok || span.Parent.SpanID() != server.SpanContext.SpanID() {
+			t.Errorf("span %s is missing or not a child of the server span", name)
+		}
+	}
+	for _, name := range []string{"decode_base64", "decode", "orientation", "encode"} {
+		if span, ok := spans[name]; !ok || span.Parent.SpanID() != operation.SpanContext.SpanID() {
+			t.Errorf("span %s is missing or not a child of the operation span", name)
+		}
+	}
+}
+
+func spanNames(spans map[string]tracetest.SpanStub) []string {
+	names := make([]string, 0, len(spans))
+	for name := range spans {
+		names = append(names, name)
+	}
+	return names
+}
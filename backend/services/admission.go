@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"image-editor-app/backend/admission"
+	"image-editor-app/backend/metrics"
+	"image-editor-app/backend/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// overloadRetryAfter is when clients turned away for lack of capacity are
+// asked to come back.
+const overloadRetryAfter = 5 * time.Second
+
+// admitter bounds the image processing running at once, weighing each
+// operation by its cost.
+var admitter = newAdmitter(limits)
+
+func newAdmitter(l Limits) *admission.Controller {
+	return admission.New(l.Capacity, l.QueueLength, func(s admission.Stats) {
+		metrics.AdmissionCapacity.Set(float64(s.Capacity))
+		metrics.AdmissionInUse.Set(float64(s.InUse))
+		metrics.AdmissionQueued.Set(float64(s.Queued))
+	})
+}
+
+// Admit waits until there is capacity to process one image with operation
+// and returns the function that gives the capacity back. Requests are
+// rejected with CodeOverloaded when too many are already waiting, or when
+// capacity does not free up within Limits.MaxQueueWait.
+func Admit(ctx context.Context, operation string) (release func(), err error) {
+	return admit(ctx, operation, limits.Cost(operation))
+}
+
+// admit waits until there is capacity for cost units of work, as Admit does.
+func admit(ctx context.Context, operation string, cost int64) (release func(), err error) {
+	l, ctrl := limits, admitter
+
+	ctx, span := tracing.Start(ctx, "admission",
+		attribute.String("image.operation", operation),
+		attribute.Int64("admission.cost", cost))
+	defer func() { tracing.End(span, err) }()
+
+	if ctrl.TryAcquire(cost) {
+		metrics.AdmissionWait.WithLabelValues(operation).Observe(0)
+		return func() { ctrl.Release(cost) }, nil
+	}
+	ReportProgress(ctx, StageQueued, -1)
+
+	waitCtx, cancel := context.WithTimeout(ctx, l.MaxQueueWait)
+	defer cancel()
+	start := time.Now()
+	if err := ctrl.Acquire(waitCtx, cost); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, contextError(ctxErr)
+		}
+		metrics.AdmissionRejected.WithLabelValues(operation).Inc()
+		stats := ctrl.Stats()
+		slog.WarnContext(ctx, "rejected operation for lack of capacity",
+			"operation", operation,
+			"cost", cost,
+			"in_use", stats.InUse,
+			"queued", stats.Queued,
+			"queue_full", errors.Is(err, admission.ErrQueueFull))
+		return nil, &Error{
+			Code:       CodeOverloaded,
+			Message:    "the server is busy, please retry later",
+			Err:        err,
+			RetryAfter: overloadRetryAfter,
+		}
+	}
+	metrics.AdmissionWait.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+
+	return func() { ctrl.Release(cost) }, nil
+}
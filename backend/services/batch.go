@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"image-editor-app/backend/metrics"
+	"image-editor-app/backend/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// BatchFile is one encoded image of a batch.
+type BatchFile struct {
+	Name string
+	Data []byte
+}
+
+// BatchResult is the outcome for one file of a batch. Exactly one of Result
+// and Err is set.
+type BatchResult struct {
+	Name   string
+	Result *Result
+	Err    *Error
+}
+
+// ProcessBatch runs op on every file, with at most Limits.BatchWorkers files
+// in flight, each within the timeout of operation and admitted like a
+// single-image request. Results are returned in
+// input order. A failing file, including one whose processing panics, does
+// not affect the others. Once ctx is done, the files not yet started fail
+// without being processed.
+func ProcessBatch(ctx context.Context, operation string, files []BatchFile, op func(context.Context, []byte) (*Result, error)) []BatchResult {
+	results := make([]BatchResult, len(files))
+	timeout := limits.Timeout(operation)
+
+	workers := limits.BatchWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(files) {
+		workers = len(files)
+	}
+
+	// The batch reports the share of files done rather than the stages of
+	// each file
+	steps := startSteps(ctx, StageBatch, len(files))
+	fileCtx := untracked(ctx)
+	var mu sync.Mutex
+	finished := 0
+
+	metrics.BatchQueueDepth.Add(float64(len(files)))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				metrics.BatchQueueDepth.Dec()
+				metrics.BatchWorkersBusy.Inc()
+				results[i] = processBatchFile(fileCtx, operation, timeout, files[i], op)
+				metrics.BatchWorkersBusy.Dec()
+
+				mu.Lock()
+				finished++
+				steps.done(finished)
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range files {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+func processBatchFile(ctx context.Context, operation string, timeout time.Duration, file BatchFile, op func(context.Context, []byte) (*Result, error)) (res BatchResult) {
+	res.Name = file.Name
+	if err := checkContext(ctx); err != nil {
+		res.Err = AsError(err)
+		return res
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			slog.ErrorContext(ctx, "batch file panicked", "file", file.Name, "panic", r)
+			res.Result = nil
+			res.Err = newError(CodeInternal, fmt.Errorf("panic: %v", r), "internal error")
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ctx, span := tracing.Start(ctx, "batch_file", attribute.String("batch.file", file.Name))
+	result, err := admitAndRun(ctx, operation, file.Data, op)
+	tracing.End(span, err)
+	if err != nil {
+		res.Err = AsError(err)
+		return res
+	}
+	res.Result = result
+	return res
+}
+
+// admitAndRun runs op once there is capacity for it.
+func admitAndRun(ctx context.Context, operation string, data []byte, op func(context.Context, []byte) (*Result, error)) (*Result, error) {
+	release, err := Admit(ctx, operation)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return op(ctx, data)
+}
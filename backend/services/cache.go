@@ -0,0 +1,133 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"os"
+
+	"image-editor-app/backend/cache"
+	"image-editor-app/backend/metrics"
+)
+
+// cacheVersion is part of every cache key. Bump it when a change to the
+// operations alters their output, to stop serving stale results.
+const cacheVersion = "2"
+
+// imageFields are the request fields holding base64 image data.
+var imageFields = []string{"image_base64", "new_background_image_base64"}
+
+// resultCache is nil when caching is disabled with IMAGE_CACHE_MB=0.
+var resultCache = newResultCache()
+
+// newResultCache configures the result cache from the environment:
+// IMAGE_CACHE_MB (memory budget, default 128, 0 disables caching),
+// IMAGE_CACHE_DIR (optional on-disk tier) and IMAGE_CACHE_DISK_MB (default 1024).
+func newResultCache() *cache.Cache {
+	if os.Getenv("IMAGE_CACHE_MB") == "0" {
+		return nil
+	}
+	memMB, ok := envFloat("IMAGE_CACHE_MB")
+	if !ok {
+		memMB = 128
+	}
+	diskMB, ok := envFloat("IMAGE_CACHE_DISK_MB")
+	if !ok {
+		diskMB = 1024
+	}
+
+	dir := os.Getenv("IMAGE_CACHE_DIR")
+	c, err := cache.New(int64(memMB*(1<<20)), dir, int64(diskMB*(1<<20)))
+	if err != nil {
+		slog.Warn("disabling on-disk cache", "dir", dir, "error", err)
+		c, _ = cache.New(int64(memMB*(1<<20)), "", 0)
+	}
+	return c
+}
+
+// CacheKey derives the content address of an operation's result from the
+// operation name and its request, given in parts, such as the image input
+// and the parameters, whose fields are merged. Image fields contribute a
+// hash of their data; the other fields are normalized by encoding the bound
+// request, so field order, whitespace and omitted defaults do not change
+// the key.
+func CacheKey(operation string, parts ...any) (string, error) {
+	fields := map[string]any{}
+	for _, part := range parts {
+		raw, err := json.Marshal(part)
+		if err != nil {
+			return "", newError(CodeInternal, err, "internal error")
+		}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&fields); err != nil {
+			return "", newError(CodeInternal, err, "internal error")
+		}
+	}
+
+	for _, name := range imageFields {
+		if data, ok := fields[name].(string); ok && data != "" {
+			sum := sha256.Sum256([]byte(data))
+			fields[name] = hex.EncodeToString(sum[:])
+		}
+	}
+	// Where the result is sent does not change it
+	delete(fields, "callback_url")
+	params, err := json.Marshal(fields) // Map keys are sorted
+	if err != nil {
+		return "", newError(CodeInternal, err, "internal error")
+	}
+
+	h := sha256.New()
+	h.Write([]byte(cacheVersion + "\x00" + operation + "\x00"))
+	h.Write(params)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Cached returns the result stored under key, or runs op and stores its
+// result. Concurrent calls with the same key run op once. It reports whether
+// the result came from the cache.
+func Cached(ctx context.Context, key string, op func() (*Result, error)) (*Result, bool, error) {
+	if resultCache == nil {
+		result, err := op()
+		return result, false, err
+	}
+
+	var computed *Result
+	var data []byte
+	var hit, led bool
+	var err error
+	for {
+		data, hit, err = resultCache.Do(ctx, key, func() ([]byte, error) {
+			led = true
+			result, err := op()
+			if err != nil {
+				return nil, err
+			}
+			computed = result
+			return result.Data, nil
+		})
+		// The call this one waited on was canceled with its own request;
+		// compute the result for this request instead
+		if err != nil && !led && ctx.Err() == nil && isContextError(err) {
+			continue
+		}
+		break
+	}
+	metrics.CacheLookup(hit)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, false, contextError(ctxErr)
+		}
+		return nil, false, err
+	}
+	if computed != nil {
+		return computed, false, nil
+	}
+
+	result, err := newResult(data)
+	return result, hit, err
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"image-editor-app/backend/cache"
+	"image-editor-app/backend/models"
+)
+
+// resizeKey returns the cache key of a resize request body, bound as the
+// handlers bind it.
+func resizeKey(t *testing.T, body string) string {
+	t.Helper()
+	var input models.ImageInput
+	var params models.ResizeParams
+	if err := json.Unmarshal([]byte(body), &input); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(body), &params); err != nil {
+		t.Fatal(err)
+	}
+	key, err := CacheKey("/v1/resize", input, params)
+	if err != nil {
+		t.Fatalf("CacheKey: %v", err)
+	}
+	return key
+}
+
+func TestCacheKey(t *testing.T) {
+	key := resizeKey(t, `{"image_base64":"aW1n","width":10,"height":5}`)
+
+	for _, body := range []string{
+		`{"height":5,"width":10,"image_base64":"aW1n"}`,
+		`{ "image_base64": "aW1n", "width": 10, "height": 5, "preset": "", "metadata": "" }`,
+		`{"image_base64":"aW1n","width":10,"height":5,"callback_url":"https://example.com/hook"}`,
+	} {
+		if got := resizeKey(t, body); got != key {
+			t.Errorf("%s: key differs from the same request written otherwise", body)
+		}
+	}
+	for _, body := range []string{
+		`{"image_base64":"aW1n","width":11,"height":5}`,
+		`{"image_base64":"aW1nMg==","width":10,"height":5}`,
+		`{"image_base64":"aW1n","width":10,"height":5,"metadata":"keep"}`,
+	} {
+		if got := resizeKey(t, body); got == key {
+			t.Errorf("%s: key equals that of a different request", body)
+		}
+	}
+
+	other, err := CacheKey("/v1/crop", models.ImageInput{ImageBase64: "aW1n"}, models.ResizeParams{Width: 10, Height: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == key {
+		t.Error("key does not depend on the operation")
+	}
+}
+
+func TestCachedRetriesCanceledCall(t *testing.T) {
+	saved := resultCache
+	resultCache, _ = cache.New(1<<20, "", 0)
+	t.Cleanup(func() { resultCache = saved })
+
+	// The first request is canceled while another waits on its call
+	started, cancelFirst := make(chan struct{}), make(chan struct{})
+	go Cached(context.Background(), "key", func() (*Result, error) {
+		close(started)
+		<-cancelFirst
+		return nil, contextError(context.Canceled)
+	})
+	<-started
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(cancelFirst)
+	}()
+
+	want := &Result{Data: []byte("result"), Format: "png"}
+	result, hit, err := Cached(context.Background(), "key", func() (*Result, error) { return want, nil })
+	if err != nil {
+		t.Fatalf("Cached = %v, want the waiting request to compute its own result", err)
+	}
+	if result != want || hit {
+		t.Errorf("Cached = %+v, hit %v; want the computed result", result, hit)
+	}
+
+	// A request that is itself canceled does not retry
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = Cached(ctx, "other", func() (*Result, error) { return nil, contextError(ctx.Err()) })
+	var svcErr *Error
+	if !errors.As(err, &svcErr) || svcErr.Code != CodeCanceled {
+		t.Errorf("Cached with a canceled context = %v, want canceled", err)
+	}
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"time"
+)
+
+// ErrorCode is a stable, machine-readable error category returned to clients.
+type ErrorCode string
+
+const (
+	CodeInvalidInput       ErrorCode = "invalid_input"
+	CodeUnsupportedFormat  ErrorCode = "unsupported_format"
+	CodeTooLarge           ErrorCode = "too_large"
+	CodeLimitExceeded      ErrorCode = "limit_exceeded"
+	CodeUnauthorized       ErrorCode = "unauthorized"
+	CodeNotFound           ErrorCode = "not_found"
+	CodeForbidden          ErrorCode = "forbidden"
+	CodeExpired            ErrorCode = "expired"
+	CodeRateLimited        ErrorCode = "rate_limited"
+	CodeQuotaExceeded      ErrorCode = "quota_exceeded"
+	CodeBackendUnavailable ErrorCode = "backend_unavailable"
+	CodeOverloaded         ErrorCode = "overloaded"
+	CodeTimeout            ErrorCode = "timeout"
+	CodeCanceled           ErrorCode = "canceled"
+	CodeInternal           ErrorCode = "internal"
+)
+
+// Error is a service error with a stable code. Message is safe to show to
+// clients; Err holds the underlying cause and is only meant for logs.
+type Error struct {
+	Code       ErrorCode
+	Message    string
+	Details    map[string]any
+	Err        error
+	RetryAfter time.Duration // When set, how long the client should wait before retrying
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// StatusClientClosedRequest is the non-standard status, borrowed from nginx,
+// recorded for requests the client abandoned before the response was ready.
+const StatusClientClosedRequest = 499
+
+// HTTPStatus returns the HTTP status code for the error's code.
+func (e *Error) HTTPStatus() int {
+	switch e.Code {
+	case CodeInvalidInput:
+		return http.StatusBadRequest
+	case CodeUnsupportedFormat:
+		return http.StatusUnsupportedMediaType
+	case CodeTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case CodeLimitExceeded:
+		return http.StatusUnprocessableEntity
+	case CodeUnauthorized:
+		return http.StatusUnauthorized
+	case CodeNotFound:
+		return http.StatusNotFound
+	case CodeForbidden:
+		return http.StatusForbidden
+	case CodeExpired:
+		return http.StatusGone
+	case CodeRateLimited, CodeQuotaExceeded:
+		return http.StatusTooManyRequests
+	case CodeBackendUnavailable, CodeOverloaded:
+		return http.StatusServiceUnavailable
+	case CodeTimeout:
+		return http.StatusGatewayTimeout
+	case CodeCanceled:
+		return StatusClientClosedRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func newError(code ErrorCode, cause error, format string, args ...any) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...), Err: cause}
+}
+
+// AsError returns err as a service error. Errors that did not originate as a
+// service error are reported as internal, without exposing their message.
+func AsError(err error) *Error {
+	var svcErr *Error
+	if errors.As(err, &svcErr) {
+		return svcErr
+	}
+	return &Error{Code: CodeInternal, Message: "internal error", Err: err}
+}
+
+// decodeError classifies a failure of image.Decode or image.DecodeConfig.
+func decodeError(err error) *Error {
+	if errors.Is(err, image.ErrFormat) {
+		return newError(CodeUnsupportedFormat, err, "image format is not supported")
+	}
+	return newError(CodeInvalidInput, err, "image data could not be decoded")
+}
+
+// contextError classifies the error of a done context: an expired deadline
+// is a timeout, anything else means the request was canceled.
+func contextError(err error) *Error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return newError(CodeTimeout, err, "operation timed out")
+	}
+	return newError(CodeCanceled, err, "request was canceled")
+}
+
+// isContextError reports whether err comes from a canceled or expired context.
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// checkContext returns a service error once ctx is done. Long operations
+// call it between stages, since decoding and resampling cannot be interrupted.
+func checkContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+	return nil
+}
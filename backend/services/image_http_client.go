@@ -2,17 +2,27 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"time"
+
+	"image-editor-app/backend/logging"
+	"image-editor-app/backend/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 const (
 	// ImageServerURL is the URL of the Python image processing server
 	ImageServerURL = "http://localhost:5001"
-	// HTTPTimeout is the timeout for HTTP requests
+	// HTTPTimeout bounds HTTP requests made without a deadline of their own
 	HTTPTimeout = 60 * time.Second
 )
 
@@ -24,11 +34,13 @@ type ImageHTTPClient struct {
 
 // RemoveBackgroundRequestPayload for HTTP communication
 type RemoveBackgroundRequestPayload struct {
+	RequestID   string `json:"request_id,omitempty"`
 	ImageBase64 string `json:"image_base64"`
 }
 
 // ChangeBackgroundRequestPayload for HTTP communication
 type ChangeBackgroundRequestPayload struct {
+	RequestID                string `json:"request_id,omitempty"`
 	ImageBase64              string `json:"image_base64"`
 	NewBackgroundImageBase64 string `json:"new_background_image_base64,omitempty"`
 	SolidColor               string `json:"solid_color,omitempty"`
@@ -45,64 +57,90 @@ type ImageHTTPResponse struct {
 // NewImageHTTPClient creates a new HTTP client
 func NewImageHTTPClient() *ImageHTTPClient {
 	return &ImageHTTPClient{
-		client: &http.Client{
-			Timeout: HTTPTimeout,
-		},
+		client:  &http.Client{},
 		baseURL: ImageServerURL,
 	}
 }
 
 // makeRequest makes an HTTP POST request to the image server
-func (c *ImageHTTPClient) makeRequest(endpoint string, payload interface{}) (*ImageHTTPResponse, error) {
+func (c *ImageHTTPClient) makeRequest(ctx context.Context, endpoint string, payload interface{}) (_ *ImageHTTPResponse, err error) {
+	ctx, span := tracing.Start(ctx, "python.http", attribute.String("url.path", endpoint))
+	defer func() { tracing.End(span, err) }()
+
+	// Requests are normally bounded by the operation's timeout
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, HTTPTimeout)
+		defer cancel()
+	}
+
 	// Marshal payload to JSON
 	payloadJSON, err := json.Marshal(payload)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return nil, newError(CodeInternal, err, "failed to marshal request")
 	}
 
 	// Create HTTP request
 	url := fmt.Sprintf("%s%s", c.baseURL, endpoint)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(payloadJSON))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(payloadJSON))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, newError(CodeInternal, err, "failed to create request")
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	if id := logging.RequestID(ctx); id != "" {
+		req.Header.Set("X-Request-ID", id)
+	}
+	tracing.Inject(ctx, propagation.HeaderCarrier(req.Header))
 
 	// Send request
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, contextError(ctxErr)
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil, newError(CodeTimeout, err, "image server did not respond in time")
+		}
+		return nil, newError(CodeBackendUnavailable, err, "image server is unavailable")
 	}
 	defer resp.Body.Close()
 
 	// Read response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, contextError(ctxErr)
+		}
+		return nil, newError(CodeBackendUnavailable, err, "failed to read response from image server")
 	}
 
 	// Unmarshal response
 	var response ImageHTTPResponse
 	err = json.Unmarshal(body, &response)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		slog.ErrorContext(ctx, "image server returned an unreadable response",
+			"endpoint", endpoint, "status", resp.StatusCode, "body", truncate(string(body), 2048))
+		return nil, newError(CodeInternal, err, "image server returned an invalid response")
 	}
 
 	if !response.Success {
-		return nil, fmt.Errorf("image processing failed: %s", response.Error)
+		slog.ErrorContext(ctx, "image server operation failed", "endpoint", endpoint, "error", response.Error)
+		return nil, pythonError(response.Error)
 	}
 
 	return &response, nil
 }
 
 // RemoveBackground requests background removal
-func (c *ImageHTTPClient) RemoveBackground(imageBase64 string) (string, string, error) {
+func (c *ImageHTTPClient) RemoveBackground(ctx context.Context, imageBase64 string) (string, string, error) {
 	payload := RemoveBackgroundRequestPayload{
+		RequestID:   logging.RequestID(ctx),
 		ImageBase64: imageBase64,
 	}
 
-	response, err := c.makeRequest("/remove_background", payload)
+	response, err := c.makeRequest(ctx, "/remove_background", payload)
 	if err != nil {
 		return "", "", err
 	}
@@ -111,8 +149,9 @@ func (c *ImageHTTPClient) RemoveBackground(imageBase64 string) (string, string,
 }
 
 // ChangeBackground requests background replacement
-func (c *ImageHTTPClient) ChangeBackground(imageBase64, newBgImage, solidColor string) (string, string, error) {
+func (c *ImageHTTPClient) ChangeBackground(ctx context.Context, imageBase64, newBgImage, solidColor string) (string, string, error) {
 	payload := ChangeBackgroundRequestPayload{
+		RequestID:   logging.RequestID(ctx),
 		ImageBase64: imageBase64,
 	}
 
@@ -122,7 +161,7 @@ func (c *ImageHTTPClient) ChangeBackground(imageBase64, newBgImage, solidColor s
 		payload.SolidColor = solidColor
 	}
 
-	response, err := c.makeRequest("/change_background", payload)
+	response, err := c.makeRequest(ctx, "/change_background", payload)
 	if err != nil {
 		return "", "", err
 	}
@@ -1,20 +1,42 @@
 package services
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
 	"strings"
+	"syscall"
+	"time"
+
+	"image-editor-app/backend/logging"
+	"image-editor-app/backend/metrics"
+	"image-editor-app/backend/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// pythonWaitDelay bounds how long a canceled script may take to exit, or
+// keep its output open through a child process, before it is killed.
+const pythonWaitDelay = 5 * time.Second
+
 // PythonClient handles direct Python subprocess calls (no Flask server)
 type PythonClient struct {
 	pythonPath string
 	scriptPath string
+	modelPath  string // rembg model the script loads on its first call
 }
 
 // PythonRequest represents input to Python script
 type PythonRequest struct {
+	RequestID                string `json:"request_id,omitempty"` // Tags the script's log lines
 	Operation                string `json:"operation"`
 	ImageBase64              string `json:"image_base64"`
 	NewBackgroundImageBase64 string `json:"new_background_image_base64,omitempty"`
@@ -38,49 +60,211 @@ func NewPythonClient() *PythonClient {
 	return &PythonClient{
 		pythonPath: pythonPath,
 		scriptPath: scriptPath,
+		modelPath:  filepath.Join(rembgModelDir(), "u2net.onnx"),
+	}
+}
+
+// rembgModelDir returns the directory rembg downloads its models to.
+func rembgModelDir() string {
+	if dir := os.Getenv("U2NET_HOME"); dir != "" {
+		return dir
+	}
+	home := os.Getenv("XDG_DATA_HOME")
+	if home == "" {
+		home, _ = os.UserHomeDir()
+	}
+	return filepath.Join(home, ".u2net")
+}
+
+// Check verifies that the interpreter, the script and the rembg model are in
+// place, without starting Python.
+func (c *PythonClient) Check() []ReadinessCheck {
+	python := ReadinessCheck{Name: "python"}
+	if info, err := os.Stat(c.pythonPath); err != nil || info.IsDir() || info.Mode()&0o111 == 0 {
+		python.Err = newError(CodeBackendUnavailable, err, "python interpreter is missing or not executable")
+	}
+	script := ReadinessCheck{Name: "python_script"}
+	if _, err := os.Stat(c.scriptPath); err != nil {
+		script.Err = newError(CodeBackendUnavailable, err, "background removal script is missing")
 	}
+	// Without the model, the first call would download it, or fail offline
+	model := ReadinessCheck{Name: "rembg_model"}
+	if _, err := os.Stat(c.modelPath); err != nil {
+		model.Err = newError(CodeBackendUnavailable, err, "rembg model has not been downloaded")
+	}
+	return []ReadinessCheck{python, script, model}
 }
 
-// callPython executes Python script and returns response
-func (c *PythonClient) callPython(request PythonRequest) (*PythonResponse, error) {
+// callPython executes Python script and returns response.
+// Python output is logged but never returned to clients.
+func (c *PythonClient) callPython(ctx context.Context, request PythonRequest) (_ *PythonResponse, err error) {
+	ctx, span := tracing.Start(ctx, "python", attribute.String("python.operation", request.Operation))
+	defer func() { tracing.End(span, err) }()
+
+	request.RequestID = logging.RequestID(ctx)
+
 	// Marshal request to JSON
 	requestJSON, err := json.Marshal(request)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return nil, newError(CodeInternal, err, "failed to marshal request")
 	}
 
-	// Execute Python script with JSON input via stdin
-	cmd := exec.Command(c.pythonPath, c.scriptPath)
+	// Execute Python script with JSON input via stdin; the script is stopped
+	// when the request is canceled or times out
+	cmd := exec.CommandContext(ctx, c.pythonPath, c.scriptPath)
+	// Ask the script to exit first; it is killed if it is still running after WaitDelay
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+	cmd.WaitDelay = pythonWaitDelay
 	cmd.Stdin = strings.NewReader(string(requestJSON))
+	// The script reads the trace context from TRACEPARENT
+	cmd.Env = append(os.Environ(), tracing.Environ(ctx)...)
 
-	// Capture output
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return nil, fmt.Errorf("python execution failed: %w, output: %s", err, string(output))
-	}
+	// Capture output; stderr carries progress, log lines, library warnings
+	// and tracebacks, and is handled as the script writes it
+	var stdout bytes.Buffer
+	stderr := &pythonStderr{ctx: ctx, operation: request.Operation}
+	cmd.Stdout = &stdout
+	cmd.Stderr = stderr
 
-	// Parse Python response
+	start := time.Now()
+	runErr := cmd.Run()
+	stderr.flush()
+	elapsed := time.Since(start)
+	metrics.PythonDuration.WithLabelValues(request.Operation).Observe(elapsed.Seconds())
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		slog.WarnContext(ctx, "python operation abandoned",
+			"operation", request.Operation,
+			"reason", ctxErr,
+			"duration_ms", elapsed.Milliseconds())
+		return nil, contextError(ctxErr)
+	}
+	if runErr != nil && (errors.Is(runErr, exec.ErrNotFound) || errors.Is(runErr, fs.ErrNotExist)) {
+		metrics.PythonFailures.WithLabelValues(request.Operation).Inc()
+		slog.ErrorContext(ctx, "python backend not available", "python", c.pythonPath, "error", runErr)
+		return nil, newError(CodeBackendUnavailable, runErr, "background removal backend is unavailable")
+	}
+	// Parse Python response; the script reports errors as JSON even when exiting non-zero
 	var response PythonResponse
-	err = json.Unmarshal(output, &response)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse python response: %w, output: %s", err, string(output))
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		metrics.PythonFailures.WithLabelValues(request.Operation).Inc()
+		slog.ErrorContext(ctx, "python returned an unreadable response",
+			"operation", request.Operation,
+			"exit_error", runErr,
+			"stdout", truncate(stdout.String(), 2048),
+			"duration_ms", elapsed.Milliseconds())
+		return nil, newError(CodeInternal, err, "background processing failed")
 	}
 
 	if !response.Success {
-		return nil, fmt.Errorf("python processing failed: %s", response.Error)
+		metrics.PythonFailures.WithLabelValues(request.Operation).Inc()
+		slog.ErrorContext(ctx, "python operation failed",
+			"operation", request.Operation,
+			"error", response.Error,
+			"duration_ms", elapsed.Milliseconds())
+		return nil, pythonError(response.Error)
 	}
 
+	slog.InfoContext(ctx, "python operation finished",
+		"operation", request.Operation,
+		"format", response.Format,
+		"duration_ms", elapsed.Milliseconds())
 	return &response, nil
 }
 
+// maxPythonLine bounds the stderr kept while waiting for the end of a line.
+const maxPythonLine = 64 << 10
+
+// pythonStderr handles what the script writes to stderr line by line, as it
+// is written. Progress lines are reported as the operation's progress.
+// Other lines that are JSON objects, as the script's own log lines are, are
+// logged with their fields; the rest, such as library warnings and
+// tracebacks, are logged verbatim.
+type pythonStderr struct {
+	ctx       context.Context
+	operation string
+	partial   []byte // Start of a line whose end has not been written yet
+}
+
+func (w *pythonStderr) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.line(w.partial[:i])
+		w.partial = w.partial[i+1:]
+	}
+	if len(w.partial) > maxPythonLine {
+		w.flush()
+	}
+	return len(p), nil
+}
+
+// flush handles the last line if it was not terminated.
+func (w *pythonStderr) flush() {
+	if len(w.partial) > 0 {
+		w.line(w.partial)
+		w.partial = nil
+	}
+}
+
+func (w *pythonStderr) line(line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+
+	var fields map[string]any
+	if json.Unmarshal(line, &fields) != nil {
+		slog.WarnContext(w.ctx, "python stderr", "operation", w.operation, "line", truncate(string(line), 2048))
+		return
+	}
+	if stage, ok := fields["progress"].(string); ok && len(fields) == 1 {
+		ReportProgress(w.ctx, stage, -1)
+		return
+	}
+
+	level := slog.LevelInfo
+	if name, ok := fields["level"].(string); ok {
+		level.UnmarshalText([]byte(name))
+	}
+	msg, _ := fields["msg"].(string)
+	attrs := []any{"source", "python"}
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		// The request ID is added from ctx
+		if key != "level" && key != "msg" && key != "time" && key != "request_id" {
+			attrs = append(attrs, key, fields[key])
+		}
+	}
+	slog.Log(w.ctx, level, msg, attrs...)
+}
+
+// truncate shortens s to at most n bytes for logging.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+// pythonError classifies an error message reported by the Python backend.
+func pythonError(message string) *Error {
+	cause := errors.New(message)
+	if strings.HasPrefix(message, "Failed to decode image") {
+		return newError(CodeInvalidInput, cause, "image data could not be decoded")
+	}
+	return newError(CodeInternal, cause, "background processing failed")
+}
+
 // RemoveBackground removes background using Python script
-func (c *PythonClient) RemoveBackground(imageBase64 string) (string, string, error) {
+func (c *PythonClient) RemoveBackground(ctx context.Context, imageBase64 string) (string, string, error) {
 	request := PythonRequest{
 		Operation:   "remove_background",
 		ImageBase64: imageBase64,
 	}
 
-	response, err := c.callPython(request)
+	response, err := c.callPython(ctx, request)
 	if err != nil {
 		return "", "", err
 	}
@@ -89,7 +273,7 @@ func (c *PythonClient) RemoveBackground(imageBase64 string) (string, string, err
 }
 
 // ChangeBackground changes background using Python script
-func (c *PythonClient) ChangeBackground(imageBase64, newBgImage, solidColor string) (string, string, error) {
+func (c *PythonClient) ChangeBackground(ctx context.Context, imageBase64, newBgImage, solidColor string) (string, string, error) {
 	request := PythonRequest{
 		Operation:                "change_background",
 		ImageBase64:              imageBase64,
@@ -97,7 +281,7 @@ func (c *PythonClient) ChangeBackground(imageBase64, newBgImage, solidColor stri
 		SolidColor:               solidColor,
 	}
 
-	response, err := c.callPython(request)
+	response, err := c.callPython(ctx, request)
 	if err != nil {
 		return "", "", err
 	}
@@ -2,14 +2,14 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
-	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
 
 	"image-editor-app/backend/models"
-	"image-editor-app/backend/utils" // Import the new utils package
+	"image-editor-app/backend/utils"
 
 	"github.com/disintegration/imaging"
 )
@@ -24,270 +24,239 @@ var presets = map[string]struct{ Width, Height int }{
 	"facebook_post":        {Width: 1200, Height: 630},
 }
 
-// ResizeImage processes an image resize request.
-func ResizeImage(req models.ResizeRequest) (string, error) {
-	// Decode the base64 image
-	imgBytes, err := base64.StdEncoding.DecodeString(req.ImageBase64)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode base64 image: %w", err)
-	}
-
-	orientation := utils.GetExifOrientation(imgBytes)
-
-	// Decode the image
-	img, format, err := image.Decode(bytes.NewReader(imgBytes))
-	if err != nil {
-		return "", fmt.Errorf("failed to decode image: %w", err)
-	}
-
-	img = utils.FixOrientation(img, orientation)
-
-	targetWidth := req.Width
-	targetHeight := req.Height
-
-	// Apply preset if provided
-	if req.Preset != "" {
-		if p, ok := presets[req.Preset]; ok {
-			targetWidth = p.Width
-			targetHeight = p.Height
-		} else {
-			return "", fmt.Errorf("invalid preset: %s", req.Preset)
+var resizeOperation = &funcOperation[models.ResizeParams]{
+	name:    "resize",
+	summary: "Resize an image to explicit dimensions or a social media preset",
+	validate: func(p *models.ResizeParams) error {
+		if p.Preset != "" {
+			if _, ok := presets[p.Preset]; !ok {
+				return newError(CodeInvalidInput, nil, "invalid preset: %s", p.Preset)
+			}
+		} else if p.Width == 0 && p.Height == 0 {
+			return newError(CodeInvalidInput, nil, "either width/height or a valid preset must be provided")
+		}
+		return nil
+	},
+	apply: func(ctx context.Context, img image.Image, p *models.ResizeParams) (image.Image, error) {
+		targetWidth := p.Width
+		targetHeight := p.Height
+
+		// Apply preset if provided
+		if preset, ok := presets[p.Preset]; ok {
+			targetWidth = preset.Width
+			targetHeight = preset.Height
 		}
-	}
-
-	if targetWidth == 0 && targetHeight == 0 {
-		return "", fmt.Errorf("either width/height or a valid preset must be provided")
-	}
-
-	// Resize the image
-	resizedImg := imaging.Resize(img, targetWidth, targetHeight, imaging.Lanczos)
-
-	// Encode the resized image back to base64
-	var buf bytes.Buffer
-	err = utils.EncodeImage(resizedImg, format, &buf)
 
-	if err != nil {
-		return "", fmt.Errorf("failed to encode resized image: %w", err)
-	}
+		// A zero dimension is derived from the aspect ratio, as imaging.Resize does
+		outputWidth, outputHeight := targetWidth, targetHeight
+		if outputWidth == 0 {
+			outputWidth = int(float64(img.Bounds().Dx()) * float64(targetHeight) / float64(img.Bounds().Dy()))
+		}
+		if outputHeight == 0 {
+			outputHeight = int(float64(img.Bounds().Dy()) * float64(targetWidth) / float64(img.Bounds().Dx()))
+		}
+		if err := checkOutputSize(outputWidth, outputHeight); err != nil {
+			return nil, err
+		}
 
-	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+		ReportProgress(ctx, "resizing", -1)
+		return imaging.Resize(img, targetWidth, targetHeight, imaging.Lanczos), nil
+	},
 }
 
-// UpscaleImage processes an image upscale request.
-func UpscaleImage(req models.UpscaleRequest) (string, error) {
-	// Decode the base64 image
-	imgBytes, err := base64.StdEncoding.DecodeString(req.ImageBase64)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode base64 image: %w", err)
-	}
-
-	orientation := utils.GetExifOrientation(imgBytes)
-
-	// Decode the image
-	img, format, err := image.Decode(bytes.NewReader(imgBytes))
-	if err != nil {
-		return "", fmt.Errorf("failed to decode image: %w", err)
-	}
-
-	img = utils.FixOrientation(img, orientation)
-
-	// Calculate new dimensions
-	originalWidth := img.Bounds().Dx()
-	originalHeight := img.Bounds().Dy()
-	targetWidth := int(float64(originalWidth) * req.ScaleFactor)
-	targetHeight := int(float64(originalHeight) * req.ScaleFactor)
-
-	// Upscale the image
-	upscaledImg := imaging.Resize(img, targetWidth, targetHeight, imaging.Lanczos)
-
-	// Encode the upscaled image back to base64
-	var buf bytes.Buffer
-	err = utils.EncodeImage(upscaledImg, format, &buf)
-	if err != nil {
-		return "", fmt.Errorf("failed to encode upscaled image: %w", err)
-	}
-
-	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+var cropOperation = &funcOperation[models.CropParams]{
+	name:    "crop",
+	summary: "Crop an image to a rectangle",
+	apply: func(ctx context.Context, img image.Image, p *models.CropParams) (image.Image, error) {
+		// The rectangle is relative to the image, whatever its origin
+		b := img.Bounds()
+		rect := image.Rect(p.X, p.Y, p.X+p.Width, p.Y+p.Height).Add(b.Min).Intersect(b)
+		if rect.Empty() {
+			return nil, newError(CodeInvalidInput, nil, "the crop rectangle lies outside the %dx%d image", b.Dx(), b.Dy())
+		}
+		return imaging.Crop(img, rect), nil
+	},
 }
 
-// ConvertImage processes an image conversion request.
-func ConvertImage(req models.ConvertRequest) (string, error) {
-	// Decode the base64 image
-	imgBytes, err := base64.StdEncoding.DecodeString(req.ImageBase64)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode base64 image: %w", err)
-	}
-
-	orientation := utils.GetExifOrientation(imgBytes)
-
-	img, _, err := image.Decode(bytes.NewReader(imgBytes))
-	if err != nil {
-		return "", fmt.Errorf("failed to decode image: %w", err)
-	}
-
-	img = utils.FixOrientation(img, orientation)
-
-	// Encode the image to the target format
-	var buf bytes.Buffer
-	err = utils.EncodeImage(img, req.Format, &buf)
-	if err != nil {
-		return "", fmt.Errorf("failed to encode converted image: %w", err)
-	}
+var upscaleOperation = &funcOperation[models.UpscaleParams]{
+	name:    "upscale",
+	summary: "Upscale an image by a factor",
+	validate: func(p *models.UpscaleParams) error {
+		return checkScaleFactor(p.ScaleFactor)
+	},
+	apply: func(ctx context.Context, img image.Image, p *models.UpscaleParams) (image.Image, error) {
+		targetWidth := int(float64(img.Bounds().Dx()) * p.ScaleFactor)
+		targetHeight := int(float64(img.Bounds().Dy()) * p.ScaleFactor)
+		if err := checkOutputSize(targetWidth, targetHeight); err != nil {
+			return nil, err
+		}
 
-	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+		// Upscale the image in bands, which takes long enough on large images
+		// to report its progress
+		return resizeInBands(ctx, img, targetWidth, targetHeight, "upscaling")
+	},
 }
 
-// BlurImage processes an image blur request for a specific path.
-func BlurImage(req models.BlurRequest) (string, error) {
-	// Decode the base64 image
-	imgBytes, err := base64.StdEncoding.DecodeString(req.ImageBase64)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode base64 image: %w", err)
-	}
-
-	orientation := utils.GetExifOrientation(imgBytes)
-
-	// Decode the image
-	img, format, err := image.Decode(bytes.NewReader(imgBytes))
-	if err != nil {
-		return "", fmt.Errorf("failed to decode image: %w", err)
-	}
-
-	img = utils.FixOrientation(img, orientation)
-
-	// Create a mask image
-	bounds := img.Bounds()
-	mask := image.NewAlpha(bounds)
+var convertOperation = &funcOperation[models.ConvertParams]{
+	name:    "convert",
+	summary: "Convert an image to another format",
+	apply: func(ctx context.Context, img image.Image, p *models.ConvertParams) (image.Image, error) {
+		return img, nil
+	},
+	encoding: func(p *models.ConvertParams, format string) (string, *int) {
+		return p.Format, nil
+	},
+}
 
-	// Draw the path onto the mask
-	// For simplicity, we'll draw filled circles around each point in the path.
-	// A more sophisticated implementation might draw lines or polygons.
-	maskColor := color.Alpha{A: 255} // Fully opaque
-	for _, p := range req.Path {
-		// Draw a circle around each point. The radius of this circle can be adjusted.
-		// For now, let's use a fixed small radius, or potentially link it to blur radius.
-		// Using a small square for simplicity.
-		size := 10 // Size of the square to draw around each point
-		for y := max(0, p.Y-size/2); y < min(bounds.Max.Y, p.Y+size/2); y++ {
-			for x := max(0, p.X-size/2); x < min(bounds.Max.X, p.X+size/2); x++ {
-				mask.Set(x, y, maskColor)
+var blurOperation = &funcOperation[models.BlurParams]{
+	name:    "blur",
+	summary: "Blur the regions of an image along a path",
+	apply: func(ctx context.Context, img image.Image, p *models.BlurParams) (image.Image, error) {
+		// Create a mask image
+		bounds := img.Bounds()
+		mask := image.NewAlpha(bounds)
+
+		// Draw the path onto the mask
+		// For simplicity, we'll draw filled circles around each point in the path.
+		// A more sophisticated implementation might draw lines or polygons.
+		maskColor := color.Alpha{A: 255} // Fully opaque
+		steps := startSteps(ctx, "masking", len(p.Path))
+		for i, point := range p.Path {
+			// Draw a circle around each point. The radius of this circle can be adjusted.
+			// For now, let's use a fixed small radius, or potentially link it to blur radius.
+			// Using a small square for simplicity.
+			size := 10 // Size of the square to draw around each point
+			for y := max(bounds.Min.Y, point.Y-size/2); y < min(bounds.Max.Y, point.Y+size/2); y++ {
+				for x := max(bounds.Min.X, point.X-size/2); x < min(bounds.Max.X, point.X+size/2); x++ {
+					mask.Set(x, y, maskColor)
+				}
 			}
+			steps.done(i + 1)
 		}
-	}
 
-	// Blur the entire image
-	fullBlurredImg := imaging.Blur(img, req.Radius)
+		// Blur the entire image
+		ReportProgress(ctx, "blurring", -1)
+		fullBlurredImg := imaging.Blur(img, p.Radius)
 
-	// Create a new image to draw the result
-	resultImg := image.NewRGBA(bounds)
-	draw.Draw(resultImg, bounds, img, bounds.Min, draw.Src) // Draw original image first
+		// Create a new image to draw the result
+		resultImg := image.NewRGBA(bounds)
+		draw.Draw(resultImg, bounds, img, bounds.Min, draw.Src) // Draw original image first
 
-	// Overlay the blurred image using the mask
-	draw.DrawMask(resultImg, bounds, fullBlurredImg, bounds.Min, mask, bounds.Min, draw.Over)
+		// Overlay the blurred image using the mask
+		draw.DrawMask(resultImg, bounds, fullBlurredImg, image.Point{}, mask, bounds.Min, draw.Over)
+		return resultImg, nil
+	},
+}
 
-	// Encode the blurred image back to base64
-	var buf bytes.Buffer
-	err = utils.EncodeImage(resultImg, format, &buf)
-	if err != nil {
-		return "", fmt.Errorf("failed to encode blurred image: %w", err)
+// Background removal and replacement run in the Python backend (direct
+// subprocess calls, no Flask server), which returns a transparent PNG or,
+// with a new background, the format it picks.
+
+var removeBackgroundOperation = newPythonOperation(&funcOperation[models.RemoveBackgroundParams]{
+	name:    "remove-background",
+	summary: "Remove the background of an image",
+	encoding: func(p *models.RemoveBackgroundParams, format string) (string, *int) {
+		return "png", nil
+	},
+}, func(ctx context.Context, imageBase64 string, p *models.RemoveBackgroundParams) (string, string, error) {
+	return GetPythonClient().RemoveBackground(ctx, imageBase64)
+})
+
+var changeBackgroundOperation = newPythonOperation(&funcOperation[models.ChangeBackgroundParams]{
+	name:    "change-background",
+	summary: "Replace the background of an image with an image or a solid color",
+	encoding: func(p *models.ChangeBackgroundParams, format string) (string, *int) {
+		if transparentBackground(p) {
+			return "png", nil
+		}
+		return "jpeg", nil
+	},
+}, func(ctx context.Context, imageBase64 string, p *models.ChangeBackgroundParams) (string, string, error) {
+	if p.NewBackgroundImage != "" {
+		if err := checkInputImage(p.NewBackgroundImage); err != nil {
+			return "", "", err
+		}
+	}
+	// If transparent flag is set or no replacement provided, just remove background
+	if transparentBackground(p) {
+		return GetPythonClient().RemoveBackground(ctx, imageBase64)
 	}
+	return GetPythonClient().ChangeBackground(ctx, imageBase64, p.NewBackgroundImage, p.SolidColor)
+})
 
-	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+func transparentBackground(p *models.ChangeBackgroundParams) bool {
+	return p.Transparent || (p.NewBackgroundImage == "" && p.SolidColor == "")
 }
 
-// Helper functions for min/max (Go 1.21+ has built-in, but for broader compatibility)
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
+// pythonOperation is an operation run by the Python backend. On its own, it
+// hands Python the request image as it was sent and passes the result
+// through; in pipelines, it hands over the decoded image as a PNG and its
+// result is decoded for the next step.
+type pythonOperation[P any] struct {
+	*funcOperation[P]
+	call func(ctx context.Context, imageBase64 string, p *P) (result, format string, err error)
 }
 
-func max(a, b int) int {
-	if a > b {
-		return a
+// newPythonOperation sets the apply function of op to call the Python
+// backend through call.
+func newPythonOperation[P any](op *funcOperation[P], call func(context.Context, string, *P) (string, string, error)) *pythonOperation[P] {
+	op.apply = func(ctx context.Context, img image.Image, p *P) (image.Image, error) {
+		return viaPython(ctx, img, func(imageBase64 string) (string, string, error) {
+			return call(ctx, imageBase64, p)
+		})
 	}
-	return b
+	return &pythonOperation[P]{funcOperation: op, call: call}
 }
 
-// CropImage processes an image crop request.
-func CropImage(req models.CropRequest) (string, error) {
-	// Decode the base64 image
-	imgBytes, err := base64.StdEncoding.DecodeString(req.ImageBase64)
+func (o *pythonOperation[P]) ApplyEncoded(ctx context.Context, imgBytes []byte, params any) ([]byte, string, error) {
+	p, err := o.params(params)
 	if err != nil {
-		return "", fmt.Errorf("failed to decode base64 image: %w", err)
+		return nil, "", err
 	}
-
-	orientation := utils.GetExifOrientation(imgBytes)
-
-	// Decode the image
-	img, format, err := image.Decode(bytes.NewReader(imgBytes))
+	if err := checkInputDimensions(imgBytes); err != nil {
+		return nil, "", err
+	}
+	result, format, err := o.call(ctx, base64.StdEncoding.EncodeToString(imgBytes), p)
 	if err != nil {
-		return "", fmt.Errorf("failed to decode image: %w", err)
+		return nil, "", err
 	}
-
-	img = utils.FixOrientation(img, orientation)
-
-	// Define the crop rectangle
-	rect := image.Rect(req.X, req.Y, req.X+req.Width, req.Y+req.Height)
-
-	// Crop the image
-	croppedImg := imaging.Crop(img, rect)
-
-	// Encode the cropped image back to base64
-	var buf bytes.Buffer
-	err = utils.EncodeImage(croppedImg, format, &buf)
-
+	resultBytes, err := base64.StdEncoding.DecodeString(result)
 	if err != nil {
-		return "", fmt.Errorf("failed to encode cropped image: %w", err)
+		return nil, "", newError(CodeInternal, err, "failed to decode processed image")
 	}
-
-	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+	return resultBytes, format, nil
 }
 
-// ChangeBackground processes an image background change request using HTTP.
-func ChangeBackground(req models.ChangeBackgroundRequest) (string, string, error) {
-	client := GetPythonClient() // Direct Python call - no Flask server
-	// If transparent flag is set or no replacement provided, just remove background
-	if req.Transparent || (req.NewBackgroundImage == "" && req.SolidColor == "") {
-		return client.RemoveBackground(req.ImageBase64)
+// viaPython hands an image to the Python backend as a PNG, which keeps its
+// alpha channel, and decodes the image it returns.
+func viaPython(ctx context.Context, img image.Image, call func(imageBase64 string) (string, string, error)) (image.Image, error) {
+	var buf bytes.Buffer
+	if err := utils.EncodeImage(img, "png", &buf); err != nil {
+		return nil, newError(CodeInternal, err, "failed to encode image for background processing")
 	}
-	return client.ChangeBackground(req.ImageBase64, req.NewBackgroundImage, req.SolidColor)
-}
-
-// RemoveBackground processes an image background removal request.
-// Uses direct Python subprocess call (no Flask server needed - saves CPU).
-func RemoveBackground(req models.RemoveBackgroundRequest) (string, string, error) {
-	client := GetPythonClient() // Direct Python call - no Flask server
-	return client.RemoveBackground(req.ImageBase64)
-}
-
-// CompressImage processes an image compression request.
-func CompressImage(req models.CompressRequest) (string, error) {
-	// Decode the base64 image
-	imgBytes, err := base64.StdEncoding.DecodeString(req.ImageBase64)
+	result, _, err := call(base64.StdEncoding.EncodeToString(buf.Bytes()))
 	if err != nil {
-		return "", fmt.Errorf("failed to decode base64 image: %w", err)
+		return nil, err
 	}
-
-	orientation := utils.GetExifOrientation(imgBytes)
-
-	// Decode the image
-	img, format, err := image.Decode(bytes.NewReader(imgBytes))
+	resultBytes, err := base64.StdEncoding.DecodeString(result)
 	if err != nil {
-		return "", fmt.Errorf("failed to decode image: %w", err)
+		return nil, newError(CodeInternal, err, "failed to decode processed image")
 	}
-
-	img = utils.FixOrientation(img, orientation)
-
-	// Determine output format
-	outputFormat := format
-	if req.Format != nil {
-		outputFormat = *req.Format
+	processed, _, err := image.Decode(bytes.NewReader(resultBytes))
+	if err != nil {
+		return nil, newError(CodeInternal, err, "failed to decode processed image")
 	}
+	return processed, nil
+}
+
+var compressOperation = &funcOperation[models.CompressParams]{
+	name:    "compress",
+	summary: "Compress an image, optionally capping its dimensions",
+	apply: func(ctx context.Context, img image.Image, p *models.CompressParams) (image.Image, error) {
+		if p.MaxWidth == nil && p.MaxHeight == nil {
+			return img, nil
+		}
 
-	// Resize if needed
-	if req.MaxWidth != nil || req.MaxHeight != nil {
 		bounds := img.Bounds()
 		originalWidth := bounds.Dx()
 		originalHeight := bounds.Dy()
@@ -295,30 +264,28 @@ func CompressImage(req models.CompressRequest) (string, error) {
 		newHeight := originalHeight
 
 		// Calculate new dimensions maintaining aspect ratio
-		if req.MaxWidth != nil && originalWidth > *req.MaxWidth {
-			ratio := float64(*req.MaxWidth) / float64(originalWidth)
-			newWidth = *req.MaxWidth
+		if p.MaxWidth != nil && originalWidth > *p.MaxWidth {
+			ratio := float64(*p.MaxWidth) / float64(originalWidth)
+			newWidth = *p.MaxWidth
 			newHeight = int(float64(originalHeight) * ratio)
 		}
 
-		if req.MaxHeight != nil && newHeight > *req.MaxHeight {
-			ratio := float64(*req.MaxHeight) / float64(newHeight)
-			newHeight = *req.MaxHeight
+		if p.MaxHeight != nil && newHeight > *p.MaxHeight {
+			ratio := float64(*p.MaxHeight) / float64(newHeight)
+			newHeight = *p.MaxHeight
 			newWidth = int(float64(newWidth) * ratio)
 		}
 
 		// Resize only if dimensions changed
 		if newWidth != originalWidth || newHeight != originalHeight {
-			img = imaging.Resize(img, newWidth, newHeight, imaging.Lanczos)
+			return imaging.Resize(img, newWidth, newHeight, imaging.Lanczos), nil
 		}
-	}
-
-	// Encode the compressed image back to base64 with quality options
-	var buf bytes.Buffer
-	err = utils.EncodeImageWithQuality(img, outputFormat, &buf, req.Quality)
-	if err != nil {
-		return "", fmt.Errorf("failed to encode compressed image: %w", err)
-	}
-
-	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+		return img, nil
+	},
+	encoding: func(p *models.CompressParams, format string) (string, *int) {
+		if p.Format != nil {
+			format = *p.Format
+		}
+		return format, p.Quality
+	},
 }
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"image-editor-app/backend/models"
+)
+
+// fakePython replaces the Python backend with a script that returns result,
+// whatever it is asked.
+func fakePython(t *testing.T, result []byte, format string) {
+	t.Helper()
+	script := filepath.Join(t.TempDir(), "background_remover.sh")
+	response := fmt.Sprintf(`{"success":true,"image_base64":"%s","format":"%s"}`, base64.StdEncoding.EncodeToString(result), format)
+	if err := os.WriteFile(script, []byte("cat >/dev/null\nprintf '%s' '"+response+"'\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	saved := pythonClient
+	pythonClient = &PythonClient{pythonPath: "/bin/sh", scriptPath: script}
+	t.Cleanup(func() { pythonClient = saved })
+}
+
+// encodePNG encodes img at the best compression, which EncodeImage does not
+// use, so that re-encoded output differs from it.
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := enc.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBackgroundPassThrough(t *testing.T) {
+	result := encodePNG(t, image.NewNRGBA(image.Rect(0, 0, 40, 30)))
+	fakePython(t, result, "png")
+	source := encodePNG(t, blackImage(40, 30))
+
+	for _, op := range []Operation{removeBackgroundOperation, changeBackgroundOperation} {
+		t.Run(op.Name(), func(t *testing.T) {
+			steps := Steps{{Operation: op, Params: op.Params()}}
+			res, err := steps.Apply(context.Background(), source, models.OutputOptions{Metadata: "strip"})
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if !bytes.Equal(res.Data, result) {
+				t.Error("the Python output was re-encoded")
+			}
+		})
+	}
+}
+
+func TestBackgroundInPipeline(t *testing.T) {
+	fakePython(t, encodePNG(t, image.NewNRGBA(image.Rect(0, 0, 40, 30))), "png")
+
+	resize := &models.ResizeParams{Width: 20, Height: 15}
+	steps := Steps{
+		{Operation: removeBackgroundOperation, Params: removeBackgroundOperation.Params()},
+		{Operation: resizeOperation, Params: resize},
+	}
+	res, err := steps.Apply(context.Background(), encodePNG(t, blackImage(40, 30)), models.OutputOptions{})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if res.Format != "png" || res.Width != 20 || res.Height != 15 {
+		t.Errorf("result is a %dx%d %s image, want a 20x15 png", res.Width, res.Height, res.Format)
+	}
+}
+
+func TestCropBounds(t *testing.T) {
+	source := encodePNG(t, blackImage(40, 30))
+	crop := func(p *models.CropParams) (*Result, error) {
+		steps := Steps{{Operation: cropOperation, Params: p}}
+		return steps.Apply(context.Background(), source, models.OutputOptions{})
+	}
+
+	// A rectangle overhanging the image is cut to it
+	res, err := crop(&models.CropParams{X: 30, Y: 20, Width: 50, Height: 50})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if res.Width != 10 || res.Height != 10 {
+		t.Errorf("result is %dx%d, want 10x10", res.Width, res.Height)
+	}
+
+	// A rectangle outside the image is the client's mistake
+	_, err = crop(&models.CropParams{X: 40, Y: 0, Width: 10, Height: 10})
+	if svcErr := AsError(err); svcErr.Code != CodeInvalidInput || svcErr.HTTPStatus() != http.StatusBadRequest {
+		t.Errorf("crop outside the image = %v, want a 400 invalid_input error", err)
+	}
+}
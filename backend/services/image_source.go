@@ -0,0 +1,223 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image"
+	"log/slog"
+	"time"
+
+	"image-editor-app/backend/metrics"
+	"image-editor-app/backend/models"
+	"image-editor-app/backend/tracing"
+	"image-editor-app/backend/utils"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// sourceImage is a decoded request image together with what was read from its raw bytes.
+type sourceImage struct {
+	img       image.Image
+	format    string
+	metadata  *utils.Metadata
+	profile   *utils.ICCProfile // Color space of img, or nil if it could not be determined
+	converted bool              // Whether img was converted out of its embedded color space
+}
+
+// validateOutputOptions checks the output settings shared by all requests.
+func validateOutputOptions(opts models.OutputOptions) error {
+	if err := utils.ValidateMetadataPolicy(opts.Metadata); err != nil {
+		return newError(CodeInvalidInput, nil, "%v", err)
+	}
+	if _, err := utils.LookupProfile(opts.OutputProfile); err != nil {
+		return newError(CodeInvalidInput, nil, "%v", err)
+	}
+	return nil
+}
+
+// decodeBase64 decodes a base64 image field.
+func decodeBase64(imageBase64 string) ([]byte, error) {
+	imgBytes, err := base64.StdEncoding.DecodeString(imageBase64)
+	if err != nil {
+		return nil, newError(CodeInvalidInput, err, "image_base64 is not valid base64")
+	}
+	return imgBytes, nil
+}
+
+// readRequestImage decodes a base64 request image within the configured length limit.
+func readRequestImage(ctx context.Context, imageBase64 string) (imgBytes []byte, err error) {
+	_, span := tracing.Start(ctx, "decode_base64", attribute.Int("image.base64_length", len(imageBase64)))
+	defer func() { tracing.End(span, err) }()
+
+	if err := checkBase64Length(imageBase64); err != nil {
+		return nil, err
+	}
+	return decodeBase64(imageBase64)
+}
+
+// decodeSource decodes encoded image data within the configured limits,
+// extracts its metadata, applies the EXIF orientation and converts the pixels
+// into the requested output color space.
+func decodeSource(ctx context.Context, imgBytes []byte, opts models.OutputOptions) (*sourceImage, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+	ReportProgress(ctx, StageDecoding, -1)
+	if err := checkInputDimensions(imgBytes); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	_, span := tracing.Start(ctx, "decode", attribute.Int("image.input_bytes", len(imgBytes)))
+	img, format, err := image.Decode(bytes.NewReader(imgBytes))
+	if err != nil {
+		err := decodeError(err)
+		tracing.End(span, err)
+		return nil, err
+	}
+	span.SetAttributes(
+		attribute.String("image.format", format),
+		attribute.Int("image.width", img.Bounds().Dx()),
+		attribute.Int("image.height", img.Bounds().Dy()))
+	span.End()
+	metrics.DecodedMegapixels.Observe(float64(img.Bounds().Dx()*img.Bounds().Dy()) / 1e6)
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	_, span = tracing.Start(ctx, "orientation")
+	orientation := utils.GetExifOrientation(imgBytes)
+	span.SetAttributes(attribute.Int("image.orientation", orientation))
+	src := &sourceImage{
+		img:      utils.FixOrientation(img, orientation),
+		format:   format,
+		metadata: utils.ExtractMetadata(imgBytes),
+	}
+	span.End()
+
+	src.img, src.profile, src.converted = convertToOutputProfile(ctx, src.img, src.metadata, opts.OutputProfile)
+
+	slog.DebugContext(ctx, "decoded image",
+		"format", format,
+		"width", img.Bounds().Dx(),
+		"height", img.Bounds().Dy(),
+		"orientation", orientation,
+		"bytes", len(imgBytes),
+		"duration_ms", time.Since(start).Milliseconds())
+	return src, nil
+}
+
+// convertToOutputProfile converts img from its embedded ICC profile (sRGB if
+// none) into the named output profile and reports whether any conversion was
+// needed. When the embedded profile cannot be interpreted, the pixels are
+// returned unchanged with a nil profile.
+func convertToOutputProfile(ctx context.Context, img image.Image, metadata *utils.Metadata, outputProfile string) (image.Image, *utils.ICCProfile, bool) {
+	target, err := utils.LookupProfile(outputProfile)
+	if err != nil {
+		return img, nil, false
+	}
+
+	source, _ := utils.LookupProfile(utils.ProfileSRGB)
+	if len(metadata.ICC) > 0 {
+		source, err = utils.ParseICCProfile(metadata.ICC)
+		if err != nil {
+			slog.WarnContext(ctx, "leaving colors unconverted", "error", err)
+			return img, nil, false
+		}
+	}
+
+	if source.Equal(target) {
+		return img, target, false
+	}
+	return utils.ConvertColorProfile(img, source, target), target, true
+}
+
+// tagOutputProfile sets the ICC profile to embed in the output. A converted
+// image drops the stale source profile; the output profile is embedded when
+// requested or when it is not sRGB, since untagged output is read as sRGB.
+func tagOutputProfile(metadata *utils.Metadata, profile *utils.ICCProfile, converted bool, opts models.OutputOptions) {
+	if profile == nil {
+		return
+	}
+	if converted {
+		metadata.ICC = nil
+	}
+	embed := opts.EmbedProfile || (opts.OutputProfile != "" && opts.OutputProfile != utils.ProfileSRGB)
+	if embed && len(metadata.ICC) == 0 {
+		metadata.ICC = profile.Bytes()
+	}
+}
+
+// encodeResult encodes img in the given format and re-embeds the source
+// metadata allowed by the policy. The pixels have already been auto-rotated,
+// so the orientation tag of the kept EXIF data is reset to 1.
+func encodeResult(ctx context.Context, src *sourceImage, img image.Image, format string, quality *int, opts models.OutputOptions) (encoded []byte, err error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+	ReportProgress(ctx, StageEncoding, -1)
+
+	start := time.Now()
+	_, span := tracing.Start(ctx, "encode",
+		attribute.String("image.format", format),
+		attribute.Int("image.width", img.Bounds().Dx()),
+		attribute.Int("image.height", img.Bounds().Dy()))
+	defer func() {
+		span.SetAttributes(attribute.Int("image.output_bytes", len(encoded)))
+		tracing.End(span, err)
+	}()
+
+	var buf bytes.Buffer
+	if err := utils.EncodeImageWithQuality(img, format, &buf, quality); err != nil {
+		return nil, err
+	}
+
+	metadata := src.metadata.Filter(opts.Metadata)
+	metadata.ResetOrientation()
+	tagOutputProfile(metadata, src.profile, src.converted, opts)
+	encoded, err = utils.EmbedMetadata(buf.Bytes(), metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, "encoded image",
+		"format", format,
+		"width", img.Bounds().Dx(),
+		"height", img.Bounds().Dy(),
+		"bytes", len(encoded),
+		"duration_ms", time.Since(start).Milliseconds())
+	return encoded, nil
+}
+
+// finishEncodedResult carries the source metadata allowed by the policy over
+// to an image an EncodedApplier produced, converting its colors into the
+// output profile first. The Python backend does not rotate pixels, so the
+// original orientation tag is kept.
+func finishEncodedResult(ctx context.Context, sourceBytes, resultBytes []byte, format string, opts models.OutputOptions) ([]byte, error) {
+	sourceMetadata := utils.ExtractMetadata(sourceBytes)
+	metadata := sourceMetadata.Filter(opts.Metadata)
+
+	needsProfile := len(sourceMetadata.ICC) > 0 || opts.EmbedProfile || (opts.OutputProfile != "" && opts.OutputProfile != utils.ProfileSRGB)
+	if metadata.IsEmpty() && !needsProfile {
+		return resultBytes, nil
+	}
+
+	if needsProfile {
+		result, _, err := image.Decode(bytes.NewReader(resultBytes))
+		if err != nil {
+			return nil, newError(CodeInternal, err, "failed to decode processed image")
+		}
+		result, profile, converted := convertToOutputProfile(ctx, result, sourceMetadata, opts.OutputProfile)
+		if converted {
+			var buf bytes.Buffer
+			if err := utils.EncodeImage(result, format, &buf); err != nil {
+				return nil, err
+			}
+			resultBytes = buf.Bytes()
+		}
+		tagOutputProfile(metadata, profile, converted, opts)
+	}
+
+	return utils.EmbedMetadata(resultBytes, metadata)
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"image-editor-app/backend/metrics"
+	"image-editor-app/backend/models"
+	"image-editor-app/backend/webhook"
+)
+
+// Statuses of a job.
+const (
+	JobPending   = "pending"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// Statuses of the webhook delivery of a job.
+const (
+	DeliveryPending   = "pending" // Not attempted yet, or waiting to be retried
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// jobStore runs operations in the background for requests with a callback
+// URL and keeps the jobs, with their delivery logs, in memory.
+type jobStore struct {
+	mu        sync.Mutex
+	jobs      map[string]*storedJob
+	running   int // Jobs whose operation has not finished
+	stopped   bool
+	lastPrune time.Time
+
+	maxRunning int
+	retention  time.Duration // How long completed jobs are kept
+	sender     *webhook.Sender
+	secret     string // Signs notifications to clients without a secret of their own
+
+	base   context.Context // Canceled by StopJobs
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// storedJob is a job with the client that started it.
+type storedJob struct {
+	models.Job
+	client string
+}
+
+var jobs = newJobStore()
+
+// newJobStore configures background jobs from the environment:
+//
+//   - WEBHOOK_SECRET: key for signing notifications to clients without an API
+//     key; without it, only requests with an API key may have a callback URL
+//   - WEBHOOK_MAX_ATTEMPTS: attempts at delivering a notification, default 8
+//   - WEBHOOK_INITIAL_BACKOFF_SECONDS and WEBHOOK_MAX_BACKOFF_SECONDS: wait
+//     before the first retry, doubled for each one after up to the maximum;
+//     default 5 and 600
+//   - WEBHOOK_TIMEOUT_SECONDS: time limit of each attempt, default 10
+//   - WEBHOOK_ALLOW_INSECURE: "true" to allow http:// callback URLs and
+//     private addresses, e.g., to test against a receiver on the local network
+//   - WEBHOOK_MAX_RUNNING_JOBS: jobs whose operation may run at once, default 100
+//
+// Completed jobs are kept as long as their download links, RESULT_TTL.
+func newJobStore() *jobStore {
+	policy := webhook.Policy{
+		MaxAttempts:    8,
+		InitialBackoff: 5 * time.Second,
+		MaxBackoff:     10 * time.Minute,
+		Timeout:        10 * time.Second,
+		AllowInsecure:  os.Getenv("WEBHOOK_ALLOW_INSECURE") == "true",
+	}
+	if n, ok := envFloat("WEBHOOK_MAX_ATTEMPTS"); ok {
+		policy.MaxAttempts = int(n)
+	}
+	if seconds, ok := envFloat("WEBHOOK_INITIAL_BACKOFF_SECONDS"); ok {
+		policy.InitialBackoff = time.Duration(seconds * float64(time.Second))
+	}
+	if seconds, ok := envFloat("WEBHOOK_MAX_BACKOFF_SECONDS"); ok {
+		policy.MaxBackoff = time.Duration(seconds * float64(time.Second))
+	}
+	if seconds, ok := envFloat("WEBHOOK_TIMEOUT_SECONDS"); ok {
+		policy.Timeout = time.Duration(seconds * float64(time.Second))
+	}
+	if policy.AllowInsecure {
+		slog.Warn("webhooks may be delivered over plain HTTP and to private addresses")
+	}
+
+	s := &jobStore{
+		jobs:       map[string]*storedJob{},
+		maxRunning: 100,
+		retention:  24 * time.Hour,
+		sender:     webhook.NewSender(policy),
+		secret:     os.Getenv("WEBHOOK_SECRET"),
+	}
+	if n, ok := envFloat("WEBHOOK_MAX_RUNNING_JOBS"); ok {
+		s.maxRunning = int(n)
+	}
+	if results != nil {
+		s.retention = results.ttl
+	}
+	s.base, s.cancel = context.WithCancel(context.Background())
+	return s
+}
+
+// JobRequest describes an operation to run in the background.
+type JobRequest struct {
+	Operation   string
+	RequestID   string
+	Client      string // ID of the API key the request was made with, if any
+	CallbackURL string
+	Secret      string // Client's webhook secret; WEBHOOK_SECRET if empty
+}
+
+// StartJob runs run in the background and returns the pending job. Once run
+// returns, the job completes with its result or error, the progress of the
+// request under ctx finishes, and the job is posted to the callback URL.
+// run is called with a context that carries the values of ctx but outlives
+// it; it is only canceled by StopJobs.
+func StartJob(ctx context.Context, req JobRequest, run func(context.Context) (*models.ImageResponse, error)) (*models.Job, error) {
+	s := jobs
+	secret := req.Secret
+	if secret == "" {
+		secret = s.secret
+	}
+	if secret == "" {
+		return nil, newError(CodeInvalidInput, nil, "callback_url requires an API key")
+	}
+	if err := s.sender.CheckURL(req.CallbackURL); err != nil {
+		return nil, &Error{Code: CodeInvalidInput, Message: "invalid callback_url: " + err.Error(), Err: err}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return nil, newError(CodeBackendUnavailable, nil, "server is shutting down")
+	}
+	if s.running >= s.maxRunning {
+		return nil, &Error{
+			Code:       CodeOverloaded,
+			Message:    "too many background jobs are running, please retry later",
+			Details:    map[string]any{"max_running_jobs": s.maxRunning},
+			RetryAfter: overloadRetryAfter,
+		}
+	}
+	s.prune(time.Now())
+
+	job := &storedJob{
+		Job: models.Job{
+			ID:             newJobID(),
+			Operation:      req.Operation,
+			Status:         JobPending,
+			RequestID:      req.RequestID,
+			CreatedAt:      time.Now().UTC(),
+			CallbackURL:    req.CallbackURL,
+			DeliveryStatus: DeliveryPending,
+		},
+		client: req.Client,
+	}
+	s.jobs[job.ID] = job
+	s.running++
+	metrics.JobsRunning.Inc()
+
+	jobCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(s.base, cancel)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+		defer stop()
+		s.run(jobCtx, job.ID, secret, run)
+	}()
+	return job.copy(), nil
+}
+
+// run runs the operation of a job and delivers its notification.
+func (s *jobStore) run(ctx context.Context, id, secret string, run func(context.Context) (*models.ImageResponse, error)) {
+	resp, err := run(ctx)
+	FinishProgress(ctx, err)
+
+	s.mu.Lock()
+	job := s.jobs[id]
+	now := time.Now().UTC()
+	job.CompletedAt = &now
+	if err != nil {
+		svcErr := AsError(err)
+		if svcErr.HTTPStatus() >= http.StatusInternalServerError {
+			slog.ErrorContext(ctx, "background job failed", "job_id", id, "code", svcErr.Code, "error", err)
+		}
+		job.Status = JobFailed
+		job.Error = &models.ErrorResponse{Error: svcErr.Message, Code: string(svcErr.Code), RequestID: job.RequestID, Details: svcErr.Details}
+	} else {
+		job.Status, job.Result = JobSucceeded, resp
+	}
+	s.running--
+	metrics.JobsRunning.Dec()
+	notification, client := job.Job, job.client
+	notification.DeliveryStatus, notification.Deliveries = "", nil
+	s.mu.Unlock()
+
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		slog.ErrorContext(ctx, "encoding webhook notification", "job_id", id, "error", err)
+		s.setDeliveryStatus(id, DeliveryFailed)
+		return
+	}
+	err = s.sender.Send(ctx, notification.CallbackURL, []byte(secret), id, payload, func(a webhook.Attempt) {
+		s.record(ctx, id, a)
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "webhook delivery failed", "job_id", id, "client", client, "error", err)
+		s.setDeliveryStatus(id, DeliveryFailed)
+		return
+	}
+	s.setDeliveryStatus(id, DeliveryDelivered)
+}
+
+// record adds an attempt to the delivery log of a job.
+func (s *jobStore) record(ctx context.Context, id string, a webhook.Attempt) {
+	d := models.WebhookDelivery{
+		Attempt:     a.Number,
+		AttemptedAt: a.Time.UTC(),
+		DurationMS:  a.Duration.Milliseconds(),
+		StatusCode:  a.StatusCode,
+	}
+	outcome := "delivered"
+	if a.Err != nil {
+		d.Error = a.Err.Error()
+		outcome = "failed"
+	}
+	if !a.NextTry.IsZero() {
+		next := a.NextTry.UTC()
+		d.NextAttemptAt = &next
+		outcome = "retry"
+	}
+	metrics.WebhookAttempts.WithLabelValues(outcome).Inc()
+	slog.InfoContext(ctx, "webhook delivery attempt",
+		"job_id", id,
+		"attempt", a.Number,
+		"status", a.StatusCode,
+		"outcome", outcome,
+		"duration_ms", d.DurationMS)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job := s.jobs[id]
+	job.Deliveries = append(job.Deliveries, d)
+}
+
+func (s *jobStore) setDeliveryStatus(id, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[id].DeliveryStatus = status
+}
+
+// prune forgets the jobs completed, and done delivering, longer than the
+// retention time ago, at most once a minute. s.mu must be held.
+func (s *jobStore) prune(now time.Time) {
+	if now.Sub(s.lastPrune) < time.Minute {
+		return
+	}
+	for id, job := range s.jobs {
+		if job.CompletedAt != nil && job.DeliveryStatus != DeliveryPending && now.Sub(*job.CompletedAt) > s.retention {
+			delete(s.jobs, id)
+		}
+	}
+	s.lastPrune = now
+}
+
+// GetJob returns the job with id, if it was started by client: the ID of an
+// API key, or "" for anonymous requests.
+func GetJob(id, client string) (*models.Job, error) {
+	s := jobs
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(time.Now())
+	job, ok := s.jobs[id]
+	if !ok || job.client != client {
+		return nil, newError(CodeNotFound, nil, "job not found")
+	}
+	return job.copy(), nil
+}
+
+// StopJobs stops accepting jobs and waits for the running ones, and their
+// webhook deliveries, until ctx is done. Then it cancels those left, whose
+// notifications are not delivered.
+func StopJobs(ctx context.Context) {
+	s := jobs
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
+	slog.Warn("canceling background jobs and webhook deliveries still running")
+	s.cancel()
+	<-done
+}
+
+func newJobID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "job_" + hex.EncodeToString(b)
+}
+
+// copy returns a copy of the job that is safe to use without holding the
+// store's lock.
+func (j *storedJob) copy() *models.Job {
+	c := j.Job
+	c.Deliveries = append([]models.WebhookDelivery(nil), j.Deliveries...)
+	return &c
+}
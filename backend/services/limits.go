@@ -0,0 +1,292 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"log/slog"
+	"maps"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits bounds the resources a single request may consume.
+type Limits struct {
+	MaxBodyBytes        int64   // Maximum size of a request body
+	MaxBase64Length     int     // Maximum length of a base64 image field
+	MaxInputMegapixels  float64 // Maximum declared pixel count of an input image
+	MaxOutputDimension  int     // Maximum width or height of an output image
+	MaxOutputMegapixels float64 // Maximum pixel count of an output image
+	MaxScaleFactor      float64 // Maximum upscale factor
+	MaxBatchFiles       int     // Maximum number of images in a batch request
+	MaxBatchBytes       int64   // Maximum total size of the images in a batch, after unzipping
+	BatchWorkers        int     // Images of a batch processed concurrently
+
+	OperationTimeout  time.Duration            // Time allowed to process one image
+	OperationTimeouts map[string]time.Duration // Overrides of OperationTimeout by operation, e.g. "upscale"
+
+	Capacity       int64            // Cost units of processing admitted at once
+	OperationCosts map[string]int64 // Cost of one image by operation; 1 if not listed
+	QueueLength    int              // Operations that may wait for capacity before requests are rejected
+	MaxQueueWait   time.Duration    // Time an operation may wait for capacity before its request is rejected
+}
+
+// DefaultLimits are sized for the free-tier VM the service runs on.
+var DefaultLimits = Limits{
+	MaxBodyBytes:        50 << 20,
+	MaxBase64Length:     48 << 20,
+	MaxInputMegapixels:  40,
+	MaxOutputDimension:  10000,
+	MaxOutputMegapixels: 50,
+	MaxScaleFactor:      8,
+	MaxBatchFiles:       100,
+	MaxBatchBytes:       200 << 20,
+	BatchWorkers:        runtime.NumCPU(),
+	OperationTimeout:    60 * time.Second,
+	// Background models run on the CPU and take the longest
+	OperationTimeouts: map[string]time.Duration{
+		"remove-background": 120 * time.Second,
+		"change-background": 120 * time.Second,
+	},
+	// One CPU runs a background removal, two upscales or four of the rest
+	Capacity: 4 * int64(runtime.NumCPU()),
+	OperationCosts: map[string]int64{
+		"upscale":           2,
+		"remove-background": 4,
+		"change-background": 4,
+	},
+	QueueLength:  16,
+	MaxQueueWait: 30 * time.Second,
+}
+
+// LoadLimits returns DefaultLimits with overrides from the environment:
+// IMAGE_MAX_BODY_MB, IMAGE_MAX_MEGAPIXELS, IMAGE_MAX_OUTPUT_DIMENSION,
+// IMAGE_MAX_OUTPUT_MEGAPIXELS, IMAGE_MAX_SCALE_FACTOR, IMAGE_MAX_BATCH_FILES,
+// IMAGE_MAX_BATCH_MB, IMAGE_BATCH_WORKERS, IMAGE_TIMEOUT_SECONDS,
+// IMAGE_CAPACITY, IMAGE_QUEUE_LENGTH, IMAGE_MAX_QUEUE_WAIT_SECONDS and, for
+// each operation, IMAGE_<OPERATION>_TIMEOUT_SECONDS and IMAGE_<OPERATION>_COST,
+// e.g. IMAGE_REMOVE_BACKGROUND_TIMEOUT_SECONDS.
+func LoadLimits() Limits {
+	l := DefaultLimits
+	if mb, ok := envFloat("IMAGE_MAX_BODY_MB"); ok {
+		l.MaxBodyBytes = int64(mb * (1 << 20))
+		// Base64 inflates by 4/3; leave room for the rest of the JSON body
+		l.MaxBase64Length = int(float64(l.MaxBodyBytes) * 0.96)
+	}
+	if mp, ok := envFloat("IMAGE_MAX_MEGAPIXELS"); ok {
+		l.MaxInputMegapixels = mp
+	}
+	if dim, ok := envFloat("IMAGE_MAX_OUTPUT_DIMENSION"); ok {
+		l.MaxOutputDimension = int(dim)
+	}
+	if mp, ok := envFloat("IMAGE_MAX_OUTPUT_MEGAPIXELS"); ok {
+		l.MaxOutputMegapixels = mp
+	}
+	if scale, ok := envFloat("IMAGE_MAX_SCALE_FACTOR"); ok {
+		l.MaxScaleFactor = scale
+	}
+	if n, ok := envFloat("IMAGE_MAX_BATCH_FILES"); ok {
+		l.MaxBatchFiles = int(n)
+	}
+	if mb, ok := envFloat("IMAGE_MAX_BATCH_MB"); ok {
+		l.MaxBatchBytes = int64(mb * (1 << 20))
+	}
+	if n, ok := envFloat("IMAGE_BATCH_WORKERS"); ok {
+		l.BatchWorkers = int(n)
+	}
+	l.OperationTimeouts = map[string]time.Duration{}
+	if seconds, ok := envFloat("IMAGE_TIMEOUT_SECONDS"); ok {
+		// An explicit timeout applies to every operation without its own
+		l.OperationTimeout = time.Duration(seconds * float64(time.Second))
+	} else {
+		maps.Copy(l.OperationTimeouts, DefaultLimits.OperationTimeouts)
+	}
+	if n, ok := envFloat("IMAGE_CAPACITY"); ok {
+		l.Capacity = int64(n)
+	}
+	if n, ok := envFloat("IMAGE_QUEUE_LENGTH"); ok {
+		l.QueueLength = int(n)
+	}
+	if seconds, ok := envFloat("IMAGE_MAX_QUEUE_WAIT_SECONDS"); ok {
+		l.MaxQueueWait = time.Duration(seconds * float64(time.Second))
+	}
+	l.OperationCosts = maps.Clone(DefaultLimits.OperationCosts)
+	if l.OperationCosts == nil {
+		l.OperationCosts = map[string]int64{}
+	}
+	// Operations are found by their variables rather than listed, so that
+	// those registered later are covered
+	for _, env := range os.Environ() {
+		name, _, _ := strings.Cut(env, "=")
+		if op, ok := operationVariable(name, "_TIMEOUT_SECONDS"); ok {
+			if seconds, ok := envFloat(name); ok {
+				l.OperationTimeouts[op] = time.Duration(seconds * float64(time.Second))
+			}
+		}
+		if op, ok := operationVariable(name, "_COST"); ok {
+			if cost, ok := envFloat(name); ok {
+				l.OperationCosts[op] = int64(cost)
+			}
+		}
+	}
+	return l
+}
+
+// operationVariable returns the operation an IMAGE_<OPERATION><suffix>
+// environment variable is set for, e.g. "remove-background" for
+// IMAGE_REMOVE_BACKGROUND_COST.
+func operationVariable(name, suffix string) (string, bool) {
+	op, ok := strings.CutPrefix(name, "IMAGE_")
+	if !ok {
+		return "", false
+	}
+	op, ok = strings.CutSuffix(op, suffix)
+	if !ok || op == "" {
+		return "", false
+	}
+	return strings.ToLower(strings.ReplaceAll(op, "_", "-")), true
+}
+
+// Timeout returns the time allowed to process one image with operation.
+func (l Limits) Timeout(operation string) time.Duration {
+	if d, ok := l.OperationTimeouts[operation]; ok {
+		return d
+	}
+	return l.OperationTimeout
+}
+
+func envFloat(name string) (float64, bool) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil || v <= 0 {
+		slog.Warn("ignoring invalid environment variable", "name", name, "value", raw)
+		return 0, false
+	}
+	return v, true
+}
+
+// Cost returns the capacity units that processing one image with operation takes.
+func (l Limits) Cost(operation string) int64 {
+	if cost, ok := l.OperationCosts[operation]; ok {
+		return cost
+	}
+	return 1
+}
+
+// singleton instance
+var limits = LoadLimits()
+
+// GetLimits returns the limits in effect.
+func GetLimits() Limits {
+	return limits
+}
+
+// SetLimits replaces the limits in effect. It is meant to be called during startup.
+func SetLimits(l Limits) {
+	limits = l
+	admitter = newAdmitter(l)
+}
+
+// checkBase64Length rejects base64 fields longer than the configured limit before decoding them.
+func checkBase64Length(imageBase64 string) error {
+	if len(imageBase64) > limits.MaxBase64Length {
+		return &Error{
+			Code:    CodeTooLarge,
+			Message: "image data exceeds the maximum allowed size",
+			Details: map[string]any{"length": len(imageBase64), "max_length": limits.MaxBase64Length},
+		}
+	}
+	return nil
+}
+
+// checkInputDimensions reads only the image header and rejects images whose
+// declared size exceeds the megapixel budget, before any pixels are allocated.
+func checkInputDimensions(imgBytes []byte) error {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(imgBytes))
+	if err != nil {
+		return decodeError(err)
+	}
+
+	megapixels := float64(cfg.Width) * float64(cfg.Height) / 1e6
+	if megapixels > limits.MaxInputMegapixels {
+		return &Error{
+			Code:    CodeTooLarge,
+			Message: fmt.Sprintf("image of %dx%d pixels exceeds the %.0f megapixel limit", cfg.Width, cfg.Height, limits.MaxInputMegapixels),
+			Details: map[string]any{"width": cfg.Width, "height": cfg.Height, "max_megapixels": limits.MaxInputMegapixels},
+		}
+	}
+	return nil
+}
+
+// checkInputImage validates a base64 image that is handed to the Python
+// backend without being decoded in Go.
+func checkInputImage(imageBase64 string) error {
+	if err := checkBase64Length(imageBase64); err != nil {
+		return err
+	}
+	imgBytes, err := decodeBase64(imageBase64)
+	if err != nil {
+		return err
+	}
+	return checkInputDimensions(imgBytes)
+}
+
+// checkOutputSize rejects output dimensions beyond the configured limits.
+func checkOutputSize(width, height int) error {
+	megapixels := float64(width) * float64(height) / 1e6
+	if width > limits.MaxOutputDimension || height > limits.MaxOutputDimension || megapixels > limits.MaxOutputMegapixels {
+		return &Error{
+			Code:    CodeLimitExceeded,
+			Message: fmt.Sprintf("output of %dx%d pixels exceeds the allowed size", width, height),
+			Details: map[string]any{
+				"width":          width,
+				"height":         height,
+				"max_dimension":  limits.MaxOutputDimension,
+				"max_megapixels": limits.MaxOutputMegapixels,
+			},
+		}
+	}
+	return nil
+}
+
+// checkScaleFactor rejects upscale factors outside (0, MaxScaleFactor].
+func checkScaleFactor(scale float64) error {
+	if scale <= 0 {
+		return newError(CodeInvalidInput, nil, "scale_factor must be greater than 0")
+	}
+	if scale > limits.MaxScaleFactor {
+		return &Error{
+			Code:    CodeLimitExceeded,
+			Message: fmt.Sprintf("scale_factor %.2f exceeds the maximum of %.2f", scale, limits.MaxScaleFactor),
+			Details: map[string]any{"scale_factor": scale, "max_scale_factor": limits.MaxScaleFactor},
+		}
+	}
+	return nil
+}
+
+// CheckBatchSize rejects batches with more files or more bytes than the
+// configured limits. It is called as files are unpacked, so that an archive
+// is abandoned as soon as it goes over.
+func CheckBatchSize(files int, totalBytes int64) error {
+	if files > limits.MaxBatchFiles {
+		return &Error{
+			Code:    CodeLimitExceeded,
+			Message: fmt.Sprintf("batch exceeds the maximum of %d files", limits.MaxBatchFiles),
+			Details: map[string]any{"max_files": limits.MaxBatchFiles},
+		}
+	}
+	if totalBytes > limits.MaxBatchBytes {
+		return &Error{
+			Code:    CodeTooLarge,
+			Message: "batch exceeds the maximum allowed size",
+			Details: map[string]any{"max_bytes": limits.MaxBatchBytes},
+		}
+	}
+	return nil
+}
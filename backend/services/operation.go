@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"regexp"
+	"time"
+
+	"image-editor-app/backend/models"
+	"image-editor-app/backend/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Operation is an image operation. Operations are registered by name, and
+// their API routes, pipeline and batch steps, gRPC binding, imgtool
+// subcommands and OpenAPI entries are all looked up in the registry.
+type Operation interface {
+	// Name identifies the operation in routes, pipelines and limits, e.g.,
+	// "remove-background".
+	Name() string
+	// Summary describes the operation in one line.
+	Summary() string
+	// Params returns a pointer to new parameters to decode a request into.
+	// Their struct type is the schema of the parameters: JSON names,
+	// binding rules, which are checked as they are decoded, and doc tags.
+	Params() any
+	// Validate checks parameters beyond their binding rules.
+	Validate(params any) error
+	// Apply applies the operation to an image. Decoding, color management,
+	// metadata and encoding are left to the caller.
+	Apply(ctx context.Context, img image.Image, params any) (image.Image, error)
+}
+
+// Encoder is implemented by operations that decide how their result is
+// encoded. Encoding returns the output format and, if it sets one, the
+// quality, given the format chosen so far: that of the source, unless an
+// earlier step changed it.
+type Encoder interface {
+	Encoding(params any, format string) (string, *int)
+}
+
+// EncodedApplier is implemented by operations that work on encoded images,
+// such as those the Python backend runs. An operation applied on its own is
+// given the request image as it was sent, and its result is passed through
+// with the source metadata rather than decoded and encoded again. In
+// pipelines, Apply is used.
+type EncodedApplier interface {
+	ApplyEncoded(ctx context.Context, imgBytes []byte, params any) (encoded []byte, format string, err error)
+}
+
+// validOperationName matches names usable as a route segment and in
+// environment variables.
+var validOperationName = regexp.MustCompile(`^[a-z][a-z0-9]*(-[a-z0-9]+)*$`)
+
+// reservedNames are the /v1 routes that are not operations.
+var reservedNames = map[string]bool{"batch": true, "pipeline": true, "progress": true, "jobs": true, "results": true}
+
+// registry holds the registered operations in the order they were
+// registered, which is the order they are listed in.
+var registry = []Operation{
+	resizeOperation,
+	cropOperation,
+	upscaleOperation,
+	convertOperation,
+	blurOperation,
+	removeBackgroundOperation,
+	changeBackgroundOperation,
+	compressOperation,
+}
+
+// Register adds an operation to the registry. It panics if the name is
+// invalid or taken. It is meant to be called from init functions.
+func Register(op Operation) {
+	name := op.Name()
+	if !validOperationName.MatchString(name) || reservedNames[name] {
+		panic(fmt.Sprintf("services: invalid operation name %q", name))
+	}
+	if _, ok := LookupOperation(name); ok {
+		panic(fmt.Sprintf("services: operation %q registered twice", name))
+	}
+	registry = append(registry, op)
+}
+
+// Operations returns the registered operations.
+func Operations() []Operation {
+	return registry
+}
+
+// LookupOperation returns the operation registered under name.
+func LookupOperation(name string) (Operation, bool) {
+	for _, op := range registry {
+		if op.Name() == name {
+			return op, true
+		}
+	}
+	return nil, false
+}
+
+// Step is an operation with its parameters, decoded and validated.
+type Step struct {
+	Operation Operation
+	Params    any
+}
+
+// Steps are operations applied one after another to an image, which is
+// decoded before the first and encoded after the last. A single step is a
+// plain operation request; more make a pipeline.
+type Steps []Step
+
+// Name names the work for limits, metrics and logs: the operation of a
+// single step, or "pipeline".
+func (s Steps) Name() string {
+	if len(s) == 1 {
+		return s[0].Operation.Name()
+	}
+	return "pipeline"
+}
+
+// Cost returns the cost units charged to the client for the steps: the sum
+// of the costs of their operations.
+func (s Steps) Cost() int64 {
+	var cost int64
+	for _, step := range s {
+		cost += limits.Cost(step.Operation.Name())
+	}
+	return cost
+}
+
+// Timeout returns the time allowed to apply the steps: the sum of the
+// timeouts of their operations.
+func (s Steps) Timeout() time.Duration {
+	var timeout time.Duration
+	for _, step := range s {
+		timeout += limits.Timeout(step.Operation.Name())
+	}
+	return timeout
+}
+
+// Admit waits for capacity to apply the steps, as Admit does for one
+// operation. Steps run one at a time, so they are weighed as the most
+// costly of them.
+func (s Steps) Admit(ctx context.Context) (release func(), err error) {
+	var cost int64
+	for _, step := range s {
+		cost = max(cost, limits.Cost(step.Operation.Name()))
+	}
+	return admit(ctx, s.Name(), cost)
+}
+
+// Format returns the format the steps encode their result in when it does
+// not depend on the source, or "".
+func (s Steps) Format() string {
+	format := ""
+	for _, step := range s {
+		if enc, ok := step.Operation.(Encoder); ok {
+			format, _ = enc.Encoding(step.Params, format)
+		}
+	}
+	return format
+}
+
+// Apply decodes encoded image data, applies the steps to it and encodes
+// the result with the output settings.
+func (s Steps) Apply(ctx context.Context, imgBytes []byte, out models.OutputOptions) (*Result, error) {
+	if err := validateOutputOptions(out); err != nil {
+		return nil, err
+	}
+	if len(s) == 1 {
+		if op, ok := s[0].Operation.(EncodedApplier); ok {
+			return applyEncoded(ctx, op, s[0].Params, imgBytes, out)
+		}
+	}
+
+	// Decode the image and apply its EXIF orientation
+	src, err := decodeSource(ctx, imgBytes, out)
+	if err != nil {
+		return nil, err
+	}
+	img, format := src.img, src.format
+
+	var quality *int
+	for _, step := range s {
+		img, err = s.apply(ctx, step, img)
+		if err != nil {
+			return nil, err
+		}
+		if enc, ok := step.Operation.(Encoder); ok {
+			var q *int
+			if format, q = enc.Encoding(step.Params, format); q != nil {
+				quality = q
+			}
+		}
+	}
+
+	encoded, err := encodeResult(ctx, src, img, format, quality, out)
+	if err != nil {
+		if isContextError(err) {
+			return nil, err
+		}
+		return nil, newError(CodeInternal, err, "failed to encode image")
+	}
+	return newResult(encoded)
+}
+
+// applyEncoded applies an operation that works on encoded images to the
+// request image and carries the source metadata over to its result.
+func applyEncoded(ctx context.Context, op EncodedApplier, params any, imgBytes []byte, out models.OutputOptions) (*Result, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+	encoded, format, err := op.ApplyEncoded(ctx, imgBytes, params)
+	if err != nil {
+		return nil, err
+	}
+	output, err := finishEncodedResult(ctx, imgBytes, encoded, format, out)
+	if err != nil {
+		return nil, newError(CodeInternal, err, "failed to apply output options")
+	}
+	return newResult(output)
+}
+
+// apply applies one step, traced on its own in pipelines.
+func (s Steps) apply(ctx context.Context, step Step, img image.Image) (_ image.Image, err error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+	if len(s) > 1 {
+		var span trace.Span
+		ctx, span = tracing.Start(ctx, "step", attribute.String("image.operation", step.Operation.Name()))
+		defer func() { tracing.End(span, err) }()
+	}
+	return step.Operation.Apply(ctx, img, step.Params)
+}
+
+// ReadImage decodes a base64 request image within the configured length limit.
+func ReadImage(ctx context.Context, imageBase64 string) ([]byte, error) {
+	return readRequestImage(ctx, imageBase64)
+}
+
+// funcOperation implements Operation with functions of parameters of type P.
+type funcOperation[P any] struct {
+	name     string
+	summary  string
+	validate func(*P) error                                              // Optional
+	apply    func(context.Context, image.Image, *P) (image.Image, error) // Required
+	encoding func(*P, string) (string, *int)                             // Optional: keeps the format if nil
+}
+
+func (o *funcOperation[P]) Name() string    { return o.name }
+func (o *funcOperation[P]) Summary() string { return o.summary }
+func (o *funcOperation[P]) Params() any     { return new(P) }
+
+func (o *funcOperation[P]) Validate(params any) error {
+	p, err := o.params(params)
+	if err != nil || o.validate == nil {
+		return err
+	}
+	return o.validate(p)
+}
+
+func (o *funcOperation[P]) Apply(ctx context.Context, img image.Image, params any) (image.Image, error) {
+	p, err := o.params(params)
+	if err != nil {
+		return nil, err
+	}
+	return o.apply(ctx, img, p)
+}
+
+func (o *funcOperation[P]) Encoding(params any, format string) (string, *int) {
+	p, err := o.params(params)
+	if err != nil || o.encoding == nil {
+		return format, nil
+	}
+	return o.encoding(p, format)
+}
+
+// params asserts the type of parameters, which callers get from Params.
+func (o *funcOperation[P]) params(params any) (*P, error) {
+	p, ok := params.(*P)
+	if !ok {
+		return nil, newError(CodeInternal, fmt.Errorf("%s: parameters of type %T", o.name, params), "internal error")
+	}
+	return p, nil
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"image-editor-app/backend/models"
+	"image-editor-app/backend/progress"
+)
+
+// Stages of an operation reported in its progress, besides the operation's
+// own, such as "resizing".
+const (
+	StageReceived = "received"
+	StageQueued   = "queued"
+	StageDecoding = "decoding"
+	StageEncoding = "encoding"
+	StageBatch    = "processing" // Files of a batch, with the share of files done
+	StageDone     = "done"
+	StageFailed   = "failed"
+)
+
+// progressRetention is how long the progress of a request can still be
+// watched after it was last reported.
+const progressRetention = time.Minute
+
+// progressHub holds the progress of requests by request ID.
+var progressHub = progress.NewHub[models.ProgressEvent](progressRetention)
+
+type progressKey struct{}
+
+// TrackProgress returns a context under which operations report their
+// progress for id to watchers of WatchProgress. A request reusing the ID of
+// a finished one reports its own progress.
+func TrackProgress(ctx context.Context, id string) context.Context {
+	progressHub.Begin(id)
+	return context.WithValue(ctx, progressKey{}, id)
+}
+
+// untracked returns a context under which nothing reports progress, for
+// work that is one part of the tracked request.
+func untracked(ctx context.Context) context.Context {
+	return context.WithValue(ctx, progressKey{}, "")
+}
+
+// WatchProgress returns a watcher of the progress of the request with id.
+// The watcher must be closed.
+func WatchProgress(id string) *progress.Watcher[models.ProgressEvent] {
+	return progressHub.Watch(id)
+}
+
+// ReportProgress reports that the operation running under ctx reached stage,
+// percent of the way through it, or with percent -1 when that is not known.
+func ReportProgress(ctx context.Context, stage string, percent int) {
+	id, _ := ctx.Value(progressKey{}).(string)
+	if id == "" {
+		return
+	}
+	e := models.ProgressEvent{Stage: stage}
+	if percent >= 0 {
+		e.Percent = &percent
+	}
+	progressHub.Publish(id, e, false)
+}
+
+// FinishProgress reports the outcome of the operation running under ctx:
+// done, or failed with err.
+func FinishProgress(ctx context.Context, err error) {
+	id, _ := ctx.Value(progressKey{}).(string)
+	if id == "" {
+		return
+	}
+	e := models.ProgressEvent{Stage: StageDone}
+	if err != nil {
+		svcErr := AsError(err)
+		e = models.ProgressEvent{Stage: StageFailed, Code: string(svcErr.Code), Message: svcErr.Message}
+	} else {
+		hundred := 100
+		e.Percent = &hundred
+	}
+	progressHub.Publish(id, e, true)
+}
+
+// stepProgress reports the progress of a loop through a stage, at most once
+// per percent.
+type stepProgress struct {
+	ctx   context.Context
+	stage string
+	total int
+	last  int
+}
+
+// startSteps reports the start of stage, which takes total steps.
+func startSteps(ctx context.Context, stage string, total int) *stepProgress {
+	ReportProgress(ctx, stage, 0)
+	return &stepProgress{ctx: ctx, stage: stage, total: max(total, 1)}
+}
+
+// done reports that n steps of the stage are done.
+func (p *stepProgress) done(n int) {
+	if percent := n * 100 / p.total; percent != p.last {
+		p.last = percent
+		ReportProgress(p.ctx, p.stage, percent)
+	}
+}
@@ -0,0 +1,24 @@
+package services
+
+import "context"
+
+// ReadinessCheck is the outcome of one check of a dependency.
+type ReadinessCheck struct {
+	Name string
+	Err  error // nil if the check passed
+}
+
+// CheckReadiness checks what requests depend on besides this process: the
+// Python backend of the background operations, with its script and rembg
+// model, and the result storage.
+func CheckReadiness(ctx context.Context) []ReadinessCheck {
+	checks := GetPythonClient().Check()
+
+	storage := ReadinessCheck{Name: "storage"}
+	if results == nil {
+		storage.Err = newError(CodeBackendUnavailable, nil, "result storage is not configured")
+	} else if err := results.store.Check(ctx); err != nil {
+		storage.Err = newError(CodeBackendUnavailable, err, "result storage is unavailable")
+	}
+	return append(checks, storage)
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"image"
+	"image/draw"
+
+	"github.com/disintegration/imaging"
+)
+
+// resampleBands is the number of bands each pass of resizeInBands is split
+// into.
+const resampleBands = 16
+
+// resizeInBands resizes img to width x height with the Lanczos filter, as
+// imaging.Resize does, reporting its progress under stage and stopping early
+// once ctx is done.
+//
+// imaging.Resize resamples rows first and then columns, and each row, then
+// each column, is resampled on its own. Resampling the rows in bands and then
+// the columns in bands is therefore exactly the same computation, with room
+// for progress reports and cancellation in between.
+func resizeInBands(ctx context.Context, img image.Image, width, height int, stage string) (*image.NRGBA, error) {
+	b := img.Bounds()
+	srcH := b.Dy()
+	steps := startSteps(ctx, stage, 2*resampleBands)
+
+	// Rows first, to the new width
+	wide := image.NewNRGBA(image.Rect(0, 0, width, srcH))
+	for i := 0; i < resampleBands; i++ {
+		if err := checkContext(ctx); err != nil {
+			return nil, err
+		}
+		y0, y1 := srcH*i/resampleBands, srcH*(i+1)/resampleBands
+		if y0 < y1 {
+			band := imaging.Crop(img, image.Rect(b.Min.X, b.Min.Y+y0, b.Max.X, b.Min.Y+y1))
+			draw.Draw(wide, image.Rect(0, y0, width, y1), imaging.Resize(band, width, y1-y0, imaging.Lanczos), image.Point{}, draw.Src)
+		}
+		steps.done(i + 1)
+	}
+
+	// Then columns, to the new height
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < resampleBands; i++ {
+		if err := checkContext(ctx); err != nil {
+			return nil, err
+		}
+		x0, x1 := width*i/resampleBands, width*(i+1)/resampleBands
+		if x0 < x1 {
+			band := imaging.Crop(wide, image.Rect(x0, 0, x1, srcH))
+			draw.Draw(out, image.Rect(x0, 0, x1, height), imaging.Resize(band, x1-x0, height, imaging.Lanczos), image.Point{}, draw.Src)
+		}
+		steps.done(resampleBands + i + 1)
+	}
+	return out, nil
+}
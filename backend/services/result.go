@@ -0,0 +1,44 @@
+package services
+
+import (
+	"bytes"
+	"image"
+)
+
+// Result is the encoded output of an image operation.
+type Result struct {
+	Data   []byte
+	Format string // Container format of Data, e.g. "jpeg" or "png"
+	Width  int
+	Height int
+}
+
+// newResult describes encoded image data. The format is read back from the
+// data because some requested formats are written as JPEG.
+func newResult(data []byte) (*Result, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, newError(CodeInternal, err, "failed to read encoded image")
+	}
+	return &Result{Data: data, Format: format, Width: cfg.Width, Height: cfg.Height}, nil
+}
+
+// MIMEType returns the media type of the result's format.
+func (r *Result) MIMEType() string {
+	switch r.Format {
+	case "jpeg":
+		return "image/jpeg"
+	case "png":
+		return "image/png"
+	case "gif":
+		return "image/gif"
+	case "bmp":
+		return "image/bmp"
+	case "tiff":
+		return "image/tiff"
+	case "webp":
+		return "image/webp"
+	default:
+		return "application/octet-stream"
+	}
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"image-editor-app/backend/storage"
+)
+
+// resultStore keeps results delivered as download links.
+type resultStore struct {
+	store   storage.Store
+	ttl     time.Duration // How long results and their links live
+	secret  []byte        // Key for signing links
+	baseURL string        // Public URL of the API for absolute links; empty to use the request's host
+
+	ephemeralSecret bool // Whether secret was generated because none was configured
+}
+
+// results is nil when result storage could not be configured.
+var results = newResultStore()
+
+// newResultStore configures result storage from the environment:
+//
+//   - RESULT_STORE: "local" (default) or "s3"
+//   - RESULT_DIR: directory of the local store, by default under the system temp directory
+//   - S3_ENDPOINT, S3_REGION, S3_BUCKET, S3_PREFIX, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY for "s3"
+//   - RESULT_TTL: lifetime of results and links as a Go duration, default 24h
+//   - RESULT_URL_SECRET: key for signing links; random per process if unset
+//   - PUBLIC_BASE_URL: public URL of the API, e.g., "https://api.imagenerd.in"
+func newResultStore() *resultStore {
+	r := &resultStore{ttl: 24 * time.Hour, baseURL: os.Getenv("PUBLIC_BASE_URL")}
+
+	if raw := os.Getenv("RESULT_TTL"); raw != "" {
+		ttl, err := time.ParseDuration(raw)
+		if err != nil || ttl <= 0 {
+			slog.Warn("ignoring invalid environment variable", "name", "RESULT_TTL", "value", raw)
+		} else {
+			r.ttl = ttl
+		}
+	}
+
+	if secret := os.Getenv("RESULT_URL_SECRET"); secret != "" {
+		r.secret = []byte(secret)
+	} else {
+		r.secret = make([]byte, 32)
+		rand.Read(r.secret)
+		r.ephemeralSecret = true
+	}
+
+	var err error
+	switch kind := os.Getenv("RESULT_STORE"); kind {
+	case "", "local":
+		dir := os.Getenv("RESULT_DIR")
+		if dir == "" {
+			dir = filepath.Join(os.TempDir(), "image-editor-results")
+		}
+		r.store, err = storage.NewLocalStore(dir)
+	case "s3":
+		r.store, err = storage.NewS3Store(storage.S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			Prefix:          os.Getenv("S3_PREFIX"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		})
+	default:
+		err = errors.New("unknown RESULT_STORE " + strconv.Quote(kind))
+	}
+	if err != nil {
+		slog.Warn("result storage is disabled", "error", err)
+		return nil
+	}
+	return r
+}
+
+// StoredResult is a result saved for download.
+type StoredResult struct {
+	ID        string
+	Path      string // Signed path of the download, relative to the API root
+	ExpiresAt time.Time
+}
+
+// StoreResult saves a result and returns a signed link to it. name is the
+// operation, used in the suggested download name.
+func StoreResult(ctx context.Context, result *Result, name string) (*StoredResult, error) {
+	if results == nil {
+		return nil, newError(CodeBackendUnavailable, nil, "result storage is unavailable")
+	}
+
+	now := time.Now()
+	obj := storage.Object{
+		ID:          storage.NewID(),
+		ContentType: result.MIMEType(),
+		Size:        int64(len(result.Data)),
+		Created:     now,
+	}
+	obj.Filename = name + "-" + obj.ID[:8] + "." + fileExtension(result.Format)
+	if err := results.store.Put(ctx, obj, result.Data); err != nil {
+		slog.ErrorContext(ctx, "storing result", "id", obj.ID, "error", err)
+		return nil, newError(CodeBackendUnavailable, err, "result storage is unavailable")
+	}
+
+	expires := now.Add(results.ttl)
+	query := url.Values{
+		"expires":   {strconv.FormatInt(expires.Unix(), 10)},
+		"signature": {results.sign(obj.ID, expires.Unix())},
+	}
+	return &StoredResult{
+		ID:        obj.ID,
+		Path:      "/v1/results/" + obj.ID + "?" + query.Encode(),
+		ExpiresAt: expires.Truncate(time.Second),
+	}, nil
+}
+
+// OpenResult checks a signed link and opens the result it points to.
+// The caller closes the reader.
+func OpenResult(ctx context.Context, id, expires, signature string) (*storage.Object, io.ReadCloser, error) {
+	if results == nil {
+		return nil, nil, newError(CodeBackendUnavailable, nil, "result storage is unavailable")
+	}
+
+	expiresUnix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || !hmac.Equal([]byte(signature), []byte(results.sign(id, expiresUnix))) {
+		return nil, nil, newError(CodeForbidden, err, "invalid result link signature")
+	}
+	if time.Now().Unix() > expiresUnix {
+		return nil, nil, newError(CodeExpired, nil, "result link has expired")
+	}
+
+	obj, body, err := results.store.Get(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil, newError(CodeNotFound, err, "result not found")
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "reading result", "id", id, "error", err)
+		return nil, nil, newError(CodeBackendUnavailable, err, "result storage is unavailable")
+	}
+	return obj, body, nil
+}
+
+// ResultBaseURL returns the configured public URL of the API, if any.
+func ResultBaseURL() string {
+	if results == nil {
+		return ""
+	}
+	return results.baseURL
+}
+
+func (r *resultStore) sign(id string, expires int64) string {
+	mac := hmac.New(sha256.New, r.secret)
+	mac.Write([]byte(id + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SweepResults deletes expired results until ctx is done. It runs every
+// quarter of the result TTL, between once a minute and once an hour.
+func SweepResults(ctx context.Context) {
+	if results == nil {
+		return
+	}
+	if results.ephemeralSecret {
+		slog.Warn("RESULT_URL_SECRET is not set, result links will stop working on restart")
+	}
+
+	interval := results.ttl / 4
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+	if interval > time.Hour {
+		interval = time.Hour
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := storage.Sweep(ctx, results.store, time.Now().Add(-results.ttl))
+		if err != nil {
+			slog.Warn("sweeping expired results", "error", err)
+		} else if n > 0 {
+			slog.Info("swept expired results", "count", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// fileExtension returns the usual file extension of a format.
+func fileExtension(format string) string {
+	if format == "jpeg" {
+		return "jpg"
+	}
+	return format
+}
@@ -0,0 +1,449 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"image-editor-app/backend/models"
+	"image-editor-app/backend/utils"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+func init() {
+	Register(watermarkOperation)
+}
+
+// bundledFonts are the fonts watermarks can be written in without uploading
+// one: the Go fonts, which are free to embed.
+var bundledFonts = map[string][]byte{
+	"regular":     goregular.TTF,
+	"bold":        gobold.TTF,
+	"italic":      goitalic.TTF,
+	"bold-italic": gobolditalic.TTF,
+	"mono":        gomono.TTF,
+	"mono-bold":   gomonobold.TTF,
+}
+
+// parsedFonts caches the bundled fonts once parsed, by name.
+var parsedFonts sync.Map
+
+// uploadedFonts caches the last uploaded fonts once parsed, by the SHA-256
+// of their base64 encoding, so that a font checked when a request is
+// validated is not parsed again for every image it is applied to.
+var uploadedFonts = struct {
+	sync.Mutex
+	fonts map[[sha256.Size]byte]*opentype.Font
+}{fonts: map[[sha256.Size]byte]*opentype.Font{}}
+
+// maxUploadedFonts bounds the number of uploaded fonts kept parsed.
+const maxUploadedFonts = 16
+
+var watermarkOperation = &funcOperation[models.WatermarkParams]{
+	name:    "watermark",
+	summary: "Add a text or logo watermark to an image",
+	validate: func(p *models.WatermarkParams) error {
+		if (p.Text == "") == (p.Logo == "") {
+			return newError(CodeInvalidInput, nil, "either text or logo_base64 must be provided")
+		}
+		if p.Font != "" && p.FontFile != "" {
+			return newError(CodeInvalidInput, nil, "font and font_file_base64 are mutually exclusive")
+		}
+		if p.FontFile != "" {
+			_, err := watermarkFont(p)
+			return err
+		}
+		return nil
+	},
+	apply: func(ctx context.Context, img image.Image, p *models.WatermarkParams) (image.Image, error) {
+		ReportProgress(ctx, "watermarking", -1)
+		mark, err := watermarkMark(img.Bounds().Dx(), p)
+		if err != nil {
+			return nil, err
+		}
+
+		opacity := 0.5
+		if p.Opacity != nil {
+			opacity = *p.Opacity
+		}
+		rotation := 0.0
+		if p.Rotation != nil {
+			rotation = *p.Rotation
+		} else if p.Tiled {
+			rotation = 30
+		}
+		fade(mark, opacity)
+		if rotation != 0 {
+			mark = imaging.Rotate(mark, rotation, color.Transparent)
+		}
+
+		bounds := img.Bounds()
+		dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+		if p.Tiled {
+			if err := tile(ctx, dst, mark, p.TileSpacing); err != nil {
+				return nil, err
+			}
+			return dst, nil
+		}
+
+		margin := bounds.Dx() / 50
+		if p.Margin != nil {
+			margin = *p.Margin
+		}
+		at := anchorPoint(dst.Bounds(), mark.Bounds().Size(), p.Anchor, margin)
+		draw.Draw(dst, mark.Bounds().Add(at), mark, image.Point{}, draw.Over)
+		return dst, nil
+	},
+}
+
+// watermarkMark renders the watermark upright and fully opaque, for an image
+// width wide: the logo scaled to it, or the text with its outline and shadow.
+func watermarkMark(width int, p *models.WatermarkParams) (*image.NRGBA, error) {
+	if p.Logo != "" {
+		return logoMark(width, p)
+	}
+	f, err := watermarkFont(p)
+	if err != nil {
+		return nil, err
+	}
+	size := p.FontSize
+	if size == 0 {
+		size = math.Max(float64(width)/20, 8)
+	}
+	return textMark(f, p, size)
+}
+
+// watermarkFont returns the font a watermark's text is written in.
+func watermarkFont(p *models.WatermarkParams) (*opentype.Font, error) {
+	if p.FontFile != "" {
+		return uploadedFont(p.FontFile)
+	}
+
+	name := p.Font
+	if name == "" {
+		name = "regular"
+	}
+	if f, ok := parsedFonts.Load(name); ok {
+		return f.(*opentype.Font), nil
+	}
+	data, ok := bundledFonts[name]
+	if !ok {
+		return nil, newError(CodeInvalidInput, nil, "unknown font: %s", name)
+	}
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, newError(CodeInternal, err, "failed to parse the bundled font %s", name)
+	}
+	parsedFonts.Store(name, f)
+	return f, nil
+}
+
+// uploadedFont parses a font uploaded in base64, or returns it from the
+// cache if it was parsed already.
+func uploadedFont(fontBase64 string) (*opentype.Font, error) {
+	if err := checkBase64Length(fontBase64); err != nil {
+		return nil, err
+	}
+	key := sha256.Sum256([]byte(fontBase64))
+	uploadedFonts.Lock()
+	f, ok := uploadedFonts.fonts[key]
+	uploadedFonts.Unlock()
+	if ok {
+		return f, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(fontBase64)
+	if err != nil {
+		return nil, newError(CodeInvalidInput, err, "font_file_base64 is not valid base64")
+	}
+	f, err = opentype.Parse(data)
+	if err != nil {
+		return nil, newError(CodeInvalidInput, err, "font_file_base64 is not a TrueType or OpenType font")
+	}
+
+	uploadedFonts.Lock()
+	defer uploadedFonts.Unlock()
+	if len(uploadedFonts.fonts) >= maxUploadedFonts {
+		for k := range uploadedFonts.fonts {
+			delete(uploadedFonts.fonts, k)
+			break
+		}
+	}
+	uploadedFonts.fonts[key] = f
+	return f, nil
+}
+
+// logoMark decodes a watermark's logo and scales it to its share of the
+// image width.
+func logoMark(width int, p *models.WatermarkParams) (*image.NRGBA, error) {
+	if err := checkBase64Length(p.Logo); err != nil {
+		return nil, err
+	}
+	logoBytes, err := base64.StdEncoding.DecodeString(p.Logo)
+	if err != nil {
+		return nil, newError(CodeInvalidInput, err, "logo_base64 is not valid base64")
+	}
+	if err := checkInputDimensions(logoBytes); err != nil {
+		return nil, err
+	}
+	logo, _, err := image.Decode(bytes.NewReader(logoBytes))
+	if err != nil {
+		return nil, decodeError(err)
+	}
+	logo = utils.FixOrientation(logo, utils.GetExifOrientation(logoBytes))
+
+	scale := p.LogoScale
+	if scale == 0 {
+		scale = 0.2
+	}
+	// The height follows from the aspect ratio, so a thin logo can be tall
+	b := logo.Bounds()
+	w := max(int(float64(width)*scale), 1)
+	h := max(int(math.Round(float64(b.Dy())*float64(w)/float64(b.Dx()))), 1)
+	if err := checkOutputSize(w, h); err != nil {
+		return nil, err
+	}
+	return imaging.Resize(logo, w, h, imaging.Lanczos), nil
+}
+
+// textMark renders a watermark's text, one line under the other and centered,
+// with room around it for its outline and shadow.
+func textMark(f *opentype.Font, p *models.WatermarkParams, size float64) (*image.NRGBA, error) {
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		return nil, newError(CodeInvalidInput, err, "failed to load the font at size %g", size)
+	}
+	defer face.Close()
+
+	lines := strings.Split(p.Text, "\n")
+	metrics := face.Metrics()
+	lineHeight := metrics.Height.Ceil()
+	textWidth := 0
+	for _, line := range lines {
+		textWidth = max(textWidth, font.MeasureString(face, line).Ceil())
+	}
+	textHeight := lineHeight * len(lines)
+
+	stroke := int(math.Ceil(p.StrokeWidth))
+	blur := int(math.Ceil(3 * p.ShadowBlur))
+	pad := image.Point{
+		X: stroke + blur + abs(p.ShadowOffsetX),
+		Y: stroke + blur + abs(p.ShadowOffsetY),
+	}
+	if err := checkOutputSize(textWidth+2*pad.X, textHeight+2*pad.Y); err != nil {
+		return nil, err
+	}
+
+	glyphs := image.NewAlpha(image.Rect(0, 0, textWidth+2*pad.X, textHeight+2*pad.Y))
+	d := &font.Drawer{Dst: glyphs, Src: image.Opaque, Face: face}
+	for i, line := range lines {
+		lineWidth := font.MeasureString(face, line)
+		d.Dot = fixed.Point26_6{
+			X: fixed.I(pad.X) + (fixed.I(textWidth)-lineWidth)/2,
+			Y: fixed.I(pad.Y+i*lineHeight) + metrics.Ascent,
+		}
+		d.DrawString(line)
+	}
+
+	outline := glyphs
+	if p.StrokeWidth > 0 {
+		outline = dilate(glyphs, p.StrokeWidth)
+	}
+
+	mark := image.NewNRGBA(glyphs.Bounds())
+	if p.ShadowOffsetX != 0 || p.ShadowOffsetY != 0 {
+		shadow := image.NewNRGBA(glyphs.Bounds())
+		offset := image.Pt(p.ShadowOffsetX, p.ShadowOffsetY)
+		draw.DrawMask(shadow, shadow.Bounds().Add(offset), image.NewUniform(parseColor(p.ShadowColor, color.Black)),
+			image.Point{}, outline, image.Point{}, draw.Src)
+		if p.ShadowBlur > 0 {
+			shadow = imaging.Blur(shadow, p.ShadowBlur)
+		}
+		draw.Draw(mark, mark.Bounds(), shadow, image.Point{}, draw.Over)
+	}
+	if p.StrokeWidth > 0 {
+		draw.DrawMask(mark, mark.Bounds(), image.NewUniform(parseColor(p.StrokeColor, color.Black)),
+			image.Point{}, outline, image.Point{}, draw.Over)
+	}
+	draw.DrawMask(mark, mark.Bounds(), image.NewUniform(parseColor(p.Color, color.White)),
+		image.Point{}, glyphs, image.Point{}, draw.Over)
+	return mark, nil
+}
+
+// dilate grows the shape of a glyph mask by radius pixels, anti-aliased, to
+// draw an outline around it. The distance of every pixel to the shape is
+// computed exactly, so the outline stays round at any width.
+func dilate(mask *image.Alpha, radius float64) *image.Alpha {
+	b := mask.Bounds()
+	w, h := b.Dx(), b.Dy()
+	inf := float64(w*w + h*h)
+
+	// Squared distance to the nearest pixel that is at least half covered,
+	// first along the columns, then along the rows
+	dist := make([]float64, w*h)
+	for i := range dist {
+		if mask.Pix[(i/w)*mask.Stride+i%w] < 128 {
+			dist[i] = inf
+		}
+	}
+	column := make([]float64, h)
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			column[y] = dist[y*w+x]
+		}
+		distanceTransform(column)
+		for y := 0; y < h; y++ {
+			dist[y*w+x] = column[y]
+		}
+	}
+	for y := 0; y < h; y++ {
+		distanceTransform(dist[y*w : (y+1)*w])
+	}
+
+	out := image.NewAlpha(b)
+	for i, d := range dist {
+		coverage := math.Min(math.Max(radius+0.5-math.Sqrt(d), 0), 1)
+		a := max(uint8(coverage*255), mask.Pix[(i/w)*mask.Stride+i%w])
+		out.Pix[(i/w)*out.Stride+i%w] = a
+	}
+	return out
+}
+
+// distanceTransform replaces f with its squared Euclidean distance transform
+// in one dimension, as described by Felzenszwalb and Huttenlocher: f[i]
+// becomes the minimum of (i-j)² + f[j] over all j.
+func distanceTransform(f []float64) {
+	n := len(f)
+	d := make([]float64, n)
+	v := make([]int, n)       // Locations of the parabolas of the lower envelope
+	z := make([]float64, n+1) // Boundaries between them
+	k := 0
+	z[0], z[1] = math.Inf(-1), math.Inf(1)
+	for q := 1; q < n; q++ {
+		s := intersection(f, q, v[k])
+		for s <= z[k] {
+			k--
+			s = intersection(f, q, v[k])
+		}
+		k++
+		v[k] = q
+		z[k], z[k+1] = s, math.Inf(1)
+	}
+	k = 0
+	for q := 0; q < n; q++ {
+		for z[k+1] < float64(q) {
+			k++
+		}
+		d[q] = float64((q-v[k])*(q-v[k])) + f[v[k]]
+	}
+	copy(f, d)
+}
+
+// intersection returns where the parabolas rooted at q and p meet.
+func intersection(f []float64, q, p int) float64 {
+	return ((f[q] + float64(q*q)) - (f[p] + float64(p*p))) / float64(2*q-2*p)
+}
+
+// fade scales the alpha of every pixel by opacity.
+func fade(img *image.NRGBA, opacity float64) {
+	if opacity >= 1 {
+		return
+	}
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = uint8(float64(img.Pix[i]) * opacity)
+	}
+}
+
+// anchorPoint returns where a watermark of the given size is drawn in bounds
+// to sit at an anchor, margin pixels from the edges it touches.
+func anchorPoint(bounds image.Rectangle, size image.Point, anchor string, margin int) image.Point {
+	if anchor == "" {
+		anchor = "bottom-right"
+	}
+	at := image.Point{
+		X: bounds.Min.X + (bounds.Dx()-size.X)/2,
+		Y: bounds.Min.Y + (bounds.Dy()-size.Y)/2,
+	}
+	switch {
+	case strings.HasSuffix(anchor, "left"):
+		at.X = bounds.Min.X + margin
+	case strings.HasSuffix(anchor, "right"):
+		at.X = bounds.Max.X - size.X - margin
+	}
+	switch {
+	case strings.HasPrefix(anchor, "top"):
+		at.Y = bounds.Min.Y + margin
+	case strings.HasPrefix(anchor, "bottom"):
+		at.Y = bounds.Max.Y - size.Y - margin
+	}
+	return at
+}
+
+// tile repeats a watermark across dst in rows, every other row shifted by
+// half a watermark, so that the copies line up diagonally.
+func tile(ctx context.Context, dst *image.NRGBA, mark *image.NRGBA, spacing *int) error {
+	size := mark.Bounds().Size()
+	gap := size.Y / 2
+	if spacing != nil {
+		gap = *spacing
+	}
+	step := image.Pt(max(size.X+gap, 1), max(size.Y+gap, 1))
+
+	bounds := dst.Bounds()
+	rows := startSteps(ctx, "tiling", bounds.Dy()/step.Y+2)
+	for row, y := 0, -step.Y/2; y < bounds.Max.Y; row, y = row+1, y+step.Y {
+		if err := checkContext(ctx); err != nil {
+			return err
+		}
+		x := -step.X / 2
+		if row%2 == 1 {
+			x += step.X / 2
+		}
+		for ; x < bounds.Max.X; x += step.X {
+			draw.Draw(dst, mark.Bounds().Add(image.Pt(x, y)), mark, image.Point{}, draw.Over)
+		}
+		rows.done(row + 1)
+	}
+	return nil
+}
+
+// parseColor parses an "R,G,B" color, which binding has already validated,
+// or returns fallback if it is empty.
+func parseColor(s string, fallback color.Color) color.Color {
+	parts := strings.Split(s, ",")
+	if len(parts) != 3 {
+		return fallback
+	}
+	var rgb [3]uint8
+	for i, part := range parts {
+		n, _ := strconv.Atoi(strings.TrimSpace(part))
+		rgb[i] = uint8(n)
+	}
+	return color.NRGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 255}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"image-editor-app/backend/models"
+)
+
+// blackImage returns an opaque black image, on which anything a watermark
+// draws shows.
+func blackImage(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+	return img
+}
+
+// whiteLogo returns a PNG of a white square, in base64.
+func whiteLogo(t *testing.T, size int) string {
+	t.Helper()
+	return whiteRect(t, size, size)
+}
+
+// whiteRect returns a PNG of a white rectangle, in base64.
+func whiteRect(t *testing.T, width, height int) string {
+	t.Helper()
+	logo := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := range logo.Pix {
+		logo.Pix[i] = 255
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, logo); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// watermark validates and applies a watermark to img.
+func watermark(t *testing.T, img image.Image, p models.WatermarkParams) *image.NRGBA {
+	t.Helper()
+	if err := watermarkOperation.Validate(&p); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	out, err := watermarkOperation.Apply(context.Background(), img, &p)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	return out.(*image.NRGBA)
+}
+
+// markedBounds returns the bounds of the pixels that are not black.
+func markedBounds(img *image.NRGBA) image.Rectangle {
+	var r image.Rectangle
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if c := img.NRGBAAt(x, y); c.R != 0 || c.G != 0 || c.B != 0 {
+				r = r.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return r
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestWatermarkAnchor(t *testing.T) {
+	cases := map[string]func(marked image.Rectangle) bool{
+		"top-left":     func(r image.Rectangle) bool { return r.Min.X >= 10 && r.Min.Y >= 10 && r.Min.X < 20 && r.Min.Y < 25 },
+		"bottom-right": func(r image.Rectangle) bool { return r.Max.X <= 190 && r.Max.Y <= 90 && r.Max.X > 180 && r.Max.Y > 75 },
+		"center":       func(r image.Rectangle) bool { return r.Min.X > 10 && r.Max.X < 190 && r.Min.Y > 10 && r.Max.Y < 90 },
+	}
+	for anchor, ok := range cases {
+		t.Run(anchor, func(t *testing.T) {
+			out := watermark(t, blackImage(200, 100), models.WatermarkParams{
+				Text:     "Brand",
+				FontSize: 20,
+				Opacity:  ptr(1.0),
+				Anchor:   anchor,
+				Margin:   ptr(10),
+			})
+			if marked := markedBounds(out); marked.Empty() || !ok(marked) {
+				t.Errorf("text drawn at %v", marked)
+			}
+		})
+	}
+}
+
+func TestWatermarkOpacity(t *testing.T) {
+	for _, opacity := range []float64{1, 0.5, 0.2} {
+		out := watermark(t, blackImage(100, 100), models.WatermarkParams{
+			Logo:    whiteLogo(t, 10),
+			Opacity: ptr(opacity),
+			Anchor:  "center",
+		})
+		got := int(out.NRGBAAt(50, 50).R)
+		if want := int(255 * opacity); got < want-2 || got > want+2 {
+			t.Errorf("opacity %g: center is %d, want %d", opacity, got, want)
+		}
+	}
+}
+
+func TestWatermarkLogoScale(t *testing.T) {
+	for _, tc := range []struct {
+		scale float64
+		width int
+	}{{0, 40}, {0.25, 50}, {0.5, 100}} {
+		out := watermark(t, blackImage(200, 150), models.WatermarkParams{
+			Logo:      whiteLogo(t, 16),
+			LogoScale: tc.scale,
+			Opacity:   ptr(1.0),
+			Anchor:    "top-left",
+			Margin:    ptr(5),
+		})
+		marked := markedBounds(out)
+		if marked.Dx() != tc.width || marked.Dy() != tc.width || marked.Min != image.Pt(5, 5) {
+			t.Errorf("logo scale %g: logo drawn at %v, want %dx%d at 5,5", tc.scale, marked, tc.width, tc.width)
+		}
+	}
+}
+
+func TestWatermarkThinLogo(t *testing.T) {
+	// Scaled to half the width, the logo would be a million pixels tall
+	p := models.WatermarkParams{Logo: whiteRect(t, 1, 2000), LogoScale: 0.5, Anchor: "center"}
+	if err := watermarkOperation.Validate(&p); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	_, err := watermarkOperation.Apply(context.Background(), blackImage(1000, 10), &p)
+	var svcErr *Error
+	if !errors.As(err, &svcErr) || svcErr.Code != CodeLimitExceeded {
+		t.Fatalf("got %v, want a limit_exceeded error", err)
+	}
+}
+
+func TestWatermarkTiled(t *testing.T) {
+	out := watermark(t, blackImage(300, 300), models.WatermarkParams{
+		Text:     "DRAFT",
+		FontSize: 16,
+		Opacity:  ptr(1.0),
+		Tiled:    true,
+	})
+
+	// Every quadrant gets some of the watermark
+	for _, quadrant := range []image.Rectangle{
+		image.Rect(0, 0, 150, 150),
+		image.Rect(150, 0, 300, 150),
+		image.Rect(0, 150, 150, 300),
+		image.Rect(150, 150, 300, 300),
+	} {
+		if markedBounds(out.SubImage(quadrant).(*image.NRGBA)).Empty() {
+			t.Errorf("no watermark in %v", quadrant)
+		}
+	}
+}
+
+func TestWatermarkStroke(t *testing.T) {
+	plain := watermark(t, blackImage(200, 100), models.WatermarkParams{Text: "Ii", FontSize: 30, Opacity: ptr(1.0), Anchor: "center"})
+	stroked := watermark(t, blackImage(200, 100), models.WatermarkParams{
+		Text:        "Ii",
+		FontSize:    30,
+		Opacity:     ptr(1.0),
+		Anchor:      "center",
+		StrokeWidth: 3,
+		StrokeColor: "0,0,255",
+	})
+
+	// The text is centered either way, so the outline extends it on all sides
+	p, s := markedBounds(plain), markedBounds(stroked)
+	if s.Dx() < p.Dx()+4 || s.Dy() < p.Dy()+4 {
+		t.Errorf("stroked text covers %v, plain text %v: want 3 pixels more on every side", s, p)
+	}
+}
+
+func TestDilate(t *testing.T) {
+	mask := image.NewAlpha(image.Rect(0, 0, 21, 21))
+	mask.SetAlpha(10, 10, color.Alpha{A: 255})
+
+	grown := dilate(mask, 4)
+
+	// Pixels on the edge of the outline are half covered
+	if a := grown.AlphaAt(14, 10).A; a < 100 || a > 155 {
+		t.Errorf("alpha 4 pixels away is %d, want about half", a)
+	}
+	for _, tc := range []struct {
+		x, y    int
+		covered bool
+	}{
+		{10, 10, true},
+		{13, 10, true}, // 3 pixels away
+		{10, 7, true},
+		{12, 12, true},  // 2.8 pixels away
+		{14, 13, false}, // 5 pixels away
+		{15, 10, false},
+		{0, 0, false},
+	} {
+		a := grown.AlphaAt(tc.x, tc.y).A
+		if covered := a > 0; covered != tc.covered {
+			t.Errorf("alpha at %d,%d is %d, want covered %v", tc.x, tc.y, a, tc.covered)
+		}
+	}
+}
+
+func TestDistanceTransform(t *testing.T) {
+	const inf = 1e9
+	f := []float64{inf, inf, 0, inf, inf, inf, 0, inf}
+	distanceTransform(f)
+	want := []float64{4, 1, 0, 1, 4, 1, 0, 1}
+	for i := range want {
+		if f[i] != want[i] {
+			t.Fatalf("got %v, want %v", f, want)
+		}
+	}
+}
+
+func TestWatermarkValidate(t *testing.T) {
+	cases := map[string]struct {
+		params  models.WatermarkParams
+		message string
+	}{
+		"nothing":         {models.WatermarkParams{}, "either text or logo_base64"},
+		"text and logo":   {models.WatermarkParams{Text: "x", Logo: "x"}, "either text or logo_base64"},
+		"two fonts":       {models.WatermarkParams{Text: "x", Font: "bold", FontFile: "AAAA"}, "mutually exclusive"},
+		"font not base64": {models.WatermarkParams{Text: "x", FontFile: "not base64!"}, "not valid base64"},
+		"font not a font": {models.WatermarkParams{Text: "x", FontFile: base64.StdEncoding.EncodeToString([]byte("plain text"))}, "not a TrueType or OpenType font"},
+		"font too large":  {models.WatermarkParams{Text: "x", FontFile: strings.Repeat("A", limits.MaxBase64Length+4)}, "exceeds the maximum"},
+	}
+	for name, tc := range cases {
+		err := watermarkOperation.Validate(&tc.params)
+		if err == nil || !strings.Contains(err.Error(), tc.message) {
+			t.Errorf("%s: got %v, want an error containing %q", name, err, tc.message)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"image-editor-app/backend/utils"
+)
+
+// LocalStore keeps objects in a directory: the data in <id> and its
+// description in <id>.json.
+type LocalStore struct {
+	dir string
+}
+
+// NewLocalStore returns a store in dir, creating the directory if needed.
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStore{dir: dir}, nil
+}
+
+func (s *LocalStore) Put(ctx context.Context, obj Object, data []byte) error {
+	if !ValidID(obj.ID) {
+		return errInvalidID
+	}
+	meta, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	// Data first: an object only becomes visible once its description exists
+	if err := utils.WriteFileAtomic(filepath.Join(s.dir, obj.ID), data, 0o600); err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(filepath.Join(s.dir, obj.ID+".json"), meta, 0o600)
+}
+
+func (s *LocalStore) Get(ctx context.Context, id string) (*Object, io.ReadCloser, error) {
+	if !ValidID(id) {
+		return nil, nil, ErrNotFound
+	}
+	obj, err := s.readMeta(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(filepath.Join(s.dir, id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return obj, f, nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, id string) error {
+	if !ValidID(id) {
+		return nil
+	}
+	// Description first, so a half-deleted object is no longer served
+	for _, name := range []string{id + ".json", id} {
+		if err := os.Remove(filepath.Join(s.dir, name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *LocalStore) List(ctx context.Context) ([]Object, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []Object
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || !ValidID(id) {
+			continue
+		}
+		obj, err := s.readMeta(id)
+		if errors.Is(err, ErrNotFound) {
+			continue // Deleted concurrently
+		}
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, *obj)
+	}
+	return objects, nil
+}
+
+func (s *LocalStore) Check(ctx context.Context) error {
+	tmp, err := os.CreateTemp(s.dir, ".check-*")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	return os.Remove(tmp.Name())
+}
+
+func (s *LocalStore) readMeta(id string) (*Object, error) {
+	meta, err := os.ReadFile(filepath.Join(s.dir, id+".json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var obj Object
+	if err := json.Unmarshal(meta, &obj); err != nil {
+		return nil, err
+	}
+	return &obj, nil
+}
@@ -0,0 +1,289 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config configures an S3Store.
+type S3Config struct {
+	Endpoint        string // e.g., "https://s3.us-east-1.amazonaws.com" or "http://localhost:9000" for MinIO
+	Region          string
+	Bucket          string
+	Prefix          string // Prepended to object IDs to form keys, e.g., "results/"
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// S3Store keeps objects in an S3-compatible bucket, addressed path-style,
+// which both AWS and MinIO accept. Requests are signed with Signature V4.
+type S3Store struct {
+	cfg        S3Config
+	endpoint   *url.URL
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// NewS3Store returns a store for the bucket described by cfg.
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("storage: invalid S3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("storage: S3 bucket is required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	return &S3Store{
+		cfg:        cfg,
+		endpoint:   endpoint,
+		httpClient: &http.Client{Timeout: time.Minute},
+		now:        time.Now,
+	}, nil
+}
+
+func (s *S3Store) Put(ctx context.Context, obj Object, data []byte) error {
+	if !ValidID(obj.ID) {
+		return errInvalidID
+	}
+	header := http.Header{}
+	header.Set("Content-Type", obj.ContentType)
+	header.Set("X-Amz-Meta-Filename", obj.Filename)
+
+	resp, err := s.do(ctx, http.MethodPut, s.cfg.Prefix+obj.ID, nil, header, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3Store) Get(ctx context.Context, id string) (*Object, io.ReadCloser, error) {
+	if !ValidID(id) {
+		return nil, nil, ErrNotFound
+	}
+	resp, err := s.do(ctx, http.MethodGet, s.cfg.Prefix+id, nil, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	created, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	obj := &Object{
+		ID:          id,
+		ContentType: resp.Header.Get("Content-Type"),
+		Filename:    resp.Header.Get("X-Amz-Meta-Filename"),
+		Size:        resp.ContentLength,
+		Created:     created,
+	}
+	return obj, resp.Body, nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, id string) error {
+	if !ValidID(id) {
+		return nil
+	}
+	resp, err := s.do(ctx, http.MethodDelete, s.cfg.Prefix+id, nil, nil, nil)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// listBucketResult is the part of a ListObjectsV2 response that is used.
+type listBucketResult struct {
+	Contents []struct {
+		Key          string
+		LastModified time.Time
+		Size         int64
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+func (s *S3Store) List(ctx context.Context) ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {s.cfg.Prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var page listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("storage: decoding S3 listing: %w", err)
+		}
+
+		for _, c := range page.Contents {
+			id := strings.TrimPrefix(c.Key, s.cfg.Prefix)
+			if ValidID(id) {
+				objects = append(objects, Object{ID: id, Size: c.Size, Created: c.LastModified})
+			}
+		}
+		if !page.IsTruncated {
+			return objects, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+func (s *S3Store) Check(ctx context.Context) error {
+	resp, err := s.do(ctx, http.MethodHead, "", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// do sends a signed request for key in the bucket, or for the bucket itself
+// with an empty key. Error responses are returned as errors, with 404 as ErrNotFound.
+func (s *S3Store) do(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	u := *s.endpoint
+	u.Path = u.Path + "/" + s.cfg.Bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.ContentLength = int64(len(body))
+	signV4(req, body, s.cfg.AccessKeyID, s.cfg.SecretAccessKey, s.cfg.Region, "s3", s.now())
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("storage: S3 %s %s: %w", method, key, err)
+	}
+	if resp.StatusCode == http.StatusNotFound && key != "" {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, fmt.Errorf("storage: S3 %s %s: %s: %s", method, key, resp.Status, bytes.TrimSpace(msg))
+	}
+	return resp, nil
+}
+
+// signV4 adds the AWS Signature Version 4 headers to req.
+// See https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_sigv-create-signed-request.html.
+func signV4(req *http.Request, body []byte, accessKey, secretKey, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// Sign every header that is set; lowercase names, sorted
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		values := req.Header.Values(name)
+		for i, v := range values {
+			values[i] = strings.Join(strings.Fields(v), " ")
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.Join(values, ",") + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+	// Host is sent from req.Host; the header was only needed for signing
+	req.Header.Del("Host")
+	req.Host = req.URL.Host
+}
+
+// canonicalQuery encodes a query string the way Signature V4 expects: keys
+// sorted and spaces escaped as %20 rather than "+".
+func canonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	escape := func(s string) string {
+		return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+	}
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, escape(k)+"="+escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// String describes the store for logs, without credentials.
+func (s *S3Store) String() string {
+	return "s3://" + s.cfg.Bucket + "/" + s.cfg.Prefix + " at " + s.endpoint.String()
+}
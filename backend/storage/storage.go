@@ -0,0 +1,77 @@
+// Package storage persists processed images under opaque IDs so they can be
+// downloaded later instead of being returned inline.
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"regexp"
+	"time"
+)
+
+// ErrNotFound is returned for IDs that are not stored.
+var ErrNotFound = errors.New("storage: object not found")
+
+var errInvalidID = errors.New("storage: invalid object ID")
+
+// Object describes a stored result.
+type Object struct {
+	ID          string
+	ContentType string
+	Filename    string // Suggested download name
+	Size        int64
+	Created     time.Time
+}
+
+// Store is a place results are kept. Implementations are safe for concurrent use.
+type Store interface {
+	// Put stores data under obj.ID.
+	Put(ctx context.Context, obj Object, data []byte) error
+	// Get opens the object stored under id. The caller closes the reader.
+	Get(ctx context.Context, id string) (*Object, io.ReadCloser, error)
+	// Delete removes the object stored under id. Deleting a missing object is not an error.
+	Delete(ctx context.Context, id string) error
+	// List returns every stored object. Only ID, Size and Created are guaranteed to be set.
+	List(ctx context.Context) ([]Object, error)
+	// Check reports whether the store can currently be written to.
+	Check(ctx context.Context) error
+}
+
+// validID matches the IDs generated by NewID.
+var validID = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// NewID returns a random, unguessable object ID.
+func NewID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ValidID reports whether id has the format of the IDs returned by NewID.
+// Stores reject other IDs, which keeps user input out of paths and keys.
+func ValidID(id string) bool {
+	return validID.MatchString(id)
+}
+
+// Sweep deletes the objects created before cutoff and returns how many were deleted.
+func Sweep(ctx context.Context, store Store, cutoff time.Time) (int, error) {
+	objects, err := store.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, obj := range objects {
+		if !obj.Created.Before(cutoff) {
+			continue
+		}
+		if err := store.Delete(ctx, obj.ID); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
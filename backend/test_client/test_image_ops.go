@@ -1,19 +1,21 @@
 package main
 
 import (
-	"encoding/base64"
+	"context"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
 	"os"
-	"strings" // Import for strings.NewReader
+	"time"
+
+	"image-editor-app/backend/client"
 )
 
 const apiBaseURL = "http://localhost:8080" // Assuming your Go server runs on 8080
 
 func main() {
-	if len(os.Args) < 3 {
-		fmt.Println("Usage: go run test_image_ops.go <image_url_for_removal> <image_url_for_new_background>")
+	if len(os.Args) < 2 {
+		fmt.Println("Usage: go run test_image_ops.go <image_url_for_removal> [image_url_for_new_background]")
 		return
 	}
 	imageURLForRemoval := os.Args[1]
@@ -23,122 +25,79 @@ func main() {
 		imageURLForNewBackground = os.Args[2]
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	api := client.New(apiBaseURL)
+
+	// Download the image once; both tests reuse it
+	original, err := download(ctx, imageURLForRemoval)
+	if err != nil {
+		fmt.Printf("Error downloading image: %v\n", err)
+		return
+	}
+
 	// --- Test Remove Background ---
 	fmt.Println("--- Testing Remove Background ---")
-	removedBgBase64, err := processImage(imageURLForRemoval, "/remove-background", `{"image_base64":"%s"}`, "")
+	removed, err := api.RemoveBackground(ctx, client.ImageFromBytes(original), client.RemoveBackgroundOptions{})
 	if err != nil {
 		fmt.Printf("Error during background removal: %v\n", err)
 		return
 	}
-	fmt.Println("Removed Background Image Base64 (first 100 chars):", removedBgBase64[:100])
-	fmt.Println("Full Removed Background Base64 string length:", len(removedBgBase64))
+	printResult(removed)
 
 	// --- Test Change Background ---
 	fmt.Println("\n--- Testing Change Background ---")
-	// Download the new background image
-	newBgResp, err := http.Get(imageURLForNewBackground)
+	// Stream the new background straight from the download into the request
+	newBgResp, err := get(ctx, imageURLForNewBackground)
 	if err != nil {
 		fmt.Printf("Error downloading new background image: %v\n", err)
 		return
 	}
 	defer newBgResp.Body.Close()
 
-	if newBgResp.StatusCode != http.StatusOK {
-		fmt.Printf("Error: Received non-200 status code %d from %s\n", newBgResp.StatusCode, imageURLForNewBackground)
-		return
-	}
-
-	newBgImgBytes, err := ioutil.ReadAll(newBgResp.Body)
-	if err != nil {
-		fmt.Printf("Error reading new background image bytes: %v\n", err)
-		return
-	}
-	newBgImageBase64 := base64.StdEncoding.EncodeToString(newBgImgBytes)
-
-	changedBgBase64, err := processImage(imageURLForRemoval, "/change-background", `{"image_base64":"%s", "new_background_image_base64":"%s"}`, newBgImageBase64)
+	changed, err := api.ChangeBackground(ctx, client.ImageFromBytes(original), client.ChangeBackgroundOptions{
+		Background: client.ImageFromReader(newBgResp.Body),
+	})
 	if err != nil {
 		fmt.Printf("Error during background change: %v\n", err)
 		return
 	}
-	fmt.Println("Changed Background Image Base64 (first 100 chars):", changedBgBase64[:100])
-	fmt.Println("Full Changed Background Base64 string length:", len(changedBgBase64))
+	printResult(changed)
 }
 
-// Helper function to download, encode, and send image to an endpoint
-func processImage(imageURL, endpoint, payloadFormat string, extraBase64 string) (string, error) {
-	// Download the image
-	resp, err := http.Get(imageURL)
+// get starts a download and checks its status.
+func get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return "", fmt.Errorf("error downloading image from %s: %w", imageURL, err)
+		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("error: received non-200 status code %d from %s", resp.StatusCode, imageURL)
-	}
-
-	imgBytes, err := ioutil.ReadAll(resp.Body)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("error reading image bytes from %s: %w", imageURL, err)
+		return nil, fmt.Errorf("error downloading image from %s: %w", url, err)
 	}
-
-	// Encode to base64
-	imageBase64 := base64.StdEncoding.EncodeToString(imgBytes)
-
-	// Prepare payload
-	var payload string
-	if extraBase64 != "" {
-		payload = fmt.Sprintf(payloadFormat, imageBase64, extraBase64)
-	} else {
-		payload = fmt.Sprintf(payloadFormat, imageBase64)
-	}
-
-	// Send request to the API
-	apiURL := apiBaseURL + endpoint
-	req, err := http.NewRequest("POST", apiURL, strings.NewReader(payload))
-	if err != nil {
-		return "", fmt.Errorf("error creating request to %s: %w", apiURL, err)
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("error: received non-200 status code %d from %s", resp.StatusCode, url)
 	}
-	req.Header.Set("Content-Type", "application/json")
+	return resp, nil
+}
 
-	client := &http.Client{}
-	apiResp, err := client.Do(req)
+// download reads a whole image into memory.
+func download(ctx context.Context, url string) ([]byte, error) {
+	resp, err := get(ctx, url)
 	if err != nil {
-		return "", fmt.Errorf("error sending request to %s: %w", apiURL, err)
-	}
-	defer apiResp.Body.Close()
-
-	if apiResp.StatusCode != http.StatusOK {
-		bodyBytes, _ := ioutil.ReadAll(apiResp.Body)
-		return "", fmt.Errorf("API call to %s failed with status %d: %s", apiURL, apiResp.StatusCode, string(bodyBytes))
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	// Read response
-	apiResponseBody, err := ioutil.ReadAll(apiResp.Body)
+	buf, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("error reading API response body from %s: %w", apiURL, err)
-	}
-
-	// Parse JSON response to extract the base64 string
-	// This is a simplified parsing, a real app would use a JSON unmarshaler
-	responseString := string(apiResponseBody)
-	// Find the key and extract its value
-	key := ""
-	if endpoint == "/remove-background" {
-		key = `"image_without_background_base64":"`
-	} else if endpoint == "/change-background" {
-		key = `"image_with_new_background_base64":"`
-	}
-
-	startIndex := strings.Index(responseString, key)
-	if startIndex == -1 {
-		return "", fmt.Errorf("could not find key '%s' in API response", key)
-	}
-	startIndex += len(key)
-	endIndex := strings.Index(responseString[startIndex:], `"`)
-	if endIndex == -1 {
-		return "", fmt.Errorf("could not find end quote for base64 string in API response")
+		return nil, fmt.Errorf("error reading image bytes from %s: %w", url, err)
 	}
+	return buf, nil
+}
 
-	return responseString[startIndex : startIndex+endIndex], nil
-}
\ No newline at end of file
+func printResult(res *client.Result) {
+	fmt.Printf("Result: %s, %dx%d, %d bytes, processed in %dms (request %s)\n",
+		res.Format, res.Width, res.Height, len(res.Data), res.ProcessingMS, res.RequestID)
+}
@@ -0,0 +1,117 @@
+// Package tracing configures OpenTelemetry tracing and helps instrument the
+// stages of image processing with spans.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans of this module.
+const tracerName = "image-editor-app/backend"
+
+// Setup installs the global tracer provider and W3C trace context
+// propagation, configured from the environment:
+//
+//   - OTEL_TRACES_EXPORTER: "none" (default), "otlp" or "stdout"
+//   - OTEL_EXPORTER_OTLP_ENDPOINT and the other standard OTLP variables for "otlp"
+//   - OTEL_SERVICE_NAME: service name of the spans, default "image-editor-api"
+//
+// The returned function flushes and stops the exporter.
+func Setup(ctx context.Context) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	switch kind := strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER")); kind {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx)
+	case "stdout":
+		exporter, err = stdouttrace.New()
+	default:
+		err = fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := newProvider(sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// UseExporter installs a tracer provider that hands every span to exporter as
+// soon as it ends, such as a tracetest.InMemoryExporter in tests.
+func UseExporter(exporter sdktrace.SpanExporter) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	otel.SetTracerProvider(newProvider(sdktrace.WithSyncer(exporter)))
+}
+
+func newProvider(exporter sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	name := os.Getenv("OTEL_SERVICE_NAME")
+	if name == "" {
+		name = "image-editor-api"
+	}
+	return sdktrace.NewTracerProvider(
+		exporter,
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(name))),
+	)
+}
+
+// Tracer returns the tracer of this module.
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Start starts a span named name as a child of the span in ctx. Without a
+// configured exporter, spans are no-ops.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends span, marking it as failed if err is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject writes the trace context of ctx into carrier, e.g., the headers of
+// an outgoing request.
+func Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+}
+
+// Extract returns a copy of ctx with the trace context read from carrier,
+// e.g., the headers of an incoming request.
+func Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
+
+// Environ returns the trace context of ctx as environment variables for a
+// subprocess, e.g., "TRACEPARENT=00-...".
+func Environ(ctx context.Context) []string {
+	carrier := propagation.MapCarrier{}
+	Inject(ctx, carrier)
+	env := make([]string, 0, len(carrier))
+	for key, value := range carrier {
+		env = append(env, strings.ToUpper(key)+"="+value)
+	}
+	return env
+}
@@ -0,0 +1,229 @@
+package utils
+
+import (
+	"encoding/binary"
+)
+
+// EXIF tag IDs used when rewriting metadata.
+const (
+	exifTagOrientation = 0x0112
+	exifTagArtist      = 0x013B
+	exifTagCopyright   = 0x8298
+	exifTagGPSInfo     = 0x8825
+)
+
+// EXIF field types and their sizes in bytes, indexed by type ID.
+const exifTypeASCII = 2
+
+var exifTypeSizes = map[uint16]int{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8,
+}
+
+// exifEntry is a single IFD entry as stored in a TIFF structure.
+type exifEntry struct {
+	offset int // Offset of the 12-byte entry within the TIFF data
+	tag    uint16
+	typ    uint16
+	count  uint32
+}
+
+// exifByteOrder validates a TIFF header and returns its byte order.
+func exifByteOrder(tiff []byte) (binary.ByteOrder, bool) {
+	if len(tiff) < 8 {
+		return nil, false
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, false
+	}
+
+	if order.Uint16(tiff[2:4]) != 0x002A {
+		return nil, false
+	}
+	return order, true
+}
+
+// exifIFDEntries returns the entries of the IFD starting at ifdOffset.
+// Entries that run past the end of the data are dropped.
+func exifIFDEntries(tiff []byte, order binary.ByteOrder, ifdOffset int) []exifEntry {
+	if ifdOffset < 8 || ifdOffset+2 > len(tiff) {
+		return nil
+	}
+
+	numEntries := int(order.Uint16(tiff[ifdOffset : ifdOffset+2]))
+	entries := make([]exifEntry, 0, numEntries)
+	for i := 0; i < numEntries; i++ {
+		offset := ifdOffset + 2 + i*12
+		if offset+12 > len(tiff) {
+			break
+		}
+		entries = append(entries, exifEntry{
+			offset: offset,
+			tag:    order.Uint16(tiff[offset : offset+2]),
+			typ:    order.Uint16(tiff[offset+2 : offset+4]),
+			count:  order.Uint32(tiff[offset+4 : offset+8]),
+		})
+	}
+	return entries
+}
+
+// exifValue returns the raw value bytes of an entry, following the value
+// offset when the value does not fit inline. Returns nil when out of range.
+func exifValue(tiff []byte, order binary.ByteOrder, e exifEntry) []byte {
+	size, ok := exifTypeSizes[e.typ]
+	if !ok {
+		return nil
+	}
+
+	length := size * int(e.count)
+	if length <= 4 {
+		return tiff[e.offset+8 : e.offset+8+length]
+	}
+
+	valueOffset := int(order.Uint32(tiff[e.offset+8 : e.offset+12]))
+	if valueOffset < 0 || valueOffset+length > len(tiff) {
+		return nil
+	}
+	return tiff[valueOffset : valueOffset+length]
+}
+
+// exifOrientation reads the orientation tag from IFD0 of a TIFF structure.
+// Returns 1 (no rotation) when the tag is missing or invalid.
+func exifOrientation(tiff []byte) int {
+	order, ok := exifByteOrder(tiff)
+	if !ok {
+		return 1
+	}
+
+	for _, e := range exifIFDEntries(tiff, order, int(order.Uint32(tiff[4:8]))) {
+		if e.tag == exifTagOrientation {
+			// Orientation tag is a SHORT with count 1; value stored in the first 2 value bytes.
+			orientation := order.Uint16(tiff[e.offset+8 : e.offset+10])
+			if orientation >= 1 && orientation <= 8 {
+				return int(orientation)
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// setExifOrientation returns a copy of the TIFF structure with the orientation
+// tag in IFD0 set to the given value. Data without the tag is returned unchanged.
+func setExifOrientation(tiff []byte, orientation uint16) []byte {
+	order, ok := exifByteOrder(tiff)
+	if !ok {
+		return tiff
+	}
+
+	for _, e := range exifIFDEntries(tiff, order, int(order.Uint32(tiff[4:8]))) {
+		if e.tag == exifTagOrientation && e.typ == 3 {
+			out := append([]byte(nil), tiff...)
+			order.PutUint16(out[e.offset+8:e.offset+10], orientation)
+			return out
+		}
+	}
+	return tiff
+}
+
+// stripExifGPS returns a copy of the TIFF structure without GPS data. The GPS
+// IFD and its out-of-line values are zeroed and the pointer to it is removed
+// from IFD0, so no coordinates survive in the output bytes.
+func stripExifGPS(tiff []byte) []byte {
+	order, ok := exifByteOrder(tiff)
+	if !ok {
+		return nil
+	}
+
+	out := append([]byte(nil), tiff...)
+	ifd0 := int(order.Uint32(out[4:8]))
+	entries := exifIFDEntries(out, order, ifd0)
+
+	gpsIndex := -1
+	for i, e := range entries {
+		if e.tag == exifTagGPSInfo {
+			gpsIndex = i
+			break
+		}
+	}
+	if gpsIndex < 0 {
+		return out
+	}
+
+	// Zero the GPS IFD, including any values stored outside of it.
+	gpsOffset := int(order.Uint32(out[entries[gpsIndex].offset+8 : entries[gpsIndex].offset+12]))
+	gpsEntries := exifIFDEntries(out, order, gpsOffset)
+	for _, e := range gpsEntries {
+		clear(exifValue(out, order, e))
+	}
+	if gpsOffset >= 8 && gpsOffset+2 <= len(out) {
+		clear(out[gpsOffset:min(len(out), gpsOffset+2+len(gpsEntries)*12+4)])
+	}
+
+	// Remove the GPSInfo pointer by shifting the following entries and the
+	// next-IFD offset up by one slot.
+	start := entries[gpsIndex].offset
+	end := ifd0 + 2 + len(entries)*12 + 4
+	if end > len(out) {
+		end = ifd0 + 2 + len(entries)*12
+	}
+	copy(out[start:], out[start+12:end])
+	clear(out[end-12 : end])
+	order.PutUint16(out[ifd0:ifd0+2], uint16(len(entries)-1))
+	return out
+}
+
+// copyrightOnlyExif builds a minimal TIFF structure holding only the Artist
+// and Copyright tags of the source. Returns nil when neither tag is present.
+func copyrightOnlyExif(tiff []byte) []byte {
+	order, ok := exifByteOrder(tiff)
+	if !ok {
+		return nil
+	}
+
+	type asciiTag struct {
+		tag   uint16
+		value []byte
+	}
+	var kept []asciiTag
+	for _, e := range exifIFDEntries(tiff, order, int(order.Uint32(tiff[4:8]))) {
+		if (e.tag == exifTagArtist || e.tag == exifTagCopyright) && e.typ == exifTypeASCII {
+			if value := exifValue(tiff, order, e); len(value) > 0 {
+				kept = append(kept, asciiTag{tag: e.tag, value: value})
+			}
+		}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+
+	// Header, then IFD0 with the kept entries, then out-of-line values.
+	ifdSize := 2 + len(kept)*12 + 4
+	out := make([]byte, 8+ifdSize)
+	copy(out, tiff[:4])
+	order.PutUint32(out[4:8], 8)
+	order.PutUint16(out[8:10], uint16(len(kept)))
+
+	for i, t := range kept {
+		entry := out[10+i*12 : 10+(i+1)*12]
+		order.PutUint16(entry[0:2], t.tag)
+		order.PutUint16(entry[2:4], exifTypeASCII)
+		order.PutUint32(entry[4:8], uint32(len(t.value)))
+		if len(t.value) <= 4 {
+			copy(entry[8:12], t.value)
+			continue
+		}
+		order.PutUint32(entry[8:12], uint32(len(out)))
+		out = append(out, t.value...)
+		if len(out)%2 == 1 {
+			out = append(out, 0) // Values must start on a word boundary
+		}
+	}
+	return out
+}
@@ -0,0 +1,243 @@
+package utils
+
+import (
+	"encoding/binary"
+)
+
+// heifBrands are the ftyp brands of HEIF-based formats that may carry an Exif item.
+var heifBrands = map[string]bool{
+	"heic": true, "heix": true, "hevc": true, "hevx": true,
+	"heim": true, "heis": true, "mif1": true, "msf1": true, "avif": true,
+}
+
+// isHEIF reports whether data starts with an ftyp box naming a HEIF brand.
+func isHEIF(data []byte) bool {
+	if len(data) < 16 || string(data[4:8]) != "ftyp" {
+		return false
+	}
+	size := int(binary.BigEndian.Uint32(data[0:4]))
+	if size < 16 || size > len(data) {
+		return false
+	}
+	if heifBrands[string(data[8:12])] {
+		return true
+	}
+	// Compatible brands follow the major brand and minor version
+	for offset := 16; offset+4 <= size; offset += 4 {
+		if heifBrands[string(data[offset:offset+4])] {
+			return true
+		}
+	}
+	return false
+}
+
+// isobmffBoxes calls fn for every box in data until fn returns false.
+// The payload excludes the box header.
+func isobmffBoxes(data []byte, fn func(typ string, payload []byte) bool) {
+	offset := 0
+	for offset+8 <= len(data) {
+		size := int(binary.BigEndian.Uint32(data[offset : offset+4]))
+		typ := string(data[offset+4 : offset+8])
+		header := 8
+		switch size {
+		case 0:
+			size = len(data) - offset // Box extends to the end of the data
+		case 1:
+			if offset+16 > len(data) {
+				return
+			}
+			large := binary.BigEndian.Uint64(data[offset+8 : offset+16])
+			if large > uint64(len(data)-offset) {
+				return
+			}
+			size = int(large)
+			header = 16
+		}
+		if size < header || offset+size > len(data) {
+			return
+		}
+		if !fn(typ, data[offset+header:offset+size]) {
+			return
+		}
+		offset += size
+	}
+}
+
+// extractHEIFExif locates the Exif item of a HEIF file through the meta box's
+// item info (iinf) and item location (iloc) tables, and returns its TIFF data.
+func extractHEIFExif(data []byte) []byte {
+	var meta []byte
+	isobmffBoxes(data, func(typ string, payload []byte) bool {
+		if typ == "meta" && len(payload) >= 4 {
+			meta = payload[4:] // Skip the full box version and flags
+			return false
+		}
+		return true
+	})
+	if meta == nil {
+		return nil
+	}
+
+	var iinf, iloc, idat []byte
+	isobmffBoxes(meta, func(typ string, payload []byte) bool {
+		switch typ {
+		case "iinf":
+			iinf = payload
+		case "iloc":
+			iloc = payload
+		case "idat":
+			idat = payload
+		}
+		return true
+	})
+
+	itemID, ok := heifExifItemID(iinf)
+	if !ok {
+		return nil
+	}
+	item := heifItemData(iloc, itemID, data, idat)
+	if len(item) < 4 {
+		return nil
+	}
+
+	// The item starts with the offset of the TIFF header, counted after this field.
+	tiffOffset := 4 + int(binary.BigEndian.Uint32(item[0:4]))
+	if tiffOffset < 4 || tiffOffset >= len(item) {
+		return nil
+	}
+	return item[tiffOffset:]
+}
+
+// heifExifItemID returns the ID of the item whose type is "Exif".
+func heifExifItemID(iinf []byte) (uint32, bool) {
+	if len(iinf) < 6 {
+		return 0, false
+	}
+	entries := iinf[6:]
+	if iinf[0] != 0 {
+		if len(iinf) < 8 {
+			return 0, false
+		}
+		entries = iinf[8:]
+	}
+
+	var id uint32
+	found := false
+	isobmffBoxes(entries, func(typ string, payload []byte) bool {
+		if typ != "infe" || len(payload) < 4 {
+			return true
+		}
+		version := payload[0]
+		body := payload[4:]
+		switch {
+		case version == 2 && len(body) >= 8:
+			if string(body[4:8]) == "Exif" {
+				id, found = uint32(binary.BigEndian.Uint16(body[0:2])), true
+			}
+		case version == 3 && len(body) >= 10:
+			if string(body[6:10]) == "Exif" {
+				id, found = binary.BigEndian.Uint32(body[0:4]), true
+			}
+		}
+		return !found
+	})
+	return id, found
+}
+
+// heifItemData assembles the extents of an item from the iloc table. Extents
+// are read from the file (construction method 0) or the idat box (method 1).
+func heifItemData(iloc []byte, itemID uint32, file, idat []byte) []byte {
+	if len(iloc) < 8 {
+		return nil
+	}
+	version := iloc[0]
+	offsetSize := int(iloc[4] >> 4)
+	lengthSize := int(iloc[4] & 0x0F)
+	baseOffsetSize := int(iloc[5] >> 4)
+	indexSize := 0
+	if version == 1 || version == 2 {
+		indexSize = int(iloc[5] & 0x0F)
+	}
+
+	pos := 6
+	readUint := func(n int) (uint64, bool) {
+		if pos+n > len(iloc) {
+			return 0, false
+		}
+		var v uint64
+		for i := 0; i < n; i++ {
+			v = v<<8 | uint64(iloc[pos+i])
+		}
+		pos += n
+		return v, true
+	}
+
+	idSize := 2
+	if version == 2 {
+		idSize = 4
+	}
+	itemCount, ok := readUint(idSize)
+	if !ok {
+		return nil
+	}
+
+	for i := uint64(0); i < itemCount; i++ {
+		id, ok := readUint(idSize)
+		if !ok {
+			return nil
+		}
+		method := uint64(0)
+		if version == 1 || version == 2 {
+			if method, ok = readUint(2); !ok {
+				return nil
+			}
+			method &= 0x0F
+		}
+		if _, ok = readUint(2); !ok { // data_reference_index
+			return nil
+		}
+		baseOffset, ok := readUint(baseOffsetSize)
+		if !ok {
+			return nil
+		}
+		extentCount, ok := readUint(2)
+		if !ok {
+			return nil
+		}
+
+		var item []byte
+		for e := uint64(0); e < extentCount; e++ {
+			if _, ok = readUint(indexSize); !ok {
+				return nil
+			}
+			extentOffset, ok1 := readUint(offsetSize)
+			extentLength, ok2 := readUint(lengthSize)
+			if !ok1 || !ok2 {
+				return nil
+			}
+			if uint32(id) != itemID {
+				continue
+			}
+
+			source := file
+			if method == 1 {
+				source = idat
+			} else if method != 0 {
+				return nil
+			}
+			start := baseOffset + extentOffset
+			end := start + extentLength
+			if extentLength == 0 {
+				end = uint64(len(source))
+			}
+			if start > end || end > uint64(len(source)) {
+				return nil
+			}
+			item = append(item, source[start:end]...)
+		}
+		if uint32(id) == itemID {
+			return item
+		}
+	}
+	return nil
+}
@@ -0,0 +1,431 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"math"
+	"sort"
+
+	"github.com/disintegration/imaging"
+)
+
+// Output color profiles accepted on requests.
+const (
+	ProfileSRGB      = "srgb"
+	ProfileDisplayP3 = "display_p3"
+	ProfileAdobeRGB  = "adobe_rgb"
+)
+
+// D50 white point of the ICC profile connection space.
+var iccD50 = [3]float64{0.9642, 1.0, 0.8249}
+
+// ICCProfile is an RGB matrix/TRC color profile: per-channel tone reproduction
+// curves followed by a 3x3 matrix into the D50 XYZ profile connection space.
+type ICCProfile struct {
+	Name   string
+	Matrix [3][3]float64 // Rows X, Y, Z; columns R, G, B
+	TRC    [3]ToneCurve
+}
+
+// ToneCurve maps an encoded channel value in [0,1] to linear light in [0,1].
+// A curve is either a sampled table or an ICC parametric function.
+type ToneCurve struct {
+	Table  []float64 // Sampled curve, evenly spaced over [0,1]
+	Kind   int       // Parametric function type (0-4), used when Table is nil
+	Params []float64 // g, a, b, c, d, e, f as applicable to Kind
+}
+
+// Eval returns the linear value for an encoded value x in [0,1].
+func (c ToneCurve) Eval(x float64) float64 {
+	if c.Table != nil {
+		n := len(c.Table)
+		if n == 1 {
+			return c.Table[0]
+		}
+		pos := x * float64(n-1)
+		i := int(pos)
+		if i >= n-1 {
+			return c.Table[n-1]
+		}
+		if i < 0 {
+			return c.Table[0]
+		}
+		frac := pos - float64(i)
+		return c.Table[i]*(1-frac) + c.Table[i+1]*frac
+	}
+
+	p := func(i int) float64 {
+		if i < len(c.Params) {
+			return c.Params[i]
+		}
+		return 0
+	}
+	g := p(0)
+	switch c.Kind {
+	case 0:
+		return math.Pow(x, g)
+	case 1:
+		if x >= -p(2)/p(1) {
+			return math.Pow(p(1)*x+p(2), g)
+		}
+		return 0
+	case 2:
+		if x >= -p(2)/p(1) {
+			return math.Pow(p(1)*x+p(2), g) + p(3)
+		}
+		return p(3)
+	case 3:
+		if x >= p(4) {
+			return math.Pow(p(1)*x+p(2), g)
+		}
+		return p(3) * x
+	case 4:
+		if x >= p(4) {
+			return math.Pow(p(1)*x+p(2), g) + p(5)
+		}
+		return p(3)*x + p(6)
+	}
+	return x
+}
+
+// srgbCurve is the IEC 61966-2.1 transfer function, shared by sRGB and Display P3.
+var srgbCurve = ToneCurve{Kind: 3, Params: []float64{2.4, 1 / 1.055, 0.055 / 1.055, 1 / 12.92, 0.04045}}
+
+// Built-in profiles with D50-adapted primaries as published in the reference ICC files.
+var builtinProfiles = map[string]*ICCProfile{
+	ProfileSRGB: {
+		Name: "sRGB IEC61966-2.1",
+		Matrix: [3][3]float64{
+			{0.4360747, 0.3850649, 0.1430804},
+			{0.2225045, 0.7168786, 0.0606169},
+			{0.0139322, 0.0971045, 0.7141733},
+		},
+		TRC: [3]ToneCurve{srgbCurve, srgbCurve, srgbCurve},
+	},
+	ProfileDisplayP3: {
+		Name: "Display P3",
+		Matrix: [3][3]float64{
+			{0.5151215, 0.2919769, 0.1570435},
+			{0.2411957, 0.6922455, 0.0665741},
+			{-0.0010529, 0.0418854, 0.7840729},
+		},
+		TRC: [3]ToneCurve{srgbCurve, srgbCurve, srgbCurve},
+	},
+	ProfileAdobeRGB: {
+		Name: "Adobe RGB (1998)",
+		Matrix: [3][3]float64{
+			{0.6097559, 0.2052401, 0.1492240},
+			{0.3111242, 0.6256560, 0.0632197},
+			{0.0194811, 0.0608902, 0.7448387},
+		},
+		TRC: [3]ToneCurve{{Kind: 0, Params: []float64{563.0 / 256}}, {Kind: 0, Params: []float64{563.0 / 256}}, {Kind: 0, Params: []float64{563.0 / 256}}},
+	},
+}
+
+// LookupProfile returns the built-in profile with the given name.
+// An empty name returns sRGB.
+func LookupProfile(name string) (*ICCProfile, error) {
+	if name == "" {
+		name = ProfileSRGB
+	}
+	if p, ok := builtinProfiles[name]; ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("invalid output profile: %s", name)
+}
+
+// ParseICCProfile reads an RGB matrix/TRC profile. LUT-based, CMYK and gray
+// profiles are reported as unsupported.
+func ParseICCProfile(data []byte) (*ICCProfile, error) {
+	if len(data) < 132 || string(data[36:40]) != "acsp" {
+		return nil, fmt.Errorf("not an ICC profile")
+	}
+	if string(data[16:20]) != "RGB " || string(data[20:24]) != "XYZ " {
+		return nil, fmt.Errorf("unsupported ICC profile: color space %q, PCS %q", data[16:20], data[20:24])
+	}
+
+	tags := make(map[string][]byte)
+	count := int(binary.BigEndian.Uint32(data[128:132]))
+	for i := 0; i < count; i++ {
+		entry := 132 + i*12
+		if entry+12 > len(data) {
+			break
+		}
+		offset := int(binary.BigEndian.Uint32(data[entry+4 : entry+8]))
+		size := int(binary.BigEndian.Uint32(data[entry+8 : entry+12]))
+		if offset < 0 || size < 0 || offset+size > len(data) {
+			continue
+		}
+		tags[string(data[entry:entry+4])] = data[offset : offset+size]
+	}
+
+	p := &ICCProfile{Name: parseICCDescription(tags["desc"])}
+	for col, sig := range []string{"rXYZ", "gXYZ", "bXYZ"} {
+		xyz, err := parseICCXYZ(tags[sig])
+		if err != nil {
+			return nil, fmt.Errorf("unsupported ICC profile: %s: %w", sig, err)
+		}
+		for row := 0; row < 3; row++ {
+			p.Matrix[row][col] = xyz[row]
+		}
+	}
+	for i, sig := range []string{"rTRC", "gTRC", "bTRC"} {
+		curve, err := parseICCCurve(tags[sig])
+		if err != nil {
+			return nil, fmt.Errorf("unsupported ICC profile: %s: %w", sig, err)
+		}
+		p.TRC[i] = curve
+	}
+	return p, nil
+}
+
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+}
+
+func parseICCXYZ(tag []byte) ([3]float64, error) {
+	if len(tag) < 20 || string(tag[:4]) != "XYZ " {
+		return [3]float64{}, fmt.Errorf("missing XYZ tag")
+	}
+	return [3]float64{s15Fixed16(tag[8:12]), s15Fixed16(tag[12:16]), s15Fixed16(tag[16:20])}, nil
+}
+
+func parseICCCurve(tag []byte) (ToneCurve, error) {
+	if len(tag) < 12 {
+		return ToneCurve{}, fmt.Errorf("missing curve tag")
+	}
+
+	switch string(tag[:4]) {
+	case "curv":
+		n := int(binary.BigEndian.Uint32(tag[8:12]))
+		if n == 0 {
+			return ToneCurve{Kind: 0, Params: []float64{1}}, nil
+		}
+		if len(tag) < 12+2*n {
+			return ToneCurve{}, fmt.Errorf("truncated curve")
+		}
+		if n == 1 {
+			// u8Fixed8Number gamma
+			return ToneCurve{Kind: 0, Params: []float64{float64(binary.BigEndian.Uint16(tag[12:14])) / 256}}, nil
+		}
+		table := make([]float64, n)
+		for i := range table {
+			table[i] = float64(binary.BigEndian.Uint16(tag[12+2*i:])) / 65535
+		}
+		return ToneCurve{Table: table}, nil
+	case "para":
+		kind := int(binary.BigEndian.Uint16(tag[8:10]))
+		paramCounts := []int{1, 3, 4, 5, 7}
+		if kind < 0 || kind >= len(paramCounts) || len(tag) < 12+4*paramCounts[kind] {
+			return ToneCurve{}, fmt.Errorf("unsupported parametric curve type %d", kind)
+		}
+		params := make([]float64, paramCounts[kind])
+		for i := range params {
+			params[i] = s15Fixed16(tag[12+4*i:])
+		}
+		return ToneCurve{Kind: kind, Params: params}, nil
+	}
+	return ToneCurve{}, fmt.Errorf("unsupported curve type %q", tag[:4])
+}
+
+// parseICCDescription reads a v2 textDescriptionType or the first record of a v4 multiLocalizedUnicodeType.
+func parseICCDescription(tag []byte) string {
+	if len(tag) < 12 {
+		return ""
+	}
+	switch string(tag[:4]) {
+	case "desc":
+		n := int(binary.BigEndian.Uint32(tag[8:12]))
+		if n > 0 && 12+n <= len(tag) {
+			return string(bytes.TrimRight(tag[12:12+n], "\x00"))
+		}
+	case "mluc":
+		if len(tag) < 28 || binary.BigEndian.Uint32(tag[8:12]) == 0 {
+			return ""
+		}
+		length := int(binary.BigEndian.Uint32(tag[20:24]))
+		offset := int(binary.BigEndian.Uint32(tag[24:28]))
+		if offset+length > len(tag) {
+			return ""
+		}
+		runes := make([]rune, 0, length/2)
+		for i := offset; i+1 < offset+length; i += 2 {
+			runes = append(runes, rune(binary.BigEndian.Uint16(tag[i:])))
+		}
+		return string(runes)
+	}
+	return ""
+}
+
+// Equal reports whether two profiles describe the same color space, within
+// the precision of the fixed-point values stored in ICC files.
+func (p *ICCProfile) Equal(q *ICCProfile) bool {
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			if math.Abs(p.Matrix[row][col]-q.Matrix[row][col]) > 0.002 {
+				return false
+			}
+		}
+	}
+	for ch := 0; ch < 3; ch++ {
+		for i := 0; i <= 16; i++ {
+			x := float64(i) / 16
+			if math.Abs(p.TRC[ch].Eval(x)-q.TRC[ch].Eval(x)) > 0.005 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ConvertColorProfile converts the pixels of img from the src color space to dst.
+// Alpha is preserved; colors outside the destination gamut are clipped.
+func ConvertColorProfile(img image.Image, src, dst *ICCProfile) *image.NRGBA {
+	out := imaging.Clone(img)
+
+	// Combined matrix: source linear RGB -> XYZ -> destination linear RGB
+	dstInverse := invert3x3(dst.Matrix)
+	var m [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				m[i][j] += dstInverse[i][k] * src.Matrix[k][j]
+			}
+		}
+	}
+
+	var decode [3][256]float64
+	var encode [3][]uint8
+	for ch := 0; ch < 3; ch++ {
+		for v := 0; v < 256; v++ {
+			decode[ch][v] = src.TRC[ch].Eval(float64(v) / 255)
+		}
+		encode[ch] = inverseCurveTable(dst.TRC[ch], 4096)
+	}
+
+	for i := 0; i+3 < len(out.Pix); i += 4 {
+		r := decode[0][out.Pix[i]]
+		g := decode[1][out.Pix[i+1]]
+		b := decode[2][out.Pix[i+2]]
+		for ch := 0; ch < 3; ch++ {
+			v := m[ch][0]*r + m[ch][1]*g + m[ch][2]*b
+			// Curves of malformed profiles can yield NaN, e.g., a negative
+			// base raised to a fractional gamma
+			if math.IsNaN(v) {
+				v = 0
+			}
+			idx := int(math.Round(math.Min(1, math.Max(0, v)) * 4095))
+			out.Pix[i+ch] = encode[ch][idx]
+		}
+	}
+	return out
+}
+
+// inverseCurveTable samples the inverse of a monotonic tone curve: entry i is
+// the 8-bit encoded value whose linear value is i/(n-1).
+func inverseCurveTable(c ToneCurve, n int) []uint8 {
+	table := make([]uint8, n)
+	for i := range table {
+		target := float64(i) / float64(n-1)
+		x := sort.Search(1<<16, func(j int) bool {
+			return c.Eval(float64(j)/65535) >= target
+		})
+		table[i] = uint8(math.Round(math.Min(1, float64(x)/65535) * 255))
+	}
+	return table
+}
+
+func invert3x3(m [3][3]float64) [3][3]float64 {
+	det := m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+	return [3][3]float64{
+		{(m[1][1]*m[2][2] - m[1][2]*m[2][1]) / det, (m[0][2]*m[2][1] - m[0][1]*m[2][2]) / det, (m[0][1]*m[1][2] - m[0][2]*m[1][1]) / det},
+		{(m[1][2]*m[2][0] - m[1][0]*m[2][2]) / det, (m[0][0]*m[2][2] - m[0][2]*m[2][0]) / det, (m[0][2]*m[1][0] - m[0][0]*m[1][2]) / det},
+		{(m[1][0]*m[2][1] - m[1][1]*m[2][0]) / det, (m[0][1]*m[2][0] - m[0][0]*m[2][1]) / det, (m[0][0]*m[1][1] - m[0][1]*m[1][0]) / det},
+	}
+}
+
+// Bytes serializes the profile as an ICC v2.1 display profile suitable for
+// embedding in JPEG, PNG and WebP output.
+func (p *ICCProfile) Bytes() []byte {
+	be := binary.BigEndian
+	xyzTag := func(x, y, z float64) []byte {
+		b := make([]byte, 20)
+		copy(b, "XYZ ")
+		for i, v := range []float64{x, y, z} {
+			be.PutUint32(b[8+4*i:], uint32(int32(math.Round(v*65536))))
+		}
+		return b
+	}
+	curveTag := func(c ToneCurve) []byte {
+		if c.Table == nil && c.Kind == 0 && len(c.Params) == 1 {
+			b := make([]byte, 14)
+			copy(b, "curv")
+			be.PutUint32(b[8:], 1)
+			be.PutUint16(b[12:], uint16(math.Round(c.Params[0]*256)))
+			return b
+		}
+		// Sample the curve; parametric curves are not part of ICC v2
+		const n = 1024
+		b := make([]byte, 12+2*n)
+		copy(b, "curv")
+		be.PutUint32(b[8:], n)
+		for i := 0; i < n; i++ {
+			v := c.Eval(float64(i) / (n - 1))
+			be.PutUint16(b[12+2*i:], uint16(math.Round(math.Min(1, math.Max(0, v))*65535)))
+		}
+		return b
+	}
+
+	desc := make([]byte, 12+len(p.Name)+1+12+67)
+	copy(desc, "desc")
+	be.PutUint32(desc[8:], uint32(len(p.Name)+1))
+	copy(desc[12:], p.Name)
+
+	cprt := append([]byte("text\x00\x00\x00\x00"), "No copyright, use freely\x00"...)
+
+	tags := []struct {
+		sig  string
+		data []byte
+	}{
+		{"desc", desc},
+		{"cprt", cprt},
+		{"wtpt", xyzTag(iccD50[0], iccD50[1], iccD50[2])},
+		{"rXYZ", xyzTag(p.Matrix[0][0], p.Matrix[1][0], p.Matrix[2][0])},
+		{"gXYZ", xyzTag(p.Matrix[0][1], p.Matrix[1][1], p.Matrix[2][1])},
+		{"bXYZ", xyzTag(p.Matrix[0][2], p.Matrix[1][2], p.Matrix[2][2])},
+		{"rTRC", curveTag(p.TRC[0])},
+		{"gTRC", curveTag(p.TRC[1])},
+		{"bTRC", curveTag(p.TRC[2])},
+	}
+
+	header := make([]byte, 128+4+12*len(tags))
+	be.PutUint32(header[128:], uint32(len(tags)))
+	var body []byte
+	for i, tag := range tags {
+		offset := len(header) + len(body)
+		entry := header[132+12*i:]
+		copy(entry, tag.sig)
+		be.PutUint32(entry[4:], uint32(offset))
+		be.PutUint32(entry[8:], uint32(len(tag.data)))
+		body = append(body, tag.data...)
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+	}
+
+	out := append(header, body...)
+	be.PutUint32(out[0:], uint32(len(out)))
+	be.PutUint32(out[8:], 0x02100000) // Version 2.1
+	copy(out[12:], "mntr")
+	copy(out[16:], "RGB ")
+	copy(out[20:], "XYZ ")
+	copy(out[36:], "acsp")
+	for i, v := range iccD50 {
+		be.PutUint32(out[68+4*i:], uint32(int32(math.Round(v*65536))))
+	}
+	return out
+}
@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// withCurve returns a copy of an ICC profile whose red tone curve is
+// replaced by curve, appended to the end of the profile.
+func withCurve(t *testing.T, profile []byte, curve []byte) []byte {
+	t.Helper()
+	out := append([]byte(nil), profile...)
+	count := int(binary.BigEndian.Uint32(out[128:]))
+	for i := 0; i < count; i++ {
+		entry := out[132+12*i:]
+		if string(entry[:4]) == "rTRC" {
+			binary.BigEndian.PutUint32(entry[4:], uint32(len(out)))
+			binary.BigEndian.PutUint32(entry[8:], uint32(len(curve)))
+			out = append(out, curve...)
+			binary.BigEndian.PutUint32(out[0:], uint32(len(out)))
+			return out
+		}
+	}
+	t.Fatal("profile has no rTRC tag")
+	return nil
+}
+
+// paraCurve encodes an ICC parametric curve of the given type.
+func paraCurve(kind uint16, params ...float64) []byte {
+	b := make([]byte, 12+4*len(params))
+	copy(b, "para")
+	binary.BigEndian.PutUint16(b[8:], kind)
+	for i, p := range params {
+		binary.BigEndian.PutUint32(b[12+4*i:], uint32(int32(p*65536)))
+	}
+	return b
+}
+
+func TestConvertColorProfileToSRGB(t *testing.T) {
+	srgb, _ := LookupProfile(ProfileSRGB)
+	// The sRGB primaries and white, and a mid grey, as written in the wider
+	// color spaces, from the conversions of CSS Color 4
+	cases := []struct {
+		profile string
+		in      color.NRGBA
+		want    color.NRGBA
+	}{
+		{ProfileDisplayP3, color.NRGBA{234, 51, 35, 255}, color.NRGBA{255, 0, 0, 255}},
+		{ProfileDisplayP3, color.NRGBA{117, 251, 76, 255}, color.NRGBA{0, 255, 0, 255}},
+		{ProfileDisplayP3, color.NRGBA{0, 0, 245, 255}, color.NRGBA{0, 0, 255, 255}},
+		{ProfileDisplayP3, color.NRGBA{255, 255, 255, 255}, color.NRGBA{255, 255, 255, 255}},
+		{ProfileDisplayP3, color.NRGBA{128, 128, 128, 128}, color.NRGBA{128, 128, 128, 128}},
+		{ProfileAdobeRGB, color.NRGBA{219, 0, 0, 255}, color.NRGBA{255, 0, 0, 255}},
+		{ProfileAdobeRGB, color.NRGBA{144, 255, 60, 255}, color.NRGBA{0, 255, 0, 255}},
+		{ProfileAdobeRGB, color.NRGBA{0, 0, 250, 255}, color.NRGBA{0, 0, 255, 255}},
+		{ProfileAdobeRGB, color.NRGBA{255, 255, 255, 255}, color.NRGBA{255, 255, 255, 255}},
+		{ProfileAdobeRGB, color.NRGBA{128, 128, 128, 255}, color.NRGBA{129, 129, 129, 255}},
+	}
+
+	for _, tc := range cases {
+		src, _ := LookupProfile(tc.profile)
+		img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+		img.SetNRGBA(0, 0, tc.in)
+		got := ConvertColorProfile(img, src, srgb).NRGBAAt(0, 0)
+		// Rounding the inputs to 8 bits shifts channels near 0 by up to 3,
+		// where the sRGB curve is steepest
+		if !near(got, tc.want, 3) {
+			t.Errorf("%s %v: got sRGB %v, want %v", tc.profile, tc.in, got, tc.want)
+		}
+	}
+}
+
+// near reports whether the channels of two colors differ by at most tolerance.
+func near(a, b color.NRGBA, tolerance int) bool {
+	for _, d := range []int{int(a.R) - int(b.R), int(a.G) - int(b.G), int(a.B) - int(b.B), int(a.A) - int(b.A)} {
+		if d < -tolerance || d > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+func TestProfileBytesRoundTrip(t *testing.T) {
+	for _, name := range []string{ProfileSRGB, ProfileDisplayP3, ProfileAdobeRGB} {
+		p, _ := LookupProfile(name)
+		parsed, err := ParseICCProfile(p.Bytes())
+		if err != nil {
+			t.Errorf("%s: ParseICCProfile: %v", name, err)
+			continue
+		}
+		if !parsed.Equal(p) || parsed.Name != p.Name {
+			t.Errorf("%s: parsed profile %q differs from the profile written", name, parsed.Name)
+		}
+	}
+	// Equal tells the profiles apart
+	p3, _ := LookupProfile(ProfileDisplayP3)
+	adobe, _ := LookupProfile(ProfileAdobeRGB)
+	if p3.Equal(adobe) {
+		t.Error("Display P3 equals Adobe RGB")
+	}
+}
+
+func TestConvertColorProfileMalformedCurves(t *testing.T) {
+	srgb, _ := LookupProfile(ProfileSRGB)
+	cases := map[string][]byte{
+		// a*x+b is negative above x=0.5, and its fractional power NaN
+		"negative base": paraCurve(1, 2.2, -1, 0.5),
+		// 0 raised to a negative gamma is infinite
+		"negative gamma": paraCurve(0, -2),
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for x := 0; x < 16; x++ {
+		for y := 0; y < 16; y++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x * 17), uint8(y * 17), 128, 255})
+		}
+	}
+
+	for name, curve := range cases {
+		t.Run(name, func(t *testing.T) {
+			profile, err := ParseICCProfile(withCurve(t, srgb.Bytes(), curve))
+			if err != nil {
+				t.Fatalf("ParseICCProfile: %v", err)
+			}
+			out := ConvertColorProfile(img, profile, srgb)
+			if out.Bounds() != img.Bounds() {
+				t.Errorf("bounds %v, want %v", out.Bounds(), img.Bounds())
+			}
+		})
+	}
+}
+
+func TestExtractPNGMetadataCapsInflatedProfile(t *testing.T) {
+	var compressed bytes.Buffer
+	w := zlib.NewWriter(&compressed)
+	w.Write(make([]byte, maxInflatedSize+1))
+	w.Close()
+
+	var png bytes.Buffer
+	png.Write(pngSignature)
+	writeTestChunk(&png, "IHDR", make([]byte, 13))
+	writeTestChunk(&png, "iCCP", append([]byte("bomb\x00\x00"), compressed.Bytes()...))
+	writeTestChunk(&png, "IEND", nil)
+
+	if m := extractPNGMetadata(png.Bytes()); m.ICC != nil {
+		t.Errorf("got a %d byte profile, want the oversized one dropped", len(m.ICC))
+	}
+}
+
+// writeTestChunk writes a PNG chunk with its length and type; the CRC is
+// not checked when reading metadata, so it is left zero.
+func writeTestChunk(buf *bytes.Buffer, typ string, payload []byte) {
+	binary.Write(buf, binary.BigEndian, uint32(len(payload)))
+	buf.WriteString(typ)
+	buf.Write(payload)
+	buf.Write(make([]byte, 4))
+}
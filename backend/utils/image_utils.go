@@ -2,14 +2,14 @@ package utils
 
 import (
 	"bytes"
-	"encoding/binary"
 	"fmt"
 	"image"
 	"image/gif"
 	"image/jpeg"
 	"image/png"
-	"log"
+	"log/slog"
 
+	"github.com/HugoSmits86/nativewebp"
 	"github.com/disintegration/imaging"
 	"golang.org/x/image/bmp"  // Import for BMP support
 	"golang.org/x/image/tiff" // Import for TIFF support
@@ -24,7 +24,8 @@ func EncodeImage(img image.Image, format string, buf *bytes.Buffer) error {
 }
 
 // EncodeImageWithQuality encodes an image into the specified format with quality settings.
-// quality parameter is used for JPEG (1-100, higher is better quality).
+// quality parameter is used for JPEG (1-100, higher is better quality). WebP is
+// encoded losslessly, so quality does not apply to it.
 func EncodeImageWithQuality(img image.Image, format string, buf *bytes.Buffer, quality *int) error {
 	var err error
 
@@ -35,7 +36,7 @@ func EncodeImageWithQuality(img image.Image, format string, buf *bytes.Buffer, q
 	}
 
 	switch format {
-	case "jpeg":
+	case "jpeg", "jpg":
 		options := &jpeg.Options{Quality: jpegQuality}
 		err = jpeg.Encode(buf, img, options)
 	case "png":
@@ -47,20 +48,16 @@ func EncodeImageWithQuality(img image.Image, format string, buf *bytes.Buffer, q
 	case "tiff":
 		err = tiff.Encode(buf, img, nil)
 	case "webp":
-		// WebP encoding is not directly supported by the standard library.
-		// For simplicity, we'll default to JPEG for now if WebP is the input format
-		// and no specific WebP encoder is integrated.
-		log.Printf("Warning: WebP format detected, encoding as JPEG. Consider adding a WebP encoder for full support.")
-		options := &jpeg.Options{Quality: jpegQuality}
-		err = jpeg.Encode(buf, img, options)
+		// The pure Go encoder only writes lossless (VP8L) WebP
+		err = nativewebp.Encode(buf, img, nil)
 	case "heic", "heif":
 		// HEIC/HEIF encoding is not commonly supported in Go libraries for writing.
 		// Convert to JPEG as a high-quality alternative
-		log.Printf("Info: Converting HEIC to JPEG format for output (HEIC encoding not supported)")
+		slog.Info("HEIC encoding is not supported, encoding as JPEG")
 		options := &jpeg.Options{Quality: jpegQuality}
 		err = jpeg.Encode(buf, img, options)
 	default:
-		log.Printf("Warning: Unknown image format '%s', encoding as JPEG.", format)
+		slog.Warn("unknown image format, encoding as JPEG", "format", format)
 		options := &jpeg.Options{Quality: jpegQuality}
 		err = jpeg.Encode(buf, img, options)
 	}
@@ -70,101 +67,14 @@ func EncodeImageWithQuality(img image.Image, format string, buf *bytes.Buffer, q
 	return nil
 }
 
-// GetExifOrientation extracts the EXIF orientation flag from a JPEG/HEIC image.
+// GetExifOrientation extracts the EXIF orientation flag from a JPEG, PNG, WebP, TIFF or HEIC image.
 // Returns 1 (no rotation) when the orientation tag is missing or cannot be read.
 func GetExifOrientation(data []byte) int {
-	// Only JPEG (and some TIFF-derived formats) contain EXIF orientation metadata.
-	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+	exif := ExtractExif(data)
+	if exif == nil {
 		return 1
 	}
-
-	offset := 2
-	for offset+4 <= len(data) {
-		if data[offset] != 0xFF {
-			offset++
-			continue
-		}
-
-		marker := data[offset+1]
-		offset += 2
-
-		// APP1 contains EXIF
-		if marker == 0xE1 && offset+2 <= len(data) {
-			length := int(data[offset])<<8 | int(data[offset+1])
-			if length < 8 || offset+length > len(data) {
-				break
-			}
-
-			exifData := data[offset+2 : offset+length]
-			if len(exifData) < 8 || string(exifData[:6]) != "Exif\x00\x00" {
-				offset += length
-				continue
-			}
-
-			tiff := exifData[6:]
-			if len(tiff) < 8 {
-				return 1
-			}
-
-			var order binary.ByteOrder
-			switch string(tiff[:2]) {
-			case "II":
-				order = binary.LittleEndian
-			case "MM":
-				order = binary.BigEndian
-			default:
-				return 1
-			}
-
-			if order.Uint16(tiff[2:4]) != 0x002A {
-				return 1
-			}
-
-			ifdOffset := int(order.Uint32(tiff[4:8]))
-			if ifdOffset < 0 || ifdOffset+2 > len(tiff) {
-				return 1
-			}
-
-			ifd := tiff[ifdOffset:]
-			numEntries := int(order.Uint16(ifd[:2]))
-			entryOffset := 2
-
-			for i := 0; i < numEntries; i++ {
-				if entryOffset+12 > len(ifd) {
-					break
-				}
-				entry := ifd[entryOffset : entryOffset+12]
-				tag := order.Uint16(entry[0:2])
-				if tag == 0x0112 { // Orientation
-					// Orientation tag is a SHORT with count 1; value stored in last 2 bytes.
-					orientation := order.Uint16(entry[8:10])
-					if orientation >= 1 && orientation <= 8 {
-						return int(orientation)
-					}
-					return 1
-				}
-				entryOffset += 12
-			}
-			// Orientation tag not found
-			return 1
-		}
-
-		if marker == 0xDA || marker == 0xD9 { // SOS or EOI
-			break
-		}
-
-		if offset+2 > len(data) {
-			break
-		}
-
-		length := int(data[offset])<<8 | int(data[offset+1])
-		if length < 2 || offset+length > len(data) {
-			break
-		}
-		offset += length
-	}
-
-	return 1
+	return exifOrientation(exif)
 }
 
 // FixOrientation rotates and/or flips an image according to the EXIF orientation flag.
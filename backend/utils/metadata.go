@@ -0,0 +1,515 @@
+package utils
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+)
+
+// Metadata policies accepted on requests.
+const (
+	MetadataKeep              = "keep"                // Keep EXIF, XMP and ICC data
+	MetadataStrip             = "strip"               // Drop all metadata (default)
+	MetadataStripGPS          = "strip_gps"           // Keep everything except location data
+	MetadataKeepCopyrightOnly = "keep_copyright_only" // Keep Artist/Copyright EXIF and the ICC profile
+)
+
+const (
+	jpegExifHeader = "Exif\x00\x00"
+	jpegXMPHeader  = "http://ns.adobe.com/xap/1.0/\x00"
+	jpegICCHeader  = "ICC_PROFILE\x00"
+	pngXMPKeyword  = "XML:com.adobe.xmp"
+
+	// Largest payload that fits in a single JPEG APPn segment.
+	jpegMaxSegment = 0xFFFF - 2
+
+	// Largest metadata payload inflated from a compressed PNG chunk.
+	maxInflatedSize = 16 << 20
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// Metadata holds the raw metadata segments carried by an encoded image.
+type Metadata struct {
+	Exif []byte // TIFF-structured EXIF data, without the "Exif\0\0" prefix
+	XMP  []byte // XMP packet
+	ICC  []byte // ICC color profile
+}
+
+// ValidateMetadataPolicy returns an error if policy is not a known metadata policy.
+// An empty policy is valid and means MetadataStrip.
+func ValidateMetadataPolicy(policy string) error {
+	switch policy {
+	case "", MetadataKeep, MetadataStrip, MetadataStripGPS, MetadataKeepCopyrightOnly:
+		return nil
+	}
+	return fmt.Errorf("invalid metadata policy: %s", policy)
+}
+
+// ExtractMetadata reads the EXIF, XMP and ICC segments from a JPEG, PNG or WebP
+// image, and the EXIF item from a HEIC image. Segments that are missing or
+// unreadable are left empty.
+func ExtractMetadata(data []byte) *Metadata {
+	switch {
+	case len(data) >= 2 && data[0] == 0xFF && data[1] == 0xD8:
+		return extractJPEGMetadata(data)
+	case bytes.HasPrefix(data, pngSignature):
+		return extractPNGMetadata(data)
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return extractWebPMetadata(data)
+	case isHEIF(data):
+		return &Metadata{Exif: extractHEIFExif(data)}
+	}
+	return &Metadata{}
+}
+
+// ExtractExif returns the TIFF-structured EXIF data of a JPEG, PNG, WebP,
+// TIFF or HEIC image, or nil if there is none. A TIFF file is its own EXIF
+// structure, so it is returned as is.
+func ExtractExif(data []byte) []byte {
+	if _, ok := exifByteOrder(data); ok {
+		return data
+	}
+	return ExtractMetadata(data).Exif
+}
+
+// Filter returns the subset of the metadata allowed by the given policy.
+func (m *Metadata) Filter(policy string) *Metadata {
+	if m == nil {
+		return &Metadata{}
+	}
+
+	switch policy {
+	case MetadataKeep:
+		return &Metadata{Exif: m.Exif, XMP: m.XMP, ICC: m.ICC}
+	case MetadataStripGPS:
+		filtered := &Metadata{ICC: m.ICC}
+		if len(m.Exif) > 0 {
+			filtered.Exif = stripExifGPS(m.Exif)
+		}
+		// XMP can mirror the EXIF GPS tags; rather than edit the packet, drop it.
+		if !bytes.Contains(m.XMP, []byte("GPS")) {
+			filtered.XMP = m.XMP
+		}
+		return filtered
+	case MetadataKeepCopyrightOnly:
+		return &Metadata{Exif: copyrightOnlyExif(m.Exif), ICC: m.ICC}
+	default:
+		return &Metadata{}
+	}
+}
+
+// ResetOrientation sets the EXIF orientation tag to 1. Call this after the
+// pixels have been rotated so viewers don't apply the rotation a second time.
+func (m *Metadata) ResetOrientation() {
+	if m != nil && len(m.Exif) > 0 {
+		m.Exif = setExifOrientation(m.Exif, 1)
+	}
+}
+
+// IsEmpty reports whether there is no metadata to embed.
+func (m *Metadata) IsEmpty() bool {
+	return m == nil || (len(m.Exif) == 0 && len(m.XMP) == 0 && len(m.ICC) == 0)
+}
+
+// EmbedMetadata inserts the metadata into an encoded JPEG, PNG or WebP image.
+// The container is detected from the encoded bytes; other formats are returned unchanged.
+func EmbedMetadata(encoded []byte, m *Metadata) ([]byte, error) {
+	if m.IsEmpty() {
+		return encoded, nil
+	}
+
+	switch {
+	case len(encoded) >= 2 && encoded[0] == 0xFF && encoded[1] == 0xD8:
+		return embedJPEGMetadata(encoded, m), nil
+	case bytes.HasPrefix(encoded, pngSignature):
+		return embedPNGMetadata(encoded, m)
+	case len(encoded) >= 12 && string(encoded[:4]) == "RIFF" && string(encoded[8:12]) == "WEBP":
+		return embedWebPMetadata(encoded, m)
+	}
+	return encoded, nil
+}
+
+// extractJPEGMetadata walks the JPEG marker segments up to the start of scan.
+func extractJPEGMetadata(data []byte) *Metadata {
+	m := &Metadata{}
+	var iccChunks [][]byte
+
+	offset := 2
+	for offset+4 <= len(data) {
+		if data[offset] != 0xFF {
+			offset++
+			continue
+		}
+
+		marker := data[offset+1]
+		if marker == 0xDA || marker == 0xD9 { // SOS or EOI
+			break
+		}
+		if marker == 0xFF || marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			// Fill byte or standalone marker without a length
+			offset += 2
+			continue
+		}
+
+		length := int(data[offset+2])<<8 | int(data[offset+3])
+		if length < 2 || offset+2+length > len(data) {
+			break
+		}
+		payload := data[offset+4 : offset+2+length]
+
+		switch {
+		case marker == 0xE1 && bytes.HasPrefix(payload, []byte(jpegExifHeader)):
+			m.Exif = payload[len(jpegExifHeader):]
+		case marker == 0xE1 && bytes.HasPrefix(payload, []byte(jpegXMPHeader)):
+			m.XMP = payload[len(jpegXMPHeader):]
+		case marker == 0xE2 && bytes.HasPrefix(payload, []byte(jpegICCHeader)) && len(payload) > len(jpegICCHeader)+2:
+			// Chunks carry a 1-based sequence number and the total count.
+			iccChunks = append(iccChunks, payload[len(jpegICCHeader):])
+		}
+
+		offset += 2 + length
+	}
+
+	if len(iccChunks) > 0 {
+		ordered := make([][]byte, len(iccChunks))
+		for _, chunk := range iccChunks {
+			seq := int(chunk[0])
+			if seq < 1 || seq > len(ordered) || ordered[seq-1] != nil {
+				ordered = nil
+				break
+			}
+			ordered[seq-1] = chunk[2:]
+		}
+		if ordered != nil {
+			m.ICC = bytes.Join(ordered, nil)
+		}
+	}
+	return m
+}
+
+// embedJPEGMetadata inserts APP1 (EXIF, XMP) and APP2 (ICC) segments right after SOI.
+func embedJPEGMetadata(encoded []byte, m *Metadata) []byte {
+	var segments bytes.Buffer
+	writeSegment := func(marker byte, parts ...[]byte) {
+		length := 2
+		for _, p := range parts {
+			length += len(p)
+		}
+		segments.Write([]byte{0xFF, marker, byte(length >> 8), byte(length)})
+		for _, p := range parts {
+			segments.Write(p)
+		}
+	}
+
+	if len(m.Exif) > 0 {
+		if len(jpegExifHeader)+len(m.Exif) <= jpegMaxSegment {
+			writeSegment(0xE1, []byte(jpegExifHeader), m.Exif)
+		} else {
+			slog.Warn("EXIF data does not fit in a JPEG segment, dropping it", "bytes", len(m.Exif))
+		}
+	}
+
+	if len(m.XMP) > 0 {
+		if len(jpegXMPHeader)+len(m.XMP) <= jpegMaxSegment {
+			writeSegment(0xE1, []byte(jpegXMPHeader), m.XMP)
+		} else {
+			slog.Warn("XMP packet does not fit in a JPEG segment, dropping it", "bytes", len(m.XMP))
+		}
+	}
+
+	if len(m.ICC) > 0 {
+		chunkSize := jpegMaxSegment - len(jpegICCHeader) - 2
+		count := (len(m.ICC) + chunkSize - 1) / chunkSize
+		if count <= 255 {
+			for i := 0; i < count; i++ {
+				chunk := m.ICC[i*chunkSize : min(len(m.ICC), (i+1)*chunkSize)]
+				writeSegment(0xE2, []byte(jpegICCHeader), []byte{byte(i + 1), byte(count)}, chunk)
+			}
+		}
+	}
+
+	out := make([]byte, 0, len(encoded)+segments.Len())
+	out = append(out, encoded[:2]...)
+	out = append(out, segments.Bytes()...)
+	return append(out, encoded[2:]...)
+}
+
+// pngChunks calls fn for every chunk in a PNG stream until fn returns false.
+func pngChunks(data []byte, fn func(offset int, typ string, payload []byte) bool) {
+	offset := len(pngSignature)
+	for offset+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[offset : offset+4]))
+		if length < 0 || offset+12+length > len(data) {
+			return
+		}
+		typ := string(data[offset+4 : offset+8])
+		if !fn(offset, typ, data[offset+8:offset+8+length]) || typ == "IEND" {
+			return
+		}
+		offset += 12 + length
+	}
+}
+
+// extractPNGMetadata reads the eXIf, iCCP and XMP iTXt chunks. Some writers
+// place eXIf after the image data, so the whole stream is scanned.
+func extractPNGMetadata(data []byte) *Metadata {
+	m := &Metadata{}
+	pngChunks(data, func(_ int, typ string, payload []byte) bool {
+		switch typ {
+		case "eXIf":
+			m.Exif = payload
+		case "iCCP":
+			// Profile name, null separator, compression method, zlib stream
+			if i := bytes.IndexByte(payload, 0); i >= 0 && i+2 <= len(payload) {
+				if profile, err := zlibDecompress(payload[i+2:]); err == nil {
+					m.ICC = profile
+				}
+			}
+		case "iTXt":
+			if xmp, ok := parsePNGXMP(payload); ok {
+				m.XMP = xmp
+			}
+		}
+		return true
+	})
+	return m
+}
+
+// parsePNGXMP returns the text of an iTXt chunk carrying an XMP packet.
+func parsePNGXMP(payload []byte) ([]byte, bool) {
+	keyword, rest, ok := bytes.Cut(payload, []byte{0})
+	if !ok || string(keyword) != pngXMPKeyword || len(rest) < 2 {
+		return nil, false
+	}
+
+	compressed := rest[0] == 1
+	rest = rest[2:]
+	// Skip the language tag and translated keyword
+	for i := 0; i < 2; i++ {
+		_, after, ok := bytes.Cut(rest, []byte{0})
+		if !ok {
+			return nil, false
+		}
+		rest = after
+	}
+
+	if compressed {
+		text, err := zlibDecompress(rest)
+		return text, err == nil
+	}
+	return rest, true
+}
+
+// embedPNGMetadata inserts iCCP, eXIf and iTXt chunks directly after IHDR.
+func embedPNGMetadata(encoded []byte, m *Metadata) ([]byte, error) {
+	var chunks bytes.Buffer
+
+	if len(m.ICC) > 0 {
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		if _, err := zw.Write(m.ICC); err != nil {
+			return nil, fmt.Errorf("failed to compress ICC profile: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress ICC profile: %w", err)
+		}
+		payload := append([]byte("ICC Profile\x00\x00"), compressed.Bytes()...)
+		writePNGChunk(&chunks, "iCCP", payload)
+	}
+
+	if len(m.Exif) > 0 {
+		writePNGChunk(&chunks, "eXIf", m.Exif)
+	}
+
+	if len(m.XMP) > 0 {
+		// Keyword, null, uncompressed flag and method, empty language tag and translated keyword
+		payload := append([]byte(pngXMPKeyword+"\x00\x00\x00\x00\x00"), m.XMP...)
+		writePNGChunk(&chunks, "iTXt", payload)
+	}
+
+	insertAt := -1
+	pngChunks(encoded, func(offset int, typ string, payload []byte) bool {
+		if typ == "IHDR" {
+			insertAt = offset + 12 + len(payload)
+		}
+		return false
+	})
+	if insertAt < 0 {
+		return nil, fmt.Errorf("failed to embed metadata: PNG has no IHDR chunk")
+	}
+
+	out := make([]byte, 0, len(encoded)+chunks.Len())
+	out = append(out, encoded[:insertAt]...)
+	out = append(out, chunks.Bytes()...)
+	return append(out, encoded[insertAt:]...), nil
+}
+
+// writePNGChunk writes a length-prefixed PNG chunk with its CRC.
+func writePNGChunk(w *bytes.Buffer, typ string, payload []byte) {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(payload)))
+	copy(header[4:], typ)
+	w.Write(header[:])
+	w.Write(payload)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(payload)
+	binary.Write(w, binary.BigEndian, crc.Sum32())
+}
+
+// webpChunks calls fn for every chunk in a WebP RIFF container.
+func webpChunks(data []byte, fn func(typ string, payload []byte)) {
+	offset := 12
+	for offset+8 <= len(data) {
+		length := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		if length < 0 || offset+8+length > len(data) {
+			return
+		}
+		fn(string(data[offset:offset+4]), data[offset+8:offset+8+length])
+		offset += 8 + length + length%2 // Chunks are padded to an even size
+	}
+}
+
+// extractWebPMetadata reads the EXIF, XMP and ICCP chunks of an extended WebP file.
+func extractWebPMetadata(data []byte) *Metadata {
+	m := &Metadata{}
+	webpChunks(data, func(typ string, payload []byte) {
+		switch typ {
+		case "EXIF":
+			// Some writers keep the JPEG-style prefix in the chunk
+			m.Exif = bytes.TrimPrefix(payload, []byte(jpegExifHeader))
+		case "XMP ":
+			m.XMP = payload
+		case "ICCP":
+			m.ICC = payload
+		}
+	})
+	return m
+}
+
+// embedWebPMetadata rewrites a WebP file into the extended (VP8X) layout with
+// ICCP before the image data and EXIF/XMP after it.
+func embedWebPMetadata(encoded []byte, m *Metadata) ([]byte, error) {
+	var vp8x []byte
+	var imageChunks bytes.Buffer
+	var width, height int
+	var alpha bool
+
+	webpChunks(encoded, func(typ string, payload []byte) {
+		switch typ {
+		case "VP8X":
+			vp8x = payload
+		case "ICCP", "EXIF", "XMP ":
+			// Replaced below
+		default:
+			if typ == "VP8L" && len(payload) >= 5 && payload[0] == 0x2F {
+				bits := binary.LittleEndian.Uint32(payload[1:5])
+				width = int(bits&0x3FFF) + 1
+				height = int((bits>>14)&0x3FFF) + 1
+				// The alpha flag is left unset: the lossless bitstream
+				// carries its own, and x/image/webp rejects VP8L images
+				// with the flag
+			}
+			if typ == "VP8 " && len(payload) >= 10 {
+				width = int(binary.LittleEndian.Uint16(payload[6:8]) & 0x3FFF)
+				height = int(binary.LittleEndian.Uint16(payload[8:10]) & 0x3FFF)
+			}
+			if typ == "ALPH" {
+				alpha = true
+			}
+			writeWebPChunk(&imageChunks, typ, payload)
+		}
+	})
+
+	header := make([]byte, 10)
+	if len(vp8x) >= 10 {
+		copy(header, vp8x[:10])
+	} else {
+		if width == 0 || height == 0 {
+			return nil, fmt.Errorf("failed to embed metadata: unrecognised WebP bitstream")
+		}
+		putUint24(header[4:7], uint32(width-1))
+		putUint24(header[7:10], uint32(height-1))
+	}
+
+	const (
+		flagICC   = 0x20
+		flagAlpha = 0x10
+		flagEXIF  = 0x08
+		flagXMP   = 0x04
+	)
+	header[0] &^= flagICC | flagEXIF | flagXMP
+	if alpha {
+		header[0] |= flagAlpha
+	}
+
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+	if len(m.ICC) > 0 {
+		header[0] |= flagICC
+	}
+	if len(m.Exif) > 0 {
+		header[0] |= flagEXIF
+	}
+	if len(m.XMP) > 0 {
+		header[0] |= flagXMP
+	}
+	writeWebPChunk(&body, "VP8X", header)
+	if len(m.ICC) > 0 {
+		writeWebPChunk(&body, "ICCP", m.ICC)
+	}
+	body.Write(imageChunks.Bytes())
+	if len(m.Exif) > 0 {
+		writeWebPChunk(&body, "EXIF", m.Exif)
+	}
+	if len(m.XMP) > 0 {
+		writeWebPChunk(&body, "XMP ", m.XMP)
+	}
+
+	out := make([]byte, 8, 8+body.Len())
+	copy(out, "RIFF")
+	binary.LittleEndian.PutUint32(out[4:8], uint32(body.Len()))
+	return append(out, body.Bytes()...), nil
+}
+
+// writeWebPChunk writes a RIFF chunk, padding odd-sized payloads.
+func writeWebPChunk(w *bytes.Buffer, typ string, payload []byte) {
+	var header [8]byte
+	copy(header[:4], typ)
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(payload)))
+	w.Write(header[:])
+	w.Write(payload)
+	if len(payload)%2 == 1 {
+		w.WriteByte(0)
+	}
+}
+
+func putUint24(b []byte, v uint32) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+}
+
+// zlibDecompress inflates a zlib stream of at most maxInflatedSize bytes,
+// so that a small chunk cannot expand into an arbitrary amount of memory.
+func zlibDecompress(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, maxInflatedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > maxInflatedSize {
+		return nil, fmt.Errorf("compressed metadata exceeds %d bytes", maxInflatedSize)
+	}
+	return out, nil
+}
@@ -0,0 +1,212 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// gpsLatitude is the value of the GPS latitude in taggedExif, distinctive
+// enough to be searched for in the output bytes.
+var gpsLatitude = []byte{0x12, 0x34, 0x56, 0x78, 0x9A, 0xBC, 0xDE, 0xF0}
+
+// taggedExif returns a big-endian TIFF structure with a camera make, the
+// given orientation, an artist, a copyright and a GPS IFD holding a
+// latitude.
+func taggedExif(orientation uint16) []byte {
+	be := binary.BigEndian
+	type entry struct {
+		tag, typ uint16
+		count    uint32
+		value    []byte // Inline if at most 4 bytes
+	}
+	ifd0 := []entry{
+		{0x010F, exifTypeASCII, 7, []byte("Camera\x00")},
+		{exifTagOrientation, 3, 1, []byte{byte(orientation >> 8), byte(orientation), 0, 0}},
+		{exifTagArtist, exifTypeASCII, 4, []byte("Ann\x00")},
+		{exifTagCopyright, exifTypeASCII, 16, []byte("(c) Ann Example\x00")},
+		{exifTagGPSInfo, 4, 1, nil}, // Offset of the GPS IFD, set below
+	}
+	gps := []entry{
+		{0x0001, exifTypeASCII, 2, []byte("N\x00\x00\x00")},
+		{0x0002, 5, 1, gpsLatitude},
+	}
+
+	out := []byte("MM\x00\x2A\x00\x00\x00\x08")
+	var values []byte
+	writeIFD := func(entries []entry, valuesAt int) []byte {
+		ifd := make([]byte, 2+12*len(entries)+4)
+		be.PutUint16(ifd, uint16(len(entries)))
+		for i, e := range entries {
+			b := ifd[2+12*i:]
+			be.PutUint16(b, e.tag)
+			be.PutUint16(b[2:], e.typ)
+			be.PutUint32(b[4:], e.count)
+			if len(e.value) <= 4 {
+				copy(b[8:], e.value)
+				continue
+			}
+			be.PutUint32(b[8:], uint32(valuesAt+len(values)))
+			values = append(values, e.value...)
+		}
+		return ifd
+	}
+
+	ifd0Size := 2 + 12*len(ifd0) + 4
+	gpsOffset := 8 + ifd0Size
+	gpsSize := 2 + 12*len(gps) + 4
+	ifd0[4].value = be.AppendUint32(nil, uint32(gpsOffset))
+
+	valuesAt := gpsOffset + gpsSize
+	out = append(out, writeIFD(ifd0, valuesAt)...)
+	out = append(out, writeIFD(gps, valuesAt)...)
+	return append(out, values...)
+}
+
+// ifd0Tags returns the values of the IFD0 tags of a TIFF structure.
+func ifd0Tags(t *testing.T, tiff []byte) map[uint16][]byte {
+	t.Helper()
+	order, ok := exifByteOrder(tiff)
+	if !ok {
+		t.Fatalf("invalid TIFF header in %x", tiff)
+	}
+	tags := map[uint16][]byte{}
+	for _, e := range exifIFDEntries(tiff, order, int(order.Uint32(tiff[4:8]))) {
+		tags[e.tag] = exifValue(tiff, order, e)
+	}
+	return tags
+}
+
+func sampleMetadata() *Metadata {
+	p3, _ := LookupProfile(ProfileDisplayP3)
+	return &Metadata{
+		Exif: taggedExif(6),
+		XMP:  []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF/></x:xmpmeta>`),
+		ICC:  p3.Bytes(),
+	}
+}
+
+func TestMetadataFilter(t *testing.T) {
+	m := sampleMetadata()
+
+	t.Run("keep", func(t *testing.T) {
+		kept := m.Filter(MetadataKeep)
+		if !bytes.Equal(kept.Exif, m.Exif) || !bytes.Equal(kept.XMP, m.XMP) || !bytes.Equal(kept.ICC, m.ICC) {
+			t.Error("keep changed the metadata")
+		}
+	})
+
+	t.Run("strip", func(t *testing.T) {
+		for _, policy := range []string{"", MetadataStrip} {
+			if stripped := m.Filter(policy); !stripped.IsEmpty() {
+				t.Errorf("policy %q kept %+v", policy, stripped)
+			}
+		}
+	})
+
+	t.Run("strip_gps", func(t *testing.T) {
+		filtered := m.Filter(MetadataStripGPS)
+		tags := ifd0Tags(t, filtered.Exif)
+		if _, ok := tags[exifTagGPSInfo]; ok {
+			t.Error("GPSInfo pointer kept")
+		}
+		if bytes.Contains(filtered.Exif, gpsLatitude) {
+			t.Error("GPS latitude still in the EXIF bytes")
+		}
+		if string(tags[0x010F]) != "Camera\x00" || exifOrientation(filtered.Exif) != 6 || string(tags[exifTagCopyright]) != "(c) Ann Example\x00" {
+			t.Errorf("other tags lost: %q", tags)
+		}
+		if !bytes.Equal(filtered.XMP, m.XMP) || !bytes.Equal(filtered.ICC, m.ICC) {
+			t.Error("XMP without GPS or ICC profile dropped")
+		}
+
+		withGPS := &Metadata{XMP: []byte(`<exif:GPSLatitude>1,2N</exif:GPSLatitude>`)}
+		if filtered := withGPS.Filter(MetadataStripGPS); filtered.XMP != nil {
+			t.Error("XMP with GPS tags kept")
+		}
+	})
+
+	t.Run("keep_copyright_only", func(t *testing.T) {
+		filtered := m.Filter(MetadataKeepCopyrightOnly)
+		tags := ifd0Tags(t, filtered.Exif)
+		if len(tags) != 2 || string(tags[exifTagArtist]) != "Ann\x00" || string(tags[exifTagCopyright]) != "(c) Ann Example\x00" {
+			t.Errorf("tags %q, want only Artist and Copyright", tags)
+		}
+		if filtered.XMP != nil || !bytes.Equal(filtered.ICC, m.ICC) {
+			t.Error("want the ICC profile without XMP")
+		}
+	})
+}
+
+func TestResetOrientation(t *testing.T) {
+	m := &Metadata{Exif: taggedExif(6)}
+	m.ResetOrientation()
+	if got := exifOrientation(m.Exif); got != 1 {
+		t.Errorf("orientation %d, want 1", got)
+	}
+	if tags := ifd0Tags(t, m.Exif); string(tags[exifTagCopyright]) != "(c) Ann Example\x00" || tags[exifTagGPSInfo] == nil {
+		t.Errorf("other tags changed: %q", tags)
+	}
+
+	// Metadata without EXIF is left alone
+	empty := &Metadata{}
+	empty.ResetOrientation()
+	if !empty.IsEmpty() {
+		t.Error("ResetOrientation added EXIF data")
+	}
+}
+
+func TestEmbedMetadataRoundTrip(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 24, 16))
+	for x := 0; x < 24; x++ {
+		for y := 0; y < 16; y++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x * 10), uint8(y * 15), 90, 255})
+		}
+	}
+
+	// Transparent pixels make the WebP encoder write an alpha channel
+	translucent := image.NewNRGBA(img.Bounds())
+	copy(translucent.Pix, img.Pix)
+	translucent.SetNRGBA(0, 0, color.NRGBA{})
+
+	for _, tc := range []struct {
+		name, format string
+		img          image.Image
+	}{
+		{"jpeg", "jpeg", img},
+		{"png", "png", img},
+		{"webp", "webp", img},
+		{"webp with alpha", "webp", translucent},
+	} {
+		format, img := tc.format, tc.img
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := EncodeImage(img, format, &buf); err != nil {
+				t.Fatal(err)
+			}
+			m := sampleMetadata()
+			m.ResetOrientation()
+			tagged, err := EmbedMetadata(buf.Bytes(), m)
+			if err != nil {
+				t.Fatalf("EmbedMetadata: %v", err)
+			}
+
+			decoded, got, err := image.Decode(bytes.NewReader(tagged))
+			if err != nil {
+				t.Fatalf("decoding the tagged image: %v", err)
+			}
+			if got != format || decoded.Bounds() != img.Bounds() {
+				t.Fatalf("decoded a %s image of %v, want a %s image of %v", got, decoded.Bounds(), format, img.Bounds())
+			}
+			extracted := ExtractMetadata(tagged)
+			if !bytes.Equal(extracted.Exif, m.Exif) || !bytes.Equal(extracted.XMP, m.XMP) || !bytes.Equal(extracted.ICC, m.ICC) {
+				t.Error("metadata read back differs from the embedded metadata")
+			}
+			if GetExifOrientation(tagged) != 1 {
+				t.Errorf("orientation %d, want 1", GetExifOrientation(tagged))
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+const (
+	sampleWidth  = 48
+	sampleHeight = 32
+	markerSize   = 16
+)
+
+// sampleImage returns a white image with a red marker block in the top-left corner.
+func sampleImage() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, sampleWidth, sampleHeight))
+	for y := 0; y < sampleHeight; y++ {
+		for x := 0; x < sampleWidth; x++ {
+			c := color.NRGBA{255, 255, 255, 255}
+			if x < markerSize && y < markerSize {
+				c = color.NRGBA{255, 0, 0, 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// sampleExif returns a big-endian TIFF structure whose IFD0 holds only the orientation tag.
+func sampleExif(orientation int) []byte {
+	b := make([]byte, 8+2+12+4)
+	copy(b, "MM\x00\x2A")
+	binary.BigEndian.PutUint32(b[4:], 8)
+	binary.BigEndian.PutUint16(b[8:], 1)
+	binary.BigEndian.PutUint16(b[10:], exifTagOrientation)
+	binary.BigEndian.PutUint16(b[12:], 3) // SHORT
+	binary.BigEndian.PutUint32(b[14:], 1)
+	binary.BigEndian.PutUint16(b[18:], uint16(orientation))
+	return b
+}
+
+func sampleJPEG(t *testing.T, orientation int) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, sampleImage(), &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := EmbedMetadata(buf.Bytes(), &Metadata{Exif: sampleExif(orientation)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func samplePNG(t *testing.T, orientation int) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, sampleImage()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := EmbedMetadata(buf.Bytes(), &Metadata{Exif: sampleExif(orientation)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// sampleTIFF builds an uncompressed little-endian RGB TIFF with an orientation tag.
+func sampleTIFF(t *testing.T, orientation int) []byte {
+	type entry struct {
+		tag, typ uint16
+		count    uint32
+		value    uint32
+	}
+
+	const numEntries = 10
+	ifdSize := 2 + numEntries*12 + 4
+	bitsOffset := 8 + ifdSize
+	stripOffset := bitsOffset + 6
+	stripSize := sampleWidth * sampleHeight * 3
+
+	entries := []entry{
+		{256, 3, 1, sampleWidth},         // ImageWidth
+		{257, 3, 1, sampleHeight},        // ImageLength
+		{258, 3, 3, uint32(bitsOffset)},  // BitsPerSample
+		{259, 3, 1, 1},                   // Compression: none
+		{262, 3, 1, 2},                   // PhotometricInterpretation: RGB
+		{273, 4, 1, uint32(stripOffset)}, // StripOffsets
+		{274, 3, 1, uint32(orientation)}, // Orientation
+		{277, 3, 1, 3},                   // SamplesPerPixel
+		{278, 3, 1, sampleHeight},        // RowsPerStrip
+		{279, 4, 1, uint32(stripSize)},   // StripByteCounts
+	}
+
+	le := binary.LittleEndian
+	b := make([]byte, stripOffset+stripSize)
+	copy(b, "II\x2A\x00")
+	le.PutUint32(b[4:], 8)
+	le.PutUint16(b[8:], numEntries)
+	for i, e := range entries {
+		p := 10 + i*12
+		le.PutUint16(b[p:], e.tag)
+		le.PutUint16(b[p+2:], e.typ)
+		le.PutUint32(b[p+4:], e.count)
+		if e.typ == 3 && e.count == 1 {
+			le.PutUint16(b[p+8:], uint16(e.value))
+		} else {
+			le.PutUint32(b[p+8:], e.value)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		le.PutUint16(b[bitsOffset+2*i:], 8)
+	}
+
+	img := sampleImage()
+	pix := b[stripOffset:]
+	for i := 0; i < sampleWidth*sampleHeight; i++ {
+		copy(pix[i*3:i*3+3], img.Pix[i*4:i*4+3])
+	}
+	return b
+}
+
+// sampleWebP builds an extended WebP container around a lossless encoding of
+// the sample image, with an EXIF chunk. The JPEG-style "Exif\0\0" prefix used
+// by some writers is added for even orientations.
+func sampleWebP(t *testing.T, orientation int) []byte {
+	var buf bytes.Buffer
+	if err := EncodeImage(sampleImage(), "webp", &buf); err != nil {
+		t.Fatal(err)
+	}
+	var vp8l []byte
+	webpChunks(buf.Bytes(), func(typ string, payload []byte) {
+		if typ == "VP8L" {
+			vp8l = payload
+		}
+	})
+	if vp8l == nil {
+		t.Fatal("no VP8L chunk in the encoded sample")
+	}
+
+	riffChunk := func(typ string, payload []byte) []byte {
+		c := append([]byte(typ), binary.LittleEndian.AppendUint32(nil, uint32(len(payload)))...)
+		c = append(c, payload...)
+		if len(payload)%2 == 1 {
+			c = append(c, 0)
+		}
+		return c
+	}
+
+	vp8x := make([]byte, 10)
+	vp8x[0] = 0x08 // EXIF present
+	putUint24(vp8x[4:], sampleWidth-1)
+	putUint24(vp8x[7:], sampleHeight-1)
+
+	exif := sampleExif(orientation)
+	if orientation%2 == 0 {
+		exif = append([]byte(jpegExifHeader), exif...)
+	}
+
+	body := []byte("WEBP")
+	body = append(body, riffChunk("VP8X", vp8x)...)
+	body = append(body, riffChunk("VP8L", vp8l)...)
+	body = append(body, riffChunk("EXIF", exif)...)
+	return append(append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...), body...)
+}
+
+// sampleHEIC builds a HEIF container whose meta box points at an Exif item in
+// mdat. It holds no coded image: there is no HEVC decoder to read one back,
+// so only the orientation tag of HEIC input is checked.
+func sampleHEIC(t *testing.T, orientation int) []byte {
+	box := func(typ string, payload ...[]byte) []byte {
+		body := bytes.Join(payload, nil)
+		return append(binary.BigEndian.AppendUint32([]byte(nil), uint32(8+len(body))), append([]byte(typ), body...)...)
+	}
+	fullBox := func(typ string, version byte, payload ...[]byte) []byte {
+		return box(typ, append([]byte{version, 0, 0, 0}, bytes.Join(payload, nil)...))
+	}
+	u16 := func(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+	u32 := func(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+
+	ftyp := box("ftyp", []byte("heic"), u32(0), []byte("mif1heic"))
+	hdlr := fullBox("hdlr", 0, u32(0), []byte("pict"), make([]byte, 12), []byte{0})
+	iinf := fullBox("iinf", 0, u16(2),
+		fullBox("infe", 2, u16(1), u16(0), []byte("hvc1"), []byte{0}),
+		fullBox("infe", 2, u16(2), u16(0), []byte("Exif"), []byte{0}),
+	)
+
+	// The Exif item: offset to the TIFF header, then the APP1-style prefix and TIFF data.
+	item := append(u32(6), append([]byte(jpegExifHeader), sampleExif(orientation)...)...)
+
+	// iloc v0 with 4-byte offsets and lengths; the offset is patched once the layout is known.
+	iloc := fullBox("iloc", 0, []byte{0x44, 0x00}, u16(1), u16(2), u16(0), u16(1), u32(0), u32(uint32(len(item))))
+	meta := fullBox("meta", 0, hdlr, iinf, iloc)
+
+	data := append(ftyp, meta...)
+	itemOffset := len(data) + 8
+	data = append(data, box("mdat", item)...)
+
+	ilocAt := bytes.Index(data, []byte("iloc"))
+	binary.BigEndian.PutUint32(data[ilocAt+4+4+2+2+2+2+2:], uint32(itemOffset))
+	return data
+}
+
+// expectedMarker returns the dimensions of the upright image and the center of
+// the red marker block after FixOrientation has been applied.
+func expectedMarker(orientation int) (width, height, x, y int) {
+	w, h, m := sampleWidth, sampleHeight, markerSize
+	switch orientation {
+	case 2:
+		return w, h, w - m/2, m / 2
+	case 3:
+		return w, h, w - m/2, h - m/2
+	case 4:
+		return w, h, m / 2, h - m/2
+	case 5:
+		return h, w, m / 2, m / 2
+	case 6:
+		return h, w, h - m/2, m / 2
+	case 7:
+		return h, w, h - m/2, w - m/2
+	case 8:
+		return h, w, m / 2, w - m/2
+	default:
+		return w, h, m / 2, m / 2
+	}
+}
+
+func TestGetExifOrientation(t *testing.T) {
+	containers := []struct {
+		name   string
+		build  func(*testing.T, int) []byte
+		decode bool // Whether the standard decoders can read the sample
+	}{
+		{"jpeg", sampleJPEG, true},
+		{"png", samplePNG, true},
+		{"tiff", sampleTIFF, true},
+		{"webp", sampleWebP, true},
+		{"heic", sampleHEIC, false},
+	}
+
+	for _, c := range containers {
+		for orientation := 1; orientation <= 8; orientation++ {
+			t.Run(fmt.Sprintf("%s/%d", c.name, orientation), func(t *testing.T) {
+				data := c.build(t, orientation)
+
+				if got := GetExifOrientation(data); got != orientation {
+					t.Fatalf("GetExifOrientation() = %d, want %d", got, orientation)
+				}
+				if !c.decode {
+					return
+				}
+
+				img, _, err := image.Decode(bytes.NewReader(data))
+				if err != nil {
+					t.Fatalf("failed to decode sample: %v", err)
+				}
+				upright := FixOrientation(img, orientation)
+
+				width, height, x, y := expectedMarker(orientation)
+				if b := upright.Bounds(); b.Dx() != width || b.Dy() != height {
+					t.Fatalf("upright size = %dx%d, want %dx%d", b.Dx(), b.Dy(), width, height)
+				}
+				r, g, b, _ := upright.At(x, y).RGBA()
+				if r>>8 < 200 || g>>8 > 80 || b>>8 > 80 {
+					t.Fatalf("pixel at (%d,%d) = (%d,%d,%d), want the red marker", x, y, r>>8, g>>8, b>>8)
+				}
+			})
+		}
+	}
+}
+
+func TestGetExifOrientationWithoutExif(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, sampleImage()); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string][]byte{
+		"png":   buf.Bytes(),
+		"empty": nil,
+		"junk":  []byte("not an image"),
+	} {
+		if got := GetExifOrientation(data); got != 1 {
+			t.Errorf("%s: GetExifOrientation() = %d, want 1", name, got)
+		}
+	}
+}
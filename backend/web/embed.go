@@ -0,0 +1,22 @@
+//go:build embedfrontend
+
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// dist holds the frontend as copied and precompressed by ./gen.
+//
+//go:embed all:dist
+var dist embed.FS
+
+// Files returns the frontend embedded in the binary.
+func Files() fs.FS {
+	files, err := fs.Sub(dist, "dist")
+	if err != nil {
+		panic(err)
+	}
+	return files
+}
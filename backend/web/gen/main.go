@@ -0,0 +1,144 @@
+// Command gen copies the frontend into the directory embedded in the binary, adding
+// gzip and brotli variants of the files that compress well, so that they are
+// compressed once at build time at the highest level rather than on every
+// request.
+//
+// Usage:
+//
+//	go run ./gen -src ../../frontend -dst dist
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"flag"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// compressible are the extensions of text files worth compressing; files
+// without an extension are pages.
+var compressible = map[string]bool{
+	"":      true,
+	".html": true,
+	".css":  true,
+	".js":   true,
+	".json": true,
+	".svg":  true,
+	".txt":  true,
+	".xml":  true,
+}
+
+// skipped are development files of the frontend that are not served.
+var skipped = []string{"*.py", "*.backup"}
+
+// minSize is the size below which compressing is not worth an extra file.
+const minSize = 1024
+
+func main() {
+	src := flag.String("src", "", "frontend directory")
+	dst := flag.String("dst", "", "output directory, replaced if it exists")
+	flag.Parse()
+	if *src == "" || *dst == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := os.RemoveAll(*dst); err != nil {
+		log.Fatal(err)
+	}
+	var files, variants int
+	err := filepath.WalkDir(*src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(*src, p)
+		if err != nil {
+			return err
+		}
+		if rel != "." && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return os.MkdirAll(filepath.Join(*dst, rel), 0o755)
+		}
+		if isSkipped(d.Name()) {
+			return nil
+		}
+
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		out := filepath.Join(*dst, rel)
+		if err := os.WriteFile(out, data, 0o644); err != nil {
+			return err
+		}
+		files++
+		if len(data) < minSize || !compressible[filepath.Ext(p)] {
+			return nil
+		}
+		n, err := writeVariants(out, data)
+		variants += n
+		return err
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("copied %d files to %s with %d precompressed variants", files, *dst, variants)
+}
+
+func isSkipped(name string) bool {
+	for _, pattern := range skipped {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// writeVariants writes the gzip and brotli variants of data next to name,
+// leaving out those that do not save at least a tenth, and returns how many
+// it wrote.
+func writeVariants(name string, data []byte) (int, error) {
+	var gz bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&gz, gzip.BestCompression)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return 0, err
+	}
+	if err := zw.Close(); err != nil {
+		return 0, err
+	}
+
+	var br bytes.Buffer
+	bw := brotli.NewWriterLevel(&br, brotli.BestCompression)
+	if _, err := bw.Write(data); err != nil {
+		return 0, err
+	}
+	if err := bw.Close(); err != nil {
+		return 0, err
+	}
+
+	var n int
+	for ext, compressed := range map[string][]byte{".gz": gz.Bytes(), ".br": br.Bytes()} {
+		if len(compressed) > len(data)*9/10 {
+			continue
+		}
+		if err := os.WriteFile(name+ext, compressed, 0o644); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,11 @@
+//go:build !embedfrontend
+
+package web
+
+import "io/fs"
+
+// Files returns nil: the frontend is only embedded in binaries built with
+// the embedfrontend tag, see `make build-embed`.
+func Files() fs.FS {
+	return nil
+}
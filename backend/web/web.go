@@ -0,0 +1,244 @@
+// Package web serves the static frontend: the site deployed to GitHub Pages
+// from ../frontend, resolved the way GitHub Pages resolves it, with the
+// client-side routes of router.js falling back to the single-page app.
+package web
+
+//go:generate go run ./gen -src ../../frontend -dst dist
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigPath is where the frontend loads its configuration from. The file
+// in the frontend holds the values of the GitHub Pages deployment and is
+// replaced by one holding the values the server is configured with.
+const ConfigPath = "/config.js"
+
+// spaRoutes are the routes of router.js, which serve the single-page app
+// when no file matches them.
+var spaRoutes = map[string]bool{
+	"/":         true,
+	"/compress": true,
+	"/resize":   true,
+	"/about":    true,
+	"/contact":  true,
+	"/privacy":  true,
+	"/terms":    true,
+}
+
+// Cache-Control of pages, which are revalidated on every visit so that a
+// deploy shows at once, and of the assets they load, whose URLs are not
+// fingerprinted.
+const (
+	pageCacheControl  = "no-cache"
+	assetCacheControl = "public, max-age=3600"
+)
+
+// Site serves a frontend held in memory. It is safe for concurrent use.
+type Site struct {
+	assets map[string]*asset // By path, without the leading slash
+}
+
+type asset struct {
+	name         string
+	contentType  string
+	cacheControl string
+	etag         string // Strong ETag of the identity encoding, unquoted
+	body         []byte
+	gzip         []byte // nil without a precompressed variant
+	brotli       []byte // nil without a precompressed variant
+}
+
+// Config holds the values the frontend is configured with.
+type Config struct {
+	APIBase string // URL the frontend sends API requests to; empty for the serving origin
+}
+
+// New loads the frontend in files into memory. A file "x.gz" or "x.br" next
+// to a file "x" is taken as its gzip or brotli variant rather than as a file
+// of its own.
+func New(files fs.FS, cfg Config) (*Site, error) {
+	s := &Site{assets: make(map[string]*asset)}
+	variants := make(map[string][]byte)
+	err := fs.WalkDir(files, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(files, name)
+		if err != nil {
+			return err
+		}
+		if ext := path.Ext(name); ext == ".gz" || ext == ".br" {
+			variants[name] = data
+			return nil
+		}
+		s.assets[name] = newAsset(name, data)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading the frontend: %w", err)
+	}
+	for name, data := range variants {
+		a, ok := s.assets[strings.TrimSuffix(name, path.Ext(name))]
+		if !ok {
+			// A compressed file of its own, served as it is
+			s.assets[name] = newAsset(name, data)
+			continue
+		}
+		if path.Ext(name) == ".gz" {
+			a.gzip = data
+		} else {
+			a.brotli = data
+		}
+	}
+	if _, ok := s.assets["index.html"]; !ok {
+		return nil, fmt.Errorf("loading the frontend: index.html is missing")
+	}
+
+	// Precompressed variants of config.js would hold the frontend's values
+	apiBase, err := json.Marshal(cfg.APIBase)
+	if err != nil {
+		return nil, err
+	}
+	config := fmt.Sprintf("// Generated by the server from its configuration\nwindow.IMAGENERD_API_BASE = %s;\n", apiBase)
+	s.assets[strings.TrimPrefix(ConfigPath, "/")] = newAsset(ConfigPath, []byte(config))
+	return s, nil
+}
+
+func newAsset(name string, data []byte) *asset {
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		// Extensionless pages such as "privacy"
+		contentType = http.DetectContentType(data)
+	}
+	cacheControl := assetCacheControl
+	if strings.HasPrefix(contentType, "text/html") || name == ConfigPath {
+		cacheControl = pageCacheControl
+	}
+	sum := sha256.Sum256(data)
+	return &asset{
+		name:         name,
+		contentType:  contentType,
+		cacheControl: cacheControl,
+		etag:         base64.RawURLEncoding.EncodeToString(sum[:16]),
+		body:         data,
+	}
+}
+
+// ServeHTTP serves the file at the request path, the same path with ".html"
+// appended or the index.html of the directory at that path, in that order.
+// Routes of the single-page app that match no file serve index.html, and
+// every other path 404.html with status 404.
+func (s *Site) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	a, status := s.lookup(r.URL.Path)
+	if a == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, encoding := a.negotiate(r.Header.Get("Accept-Encoding"))
+	h := w.Header()
+	h.Set("Content-Type", a.contentType)
+	h.Set("Cache-Control", a.cacheControl)
+	h.Set("X-Content-Type-Options", "nosniff")
+	if a.gzip != nil || a.brotli != nil {
+		h.Add("Vary", "Accept-Encoding")
+	}
+	if encoding != "" {
+		h.Set("Content-Encoding", encoding)
+		h.Set("ETag", strconv.Quote(a.etag+"-"+encoding))
+	} else {
+		h.Set("ETag", strconv.Quote(a.etag))
+	}
+
+	if status != http.StatusOK {
+		h.Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(status)
+		if r.Method != http.MethodHead {
+			w.Write(body)
+		}
+		return
+	}
+	// ServeContent answers conditional and range requests
+	http.ServeContent(w, r, a.name, time.Time{}, bytes.NewReader(body))
+}
+
+// lookup returns the asset serving urlPath and the status to serve it with,
+// or nil if there is none.
+func (s *Site) lookup(urlPath string) (*asset, int) {
+	clean := path.Clean("/" + urlPath)
+	name := strings.TrimPrefix(clean, "/")
+	if name == "" {
+		name = "index.html"
+	}
+	for _, candidate := range []string{name, name + ".html", path.Join(name, "index.html")} {
+		if a, ok := s.assets[candidate]; ok {
+			return a, http.StatusOK
+		}
+	}
+	if spaRoutes[clean] {
+		return s.assets["index.html"], http.StatusOK
+	}
+	return s.assets["404.html"], http.StatusNotFound
+}
+
+// negotiate returns the variant of a to send to a client accepting the
+// encodings in acceptEncoding, preferring brotli, and its content encoding,
+// or "" for the identity encoding.
+func (a *asset) negotiate(acceptEncoding string) ([]byte, string) {
+	accepted := parseAcceptEncoding(acceptEncoding)
+	if a.brotli != nil && accepted.accepts("br") {
+		return a.brotli, "br"
+	}
+	if a.gzip != nil && accepted.accepts("gzip") {
+		return a.gzip, "gzip"
+	}
+	return a.body, ""
+}
+
+// acceptEncoding maps the codings listed in an Accept-Encoding header to
+// whether they are accepted, which they are unless their quality is 0.
+type acceptEncoding map[string]bool
+
+func parseAcceptEncoding(header string) acceptEncoding {
+	accepted := make(acceptEncoding)
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		accepted[coding] = true
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q == 0 {
+				accepted[coding] = false
+			}
+		}
+	}
+	return accepted
+}
+
+// accepts reports whether coding is accepted, by name or by "*".
+func (a acceptEncoding) accepts(coding string) bool {
+	if ok, listed := a[coding]; listed {
+		return ok
+	}
+	return a["*"]
+}
@@ -0,0 +1,123 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func testSite(t *testing.T) *Site {
+	t.Helper()
+	site, err := New(fstest.MapFS{
+		"index.html":      {Data: []byte("<!DOCTYPE html><title>home</title>")},
+		"404.html":        {Data: []byte("<!DOCTYPE html><title>not found</title>")},
+		"resize.html":     {Data: []byte("<!DOCTYPE html><title>resize</title>")},
+		"privacy":         {Data: []byte("<!DOCTYPE html><title>privacy</title>")},
+		"style.css":       {Data: []byte("body{}")},
+		"style.css.gz":    {Data: []byte("gzip")},
+		"style.css.br":    {Data: []byte("brotli")},
+		"config.js":       {Data: []byte("window.IMAGENERD_API_BASE = 'https://api.imagenerd.in';")},
+		"config.js.gz":    {Data: []byte("stale")},
+		"docs/index.html": {Data: []byte("<!DOCTYPE html><title>docs</title>")},
+	}, Config{APIBase: "https://api.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return site
+}
+
+func get(site *Site, path, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	site.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServeResolvesPaths(t *testing.T) {
+	site := testSite(t)
+	tests := []struct {
+		path   string
+		status int
+		title  string
+	}{
+		{"/", http.StatusOK, "home"},
+		{"/resize", http.StatusOK, "resize"},
+		{"/privacy", http.StatusOK, "privacy"},
+		{"/docs/", http.StatusOK, "docs"},
+		{"/compress", http.StatusOK, "home"}, // Route of router.js
+		{"/../about", http.StatusOK, "home"}, // Cleaned to a route of router.js
+		{"/no-such-page", http.StatusNotFound, "not found"},
+	}
+	for _, tt := range tests {
+		rec := get(site, tt.path, "")
+		if rec.Code != tt.status || !strings.Contains(rec.Body.String(), "<title>"+tt.title+"</title>") {
+			t.Errorf("GET %s = %d %q, want %d with title %q", tt.path, rec.Code, rec.Body, tt.status, tt.title)
+		}
+		if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/html") {
+			t.Errorf("GET %s: Content-Type = %q, want text/html", tt.path, got)
+		}
+		if got := rec.Header().Get("Cache-Control"); got != pageCacheControl {
+			t.Errorf("GET %s: Cache-Control = %q, want %q", tt.path, got, pageCacheControl)
+		}
+	}
+}
+
+func TestServeNegotiatesEncoding(t *testing.T) {
+	site := testSite(t)
+	tests := []struct {
+		acceptEncoding string
+		encoding       string
+		body           string
+	}{
+		{"", "", "body{}"},
+		{"gzip, deflate", "gzip", "gzip"},
+		{"gzip, deflate, br", "br", "brotli"},
+		{"br;q=0, gzip;q=0.5", "gzip", "gzip"},
+		{"*, br;q=0", "gzip", "gzip"},
+		{"identity", "", "body{}"},
+	}
+	etags := make(map[string]bool)
+	for _, tt := range tests {
+		rec := get(site, "/style.css", tt.acceptEncoding)
+		if got := rec.Header().Get("Content-Encoding"); got != tt.encoding || rec.Body.String() != tt.body {
+			t.Errorf("Accept-Encoding %q: got %q encoded %q, want %q encoded %q", tt.acceptEncoding, rec.Body, got, tt.body, tt.encoding)
+		}
+		if got := rec.Header().Get("Cache-Control"); got != assetCacheControl {
+			t.Errorf("Cache-Control = %q, want %q", got, assetCacheControl)
+		}
+		if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("Vary = %q, want Accept-Encoding", got)
+		}
+		etags[tt.encoding+" "+rec.Header().Get("ETag")] = true
+	}
+	if len(etags) != 3 {
+		t.Errorf("want one ETag per encoding, got %v", etags)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/style.css", nil)
+	req.Header.Set("Accept-Encoding", "br")
+	req.Header.Set("If-None-Match", get(site, "/style.css", "br").Header().Get("ETag"))
+	rec := httptest.NewRecorder()
+	site.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("conditional GET = %d, want %d", rec.Code, http.StatusNotModified)
+	}
+}
+
+func TestServeConfig(t *testing.T) {
+	rec := get(testSite(t), ConfigPath, "gzip, br")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("GET %s = %d encoded %q, want the generated file unencoded", ConfigPath, rec.Code, rec.Header().Get("Content-Encoding"))
+	}
+	if want := `window.IMAGENERD_API_BASE = "https://api.example.com";`; !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("config.js = %q, want it to contain %q", rec.Body, want)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != pageCacheControl {
+		t.Errorf("Cache-Control = %q, want %q", got, pageCacheControl)
+	}
+}
@@ -0,0 +1,267 @@
+// Package webhook delivers signed JSON notifications over HTTP, retrying
+// failed deliveries with exponential backoff.
+//
+// Every delivery carries an X-Webhook-Signature header of the form
+// "t=<unix time>,v1=<signature>", where the signature is the hex-encoded
+// HMAC-SHA256, keyed with the receiver's secret, of the time, a period and
+// the request body. Receivers check it with Verify, or its equivalent, and
+// reject old timestamps to stop replays.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Headers of a delivery.
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	IDHeader        = "X-Webhook-ID"      // ID of the notification, the same on every attempt
+	AttemptHeader   = "X-Webhook-Attempt" // Number of the attempt, from 1
+)
+
+// ErrPrivateAddress is returned for URLs resolving to loopback, private or
+// link-local addresses, unless the sender allows them.
+var ErrPrivateAddress = errors.New("webhook: address is not public")
+
+// Policy bounds the attempts at delivering a notification.
+type Policy struct {
+	MaxAttempts    int           // Attempts in total, at least 1
+	InitialBackoff time.Duration // Wait before the second attempt; doubled for each one after
+	MaxBackoff     time.Duration // Longest wait between attempts
+	Timeout        time.Duration // Time limit of each attempt; 10s if not set
+
+	// AllowInsecure allows http:// URLs and private addresses, to deliver
+	// to receivers on the local network while testing
+	AllowInsecure bool
+}
+
+// Attempt is the outcome of one attempt at delivering a notification.
+type Attempt struct {
+	Number     int
+	Time       time.Time
+	Duration   time.Duration
+	StatusCode int       // 0 when no response was received
+	Err        error     // nil for a 2xx response
+	NextTry    time.Time // When the next attempt is due; zero after the last one
+}
+
+// Sender is safe for concurrent use.
+type Sender struct {
+	policy Policy
+	client *http.Client
+
+	now   func() time.Time
+	sleep func(context.Context, time.Duration) error
+}
+
+// NewSender returns a sender delivering under policy.
+func NewSender(policy Policy) *Sender {
+	policy.MaxAttempts = max(policy.MaxAttempts, 1)
+	if policy.Timeout <= 0 {
+		policy.Timeout = 10 * time.Second
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !policy.AllowInsecure {
+		// Checked on the address actually dialed, so that a name cannot
+		// resolve to a public address when validated and a private one later
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublic(ip) {
+				return ErrPrivateAddress
+			}
+			return nil
+		}
+	}
+	return &Sender{
+		policy: policy,
+		client: &http.Client{
+			Transport: &http.Transport{
+				// No proxy from the environment: it would dial on the sender's behalf
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: 10 * time.Second,
+				MaxIdleConnsPerHost: 2,
+				IdleConnTimeout:     90 * time.Second,
+			},
+			// A redirect is a failed delivery: following it would send the
+			// notification somewhere the URL's owner did not name
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		now:   time.Now,
+		sleep: sleep,
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CheckURL returns an error if notifications cannot be delivered to rawURL
+// under the sender's policy. Addresses are only checked when delivering.
+func (s *Sender) CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	switch {
+	case u.Scheme != "https" && !(u.Scheme == "http" && s.policy.AllowInsecure):
+		return errors.New("webhook: URL must use https")
+	case u.Hostname() == "":
+		return errors.New("webhook: URL has no host")
+	case u.User != nil:
+		return errors.New("webhook: URL must not hold credentials")
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !isPublic(ip) && !s.policy.AllowInsecure {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+func isPublic(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// Send delivers payload to rawURL, signed with secret, until a 2xx response,
+// a response that retrying will not change, the last attempt or until ctx
+// is done. record, if not nil, is called after every attempt. Send returns
+// the error of the last attempt.
+func (s *Sender) Send(ctx context.Context, rawURL string, secret []byte, id string, payload []byte, record func(Attempt)) error {
+	for n := 1; ; n++ {
+		a, retry := s.attempt(ctx, rawURL, secret, id, n, payload)
+		var wait time.Duration
+		if retry && n < s.policy.MaxAttempts && ctx.Err() == nil {
+			wait = s.backoff(n)
+			a.NextTry = a.Time.Add(a.Duration + wait)
+		}
+		if record != nil {
+			record(a)
+		}
+		if a.NextTry.IsZero() {
+			return a.Err
+		}
+		if err := s.sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// attempt makes one delivery attempt and reports whether a failure may be
+// retried.
+func (s *Sender) attempt(ctx context.Context, rawURL string, secret []byte, id string, n int, payload []byte) (a Attempt, retry bool) {
+	a = Attempt{Number: n, Time: s.now()}
+	ctx, cancel := context.WithTimeout(ctx, s.policy.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(payload))
+	if err != nil {
+		a.Err = err
+		return a, false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "image-editor-webhook/1")
+	req.Header.Set(IDHeader, id)
+	req.Header.Set(AttemptHeader, strconv.Itoa(n))
+	req.Header.Set(SignatureHeader, Sign(secret, a.Time, payload))
+
+	resp, err := s.client.Do(req)
+	a.Duration = s.now().Sub(a.Time)
+	if err != nil {
+		a.Err = err
+		return a, !errors.Is(err, ErrPrivateAddress)
+	}
+	// Only the status matters; a little of the body is read so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+	resp.Body.Close()
+
+	a.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return a, false
+	}
+	a.Err = fmt.Errorf("webhook: receiver responded %s", resp.Status)
+	switch {
+	case resp.StatusCode >= 500, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusRequestTimeout:
+		return a, true
+	default:
+		return a, false
+	}
+}
+
+// backoff returns the wait after attempt n: the initial backoff doubled for
+// every attempt since the first, within a fifth either way so that retries
+// of many notifications spread out, and at most the maximum backoff.
+func (s *Sender) backoff(n int) time.Duration {
+	d := s.policy.InitialBackoff
+	for i := 1; i < n && d < s.policy.MaxBackoff; i++ {
+		d *= 2
+	}
+	d = time.Duration(float64(d) * (0.8 + 0.4*rand.Float64()))
+	return min(d, s.policy.MaxBackoff)
+}
+
+// Sign returns the signature header of body sent at t.
+func Sign(secret []byte, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + signature(secret, ts, body)
+}
+
+func signature(secret []byte, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature header of body against secret, rejecting
+// signatures made more than tolerance away from now.
+func Verify(secret []byte, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var ts string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return errors.New("webhook: malformed signature header")
+	}
+	if d := now.Sub(time.Unix(unix, 0)); d > tolerance || d < -tolerance {
+		return errors.New("webhook: signature timestamp is outside the tolerance")
+	}
+	want := signature(secret, ts, body)
+	for _, sig := range signatures {
+		if hmac.Equal([]byte(sig), []byte(want)) {
+			return nil
+		}
+	}
+	return errors.New("webhook: signature does not match")
+}
@@ -0,0 +1,3 @@
+// Configuration of the GitHub Pages deployment. The backend serves its own
+// version of this file when it serves the frontend.
+window.IMAGENERD_API_BASE = 'https://api.imagenerd.in';